@@ -0,0 +1,247 @@
+// fakecrt is a stand-in for Vizir REST API for offline development. It serves
+// camera events, match details and person cards the CRT client polls:
+//
+//	<url>CameraEvent/GetItems          new face cards on cameras
+//	<url>MatchDetailMessage/GetItems   match of face card to person face card
+//	<url>PersonCard/GetItems           person card (Information = person ID, _fio)
+//
+// Events are played from script file or added by /fake/event. CRT client skips
+// events of its first poll (baseline) and events within 1 s after newest polled
+// one, so events should be at least 1 s apart.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scriptEvent is a scripted camera event: person seen by camera "after" seconds
+// from start (or from previous loop)
+type scriptEvent struct {
+	After  float64 `json:"after"`
+	Camera string  `json:"camera"`
+	PID    string  `json:"pid"`
+	FIO    string  `json:"fio"`
+	Score  float64 `json:"score"`
+}
+
+// cameraEvent is a face card created by camera
+type cameraEvent struct {
+	faceCardID   int
+	camera       string
+	personCardID int
+	score        float64
+	created      time.Time
+}
+
+// person is a person card of Vizir database
+type person struct {
+	faceCardID int
+	pid        string
+	fio        string
+}
+
+// vizir holds fake Vizir database
+type vizir struct {
+	events     []*cameraEvent
+	persons    map[string]*person // pid -> person
+	byFaceCard map[int]*person    // person face card ID -> person
+	nextCard   int
+	mutex      sync.RWMutex
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:34015", "Listen address (crt.ip:crt.port of daemon)")
+	prefix := flag.String("url", "/vizir/v1/api/", "API URL prefix (crt.url)")
+	scriptFile := flag.String("script", "", "JSON file with events: [{\"after\":2,\"camera\":\"1\",\"pid\":\"12345\",\"fio\":\"Иванов Иван\",\"score\":0.93}]")
+	loop := flag.Bool("loop", false, "Repeat script")
+	flag.Parse()
+
+	v := &vizir{
+		persons:    make(map[string]*person),
+		byFaceCard: make(map[int]*person),
+		nextCard:   1000,
+	}
+
+	var script []scriptEvent
+	if *scriptFile != "" {
+		data, err := os.ReadFile(*scriptFile)
+		if err != nil {
+			fmt.Printf("Error: failed to read script: %v\n", err)
+			os.Exit(1)
+		}
+		if err := json.Unmarshal(data, &script); err != nil {
+			fmt.Printf("Error: failed to parse script: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(*prefix+"CameraEvent/GetItems", v.handleCameraEvents)
+	mux.HandleFunc(*prefix+"MatchDetailMessage/GetItems", v.handleMatchDetails)
+	mux.HandleFunc(*prefix+"PersonCard/GetItems", v.handlePersonCards)
+
+	// Add event: /fake/event?camera=1&pid=12345&fio=...&score=0.93
+	mux.HandleFunc("/fake/event", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		score, _ := strconv.ParseFloat(q.Get("score"), 64)
+		ev := scriptEvent{Camera: q.Get("camera"), PID: q.Get("pid"), FIO: q.Get("fio"), Score: score}
+		if ev.Camera == "" || ev.PID == "" {
+			http.Error(w, "camera and pid required", http.StatusBadRequest)
+			return
+		}
+		v.addEvent(ev)
+		fmt.Fprintf(w, "ok\n")
+	})
+
+	// Baseline event for first poll of CRT client
+	v.addEvent(scriptEvent{Camera: "0", PID: "0", FIO: "baseline"})
+	if len(script) > 0 {
+		go v.play(script, *loop)
+	}
+
+	fmt.Printf("Fake Vizir listening on %s%s (%d scripted events)\n", *addr, *prefix, len(script))
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// play adds scripted events in time
+func (v *vizir) play(script []scriptEvent, loop bool) {
+	for {
+		for _, ev := range script {
+			time.Sleep(time.Duration(ev.After * float64(time.Second)))
+			v.addEvent(ev)
+		}
+		if !loop {
+			return
+		}
+	}
+}
+
+// addEvent adds face card of person seen by camera
+func (v *vizir) addEvent(ev scriptEvent) {
+	if ev.Score <= 0 || ev.Score > 1 {
+		ev.Score = 0.9
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	p := v.persons[ev.PID]
+	if p == nil {
+		v.nextCard++
+		p = &person{faceCardID: v.nextCard, pid: ev.PID}
+		v.persons[ev.PID] = p
+		v.byFaceCard[p.faceCardID] = p
+	}
+	if ev.FIO != "" {
+		p.fio = ev.FIO
+	}
+
+	v.nextCard++
+	v.events = append(v.events, &cameraEvent{
+		faceCardID:   v.nextCard,
+		camera:       ev.Camera,
+		personCardID: p.faceCardID,
+		score:        ev.Score,
+		created:      time.Now().UTC(),
+	})
+	fmt.Printf("Event: camera=%s, pid=%s, fio=%s, score=%.2f, face card=%d\n", ev.Camera, ev.PID, p.fio, ev.Score, v.nextCard)
+}
+
+// handleCameraEvents serves newest camera events from criteria.matchDateFrom
+func (v *vizir) handleCameraEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	take, _ := strconv.Atoi(q.Get("criteria.take"))
+	if take <= 0 {
+		take = 1
+	}
+	var from time.Time
+	if s := q.Get("criteria.matchDateFrom"); s != "" {
+		from, _ = time.Parse("2006-01-02T15:04:05.9999999Z", s)
+	}
+
+	v.mutex.RLock()
+	var events []*cameraEvent
+	for _, ev := range v.events {
+		if !ev.created.Before(from) {
+			events = append(events, ev)
+		}
+	}
+	v.mutex.RUnlock()
+
+	// Newest first (matchDateSortType=2)
+	sort.Slice(events, func(i, j int) bool { return events[i].created.After(events[j].created) })
+	if len(events) > take {
+		events = events[:take]
+	}
+
+	items := make([]interface{}, 0, len(events))
+	for _, ev := range events {
+		items = append(items, map[string]interface{}{
+			"FaceCardId":  ev.faceCardID,
+			"CameraId":    ev.camera,
+			"DateCreated": ev.created.Format("2006-01-02T15:04:05.0000000Z"),
+		})
+	}
+	writeJSON(w, items)
+}
+
+// handleMatchDetails serves match of camera face card to person face card
+func (v *vizir) handleMatchDetails(w http.ResponseWriter, r *http.Request) {
+	fcid, _ := strconv.Atoi(r.URL.Query().Get("criteria.parentFaceCardId"))
+
+	items := make([]interface{}, 0, 1)
+	v.mutex.RLock()
+	for _, ev := range v.events {
+		if ev.faceCardID == fcid {
+			items = append(items, map[string]interface{}{
+				"ParentFaceCardId": ev.faceCardID,
+				"ChildFaceCardId":  ev.personCardID,
+				"Score":            ev.score,
+			})
+			break
+		}
+	}
+	v.mutex.RUnlock()
+	writeJSON(w, items)
+}
+
+// handlePersonCards serves person card by person face card
+func (v *vizir) handlePersonCards(w http.ResponseWriter, r *http.Request) {
+	fcid, _ := strconv.Atoi(r.URL.Query().Get("criteria.faceCardId"))
+
+	items := make([]interface{}, 0, 1)
+	v.mutex.RLock()
+	if p := v.byFaceCard[fcid]; p != nil {
+		items = append(items, map[string]interface{}{
+			"Information": p.pid,
+			"Header":      strings.TrimSpace(p.fio),
+			"AlternateId": p.pid,
+			"PersonCardPropertyValues": []interface{}{
+				map[string]interface{}{
+					"Value":                 p.fio,
+					"PropertyTemplateEntry": map[string]interface{}{"Name": "_fio"},
+				},
+			},
+		})
+	}
+	v.mutex.RUnlock()
+	writeJSON(w, items)
+}
+
+// writeJSON writes JSON response
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,209 @@
+// fakehelios is a stand-in for Helios face verification WebSocket API for offline
+// development: ws://<addr>/api/cameras/<cam>/verify?person_id=<cid> answers with
+// scripted result of person.
+//
+// Results: YES (correlations, then verified), NO (correlations, then terminated
+// without match), NF (close 4002, no photo), COR (correlations until client closes),
+// FAIL (close 1011).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var results = map[string]bool{"YES": true, "NO": true, "NF": true, "COR": true, "FAIL": true}
+
+// script holds results of persons, changeable at runtime by /fake/result
+type script struct {
+	defaultResult string
+	persons       map[string]string
+	delay         time.Duration
+	mutex         sync.RWMutex
+}
+
+// result returns scripted result of person
+func (s *script) result(personID string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if r, ok := s.persons[personID]; ok {
+		return r
+	}
+	return s.defaultResult
+}
+
+// set sets result of person ("" person sets default result)
+func (s *script) set(personID string, result string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if personID == "" {
+		s.defaultResult = result
+		return
+	}
+	s.persons[personID] = result
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8088", "Listen address (cam_service_ip:cam_service_port of daemon)")
+	result := flag.String("result", "YES", "Default verification result: YES, NO, NF, COR, FAIL")
+	persons := flag.String("persons", "", "Results of persons: <cid>=<result>[,<cid>=<result>...]")
+	delay := flag.Duration("delay", 1500*time.Millisecond, "Time to verification result")
+	flag.Parse()
+
+	sc := &script{
+		defaultResult: strings.ToUpper(*result),
+		persons:       make(map[string]string),
+		delay:         *delay,
+	}
+	if !results[sc.defaultResult] {
+		fmt.Printf("Unknown result %q\n", *result)
+		os.Exit(1)
+	}
+	for _, item := range strings.Split(*persons, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		r := strings.ToUpper(strings.TrimSpace(parts[1]))
+		if !results[r] {
+			fmt.Printf("Unknown result %q of person %s\n", parts[1], parts[0])
+			os.Exit(1)
+		}
+		sc.set(strings.TrimSpace(parts[0]), r)
+	}
+
+	upgrader := websocket.Upgrader{
+		Subprotocols: []string{"verification"},
+		CheckOrigin:  func(r *http.Request) bool { return true },
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/cameras/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 4 || parts[3] != "verify" {
+			http.NotFound(w, r)
+			return
+		}
+		camID := parts[2]
+		personID := r.URL.Query().Get("person_id")
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			fmt.Printf("Upgrade failed: %v\n", err)
+			return
+		}
+		res := sc.result(personID)
+		fmt.Printf("Verify: cam=%s, person=%s -> %s\n", camID, personID, res)
+		go verify(conn, personID, res, sc.delay)
+	})
+
+	// Change results at runtime: /fake/result?person_id=<cid>&result=<result>
+	mux.HandleFunc("/fake/result", func(w http.ResponseWriter, r *http.Request) {
+		res := strings.ToUpper(r.URL.Query().Get("result"))
+		if !results[res] {
+			http.Error(w, "unknown result", http.StatusBadRequest)
+			return
+		}
+		sc.set(r.URL.Query().Get("person_id"), res)
+		fmt.Fprintf(w, "ok\n")
+	})
+
+	fmt.Printf("Fake Helios listening on %s (default %s, delay %s)\n", *addr, sc.defaultResult, sc.delay)
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// verify plays verification of person with result
+func verify(conn *websocket.Conn, personID string, result string, delay time.Duration) {
+	defer conn.Close()
+
+	// Client close ends COR stream
+	closed := make(chan struct{})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+
+	switch result {
+	case "NF":
+		time.Sleep(delay / 3)
+		closeWith(conn, 4002, "person "+personID+" has no photo")
+		return
+	case "FAIL":
+		time.Sleep(delay / 3)
+		closeWith(conn, websocket.CloseInternalServerErr, "camera unavailable")
+		return
+	}
+
+	// Correlation updates while face is being matched
+	steps := 3
+	for i := 1; result == "COR" || i <= steps; i++ {
+		select {
+		case <-closed:
+			return
+		case <-time.After(delay / time.Duration(steps+1)):
+		}
+		corr := 0.3 + 0.5*float64(i)/float64(steps) + rand.Float64()*0.05
+		if result == "NO" {
+			corr = 0.2 + rand.Float64()*0.2
+		}
+		if result == "COR" && corr > 0.8 {
+			corr = 0.8
+		}
+		if err := conn.WriteJSON(correlations(personID, corr)); err != nil {
+			return
+		}
+	}
+
+	time.Sleep(delay / time.Duration(steps+1))
+	answer := map[string]interface{}{
+		"person_id":  personID,
+		"verified":   result == "YES",
+		"terminated": result == "NO",
+	}
+	if err := conn.WriteJSON(answer); err != nil {
+		return
+	}
+
+	// Wait for client autoclose
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+// correlations builds correlation update frame
+func correlations(personID string, corr float64) map[string]interface{} {
+	return map[string]interface{}{
+		"correlations": map[string]interface{}{
+			"face_0": map[string]interface{}{
+				"matches": []interface{}{
+					map[string]interface{}{
+						"person_id":   personID,
+						"correlation": corr,
+					},
+				},
+			},
+		},
+	}
+}
+
+// closeWith sends close frame with code and text
+func closeWith(conn *websocket.Conn, code int, text string) {
+	msg := websocket.FormatCloseMessage(code, text)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	time.Sleep(100 * time.Millisecond)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// client calls daemon REST API
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	stream  *http.Client // without timeout, for /api/events
+}
+
+// newClient creates API client from config
+func newClient(cfg *Config) *client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if strings.HasPrefix(cfg.URL, "https://") {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		switch {
+		case cfg.Fingerprint != "":
+			// Self-signed certificate: trust only certificate with given fingerprint
+			want := strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(cfg.Fingerprint))
+			tlsConfig.InsecureSkipVerify = true
+			tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if len(rawCerts) == 0 {
+					return fmt.Errorf("no server certificate")
+				}
+				sum := sha256.Sum256(rawCerts[0])
+				if got := hex.EncodeToString(sum[:]); got != want {
+					return fmt.Errorf("certificate fingerprint %s does not match configured %s", got, want)
+				}
+				return nil
+			}
+		case cfg.Insecure:
+			tlsConfig.InsecureSkipVerify = true
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &client{
+		baseURL: strings.TrimRight(cfg.URL, "/"),
+		token:   cfg.Token,
+		http:    &http.Client{Transport: transport, Timeout: timeout},
+		stream:  &http.Client{Transport: transport},
+	}
+}
+
+// newRequest builds request with token
+func (c *client) newRequest(ctx context.Context, method, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// get calls GET path, returns response data
+func (c *client) get(path string) (interface{}, error) {
+	return c.call(http.MethodGet, path, nil)
+}
+
+// post calls POST path with JSON body, returns response data
+func (c *client) post(path string, body interface{}) (interface{}, error) {
+	return c.call(http.MethodPost, path, body)
+}
+
+// call executes API request. Responses {"code": 200, "data": ...} are unwrapped
+// to data, {"code": ..., "error": "..."} and HTTP errors become errors
+func (c *client) call(method, path string, body interface{}) (interface{}, error) {
+	req, err := c.newRequest(context.Background(), method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		result = strings.TrimSpace(string(data))
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, apiError(resp.StatusCode, result)
+	}
+	if m, ok := result.(map[string]interface{}); ok {
+		if _, hasCode := m["code"]; hasCode {
+			if e, ok := m["error"]; ok {
+				return nil, fmt.Errorf("%v", e)
+			}
+			if d, ok := m["data"]; ok {
+				return d, nil
+			}
+		}
+	}
+	return result, nil
+}
+
+// httpError is error response of API
+type httpError struct {
+	status int
+	text   string
+}
+
+func (e *httpError) Error() string {
+	switch e.status {
+	case http.StatusUnauthorized:
+		return fmt.Sprintf("HTTP 401: %s (set token in config file or SKDCTL_TOKEN)", e.text)
+	case http.StatusForbidden:
+		return fmt.Sprintf("HTTP 403: %s (token role is not enough)", e.text)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.status, e.text)
+}
+
+// apiError builds error of response with decoded body
+func apiError(status int, result interface{}) error {
+	text := http.StatusText(status)
+	if m, ok := result.(map[string]interface{}); ok {
+		if e, ok := m["error"]; ok {
+			text = fmt.Sprintf("%v", e)
+		}
+	} else if s, ok := result.(string); ok && s != "" {
+		text = s
+	}
+	return &httpError{status: status, text: text}
+}
+
+// event is one server-sent event
+type event struct {
+	ID   string
+	Type string
+	Data string
+}
+
+// events reads /api/events stream until it ends or ctx is done. lastID is sent
+// as Last-Event-ID to resume after reconnect.
+func (c *client) events(ctx context.Context, lastID string, handle func(event)) error {
+	req, err := c.newRequest(ctx, http.MethodGet, "/api/events", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		var result interface{}
+		if json.Unmarshal(data, &result) != nil {
+			result = strings.TrimSpace(string(data))
+		}
+		return apiError(resp.StatusCode, result)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var ev event
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// Blank line ends event
+			if len(data) > 0 {
+				ev.Data = strings.Join(data, "\n")
+				handle(ev)
+			}
+			ev, data = event{}, nil
+		case strings.HasPrefix(line, ":"):
+			// Comment (heartbeat)
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case strings.HasPrefix(line, "id:"):
+			ev.ID = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "event:"):
+			ev.Type = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
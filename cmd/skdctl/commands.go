@@ -0,0 +1,586 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// command is skdctl subcommand
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(c *client, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"sessions", "sessions [<id>]", "активные сессии или подробности сессии", cmdSessions},
+		{"terminals", "terminals [show <key> | add <ip:port>... [id= type= role= reg_query=1] | del <key|ip:port>... | check (<ip:port>... [type=] | -f <file.json>)]",
+			"терминалы: список, подробности, добавление, отключение, синхронизация", cmdTerminals},
+		{"cardlist", "cardlist [global|secondary] | add <list> <uid>[=<message>]... | del <list> <uid>... | sync <file|->",
+			"списки карт (gmclist/mclist)", cmdCardList},
+		{"memreg", "memreg [<storage>] | del <storage> <uid>...", "хранилища MEMREG", cmdMemReg},
+		{"tlogs", "tlogs [-n <count>] [<key>]", "журналы терминалов: ключи или последние записи терминала", cmdTLogs},
+		{"settings", "settings [get <key> | set <key> <value>]", "настройки времени выполнения", cmdSettings},
+		{"halt", "halt [-y]", "остановить СКД", cmdHalt},
+		{"watch", "watch [-raw] [<type>...]", "поток событий /api/events (фильтр по префиксу типа)", cmdWatch},
+		{"whoami", "whoami", "пользователь и роль токена", cmdWhoami},
+		{"config", "config [save]", "показать или сохранить конфигурацию skdctl", cmdConfig},
+	}
+}
+
+// run executes subcommand
+func run(c *client, args []string) error {
+	name := strings.ToLower(args[0])
+	if name == "help" {
+		usage()
+		return nil
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(c, args[1:])
+		}
+	}
+	return fmt.Errorf("unknown command %q, see skdctl help", args[0])
+}
+
+// usageError returns usage of command as error
+func usageError(name string) error {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return fmt.Errorf("usage: skdctl %s", cmd.usage)
+		}
+	}
+	return fmt.Errorf("usage error")
+}
+
+// splitArgs separates key=value arguments from positional ones
+func splitArgs(args []string) ([]string, map[string]string) {
+	var positional []string
+	params := make(map[string]string)
+	for _, arg := range args {
+		if i := strings.IndexByte(arg, '='); i > 0 {
+			params[strings.ToLower(arg[:i])] = arg[i+1:]
+			continue
+		}
+		positional = append(positional, arg)
+	}
+	return positional, params
+}
+
+// readInput reads file, "-" is standard input
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// confirm asks yes/no question
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", question)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes" || answer == "д" || answer == "да"
+}
+
+func cmdSessions(c *client, args []string) error {
+	if len(args) > 0 {
+		data, err := c.get("/api/session/" + url.PathEscape(args[0]))
+		if err != nil {
+			return err
+		}
+		out.print(data)
+		return nil
+	}
+
+	data, err := c.get("/api/sessions")
+	if err != nil {
+		return err
+	}
+	rows := rowsOf(data)
+	sortRows(rows, "req_time")
+	out.table(rows, []column{
+		{"id", "ID", FMT_TEXT},
+		{"key", "TERMINAL", FMT_TEXT},
+		{"uid", "UID", FMT_TEXT},
+		{"stage", "STAGE", FMT_TEXT},
+		{"req_time", "STARTED", FMT_MS},
+	})
+	return nil
+}
+
+func cmdTerminals(c *client, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		data, err := c.get("/api/terminals")
+		if err != nil {
+			return err
+		}
+		rows := rowsOf(data)
+		sortRows(rows, "id")
+		out.table(rows, []column{
+			{"id", "ID", FMT_TEXT},
+			{"ip", "IP", FMT_TEXT},
+			{"port", "PORT", FMT_TEXT},
+			{"type", "TYPE", FMT_TEXT},
+			{"key", "KEY", FMT_TEXT},
+			{"connected", "CONNECTED", FMT_TEXT},
+			{"connection_error", "ERROR", FMT_TEXT},
+		})
+		return nil
+	}
+
+	var data interface{}
+	var err error
+	switch args[0] {
+	case "show":
+		if len(args) != 2 {
+			return usageError("terminals")
+		}
+		data, err = c.get("/api/terminal/" + url.PathEscape(args[1]))
+	case "add":
+		terminals, perr := terminalArgs(args[1:])
+		if perr != nil {
+			return perr
+		}
+		data, err = c.post("/api/terminals/add", terminals)
+	case "del":
+		if len(args) < 2 {
+			return usageError("terminals")
+		}
+		data, err = c.post("/api/terminals/del", args[1:])
+	case "check":
+		var terminals []map[string]interface{}
+		if len(args) == 3 && args[1] == "-f" {
+			raw, rerr := readInput(args[2])
+			if rerr != nil {
+				return rerr
+			}
+			if err := json.Unmarshal(raw, &terminals); err != nil {
+				return fmt.Errorf("%s: %v", args[2], err)
+			}
+		} else if terminals, err = terminalArgs(args[1:]); err != nil {
+			return err
+		}
+		// Empty list would disconnect all terminals
+		if len(terminals) == 0 {
+			return usageError("terminals")
+		}
+		data, err = c.post("/api/terminals/check", terminals)
+	default:
+		return usageError("terminals")
+	}
+	if err != nil {
+		return err
+	}
+	out.print(data)
+	return nil
+}
+
+// terminalArgs builds terminal list of "ip:port" arguments sharing id, type,
+// role and reg_query params
+func terminalArgs(args []string) ([]map[string]interface{}, error) {
+	positional, params := splitArgs(args)
+	if len(positional) == 0 {
+		return nil, usageError("terminals")
+	}
+	terminals := make([]map[string]interface{}, 0, len(positional))
+	for _, addr := range positional {
+		host, portText, err := net.SplitHostPort(addr)
+		port, perr := strconv.Atoi(portText)
+		if err != nil || perr != nil || port <= 0 {
+			return nil, fmt.Errorf("invalid terminal address %q, expected ip:port", addr)
+		}
+		t := map[string]interface{}{"ip": host, "port": port}
+		for _, key := range []string{"id", "type", "role"} {
+			if v, ok := params[key]; ok {
+				t[key] = v
+			}
+		}
+		if v, ok := params["reg_query"]; ok {
+			t["reg_query"], _ = strconv.ParseBool(v)
+		}
+		terminals = append(terminals, t)
+	}
+	return terminals, nil
+}
+
+func cmdCardList(c *client, args []string) error {
+	if len(args) == 0 || args[0] == "global" || args[0] == "secondary" {
+		path := "/api/cardlist"
+		if len(args) > 0 {
+			path += "/" + args[0]
+		}
+		data, err := c.get(path)
+		if err != nil {
+			return err
+		}
+		if out.json {
+			out.printJSON(data)
+			return nil
+		}
+		var rows []map[string]interface{}
+		if len(args) > 0 {
+			list := map[string]string{"global": "gmclist", "secondary": "mclist"}[args[0]]
+			for _, row := range mapRows(data, "uid", "message") {
+				row["list"] = list
+				rows = append(rows, row)
+			}
+		} else {
+			lists, _ := data.(map[string]interface{})
+			for _, list := range []string{"gmclist", "mclist"} {
+				for _, row := range mapRows(lists[list], "uid", "message") {
+					row["list"] = list
+					rows = append(rows, row)
+				}
+			}
+		}
+		out.table(rows, []column{
+			{"list", "LIST", FMT_TEXT},
+			{"uid", "UID", FMT_TEXT},
+			{"message", "MESSAGE", FMT_TEXT},
+		})
+		return nil
+	}
+
+	var data interface{}
+	var err error
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			return usageError("cardlist")
+		}
+		entries := make([]map[string]string, 0, len(args)-2)
+		for _, arg := range args[2:] {
+			uid, message, _ := strings.Cut(arg, "=")
+			entries = append(entries, map[string]string{"uid": uid, "message": message})
+		}
+		data, err = c.post("/api/cardlist/"+url.PathEscape(args[1])+"/add", entries)
+	case "del":
+		if len(args) < 3 {
+			return usageError("cardlist")
+		}
+		data, err = c.post("/api/cardlist/"+url.PathEscape(args[1])+"/del", args[2:])
+	case "sync":
+		if len(args) != 2 {
+			return usageError("cardlist")
+		}
+		entries, rerr := readCardEntries(args[1])
+		if rerr != nil {
+			return rerr
+		}
+		data, err = c.post("/api/cardlist/global/sync", entries)
+	default:
+		return usageError("cardlist")
+	}
+	if err != nil {
+		return err
+	}
+	out.print(data)
+	return nil
+}
+
+// readCardEntries reads card list for sync: JSON [{"uid": "...", "message": "..."}]
+// or text lines "uid[;message]"
+func readCardEntries(path string) ([]map[string]string, error) {
+	raw, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []map[string]string
+	if text := strings.TrimSpace(string(raw)); strings.HasPrefix(text, "[") {
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return entries, nil
+	}
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		uid, message, _ := strings.Cut(line, ";")
+		entries = append(entries, map[string]string{"uid": strings.TrimSpace(uid), "message": strings.TrimSpace(message)})
+	}
+	return entries, nil
+}
+
+func cmdMemReg(c *client, args []string) error {
+	if len(args) > 0 && args[0] == "del" {
+		if len(args) < 3 {
+			return usageError("memreg")
+		}
+		data, err := c.post("/api/memreg/"+url.PathEscape(args[1])+"/del", args[2:])
+		if err != nil {
+			return err
+		}
+		out.print(data)
+		return nil
+	}
+	if len(args) > 1 {
+		return usageError("memreg")
+	}
+
+	path := "/api/memreg"
+	if len(args) == 1 {
+		path += "/" + url.PathEscape(args[0])
+	}
+	data, err := c.get(path)
+	if err != nil {
+		return err
+	}
+	if out.json {
+		out.printJSON(data)
+		return nil
+	}
+	var rows []map[string]interface{}
+	if len(args) == 1 {
+		for _, row := range mapRows(data, "uid", "value") {
+			row["storage"] = args[0]
+			rows = append(rows, row)
+		}
+	} else {
+		for _, storage := range mapRows(data, "storage", "uids") {
+			for _, row := range mapRows(storage["uids"], "uid", "value") {
+				row["storage"] = storage["storage"]
+				rows = append(rows, row)
+			}
+		}
+	}
+	out.table(rows, []column{
+		{"storage", "STORAGE", FMT_TEXT},
+		{"uid", "UID", FMT_TEXT},
+		{"value", "VALUE", FMT_TEXT},
+	})
+	return nil
+}
+
+func cmdTLogs(c *client, args []string) error {
+	fs := flag.NewFlagSet("tlogs", flag.ContinueOnError)
+	count := fs.Int("n", 20, "number of newest entries")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || *count < 1 {
+		return usageError("tlogs")
+	}
+
+	if fs.NArg() == 0 {
+		data, err := c.get("/api/tlogs")
+		if err != nil {
+			return err
+		}
+		if out.json {
+			out.printJSON(data)
+			return nil
+		}
+		out.table(mapRows(data, "key", "count"), []column{
+			{"key", "TERMINAL", FMT_TEXT},
+			{"count", "ENTRIES", FMT_TEXT},
+		})
+		return nil
+	}
+
+	data, err := c.get(fmt.Sprintf("/api/tlogs/%s/page_r/%d/0", url.PathEscape(fs.Arg(0)), *count))
+	if err != nil {
+		return err
+	}
+	out.table(rowsOf(data), []column{
+		{"time", "TIME", FMT_TIME},
+		{"type", "TYPE", FMT_TEXT},
+		{"uid", "UID", FMT_TEXT},
+		{"params", "PARAMS", FMT_TEXT},
+		{"action", "ACTION", FMT_TEXT},
+	})
+	return nil
+}
+
+func cmdSettings(c *client, args []string) error {
+	switch {
+	case len(args) == 0:
+		data, err := c.get("/api/system/settings")
+		if err != nil {
+			return err
+		}
+		if out.json {
+			out.printJSON(data)
+			return nil
+		}
+		out.table(mapRows(data, "key", "value"), []column{
+			{"key", "KEY", FMT_TEXT},
+			{"value", "VALUE", FMT_TEXT},
+		})
+	case args[0] == "get" && len(args) == 2:
+		data, err := c.get("/api/system/settings/" + url.PathEscape(args[1]))
+		if err != nil {
+			return err
+		}
+		out.print(data)
+	case args[0] == "set" && len(args) == 3:
+		if _, err := c.post("/api/system/settings/"+url.PathEscape(args[1]), map[string]interface{}{"value": settingValue(args[2])}); err != nil {
+			return err
+		}
+		data, err := c.get("/api/system/settings/" + url.PathEscape(args[1]))
+		if err != nil {
+			return err
+		}
+		out.print(map[string]interface{}{"key": args[1], "value": data})
+	default:
+		return usageError("settings")
+	}
+	return nil
+}
+
+// settingValue converts text to number, bool or string like the daemon console does
+func settingValue(s string) interface{} {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseBool(s); err == nil {
+		return v
+	}
+	return s
+}
+
+func cmdHalt(c *client, args []string) error {
+	fs := flag.NewFlagSet("halt", flag.ContinueOnError)
+	yes := fs.Bool("y", false, "do not ask for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*yes && !confirm(fmt.Sprintf("Остановить СКД %s?", c.baseURL)) {
+		return errors.New("cancelled")
+	}
+	data, err := c.post("/api/system/halt", nil)
+	if err != nil {
+		return err
+	}
+	out.print(data)
+	return nil
+}
+
+func cmdWatch(c *client, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	raw := fs.Bool("raw", false, "print event data as received")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	types := fs.Args()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	lastID := ""
+	for {
+		err := c.events(ctx, lastID, func(ev event) {
+			if ev.ID != "" {
+				lastID = ev.ID
+			}
+			printEvent(ev, types, *raw)
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		var he *httpError
+		if errors.As(err, &he) {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "watch: %v, reconnecting in 3s\n", err)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(3 * time.Second):
+		}
+	}
+}
+
+// printEvent prints event as "time type key=value..." if its type matches filter
+func printEvent(ev event, types []string, raw bool) {
+	var msg struct {
+		Type      string                 `json:"type"`
+		Timestamp int64                  `json:"timestamp"`
+		Data      map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(ev.Data), &msg); err != nil {
+		msg.Type = ev.Type
+	}
+	if msg.Type == "" {
+		msg.Type = ev.Type
+	}
+	if len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if strings.HasPrefix(msg.Type, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return
+		}
+	}
+	if raw || out.json {
+		fmt.Fprintln(out.w, ev.Data)
+		return
+	}
+
+	ts := time.Now()
+	if msg.Timestamp > 0 {
+		ts = time.Unix(msg.Timestamp, 0)
+	}
+	keys := make([]string, 0, len(msg.Data))
+	for k := range msg.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{ts.Format("15:04:05"), msg.Type}
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, cellText(msg.Data[k], FMT_TEXT)))
+	}
+	fmt.Fprintln(out.w, strings.Join(parts, " "))
+}
+
+func cmdWhoami(c *client, args []string) error {
+	data, err := c.get("/api/auth/me")
+	if err != nil {
+		return err
+	}
+	out.print(data)
+	return nil
+}
+
+func cmdConfig(c *client, args []string) error {
+	switch {
+	case len(args) == 0:
+		shown := *cfg
+		if len(shown.Token) > 8 {
+			shown.Token = shown.Token[:8] + "..."
+		}
+		fmt.Fprintf(out.w, "# %s\n", configPath)
+		out.printJSON(shown)
+	case len(args) == 1 && args[0] == "save":
+		if err := saveConfig(configPath, cfg); err != nil {
+			return err
+		}
+		fmt.Fprintf(out.w, "saved %s\n", configPath)
+	default:
+		return usageError("config")
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Config is skdctl configuration: daemon web address and API token
+type Config struct {
+	URL         string `json:"url"`                   // web interface address, e.g. https://10.0.0.1:8080
+	Token       string `json:"token,omitempty"`       // API token (skd_...), see /api/auth/tokens
+	Insecure    bool   `json:"insecure,omitempty"`    // do not verify HTTPS certificate
+	Fingerprint string `json:"fingerprint,omitempty"` // SHA-256 of daemon certificate (self-signed), hex
+	Timeout     int    `json:"timeout,omitempty"`     // request timeout, seconds
+}
+
+// defaultConfigPath returns $SKDCTL_CONFIG or <user config dir>/skdctl/config.json
+func defaultConfigPath() string {
+	if path := os.Getenv("SKDCTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "skdctl.json"
+	}
+	return filepath.Join(dir, "skdctl", "config.json")
+}
+
+// loadConfig reads config file (missing file is not an error) and applies
+// SKDCTL_URL, SKDCTL_TOKEN, SKDCTL_INSECURE environment variables
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{URL: "http://127.0.0.1:8080", Timeout: 10}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("config %s: %v", path, err)
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	if v := os.Getenv("SKDCTL_URL"); v != "" {
+		cfg.URL = v
+	}
+	if v := os.Getenv("SKDCTL_TOKEN"); v != "" {
+		cfg.Token = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("SKDCTL_INSECURE")); err == nil {
+		cfg.Insecure = v
+	}
+	return cfg, nil
+}
+
+// saveConfig writes config file readable only by owner (it holds token)
+func saveConfig(path string, cfg *Config) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
@@ -0,0 +1,123 @@
+// skdctl is operator client of СКД REST API: sessions, terminals, card lists,
+// MEMREG, terminal logs, settings, shutdown and live event stream.
+//
+//	skdctl [-config <file>] [-url <address>] [-token <token>] [-json] <command> [args]
+//
+// Without command it starts interactive shell on terminal or runs commands
+// read from standard input.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"nd-go/internal/handler"
+)
+
+var (
+	cfg        *Config
+	configPath string
+	stdin      = bufio.NewReader(os.Stdin)
+)
+
+func main() {
+	flag.StringVar(&configPath, "config", defaultConfigPath(), "Config file")
+	addr := flag.String("url", "", "Daemon web address, e.g. https://10.0.0.1:8080")
+	token := flag.String("token", "", "API token")
+	insecure := flag.Bool("insecure", false, "Do not verify HTTPS certificate")
+	fingerprint := flag.String("fingerprint", "", "Trust HTTPS certificate with SHA-256 fingerprint")
+	jsonOut := flag.Bool("json", false, "JSON output")
+	flag.Usage = usage
+	flag.Parse()
+
+	var err error
+	cfg, err = loadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *addr != "" {
+		cfg.URL = *addr
+	}
+	if *token != "" {
+		cfg.Token = *token
+	}
+	if *insecure {
+		cfg.Insecure = true
+	}
+	if *fingerprint != "" {
+		cfg.Fingerprint = *fingerprint
+	}
+	out.json = *jsonOut
+	c := newClient(cfg)
+
+	if flag.NArg() == 0 {
+		info, err := os.Stdin.Stat()
+		interactive := err == nil && info.Mode()&os.ModeCharDevice != 0
+		if !shell(c, interactive) {
+			os.Exit(1)
+		}
+		return
+	}
+	if err := run(c, flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// shell runs commands line by line: interactive shell on terminal or script
+// from standard input. Returns false if any command failed.
+func shell(c *client, interactive bool) bool {
+	if interactive {
+		fmt.Printf("skdctl: %s (help - команды, exit - выход)\n", cfg.URL)
+	}
+	ok := true
+	for {
+		if interactive {
+			fmt.Print("skd> ")
+		}
+		line, err := stdin.ReadString('\n')
+		args := handler.SplitLine(line)
+		if len(args) > 0 && !strings.HasPrefix(args[0], "#") {
+			switch strings.ToLower(args[0]) {
+			case "exit", "quit":
+				return ok
+			}
+			if rerr := run(c, args); rerr != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", rerr)
+				ok = false
+			}
+		}
+		if err == io.EOF {
+			if interactive {
+				fmt.Println()
+			}
+			return ok
+		}
+	}
+}
+
+// usage prints help
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintln(w, "skdctl - управление СКД через REST API")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Использование:")
+	fmt.Fprintln(w, "  skdctl [опции] <команда> [аргументы]")
+	fmt.Fprintln(w, "  skdctl [опции]                 интерактивный режим (или команды построчно со стандартного ввода)")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Команды:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\n      %s\n", cmd.usage, cmd.help)
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Опции:")
+	flag.PrintDefaults()
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "Конфигурация: %s (адрес, токен), переменные окружения SKDCTL_CONFIG, SKDCTL_URL, SKDCTL_TOKEN, SKDCTL_INSECURE.\n", defaultConfigPath())
+	fmt.Fprintln(w, "Сохранить адрес и токен: skdctl -url https://10.0.0.1:8080 -token skd_... config save")
+}
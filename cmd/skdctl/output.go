@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"nd-go/internal/handler"
+)
+
+// Column formats
+const (
+	FMT_TEXT = iota
+	FMT_MS   // unix time in milliseconds
+	FMT_UNIX // unix time in seconds
+	FMT_TIME // RFC3339 time
+)
+
+// column is table column: row key, header and value format
+type column struct {
+	key    string
+	title  string
+	format int
+}
+
+// output prints command results as tables/text or JSON
+type output struct {
+	w    io.Writer
+	json bool
+}
+
+var out = &output{w: os.Stdout}
+
+// print prints value: JSON in JSON mode, else "key: value" text
+func (o *output) print(v interface{}) {
+	if o.json {
+		o.printJSON(v)
+		return
+	}
+	fmt.Fprintln(o.w, handler.FormatResult(v))
+}
+
+// printJSON prints value as indented JSON
+func (o *output) printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintln(o.w, v)
+		return
+	}
+	fmt.Fprintln(o.w, string(data))
+}
+
+// table prints rows as table with given columns (JSON mode prints rows as is)
+func (o *output) table(rows []map[string]interface{}, cols []column) {
+	if o.json {
+		o.printJSON(rows)
+		return
+	}
+	if len(rows) == 0 {
+		fmt.Fprintln(o.w, "(empty)")
+		return
+	}
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	titles := make([]string, len(cols))
+	for i, c := range cols {
+		titles[i] = c.title
+	}
+	fmt.Fprintln(tw, strings.Join(titles, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(cols))
+		for i, c := range cols {
+			cells[i] = cellText(row[c.key], c.format)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	tw.Flush()
+}
+
+// rowsOf converts decoded JSON list to table rows
+func rowsOf(v interface{}) []map[string]interface{} {
+	list, _ := v.([]interface{})
+	rows := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			rows = append(rows, m)
+		}
+	}
+	return rows
+}
+
+// mapRows converts decoded JSON map to rows {keyName: key, valueName: value} sorted by key
+func mapRows(v interface{}, keyName, valueName string) []map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	rows := make([]map[string]interface{}, 0, len(keys))
+	for _, k := range keys {
+		rows = append(rows, map[string]interface{}{keyName: k, valueName: m[k]})
+	}
+	return rows
+}
+
+// sortRows sorts rows by text of key
+func sortRows(rows []map[string]interface{}, key string) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		return cellText(rows[i][key], FMT_TEXT) < cellText(rows[j][key], FMT_TEXT)
+	})
+}
+
+// cellText formats table cell
+func cellText(v interface{}, format int) string {
+	if v == nil {
+		return "-"
+	}
+	switch format {
+	case FMT_MS, FMT_UNIX:
+		n, err := strconv.ParseInt(fmt.Sprintf("%v", v), 10, 64)
+		if err != nil || n <= 0 {
+			return "-"
+		}
+		if format == FMT_MS {
+			return time.UnixMilli(n).Format("2006-01-02 15:04:05")
+		}
+		return time.Unix(n, 0).Format("2006-01-02 15:04:05")
+	case FMT_TIME:
+		if t, err := time.Parse(time.RFC3339Nano, fmt.Sprintf("%v", v)); err == nil {
+			return t.Local().Format("2006-01-02 15:04:05")
+		}
+	}
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return "-"
+		}
+		return val
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+	return fmt.Sprintf("%v", v)
+}
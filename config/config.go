@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"nd-go/pkg/types"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -17,11 +18,26 @@ type ConfigFile struct {
 	Server struct {
 		Addr string `json:"addr"`
 		Port int    `json:"port"`
+		Mode string `json:"mode"` // admin port: auto, text, protopack
 	} `json:"server"`
 	Web struct {
 		Addr    string `json:"addr"`
 		Port    int    `json:"port"`
 		Enabled bool   `json:"enabled"`
+		Auth    struct {
+			Enabled       *bool  `json:"enabled"` // nil (key missing) keeps default (enabled)
+			DBPath        string `json:"db_path"`
+			SessionTTL    int    `json:"session_ttl"`
+			AdminUser     string `json:"admin_user"`
+			AdminPassword string `json:"admin_password"`
+		} `json:"auth"`
+		TLS struct {
+			Enabled      bool   `json:"enabled"`
+			CertFile     string `json:"cert_file"`
+			KeyFile      string `json:"key_file"`
+			SelfSigned   bool   `json:"self_signed"`   // generate self-signed certificate if files are missing
+			RedirectPort int    `json:"redirect_port"` // HTTP port redirecting to HTTPS, 0 - disabled
+		} `json:"tls"`
 	} `json:"web"`
 	HTTPService struct {
 		Active              bool     `json:"active"`
@@ -31,7 +47,9 @@ type ConfigFile struct {
 		TermlistPath        string   `json:"termlist_path"`
 		IdentPath           string   `json:"ident_path"`
 		SolarPath           string   `json:"solar_path"`
+		PINPath             string   `json:"pin_path"`
 		UIDPath             string   `json:"uid_path"`
+		CIDPath             string   `json:"cid_path"`
 		URLFmtSuff          string   `json:"url_fmt_suff"`
 		RequestExtraHeaders []string `json:"request_extra_headers"`
 	} `json:"http_service"`
@@ -39,6 +57,9 @@ type ConfigFile struct {
 		ServiceRequestExpireTime float64 `json:"service_request_expire_time"`
 		SessionExpireTime        float64 `json:"session_expire_time"`
 		TerminalConnectTimeout   float64 `json:"terminal_connect_timeout"`
+		TerminalLockTimeout      float64 `json:"terminal_lock_timeout"`
+		MFAPINTimeout            float64 `json:"mfa_pin_timeout"`
+		MFAFaceTimeout           float64 `json:"mfa_face_timeout"`
 	} `json:"timeouts"`
 	ErrorHandling struct {
 		ServiceAutofixExpired bool    `json:"service_autofix_expired"`
@@ -54,11 +75,27 @@ type ConfigFile struct {
 	JSP struct {
 		ListenerPort       interface{} `json:"listener_port"` // Can be bool or int
 		DevAutoPingEnabled bool        `json:"dev_auto_ping_enabled"`
+		ListenerSecret     string      `json:"listener_secret"` // better set JSP_LISTENER_SECRET env
 	} `json:"jsp"`
+	Pocket struct {
+		ConfigOnConnect bool                              `json:"config_on_connect"`
+		Profiles        map[string]map[string]interface{} `json:"profiles"` // profile name / terminal ID / IP / "default" -> tags
+		Keys            struct {
+			File           string  `json:"file"`
+			MasterKey      string  `json:"master_key"` // better set POCKET_KEYS_MASTER_KEY env
+			Sectors        []int   `json:"sectors"`
+			ConfirmTimeout float64 `json:"confirm_timeout"`
+		} `json:"keys"`
+	} `json:"pocket"`
+	Sphinx struct {
+		EscortCards   []string `json:"escort_cards"`   // UIDs of escort cards
+		EscortTimeout float64  `json:"escort_timeout"` // seconds between visitor and escort cards
+	} `json:"sphinx"`
 	Camera struct {
 		ResultMsgNo   string `json:"result_msg_no"`
 		ResultMsgNf   string `json:"result_msg_nf"`
 		ResultMsgFail string `json:"result_msg_fail"`
+		Provider      string `json:"provider"` // face provider: helios, crt, mock (term string face_provider=<name>)
 	} `json:"camera"`
 	TerminalList struct {
 		CheckTime    float64 `json:"check_time"`
@@ -85,6 +122,7 @@ type ConfigFile struct {
 	PhrasesFixes map[string]string `json:"phrases_fixes"` // message corrections for terminal display
 	Storage struct {
 		SqlitePath string `json:"sqlite_path"` // if set, use SQLite instead of CSV (e.g. "./data/skud.db")
+		AuditPath  string `json:"audit_path"`  // SQLite audit trail of administrative changes
 	} `json:"storage"`
 	Email struct {
 		Enabled    bool     `json:"enabled"`
@@ -97,6 +135,15 @@ type ConfigFile struct {
 		SendTimes  []string `json:"send_times"` // daily send at these times, "HH:MM" (e.g. ["08:00", "20:00"])
 		Subject    string   `json:"subject"`    // subject template, e.g. "СКД отчёт за %s"
 	} `json:"email"`
+	Tracing struct {
+		Enabled     bool     `json:"enabled"`
+		OTLPURL     string   `json:"otlp_url"`     // OTLP/HTTP collector, e.g. "http://otel:4318" (path /v1/traces by default)
+		OTLPHeaders []string `json:"otlp_headers"` // "Key: Value", e.g. "Authorization: Bearer ..."
+		File        string   `json:"file"`         // OTLP/JSON lines file for offline analysis
+		ServiceName string   `json:"service_name"` // service.name of spans
+		IncludeIDs  bool     `json:"include_ids"`  // raw card UIDs and person IDs instead of hashes
+		IDKey       string   `json:"id_key"`       // HMAC key of identifier hashes, better set TRACING_ID_KEY env
+	} `json:"tracing"`
 	CRT struct {
 		Active             bool              `json:"active"`
 		IdentificationMode bool              `json:"identification_mode"`
@@ -117,6 +164,7 @@ type ConfigFile struct {
 		NoKpoPass          bool              `json:"no_kpo_pass"`
 		SeenTimeout        float64           `json:"seen_timeout"`
 		CamLinks           map[string]string `json:"cam_links"`
+		FaceMinScore       float64           `json:"face_min_score"` // cardless entry (term string face_entry=1)
 	} `json:"crt"`
 }
 
@@ -205,10 +253,23 @@ func getDefaultConfig() *types.Config {
 	return &types.Config{
 		ServerAddr: getEnvString("SERVER_ADDR", "0.0.0.0"),
 		ServerPort: getEnvInt("SERVER_PORT", 8999),
+		ServerMode: strings.ToLower(getEnvString("SERVER_MODE", "auto")),
 		WebAddr:    getEnvString("WEB_ADDR", "0.0.0.0"),
 		WebPort:    getEnvInt("WEB_PORT", 8080),
 		WebEnabled: getEnvBool("WEB_ENABLED", true),
 
+		WebAuthEnabled:   getEnvBool("WEB_AUTH_ENABLED", true),
+		WebAuthDBPath:    getEnvString("WEB_AUTH_DB_PATH", "./data/auth.db"),
+		WebSessionTTL:    getEnvInt("WEB_SESSION_TTL", 43200),
+		WebAdminUser:     getEnvString("WEB_ADMIN_USER", "admin"),
+		WebAdminPassword: getEnvString("WEB_ADMIN_PASSWORD", ""),
+
+		WebTLSEnabled:      getEnvBool("WEB_TLS_ENABLED", false),
+		WebTLSCertFile:     getEnvString("WEB_TLS_CERT_FILE", "./data/web.crt"),
+		WebTLSKeyFile:      getEnvString("WEB_TLS_KEY_FILE", "./data/web.key"),
+		WebTLSSelfSigned:   getEnvBool("WEB_TLS_SELF_SIGNED", true),
+		WebTLSRedirectPort: getEnvInt("WEB_TLS_REDIRECT_PORT", 0),
+
 		// HTTP Service (1C integration) - from environment or working defaults
 		HTTPServiceActive:              getEnvBool("HTTP_SERVICE_ACTIVE", true),
 		HTTPServiceIP:                  getEnvString("HTTP_SERVICE_IP", "virt201.worldclass.nnov.ru"),
@@ -217,7 +278,9 @@ func getDefaultConfig() *types.Config {
 		HTTPServiceTermlistPath:        getEnvString("HTTP_SERVICE_TERMLIST_PATH", "/gymdb/hs/ACS/terminals"),
 		HTTPServiceIdentPath:           getEnvString("HTTP_SERVICE_IDENT_PATH", "/gymdb/hs/ACS/checking"),
 		HTTPServiceSolarPath:           getEnvString("HTTP_SERVICE_SOLAR_PATH", "/gymdb/hs/ACS/solarium"),
+		HTTPServicePINPath:             getEnvString("HTTP_SERVICE_PIN_PATH", "/gymdb/hs/ACS/pin"),
 		HTTPServiceUIDPath:             getEnvString("HTTP_SERVICE_UID_PATH", "/gymdb/hs/ACS/uid"),
+		HTTPServiceCIDPath:             getEnvString("HTTP_SERVICE_CID_PATH", "/gymdb/hs/ACS/cid"),
 		HTTPServiceUrlFmtSuff:          getEnvString("HTTP_SERVICE_URL_FMT_SUFF", "wc1c"),
 		HTTPServiceRequestExtraHeaders: httpServiceHeaders,
 
@@ -225,6 +288,9 @@ func getDefaultConfig() *types.Config {
 		ServiceRequestExpireTime: getEnvFloat("SERVICE_REQUEST_EXPIRE_TIME", 5.0),
 		SessionExpireTime:        getEnvFloat("SESSION_EXPIRE_TIME", 300.0),
 		TerminalConnectTimeout:   getEnvFloat("TERMINAL_CONNECT_TIMEOUT", 10.0),
+		TerminalLockTimeout:      getEnvFloat("TERMINAL_LOCK_TIMEOUT", 30.0),
+		MFAPINTimeout:            getEnvFloat("MFA_PIN_TIMEOUT", 15.0),
+		MFAFaceTimeout:           getEnvFloat("MFA_FACE_TIMEOUT", 10.0),
 
 		// Error handling
 		ServiceAutofixExpired: getEnvBool("SERVICE_AUTOFIX_EXPIRED", false),
@@ -240,11 +306,30 @@ func getDefaultConfig() *types.Config {
 		// JSP settings
 		JSPListenerPort:       getEnvBool("JSP_LISTENER_PORT", false),
 		JSPDevAutoPingEnabled: getEnvBool("JSP_DEV_AUTO_PING_ENABLED", true),
+		JSPListenerSecret:     getEnvString("JSP_LISTENER_SECRET", ""),
+
+		// POCKET configuration profiles
+		PocketConfigOnConnect: getEnvBool("POCKET_CONFIG_ON_CONNECT", false),
+		PocketProfiles:        make(map[string]map[string]interface{}),
+
+		// MIFARE sector key rotation
+		PocketKeysFile:          getEnvString("POCKET_KEYS_FILE", "pocket_keys.json"),
+		PocketKeysMasterKey:     getEnvString("POCKET_KEYS_MASTER_KEY", ""),
+		PocketKeySectors:        []int{1},
+		PocketKeyConfirmTimeout: getEnvFloat("POCKET_KEY_CONFIRM_TIMEOUT", 10.0),
+
+		// SPHINX escort access
+		SphinxEscortCards:   make([]string, 0),
+		SphinxEscortTimeout: getEnvFloat("SPHINX_ESCORT_TIMEOUT", 10.0),
 
 		// Camera service messages
 		CamServiceResultMsgNo:   getEnvString("CAM_SERVICE_RESULT_MSG_NO", "Лицо не распознано"),
 		CamServiceResultMsgNf:   getEnvString("CAM_SERVICE_RESULT_MSG_NF", "НЕТ ФОТО !!! Обратитесь в отдел продаж"),
 		CamServiceResultMsgFail: getEnvString("CAM_SERVICE_RESULT_MSG_FAIL", "Ошибка распознавания"),
+		CamServiceProvider:      getEnvString("CAM_SERVICE_PROVIDER", "helios"),
+
+		// CRT cardless entry
+		CRTFaceMinScore: getEnvFloat("CRT_FACE_MIN_SCORE", 0.85),
 
 		// Terminal list checking
 		TermListCheckTime:    getEnvFloat("TERM_LIST_CHECK_TIME", 60.0),
@@ -258,12 +343,19 @@ func getDefaultConfig() *types.Config {
 		LogRotationMaxFiles: getEnvInt("LOG_ROTATION_MAX_FILES", 10),
 		LogRotationMaxDays:  getEnvInt("LOG_ROTATION_MAX_DAYS", 30),
 		StorageSqlitePath:   "",
+		StorageAuditPath:    getEnvString("STORAGE_AUDIT_PATH", "./data/audit.db"),
 		EmailEnabled:        false,
 		EmailHost:           "",
 		EmailPort:           587,
 		EmailFrom:           "",
 		EmailSubject:        "СКД отчёт за %s",
 		EmailSendTimes:      []string{"08:00"},
+		TracingEnabled:      getEnvBool("TRACING_ENABLED", false),
+		TracingOTLPURL:      getEnvString("TRACING_OTLP_URL", ""),
+		TracingFile:         getEnvString("TRACING_FILE", ""),
+		TracingServiceName:  getEnvString("TRACING_SERVICE_NAME", "skd"),
+		TracingIncludeIDs:   getEnvBool("TRACING_INCLUDE_IDS", false),
+		TracingIDKey:        getEnvString("TRACING_ID_KEY", ""),
 		Stats:               map[string]interface{}{"start_time": time.Now()},
 		IDGen:               0,
 		Connections:         make(map[string]*types.Connection),
@@ -292,6 +384,9 @@ func loadConfigFromFile(path string, cfg *types.Config) error {
 	if fileCfg.Server.Port > 0 {
 		cfg.ServerPort = fileCfg.Server.Port
 	}
+	if fileCfg.Server.Mode != "" {
+		cfg.ServerMode = strings.ToLower(fileCfg.Server.Mode)
+	}
 
 	if fileCfg.Web.Addr != "" {
 		cfg.WebAddr = fileCfg.Web.Addr
@@ -300,6 +395,32 @@ func loadConfigFromFile(path string, cfg *types.Config) error {
 		cfg.WebPort = fileCfg.Web.Port
 	}
 	cfg.WebEnabled = fileCfg.Web.Enabled
+	if fileCfg.Web.Auth.Enabled != nil {
+		cfg.WebAuthEnabled = *fileCfg.Web.Auth.Enabled
+	}
+	if fileCfg.Web.Auth.DBPath != "" {
+		cfg.WebAuthDBPath = fileCfg.Web.Auth.DBPath
+	}
+	if fileCfg.Web.Auth.SessionTTL > 0 {
+		cfg.WebSessionTTL = fileCfg.Web.Auth.SessionTTL
+	}
+	if fileCfg.Web.Auth.AdminUser != "" {
+		cfg.WebAdminUser = fileCfg.Web.Auth.AdminUser
+	}
+	if fileCfg.Web.Auth.AdminPassword != "" {
+		cfg.WebAdminPassword = fileCfg.Web.Auth.AdminPassword
+	}
+	cfg.WebTLSEnabled = fileCfg.Web.TLS.Enabled
+	if fileCfg.Web.TLS.CertFile != "" {
+		cfg.WebTLSCertFile = fileCfg.Web.TLS.CertFile
+	}
+	if fileCfg.Web.TLS.KeyFile != "" {
+		cfg.WebTLSKeyFile = fileCfg.Web.TLS.KeyFile
+	}
+	cfg.WebTLSSelfSigned = fileCfg.Web.TLS.SelfSigned
+	if fileCfg.Web.TLS.RedirectPort > 0 {
+		cfg.WebTLSRedirectPort = fileCfg.Web.TLS.RedirectPort
+	}
 
 	// HTTP Service
 	cfg.HTTPServiceActive = fileCfg.HTTPService.Active
@@ -321,9 +442,15 @@ func loadConfigFromFile(path string, cfg *types.Config) error {
 	if fileCfg.HTTPService.SolarPath != "" {
 		cfg.HTTPServiceSolarPath = fileCfg.HTTPService.SolarPath
 	}
+	if fileCfg.HTTPService.PINPath != "" {
+		cfg.HTTPServicePINPath = fileCfg.HTTPService.PINPath
+	}
 	if fileCfg.HTTPService.UIDPath != "" {
 		cfg.HTTPServiceUIDPath = fileCfg.HTTPService.UIDPath
 	}
+	if fileCfg.HTTPService.CIDPath != "" {
+		cfg.HTTPServiceCIDPath = fileCfg.HTTPService.CIDPath
+	}
 	if fileCfg.HTTPService.URLFmtSuff != "" {
 		cfg.HTTPServiceUrlFmtSuff = fileCfg.HTTPService.URLFmtSuff
 	}
@@ -341,6 +468,15 @@ func loadConfigFromFile(path string, cfg *types.Config) error {
 	if fileCfg.Timeouts.TerminalConnectTimeout > 0 {
 		cfg.TerminalConnectTimeout = fileCfg.Timeouts.TerminalConnectTimeout
 	}
+	if fileCfg.Timeouts.TerminalLockTimeout > 0 {
+		cfg.TerminalLockTimeout = fileCfg.Timeouts.TerminalLockTimeout
+	}
+	if fileCfg.Timeouts.MFAPINTimeout > 0 {
+		cfg.MFAPINTimeout = fileCfg.Timeouts.MFAPINTimeout
+	}
+	if fileCfg.Timeouts.MFAFaceTimeout > 0 {
+		cfg.MFAFaceTimeout = fileCfg.Timeouts.MFAFaceTimeout
+	}
 
 	// Error handling
 	cfg.ServiceAutofixExpired = fileCfg.ErrorHandling.ServiceAutofixExpired
@@ -377,6 +513,35 @@ func loadConfigFromFile(path string, cfg *types.Config) error {
 		}
 	}
 	cfg.JSPDevAutoPingEnabled = fileCfg.JSP.DevAutoPingEnabled
+	if fileCfg.JSP.ListenerSecret != "" {
+		cfg.JSPListenerSecret = fileCfg.JSP.ListenerSecret
+	}
+
+	// POCKET configuration profiles
+	cfg.PocketConfigOnConnect = fileCfg.Pocket.ConfigOnConnect
+	if len(fileCfg.Pocket.Profiles) > 0 {
+		cfg.PocketProfiles = fileCfg.Pocket.Profiles
+	}
+	if fileCfg.Pocket.Keys.File != "" {
+		cfg.PocketKeysFile = fileCfg.Pocket.Keys.File
+	}
+	if fileCfg.Pocket.Keys.MasterKey != "" {
+		cfg.PocketKeysMasterKey = fileCfg.Pocket.Keys.MasterKey
+	}
+	if len(fileCfg.Pocket.Keys.Sectors) > 0 {
+		cfg.PocketKeySectors = fileCfg.Pocket.Keys.Sectors
+	}
+	if fileCfg.Pocket.Keys.ConfirmTimeout > 0 {
+		cfg.PocketKeyConfirmTimeout = fileCfg.Pocket.Keys.ConfirmTimeout
+	}
+
+	// SPHINX escort access
+	if len(fileCfg.Sphinx.EscortCards) > 0 {
+		cfg.SphinxEscortCards = fileCfg.Sphinx.EscortCards
+	}
+	if fileCfg.Sphinx.EscortTimeout > 0 {
+		cfg.SphinxEscortTimeout = fileCfg.Sphinx.EscortTimeout
+	}
 
 	// Camera
 	if fileCfg.Camera.ResultMsgNo != "" {
@@ -388,6 +553,9 @@ func loadConfigFromFile(path string, cfg *types.Config) error {
 	if fileCfg.Camera.ResultMsgFail != "" {
 		cfg.CamServiceResultMsgFail = fileCfg.Camera.ResultMsgFail
 	}
+	if fileCfg.Camera.Provider != "" {
+		cfg.CamServiceProvider = fileCfg.Camera.Provider
+	}
 
 	// Terminal list
 	if fileCfg.TerminalList.CheckTime > 0 {
@@ -403,9 +571,25 @@ func loadConfigFromFile(path string, cfg *types.Config) error {
 		cfg.LogFile = fileCfg.Logging.LogFile
 	}
 
-	// Helios - check if fields exist in Config
-	// Note: Helios configuration may be stored differently in Config
-	// For now, skip if fields don't exist
+	// Helios (camera service): url "ws://<ip>:<port>"
+	if fileCfg.Helios.Enabled {
+		cfg.CamServiceActive = true
+	}
+	if fileCfg.Helios.URL != "" {
+		hostPort := fileCfg.Helios.URL
+		if i := strings.Index(hostPort, "://"); i >= 0 {
+			hostPort = hostPort[i+3:]
+		}
+		hostPort = strings.SplitN(hostPort, "/", 2)[0]
+		if host, port, err := net.SplitHostPort(hostPort); err == nil {
+			cfg.CamServiceIP = host
+			if p, err := strconv.Atoi(port); err == nil {
+				cfg.CamServicePort = p
+			}
+		} else {
+			cfg.CamServiceIP = hostPort
+		}
+	}
 
 	// Phrase fixes
 	if len(fileCfg.PhrasesFixes) > 0 {
@@ -458,11 +642,17 @@ func loadConfigFromFile(path string, cfg *types.Config) error {
 	if len(fileCfg.CRT.CamLinks) > 0 {
 		cfg.CRTCamLinks = fileCfg.CRT.CamLinks
 	}
+	if fileCfg.CRT.FaceMinScore > 0 {
+		cfg.CRTFaceMinScore = fileCfg.CRT.FaceMinScore
+	}
 
 	// Storage
 	if fileCfg.Storage.SqlitePath != "" {
 		cfg.StorageSqlitePath = fileCfg.Storage.SqlitePath
 	}
+	if fileCfg.Storage.AuditPath != "" {
+		cfg.StorageAuditPath = fileCfg.Storage.AuditPath
+	}
 
 	// Email
 	cfg.EmailEnabled = fileCfg.Email.Enabled
@@ -487,6 +677,29 @@ func loadConfigFromFile(path string, cfg *types.Config) error {
 		cfg.EmailSubject = fileCfg.Email.Subject
 	}
 
+	// Tracing
+	if fileCfg.Tracing.Enabled {
+		cfg.TracingEnabled = true
+	}
+	if fileCfg.Tracing.OTLPURL != "" {
+		cfg.TracingOTLPURL = fileCfg.Tracing.OTLPURL
+	}
+	if len(fileCfg.Tracing.OTLPHeaders) > 0 {
+		cfg.TracingOTLPHeaders = fileCfg.Tracing.OTLPHeaders
+	}
+	if fileCfg.Tracing.File != "" {
+		cfg.TracingFile = fileCfg.Tracing.File
+	}
+	if fileCfg.Tracing.ServiceName != "" {
+		cfg.TracingServiceName = fileCfg.Tracing.ServiceName
+	}
+	if fileCfg.Tracing.IncludeIDs {
+		cfg.TracingIncludeIDs = true
+	}
+	if fileCfg.Tracing.IDKey != "" {
+		cfg.TracingIDKey = fileCfg.Tracing.IDKey
+	}
+
 	return nil
 }
 
@@ -500,6 +713,8 @@ func applyCmdArgs(cfg *types.Config, cmdArgs map[string]string) {
 			if port, err := strconv.Atoi(value); err == nil {
 				cfg.ServerPort = port
 			}
+		case "server.mode", "server_mode":
+			cfg.ServerMode = strings.ToLower(value)
 		case "web.addr", "web_addr":
 			cfg.WebAddr = value
 		case "web.port", "web_port":
@@ -510,6 +725,14 @@ func applyCmdArgs(cfg *types.Config, cmdArgs map[string]string) {
 			if enabled, err := strconv.ParseBool(value); err == nil {
 				cfg.WebEnabled = enabled
 			}
+		case "web.tls.enabled", "web_tls_enabled":
+			if enabled, err := strconv.ParseBool(value); err == nil {
+				cfg.WebTLSEnabled = enabled
+			}
+		case "web.auth.enabled", "web_auth_enabled":
+			if enabled, err := strconv.ParseBool(value); err == nil {
+				cfg.WebAuthEnabled = enabled
+			}
 		case "http_service.active", "http_service_active":
 			if active, err := strconv.ParseBool(value); err == nil {
 				cfg.HTTPServiceActive = active
@@ -530,6 +753,14 @@ func applyCmdArgs(cfg *types.Config, cmdArgs map[string]string) {
 			}
 		case "log.file", "log_file":
 			cfg.LogFile = value
+		case "tracing.enabled", "tracing_enabled":
+			if enabled, err := strconv.ParseBool(value); err == nil {
+				cfg.TracingEnabled = enabled
+			}
+		case "tracing.otlp_url", "tracing_otlp_url":
+			cfg.TracingOTLPURL = value
+		case "tracing.file", "tracing_file":
+			cfg.TracingFile = value
 		}
 	}
 }
@@ -539,9 +770,21 @@ func SaveConfigExample(path string) error {
 	example := ConfigFile{}
 	example.Server.Addr = "0.0.0.0"
 	example.Server.Port = 8999
+	example.Server.Mode = "auto"
 	example.Web.Addr = "0.0.0.0"
 	example.Web.Port = 8080
 	example.Web.Enabled = true
+	authEnabled := true
+	example.Web.Auth.Enabled = &authEnabled
+	example.Web.Auth.DBPath = "./data/auth.db"
+	example.Web.Auth.SessionTTL = 43200
+	example.Web.Auth.AdminUser = "admin"
+	example.Web.Auth.AdminPassword = ""
+	example.Web.TLS.Enabled = false
+	example.Web.TLS.CertFile = "./data/web.crt"
+	example.Web.TLS.KeyFile = "./data/web.key"
+	example.Web.TLS.SelfSigned = true
+	example.Web.TLS.RedirectPort = 80
 	example.HTTPService.Active = true
 	example.HTTPService.IP = ""
 	example.HTTPService.Port = 80
@@ -549,12 +792,17 @@ func SaveConfigExample(path string) error {
 	example.HTTPService.TermlistPath = ""
 	example.HTTPService.IdentPath = ""
 	example.HTTPService.SolarPath = ""
+	example.HTTPService.PINPath = ""
 	example.HTTPService.UIDPath = ""
+	example.HTTPService.CIDPath = ""
 	example.HTTPService.URLFmtSuff = ""
 	example.HTTPService.RequestExtraHeaders = []string{}
 	example.Timeouts.ServiceRequestExpireTime = 5.0
 	example.Timeouts.SessionExpireTime = 300.0
 	example.Timeouts.TerminalConnectTimeout = 10.0
+	example.Timeouts.TerminalLockTimeout = 30.0
+	example.Timeouts.MFAPINTimeout = 15.0
+	example.Timeouts.MFAFaceTimeout = 10.0
 	example.ErrorHandling.ServiceAutofixExpired = false
 	example.ErrorHandling.ServiceLinkErrMsg = "Ошибка связи. Обратитесь на рецепцию."
 	example.ErrorHandling.HTTPRequestRetryCount = 2
@@ -564,9 +812,24 @@ func SaveConfigExample(path string) error {
 	example.Messages.ServiceDeniedMsg = "Доступ запрещен"
 	example.JSP.ListenerPort = false
 	example.JSP.DevAutoPingEnabled = true
+	example.Pocket.ConfigOnConnect = false
+	example.Pocket.Profiles = map[string]map[string]interface{}{
+		"default": {
+			"lang":           "ru",
+			"wait_tmo":       30,
+			"led_brightness": 80,
+			"buzzer_duty":    50,
+		},
+	}
+	example.Pocket.Keys.File = "pocket_keys.json"
+	example.Pocket.Keys.Sectors = []int{1}
+	example.Pocket.Keys.ConfirmTimeout = 10.0
+	example.Sphinx.EscortCards = []string{}
+	example.Sphinx.EscortTimeout = 10.0
 	example.Camera.ResultMsgNo = "Лицо не распознано"
 	example.Camera.ResultMsgNf = "НЕТ ФОТО !!! Обратитесь в отдел продаж"
 	example.Camera.ResultMsgFail = "Ошибка распознавания"
+	example.Camera.Provider = "helios"
 	example.TerminalList.CheckTime = 60.0
 	example.TerminalList.Filter = `/192\.168\.1\.(10|20|30)/`
 	example.TerminalList.FilterAbsent = false
@@ -604,7 +867,9 @@ func SaveConfigExample(path string) error {
 	example.CRT.NoKpoPass = true
 	example.CRT.SeenTimeout = 10.0
 	example.CRT.CamLinks = map[string]string{}
+	example.CRT.FaceMinScore = 0.85
 	example.Storage.SqlitePath = "./data/skud.db"
+	example.Storage.AuditPath = "./data/audit.db"
 	example.Email.Enabled = false
 	example.Email.Host = "smtp.example.com"
 	example.Email.Port = 587
@@ -614,6 +879,11 @@ func SaveConfigExample(path string) error {
 	example.Email.Recipients = []string{"admin@example.com"}
 	example.Email.SendTimes = []string{"08:00", "20:00"}
 	example.Email.Subject = "СКД отчёт за %s"
+	example.Tracing.Enabled = false
+	example.Tracing.OTLPURL = "http://localhost:4318"
+	example.Tracing.OTLPHeaders = []string{}
+	example.Tracing.File = ""
+	example.Tracing.ServiceName = "skd"
 
 	data, err := json.MarshalIndent(example, "", "  ")
 	if err != nil {
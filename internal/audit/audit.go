@@ -0,0 +1,261 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Sources of changes
+const (
+	SOURCE_WEB = "web" // web UI / REST API
+	SOURCE_TCP = "tcp" // TCP command console
+)
+
+// Entry is one administrative change: who, what, before/after values, where from and when
+type Entry struct {
+	ID     int64           `json:"id"`
+	Time   time.Time       `json:"time"`
+	User   string          `json:"user"`
+	Role   string          `json:"role"`
+	Source string          `json:"source"`
+	IP     string          `json:"ip"`
+	Action string          `json:"action"` // e.g. cardlist.add, settings.set, system.halt
+	Target string          `json:"target"` // e.g. list name, setting key, terminal key
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+	Status int             `json:"status"` // HTTP status of request (200 for TCP success)
+	Detail string          `json:"detail,omitempty"`
+}
+
+// SetBefore stores value before change as JSON
+func (e *Entry) SetBefore(v interface{}) {
+	e.Before = toJSON(v)
+}
+
+// SetAfter stores value after change as JSON
+func (e *Entry) SetAfter(v interface{}) {
+	e.After = toJSON(v)
+}
+
+// Filter selects entries, empty fields match all
+type Filter struct {
+	User   string
+	Action string // prefix: "cardlist" matches cardlist.add, cardlist.del...
+	Target string
+	Source string
+	IP     string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// Store keeps audit entries in SQLite
+type Store struct {
+	dbPath string
+	db     *sql.DB
+	mutex  sync.Mutex
+}
+
+// NewStore creates audit store. Open() must be called before use.
+func NewStore(dbPath string) *Store {
+	if dbPath == "" {
+		dbPath = "./data/audit.db"
+	}
+	return &Store{dbPath: dbPath}
+}
+
+// Open opens or creates the database and initializes table.
+func (s *Store) Open() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.dbPath), 0755); err != nil {
+		return fmt.Errorf("create db dir: %w", err)
+	}
+	db, err := sql.Open("sqlite", s.dbPath)
+	if err != nil {
+		return fmt.Errorf("open sqlite: %w", err)
+	}
+	s.db = db
+
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS audit (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ts DATETIME NOT NULL,
+			user TEXT,
+			role TEXT,
+			source TEXT,
+			ip TEXT,
+			action TEXT NOT NULL,
+			target TEXT,
+			before TEXT,
+			after TEXT,
+			status INTEGER,
+			detail TEXT
+		);
+		CREATE INDEX IF NOT EXISTS idx_audit_ts ON audit(ts);
+		CREATE INDEX IF NOT EXISTS idx_audit_action ON audit(action);
+	`)
+	if err != nil {
+		db.Close()
+		s.db = nil
+		return fmt.Errorf("create audit table: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+// Record saves entry, sets its ID and time (if not set)
+func (s *Store) Record(e *Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.db == nil {
+		return fmt.Errorf("audit store closed")
+	}
+	res, err := s.db.Exec(`
+		INSERT INTO audit (ts, user, role, source, ip, action, target, before, after, status, detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Time.UTC(), e.User, e.Role, e.Source, e.IP, e.Action, e.Target,
+		nullJSON(e.Before), nullJSON(e.After), e.Status, e.Detail)
+	if err != nil {
+		return err
+	}
+	e.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// Query returns entries matching filter, newest first
+func (s *Store) Query(f Filter) ([]Entry, error) {
+	where := []string{"1=1"}
+	var args []interface{}
+	if f.User != "" {
+		where = append(where, "user = ?")
+		args = append(args, f.User)
+	}
+	if f.Action != "" {
+		where = append(where, "(action = ? OR action LIKE ?)")
+		args = append(args, f.Action, f.Action+".%")
+	}
+	if f.Target != "" {
+		where = append(where, "target = ?")
+		args = append(args, f.Target)
+	}
+	if f.Source != "" {
+		where = append(where, "source = ?")
+		args = append(args, f.Source)
+	}
+	if f.IP != "" {
+		where = append(where, "ip = ?")
+		args = append(args, f.IP)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "ts >= ?")
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		where = append(where, "ts < ?")
+		args = append(args, f.To.UTC())
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	args = append(args, limit, f.Offset)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.db == nil {
+		return nil, fmt.Errorf("audit store closed")
+	}
+	rows, err := s.db.Query(`
+		SELECT id, ts, COALESCE(user, ''), COALESCE(role, ''), COALESCE(source, ''), COALESCE(ip, ''),
+			action, COALESCE(target, ''), COALESCE(before, ''), COALESCE(after, ''), COALESCE(status, 0), COALESCE(detail, '')
+		FROM audit WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY id DESC LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		var before, after string
+		if err := rows.Scan(&e.ID, &e.Time, &e.User, &e.Role, &e.Source, &e.IP,
+			&e.Action, &e.Target, &before, &after, &e.Status, &e.Detail); err != nil {
+			return nil, err
+		}
+		if before != "" {
+			e.Before = json.RawMessage(before)
+		}
+		if after != "" {
+			e.After = json.RawMessage(after)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// WriteCSV writes entries as CSV with header
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "time", "user", "role", "source", "ip", "action", "target", "before", "after", "status", "detail"})
+	for _, e := range entries {
+		cw.Write([]string{
+			strconv.FormatInt(e.ID, 10),
+			e.Time.Local().Format("2006-01-02 15:04:05"),
+			e.User, e.Role, e.Source, e.IP, e.Action, e.Target,
+			string(e.Before), string(e.After),
+			strconv.Itoa(e.Status), e.Detail,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// toJSON marshals value, nil stays empty
+func toJSON(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%v", v))
+	}
+	return data
+}
+
+// nullJSON stores empty JSON as NULL
+func nullJSON(v json.RawMessage) interface{} {
+	if len(v) == 0 {
+		return nil
+	}
+	return string(v)
+}
@@ -0,0 +1,448 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	_ "modernc.org/sqlite"
+)
+
+// User roles, each role includes rights of previous ones
+const (
+	ROLE_VIEWER   = "viewer"   // read-only monitoring
+	ROLE_OPERATOR = "operator" // card lists, memreg
+	ROLE_ADMIN    = "admin"    // halt, terminals, settings, keys, users
+)
+
+var roleLevels = map[string]int{
+	ROLE_VIEWER:   1,
+	ROLE_OPERATOR: 2,
+	ROLE_ADMIN:    3,
+}
+
+// Prefix of API tokens, helps to tell tokens from passwords in configs and logs
+const TOKEN_PREFIX = "skd_"
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidRole        = errors.New("invalid role")
+)
+
+// User is a web UI / API user
+type User struct {
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Token is an API token of user (token itself is shown only once on creation)
+type Token struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+}
+
+// ValidRole checks if role is known
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// RoleAllows checks if role has rights of required role
+func RoleAllows(role string, required string) bool {
+	return roleLevels[role] > 0 && roleLevels[role] >= roleLevels[required]
+}
+
+// Store keeps users (bcrypt password hashes) and API tokens (SHA-256 hashes) in SQLite
+// and web sessions in memory
+type Store struct {
+	dbPath     string
+	db         *sql.DB
+	mutex      sync.Mutex
+	sessions   map[string]*webSession
+	sessMutex  sync.Mutex
+	sessionTTL time.Duration
+}
+
+// webSession is a logged in web UI session (cookie)
+type webSession struct {
+	user    *User
+	expires time.Time
+}
+
+// NewStore creates auth store. Open() must be called before use.
+func NewStore(dbPath string, sessionTTL time.Duration) *Store {
+	if dbPath == "" {
+		dbPath = "./data/auth.db"
+	}
+	if sessionTTL <= 0 {
+		sessionTTL = 12 * time.Hour
+	}
+	return &Store{
+		dbPath:     dbPath,
+		sessions:   make(map[string]*webSession),
+		sessionTTL: sessionTTL,
+	}
+}
+
+// Open opens or creates the database and initializes tables.
+func (s *Store) Open() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.dbPath), 0755); err != nil {
+		return fmt.Errorf("create db dir: %w", err)
+	}
+	db, err := sql.Open("sqlite", s.dbPath)
+	if err != nil {
+		return fmt.Errorf("open sqlite: %w", err)
+	}
+	s.db = db
+
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS users (
+			username TEXT PRIMARY KEY,
+			password_hash TEXT NOT NULL,
+			role TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			token_hash TEXT NOT NULL UNIQUE,
+			username TEXT NOT NULL,
+			name TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_used DATETIME
+		);
+	`)
+	if err != nil {
+		db.Close()
+		s.db = nil
+		return fmt.Errorf("create auth tables: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+// Bootstrap creates admin user if there are no users. Empty password is generated
+// and returned so it can be shown once.
+func (s *Store) Bootstrap(username, password string) (string, error) {
+	s.mutex.Lock()
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count)
+	s.mutex.Unlock()
+	if err != nil || count > 0 {
+		return "", err
+	}
+
+	if username == "" {
+		username = "admin"
+	}
+	generated := ""
+	if password == "" {
+		generated = randomHex(8)
+		password = generated
+	}
+	if err := s.CreateUser(username, password, ROLE_ADMIN); err != nil {
+		return "", err
+	}
+	return generated, nil
+}
+
+// CreateUser adds user with password and role
+func (s *Store) CreateUser(username, password, role string) error {
+	username = strings.TrimSpace(username)
+	if username == "" || password == "" {
+		return fmt.Errorf("username and password required")
+	}
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err = s.db.Exec(`INSERT INTO users (username, password_hash, role) VALUES (?, ?, ?)`, username, string(hash), role)
+	if err != nil {
+		return fmt.Errorf("create user %s: %w", username, err)
+	}
+	return nil
+}
+
+// UpdateUser changes password and/or role of user (empty values are kept)
+func (s *Store) UpdateUser(username, password, role string) error {
+	if role != "" && !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	var hash []byte
+	if password != "" {
+		var err error
+		if hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost); err != nil {
+			return err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res, err := s.db.Exec(`
+		UPDATE users SET
+			password_hash = CASE WHEN ? != '' THEN ? ELSE password_hash END,
+			role = CASE WHEN ? != '' THEN ? ELSE role END
+		WHERE username = ?`, string(hash), string(hash), role, role, username)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	s.dropSessions(username)
+	return nil
+}
+
+// DeleteUser removes user with its tokens and sessions
+func (s *Store) DeleteUser(username string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res, err := s.db.Exec(`DELETE FROM users WHERE username = ?`, username)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	s.db.Exec(`DELETE FROM tokens WHERE username = ?`, username)
+	s.dropSessions(username)
+	return nil
+}
+
+// ListUsers returns all users
+func (s *Store) ListUsers() ([]User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	rows, err := s.db.Query(`SELECT username, role, created_at FROM users ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.Username, &u.Role, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetUser returns user by name
+func (s *Store) GetUser(username string) (*User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	u := &User{Username: username}
+	err := s.db.QueryRow(`SELECT role, created_at FROM users WHERE username = ?`, username).Scan(&u.Role, &u.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Authenticate checks username and password
+func (s *Store) Authenticate(username, password string) (*User, error) {
+	s.mutex.Lock()
+	var hash string
+	u := &User{Username: username}
+	err := s.db.QueryRow(`SELECT password_hash, role, created_at FROM users WHERE username = ?`, username).
+		Scan(&hash, &u.Role, &u.CreatedAt)
+	s.mutex.Unlock()
+	if err != nil {
+		// Compare anyway so unknown users take the same time
+		bcrypt.CompareHashAndPassword([]byte("$2a$10$invalidinvalidinvalidinvalidinvalidinvalidinvalidinva"), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return u, nil
+}
+
+// CreateToken creates API token of user, returns token value (shown only once)
+func (s *Store) CreateToken(username, name string) (string, *Token, error) {
+	value := TOKEN_PREFIX + randomHex(24)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var exists int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE username = ?`, username).Scan(&exists); err != nil {
+		return "", nil, err
+	}
+	if exists == 0 {
+		return "", nil, ErrUserNotFound
+	}
+	res, err := s.db.Exec(`INSERT INTO tokens (token_hash, username, name) VALUES (?, ?, ?)`, hashToken(value), username, name)
+	if err != nil {
+		return "", nil, err
+	}
+	id, _ := res.LastInsertId()
+	return value, &Token{ID: id, Username: username, Name: name, CreatedAt: time.Now()}, nil
+}
+
+// ListTokens returns tokens of user, all tokens if username is empty
+func (s *Store) ListTokens(username string) ([]Token, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	rows, err := s.db.Query(`
+		SELECT id, username, COALESCE(name, ''), created_at, last_used FROM tokens
+		WHERE ? = '' OR username = ? ORDER BY id`, username, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []Token{}
+	for rows.Next() {
+		var t Token
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Username, &t.Name, &t.CreatedAt, &lastUsed); err != nil {
+			return nil, err
+		}
+		t.LastUsed = lastUsed.Time
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteToken revokes token by ID (of user if username is not empty)
+func (s *Store) DeleteToken(id int64, username string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res, err := s.db.Exec(`DELETE FROM tokens WHERE id = ? AND (? = '' OR username = ?)`, id, username, username)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("token %d not found", id)
+	}
+	return nil
+}
+
+// CheckToken returns user of API token
+func (s *Store) CheckToken(value string) (*User, error) {
+	if !strings.HasPrefix(value, TOKEN_PREFIX) {
+		return nil, ErrInvalidCredentials
+	}
+	hash := hashToken(value)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	u := &User{}
+	err := s.db.QueryRow(`
+		SELECT u.username, u.role, u.created_at FROM tokens t JOIN users u ON u.username = t.username
+		WHERE t.token_hash = ?`, hash).Scan(&u.Username, &u.Role, &u.CreatedAt)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	s.db.Exec(`UPDATE tokens SET last_used = ? WHERE token_hash = ?`, time.Now(), hash)
+	return u, nil
+}
+
+// NewSession starts web session of user, returns session ID for cookie
+func (s *Store) NewSession(user *User) string {
+	id := randomHex(32)
+	s.sessMutex.Lock()
+	defer s.sessMutex.Unlock()
+
+	now := time.Now()
+	for sid, sess := range s.sessions {
+		if now.After(sess.expires) {
+			delete(s.sessions, sid)
+		}
+	}
+	s.sessions[id] = &webSession{user: user, expires: now.Add(s.sessionTTL)}
+	return id
+}
+
+// Session returns user of web session and prolongs it
+func (s *Store) Session(id string) *User {
+	s.sessMutex.Lock()
+	defer s.sessMutex.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(sess.expires) {
+		delete(s.sessions, id)
+		return nil
+	}
+	sess.expires = time.Now().Add(s.sessionTTL)
+	return sess.user
+}
+
+// DeleteSession ends web session
+func (s *Store) DeleteSession(id string) {
+	s.sessMutex.Lock()
+	defer s.sessMutex.Unlock()
+	delete(s.sessions, id)
+}
+
+// SessionTTL returns web session lifetime
+func (s *Store) SessionTTL() time.Duration {
+	return s.sessionTTL
+}
+
+// dropSessions ends web sessions of user (role or password changed)
+func (s *Store) dropSessions(username string) {
+	s.sessMutex.Lock()
+	defer s.sessMutex.Unlock()
+	for id, sess := range s.sessions {
+		if sess.user.Username == username {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// hashToken returns SHA-256 of token (tokens are random, no salt needed)
+func hashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
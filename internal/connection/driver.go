@@ -0,0 +1,186 @@
+package connection
+
+import (
+	"fmt"
+	"nd-go/pkg/types"
+	"sync"
+	"time"
+)
+
+// TerminalDriver implements protocol specifics of a terminal family.
+// Drivers are stateless: per-connection state lives in Connection.
+// Packet builders return nil packet with nil error when the protocol
+// has nothing to send for the operation.
+type TerminalDriver interface {
+	// Type returns terminal type handled by driver
+	Type() types.TerminalType
+	// Detect reports whether raw data from unknown connection belongs to this protocol
+	Detect(data []byte) bool
+	// Init prepares protocol state (ping etc.) after settings are assigned
+	Init(cp *ConnectionPool, conn *Connection)
+	// Process extracts complete frames from conn.Buffer, decodes and handles them
+	Process(cp *ConnectionPool, conn *Connection)
+	// Ping sends keep-alive when due, returns error on ping timeout
+	Ping(cp *ConnectionPool, conn *Connection, now time.Time) error
+	// Lock builds packet that puts terminal into waiting state
+	Lock(conn *Connection, text string) ([]byte, error)
+	// Unlock builds packet that clears waiting state
+	Unlock(conn *Connection) ([]byte, error)
+	// RelayOpen builds packet that grants pass
+	RelayOpen(conn *Connection, uid string, caption string, timeMs int, cid string) ([]byte, error)
+	// Message builds packet that shows text on terminal display
+	Message(conn *Connection, text string, timeMs int) ([]byte, error)
+	// Deny builds packet that shows deny text and rejects pending card read
+	Deny(conn *Connection, text string) ([]byte, error)
+}
+
+var (
+	drivers     = make(map[types.TerminalType]TerminalDriver)
+	driverOrder []TerminalDriver
+	driverMutex sync.RWMutex
+)
+
+// RegisterDriver registers terminal driver for its terminal type
+func RegisterDriver(driver TerminalDriver) {
+	driverMutex.Lock()
+	defer driverMutex.Unlock()
+
+	if _, exists := drivers[driver.Type()]; !exists {
+		driverOrder = append(driverOrder, driver)
+	} else {
+		for i, d := range driverOrder {
+			if d.Type() == driver.Type() {
+				driverOrder[i] = driver
+			}
+		}
+	}
+	drivers[driver.Type()] = driver
+}
+
+// GetDriver returns driver registered for terminal type
+func GetDriver(ttype types.TerminalType) TerminalDriver {
+	driverMutex.RLock()
+	defer driverMutex.RUnlock()
+	return drivers[ttype]
+}
+
+// GetDriverTypes returns registered terminal types in registration order
+func GetDriverTypes() []types.TerminalType {
+	driverMutex.RLock()
+	defer driverMutex.RUnlock()
+
+	result := make([]types.TerminalType, 0, len(driverOrder))
+	for _, d := range driverOrder {
+		result = append(result, d.Type())
+	}
+	return result
+}
+
+// detectDriver returns driver which recognizes data
+func detectDriver(data []byte) TerminalDriver {
+	driverMutex.RLock()
+	defer driverMutex.RUnlock()
+
+	for _, d := range driverOrder {
+		if d.Detect(data) {
+			return d
+		}
+	}
+	return nil
+}
+
+// connDriver returns driver for connection
+func connDriver(conn *Connection) (TerminalDriver, error) {
+	if conn.Settings == nil {
+		return nil, fmt.Errorf("terminal settings missing: %s", conn.Key)
+	}
+	driver := GetDriver(conn.Settings.Type)
+	if driver == nil {
+		return nil, fmt.Errorf("unknown terminal type for connection %s: %s", conn.Key, conn.Settings.Type)
+	}
+	return driver, nil
+}
+
+// getConnected returns connected connection by key
+func (cp *ConnectionPool) getConnected(key string) (*Connection, error) {
+	cp.mutex.RLock()
+	conn, exists := cp.connections[key]
+	cp.mutex.RUnlock()
+
+	if !exists || !conn.Connected {
+		return nil, fmt.Errorf("connection not found or not connected: %s", key)
+	}
+	return conn, nil
+}
+
+// SetSettings assigns terminal settings to connection and initializes protocol state
+func (cp *ConnectionPool) SetSettings(key string, settings *types.TerminalSettings) error {
+	cp.mutex.RLock()
+	conn, exists := cp.connections[key]
+	cp.mutex.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("connection not found: %s", key)
+	}
+
+	conn.Settings = settings
+	if driver := GetDriver(settings.Type); driver != nil {
+		driver.Init(cp, conn)
+	}
+	return nil
+}
+
+// PingProc sends keep-alive pings and drops connections with ping timeout
+func (cp *ConnectionPool) PingProc() {
+	now := time.Now()
+
+	for key, conn := range cp.GetConnections() {
+		if !conn.Connected || conn.Settings == nil {
+			continue
+		}
+		cp.checkLockTimeout(conn, now)
+		driver := GetDriver(conn.Settings.Type)
+		if driver == nil {
+			continue
+		}
+		if err := driver.Ping(cp, conn, now); err != nil {
+			fmt.Printf("%s, disconnecting %s\n", err, key)
+			conn.Conn.Close()
+			conn.Connected = false
+		}
+	}
+}
+
+// pingDue checks ping state and reports whether ping should be sent now
+func pingDue(conn *Connection, interval, timeout int, sent bool, lastPing time.Time, now time.Time) (bool, error) {
+	if sent && timeout > 0 {
+		if int(now.Sub(lastPing).Seconds()) >= timeout {
+			return false, fmt.Errorf("%s ping timeout", conn.Settings.Type)
+		}
+	}
+	if !sent && interval > 0 {
+		if int(now.Sub(conn.LastActivity).Seconds()) >= interval {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// logUID hides PIN entered on terminal keypad in logs
+func logUID(uid string, readerType uint8) string {
+	if readerType == types.READER_TYPE_PIN {
+		return "****"
+	}
+	return uid
+}
+
+// intSetting returns integer term string setting
+func intSetting(conn *Connection, key string, def int) int {
+	if conn.Settings == nil || conn.Settings.Extra == nil {
+		return def
+	}
+	if v, ok := conn.Settings.Extra[key].(int); ok {
+		return v
+	}
+	return def
+}
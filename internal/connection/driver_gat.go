@@ -0,0 +1,248 @@
+package connection
+
+import (
+	"fmt"
+	"nd-go/internal/protocols/gat"
+	"nd-go/pkg/types"
+	"time"
+)
+
+// gatDriver implements GAT protocol
+type gatDriver struct{}
+
+func init() {
+	RegisterDriver(&gatDriver{})
+}
+
+// Type returns terminal type handled by driver
+func (d *gatDriver) Type() types.TerminalType {
+	return types.TTYPE_GAT
+}
+
+// Detect checks for complete GAT frame with valid LRC
+func (d *gatDriver) Detect(data []byte) bool {
+	return gatFrameLen(data) > 0
+}
+
+// Init initializes GAT ping state
+func (d *gatDriver) Init(cp *ConnectionPool, conn *Connection) {
+	if conn.GatPing == nil {
+		conn.GatPing = &GatPingState{
+			PingInterval: 10, // Default 10 seconds
+			PingTimeout:  15, // Default 15 seconds
+			PingSent:     false,
+			TerminalType: gat.GAT_TTYPE_ACCESS,
+		}
+	}
+}
+
+// Ping sends REQ_MASTER packet (ping)
+func (d *gatDriver) Ping(cp *ConnectionPool, conn *Connection, now time.Time) error {
+	gatPing := conn.GatPing
+	if gatPing == nil {
+		return nil
+	}
+
+	due, err := pingDue(conn, gatPing.PingInterval, gatPing.PingTimeout, gatPing.PingSent, gatPing.LastPingTime, now)
+	if err != nil || !due {
+		return err
+	}
+
+	packet := gat.CreateReqMasterPacket(0x00, gatPing.TerminalType) // 0x00 = broadcast address
+	if err := cp.Send(conn.Key, packet); err != nil {
+		fmt.Printf("Failed to send GAT ping to %s: %v\n", conn.Key, err)
+		return nil
+	}
+	gatPing.PingSent = true
+	gatPing.PingSinceLast = 0
+	gatPing.LastPingTime = now
+	fmt.Printf("GAT ping (REQ_MASTER) sent to %s\n", conn.Key)
+	return nil
+}
+
+// Lock builds HOST_CONTROL busy packet: terminal shows text and stops
+// accepting cards until grant/deny or unlock
+func (d *gatDriver) Lock(conn *Connection, text string) ([]byte, error) {
+	control := uint16(gat.GAT_HC_BUSY)
+	if text != "" {
+		control |= gat.GAT_HC_MESSAGE
+	}
+	return gat.CreateHostControlPacket(gatAddress(conn), control, text), nil
+}
+
+// Unlock builds CANCEL of pending card transaction followed by HOST_CONTROL
+// clearing busy state (sequence is unverified, see gat.GAT_HC_* note)
+func (d *gatDriver) Unlock(conn *Connection) ([]byte, error) {
+	adr := gatAddress(conn)
+	packet := gat.CreateCancelPacket(adr)
+	return append(packet, gat.CreateHostControlPacket(adr, gat.GAT_HC_NONE, "")...), nil
+}
+
+// RelayOpen builds HOST_CONTROL grant packet
+func (d *gatDriver) RelayOpen(conn *Connection, uid string, caption string, timeMs int, cid string) ([]byte, error) {
+	return gat.CreateHostControlPacket(gatAddress(conn), gat.GAT_HC_ACCESS_GRANT, caption), nil
+}
+
+// Message builds HOST_CONTROL message packet
+func (d *gatDriver) Message(conn *Connection, text string, timeMs int) ([]byte, error) {
+	return gat.CreateHostControlPacket(gatAddress(conn), gat.GAT_HC_MESSAGE, text), nil
+}
+
+// Deny builds HOST_CONTROL deny packet
+func (d *gatDriver) Deny(conn *Connection, text string) ([]byte, error) {
+	return gat.CreateHostControlPacket(gatAddress(conn), gat.GAT_HC_MESSAGE|gat.GAT_HC_ACCESS_DENY, text), nil
+}
+
+// gatAddress returns terminal address remembered from last card ident
+func gatAddress(conn *Connection) uint8 {
+	state := conn.GatPing
+	if state == nil {
+		return 0x00
+	}
+	state.Mutex.Lock()
+	defer state.Mutex.Unlock()
+	return state.Address
+}
+
+// gatFrameLen returns length of complete GAT frame at buffer start,
+// 0 if more data is needed and -1 if buffer doesn't start with valid frame
+// Frame: len, adr, cmd, [te_status], data, lrc (len excludes LRC)
+func gatFrameLen(buf []byte) int {
+	if len(buf) < 1 {
+		return 0
+	}
+	pktLen := int(buf[0])
+	if pktLen < 3 {
+		return -1
+	}
+	if len(buf) < pktLen+1 {
+		return 0
+	}
+	var lrc uint8
+	for _, b := range buf[:pktLen] {
+		lrc ^= b
+	}
+	if lrc != buf[pktLen] {
+		return -1
+	}
+	return pktLen + 1
+}
+
+// Process processes GAT protocol data
+func (d *gatDriver) Process(cp *ConnectionPool, conn *Connection) {
+	// Try to find complete packet in buffer
+	for len(conn.Buffer) > 0 {
+		frameLen := gatFrameLen(conn.Buffer)
+		if frameLen == 0 {
+			// Not enough data for complete packet
+			break
+		}
+		if frameLen < 0 {
+			// Invalid frame, skip byte
+			conn.Buffer = conn.Buffer[1:]
+			continue
+		}
+
+		// Extract packet
+		packetData := conn.Buffer[:frameLen]
+		conn.Buffer = conn.Buffer[frameLen:]
+
+		// Process packet
+		d.handlePacket(cp, conn, packetData)
+	}
+}
+
+// handlePacket handles decoded GAT packet
+func (d *gatDriver) handlePacket(cp *ConnectionPool, conn *Connection, data []byte) {
+	packet, err := gat.DecodePacket(data)
+	if err != nil {
+		fmt.Printf("Failed to decode GAT packet from %s: %v\n", conn.Key, err)
+		return
+	}
+
+	fmt.Printf("GAT packet from %s: cmd=0x%02X\n", conn.Key, packet.Cmd)
+
+	// Handle specific commands
+	switch packet.Cmd {
+	case 0xE5: // REQ_MASTER (ping response)
+		d.handleReqMasterResponse(cp, conn, packet)
+	case 0x80: // CARD_IDENT
+		d.handleCardIdent(cp, conn, packet)
+	}
+}
+
+// handleReqMasterResponse handles REQ_MASTER response (pong)
+func (d *gatDriver) handleReqMasterResponse(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	// Update last activity time
+	conn.LastActivity = time.Now()
+
+	// Reset ping state if we have it
+	if conn.GatPing != nil {
+		conn.GatPing.PingSent = false
+		conn.GatPing.PingSinceLast = 0
+	}
+
+	fmt.Printf("GAT REQ_MASTER response (pong) from %s\n", conn.Key)
+}
+
+// handleCardIdent handles CARD_IDENT command
+func (d *gatDriver) handleCardIdent(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	uidHex, ok := packet.Data["uid_hex"].(string)
+	if !ok || uidHex == "" {
+		fmt.Printf("No UID in GAT card ident from %s\n", conn.Key)
+		return
+	}
+
+	readerType, _ := packet.Data["reader_type"].(uint8)
+	terminalType, _ := packet.Data["terminal_type"].(uint8)
+
+	fmt.Printf("GAT Card ident: key=%s, uid=%s, reader_type=%d, terminal_type=%d\n",
+		conn.Key, logUID(uidHex, readerType), readerType, terminalType)
+
+	// Remember terminal address for HOST_CONTROL replies
+	if adr, ok := packet.Data["address"].(uint8); ok && conn.GatPing != nil {
+		conn.GatPing.Mutex.Lock()
+		conn.GatPing.Address = adr
+		conn.GatPing.Mutex.Unlock()
+	}
+
+	// Store GAT-specific data (solar time, price, etc.) in connection extra
+	if terminalType == gat.GAT_TTYPE_TIME {
+		cp.mutex.Lock()
+		if conn.Settings == nil {
+			conn.Settings = &types.TerminalSettings{}
+		}
+		if conn.Settings.Extra == nil {
+			conn.Settings.Extra = make(map[string]interface{})
+		}
+		conn.Settings.Extra["gat_terminal_type"] = terminalType
+		if solarTime, ok := packet.Data["time"].(uint16); ok {
+			conn.Settings.Extra["gat_solar_time"] = int(solarTime)
+		}
+		if price, ok := packet.Data["price"].(uint32); ok {
+			conn.Settings.Extra["gat_solar_price"] = int(price)
+		}
+		if vendor, ok := packet.Data["vendor"].(uint32); ok {
+			conn.Settings.Extra["gat_solar_vendor"] = int(vendor)
+		}
+		if ares, ok := packet.Data["access_result"].(uint8); ok {
+			conn.Settings.Extra["gat_access_result"] = int(ares)
+		}
+		cp.mutex.Unlock()
+	}
+
+	// Card presented while session is pending: repeat busy state, drop read
+	// (PIN goes to pending session waiting for it)
+	if readerType != types.READER_TYPE_PIN && cp.readerLock(conn) != "" {
+		fmt.Printf("GAT card %s ignored: %s is locked by pending session\n", uidHex, conn.Key)
+		if packet, err := d.Lock(conn, ""); err == nil {
+			cp.Send(conn.Key, packet)
+		}
+		return
+	}
+
+	// Call event handler
+	if cp.onTagRead != nil {
+		cp.onTagRead(conn.Key, uidHex, readerType, true)
+	}
+}
@@ -0,0 +1,408 @@
+package connection
+
+import (
+	"encoding/json"
+	"fmt"
+	"nd-go/internal/protocols/jsp"
+	"nd-go/pkg/types"
+	"nd-go/pkg/utils"
+	"strings"
+	"time"
+)
+
+// jspDriver implements JSP protocol
+type jspDriver struct{}
+
+func init() {
+	RegisterDriver(&jspDriver{})
+}
+
+// Type returns terminal type handled by driver
+func (d *jspDriver) Type() types.TerminalType {
+	return types.TTYPE_JSP
+}
+
+// Detect checks for JSP start of frame followed by hex length
+func (d *jspDriver) Detect(data []byte) bool {
+	if len(data) < 1+jsp.JSP_LENGTH_SIZE || data[0] != jsp.JSP_SOF {
+		return false
+	}
+	_, err := jsp.DecodeHex(string(data[1 : 1+jsp.JSP_LENGTH_SIZE]))
+	return err == nil
+}
+
+// Init initializes JSP connection state and auto-ping
+func (d *jspDriver) Init(cp *ConnectionPool, conn *Connection) {
+	if conn.JSPConn == nil {
+		conn.JSPConn = jsp.NewJSPConnection()
+	}
+	// Set auto-ping if enabled
+	if cp.config.JSPDevAutoPingEnabled && conn.JSPConn.PingInterval == 0 {
+		conn.JSPConn.PingInterval = 10 // Default 10 seconds
+		conn.JSPConn.PingTimeout = 15  // Default 15 seconds
+	}
+}
+
+// Ping sends ping request
+func (d *jspDriver) Ping(cp *ConnectionPool, conn *Connection, now time.Time) error {
+	jspConn := conn.JSPConn
+	if jspConn == nil {
+		return nil
+	}
+
+	// Send() updates LastActivity, so it holds last ping time while waiting for pong
+	due, err := pingDue(conn, jspConn.PingInterval, jspConn.PingTimeout, jspConn.PingSent, conn.LastActivity, now)
+	if err != nil || !due {
+		return err
+	}
+
+	packet, err := jsp.SendPing(&conn.JSPRIDCounter)
+	if err != nil {
+		return nil
+	}
+	trackJSPRequest(conn, packet, "ping")
+	if err := cp.Send(conn.Key, packet); err != nil {
+		fmt.Printf("Failed to send JSP ping to %s: %v\n", conn.Key, err)
+		return nil
+	}
+	jspConn.PingSent = true
+	jspConn.PingSinceLast = 0
+	fmt.Printf("JSP ping sent to %s\n", conn.Key)
+	return nil
+}
+
+// Lock builds waiting message packet
+func (d *jspDriver) Lock(conn *Connection, text string) ([]byte, error) {
+	if conn.JSPConn == nil {
+		return nil, fmt.Errorf("JSP connection not initialized: %s", conn.Key)
+	}
+	if text == "" {
+		text = "Ожидание..."
+	}
+	return jsp.CreateMessagePacket(text, 0), nil // 0 = wait until removed
+}
+
+// Unlock builds empty message packet
+func (d *jspDriver) Unlock(conn *Connection) ([]byte, error) {
+	if conn.JSPConn == nil {
+		return nil, fmt.Errorf("JSP connection not initialized: %s", conn.Key)
+	}
+	return jsp.CreateMessagePacket("", 0), nil
+}
+
+// RelayOpen builds relay_open request and tracks it
+func (d *jspDriver) RelayOpen(conn *Connection, uid string, caption string, timeMs int, cid string) ([]byte, error) {
+	if conn.JSPConn == nil {
+		return nil, fmt.Errorf("JSP connection not initialized: %s", conn.Key)
+	}
+	packet, err := jsp.SendRelayOpen(&conn.JSPRIDCounter, uid, caption, timeMs, cid)
+	if err != nil {
+		return nil, err
+	}
+	trackJSPRequest(conn, packet, "relay_open")
+	return packet, nil
+}
+
+// Message builds message packet
+func (d *jspDriver) Message(conn *Connection, text string, timeMs int) ([]byte, error) {
+	if conn.JSPConn == nil {
+		return nil, fmt.Errorf("JSP connection not initialized: %s", conn.Key)
+	}
+	return jsp.CreateMessagePacket(text, timeMs), nil
+}
+
+// Deny builds relay close command followed by message packet with deny text
+func (d *jspDriver) Deny(conn *Connection, text string) ([]byte, error) {
+	if conn.JSPConn == nil {
+		return nil, fmt.Errorf("JSP connection not initialized: %s", conn.Key)
+	}
+	packet, err := jsp.SendRelayClose(&conn.JSPRIDCounter)
+	if err != nil {
+		return nil, err
+	}
+	trackJSPRequest(conn, packet, "relay_close")
+	return append(packet, jsp.CreateMessagePacket(text, MESSAGE_DENY_TIME)...), nil
+}
+
+// trackJSPRequest stores request by RID extracted from packet
+// Packet format: SOF(1) + LENGTH(4) + JSON + EOF(1)
+func trackJSPRequest(conn *Connection, packet []byte, cmd string) {
+	if conn.JSPConn == nil || len(packet) <= 6 {
+		return
+	}
+	if conn.JSPConn.Requests == nil {
+		conn.JSPConn.Requests = make(map[string]*jsp.JSPRequest)
+	}
+
+	var packetData map[string]interface{}
+	if err := json.Unmarshal(packet[5:len(packet)-1], &packetData); err == nil {
+		if rid, ok := packetData["rid"].(string); ok {
+			conn.JSPConn.Requests[rid] = &jsp.JSPRequest{
+				ID:   rid,
+				RKey: rid,
+				Cmd:  cmd,
+				Time: utils.GetMtf(),
+			}
+		}
+	}
+}
+
+// SendJSPMessage sends message to JSP terminal
+func (cp *ConnectionPool) SendJSPMessage(key string, text string, timeMs int) error {
+	conn, err := cp.getJSPConnection(key)
+	if err != nil {
+		return err
+	}
+
+	packet, err := GetDriver(types.TTYPE_JSP).Message(conn, text, timeMs)
+	if err != nil {
+		return err
+	}
+	return cp.Send(key, packet)
+}
+
+// SendJSPRelayOpen sends relay open command to JSP terminal
+func (cp *ConnectionPool) SendJSPRelayOpen(key string, uid string, caption string, timeMs int, cid string) error {
+	conn, err := cp.getJSPConnection(key)
+	if err != nil {
+		return err
+	}
+
+	packet, err := GetDriver(types.TTYPE_JSP).RelayOpen(conn, uid, caption, timeMs, cid)
+	if err != nil {
+		return err
+	}
+	return cp.Send(key, packet)
+}
+
+// SendJSPRelayClose sends relay close command to JSP terminal
+func (cp *ConnectionPool) SendJSPRelayClose(key string) error {
+	conn, err := cp.getJSPConnection(key)
+	if err != nil {
+		return err
+	}
+
+	packet, err := jsp.SendRelayClose(&conn.JSPRIDCounter)
+	if err != nil {
+		return err
+	}
+	trackJSPRequest(conn, packet, "relay_close")
+
+	return cp.Send(key, packet)
+}
+
+// getJSPConnection returns connected JSP connection by key
+func (cp *ConnectionPool) getJSPConnection(key string) (*Connection, error) {
+	conn, err := cp.getConnected(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.Settings == nil || conn.Settings.Type != types.TTYPE_JSP {
+		return nil, fmt.Errorf("connection is not JSP type: %s", key)
+	}
+
+	if conn.JSPConn == nil {
+		return nil, fmt.Errorf("JSP connection not initialized: %s", key)
+	}
+	return conn, nil
+}
+
+// Process processes JSP protocol data
+func (d *jspDriver) Process(cp *ConnectionPool, conn *Connection) {
+	if conn.JSPConn == nil {
+		conn.JSPConn = jsp.NewJSPConnection()
+	}
+
+	// Add data to JSP buffer
+	conn.JSPConn.Buffer = append(conn.JSPConn.Buffer, conn.Buffer...)
+	conn.Buffer = nil
+
+	// Try to read packets
+	for {
+		result, err := jsp.TryReadPacket(conn.JSPConn)
+		if err != nil {
+			fmt.Printf("JSP packet error from %s: %v\n", conn.Key, err)
+			return
+		}
+
+		// Check result type
+		switch v := result.(type) {
+		case bool:
+			if len(conn.JSPConn.Buffer) == 0 {
+				// No more packets
+				return
+			}
+			// Garbage skipped, try again
+		case int:
+			// Need more data
+			return
+		case map[string]interface{}:
+			// Got packet, process it
+			d.handlePacket(cp, conn, v)
+		default:
+			// Unknown result
+			return
+		}
+	}
+}
+
+// handlePacket handles decoded JSP packet
+func (d *jspDriver) handlePacket(cp *ConnectionPool, conn *Connection, packet map[string]interface{}) {
+	packetType, data, err := jsp.ProcessPacket(packet)
+	if err != nil {
+		fmt.Printf("JSP packet processing error from %s: %v\n", conn.Key, err)
+		return
+	}
+
+	// Terminals dialing in without known IP introduce themselves in first packet
+	if !cp.identifyInbound(conn, data) {
+		return
+	}
+
+	fmt.Printf("JSP packet from %s: type=%s\n", conn.Key, packetType)
+
+	switch packetType {
+	case "command":
+		d.handleCommand(cp, conn, data)
+	case "answer":
+		d.handleAnswer(cp, conn, data)
+	default:
+		fmt.Printf("Unknown JSP packet type: %s\n", packetType)
+	}
+}
+
+// handleCommand handles JSP command
+func (d *jspDriver) handleCommand(cp *ConnectionPool, conn *Connection, packet map[string]interface{}) {
+	cmd, ok := packet["cmd"].(string)
+	if !ok {
+		return
+	}
+
+	cmd = strings.ToLower(strings.TrimSpace(cmd))
+
+	switch cmd {
+	case "tag_read":
+		d.handleTagRead(cp, conn, packet)
+	case "pass_report":
+		d.handlePassReport(cp, conn, packet)
+	case "pong":
+		// Ping response, just update activity
+		d.handlePong(cp, conn, packet)
+	default:
+		// Unknown command, send answer if has RID
+		if rid, ok := packet["rid"].(string); ok {
+			answer, _ := jsp.AnswerRequest(rid, map[string]interface{}{"result": false, "error": "unknown command"})
+			cp.Send(conn.Key, answer)
+		}
+	}
+}
+
+// handleTagRead handles JSP tag_read command
+func (d *jspDriver) handleTagRead(cp *ConnectionPool, conn *Connection, packet map[string]interface{}) {
+	uid, ok := packet["uid"].(string)
+	if !ok {
+		fmt.Printf("JSP tag_read missing UID from %s\n", conn.Key)
+		return
+	}
+
+	// Transform lockers data if present
+	jsp.TransformLockersData(packet)
+
+	// Extract reader type (default to RFID)
+	readerType := uint8(0x01) // Card Reader
+	if rt, ok := packet["reader_type"].(float64); ok {
+		readerType = uint8(rt)
+	}
+
+	auth := true
+	if a, ok := packet["auth"].(bool); ok {
+		auth = a
+	}
+
+	fmt.Printf("JSP Tag read: key=%s, uid=%s, reader_type=%d, auth=%v\n",
+		conn.Key, logUID(uid, readerType), readerType, auth)
+
+	// Call event handler
+	if cp.onTagRead != nil {
+		cp.onTagRead(conn.Key, uid, readerType, auth)
+	}
+
+	// Store RID if present
+	if rid, ok := packet["rid"].(string); ok {
+		// Store in connection for later use
+		if conn.JSPConn != nil {
+			if conn.JSPConn.Requests == nil {
+				conn.JSPConn.Requests = make(map[string]*jsp.JSPRequest)
+			}
+			conn.JSPConn.Requests[rid] = &jsp.JSPRequest{
+				ID:   rid,
+				RKey: rid,
+				Cmd:  "tag_read",
+				Time: utils.GetMtf(),
+			}
+		}
+	}
+}
+
+// handlePassReport handles JSP pass_report command
+func (d *jspDriver) handlePassReport(cp *ConnectionPool, conn *Connection, packet map[string]interface{}) {
+	passed := true
+	if p, ok := packet["passed"].(bool); ok {
+		passed = p
+	}
+
+	fmt.Printf("JSP Pass report: key=%s, passed=%v\n", conn.Key, passed)
+
+	// Call event handler
+	if cp.onPassEvent != nil {
+		cp.onPassEvent(conn.Key, passed)
+	}
+}
+
+// handleAnswer handles JSP answer packet
+func (d *jspDriver) handleAnswer(cp *ConnectionPool, conn *Connection, packet map[string]interface{}) {
+	rid, ok := packet["rid"].(string)
+	if !ok {
+		return
+	}
+
+	// Find request
+	if conn.JSPConn == nil || conn.JSPConn.Requests == nil {
+		return
+	}
+
+	request, exists := conn.JSPConn.Requests[rid]
+	if !exists {
+		return
+	}
+
+	fmt.Printf("JSP Answer for request %s from %s\n", rid, conn.Key)
+
+	// Process answer based on request command
+	switch request.Cmd {
+	case "relay_open":
+		// Relay opened
+		fmt.Printf("JSP Relay opened for request %s\n", rid)
+	case "message":
+		// Message sent
+		fmt.Printf("JSP Message sent for request %s\n", rid)
+	case "ping":
+		// Ping response (pong)
+		fmt.Printf("JSP Pong received for request %s\n", rid)
+		conn.JSPConn.PingSent = false
+		conn.JSPConn.PingSinceLast = 0
+		conn.LastActivity = time.Now()
+	}
+
+	// Remove request
+	delete(conn.JSPConn.Requests, rid)
+}
+
+// handlePong handles JSP pong response
+func (d *jspDriver) handlePong(cp *ConnectionPool, conn *Connection, packet map[string]interface{}) {
+	conn.JSPConn.PingSent = false
+	conn.JSPConn.PingSinceLast = 0
+	conn.LastActivity = time.Now()
+	fmt.Printf("JSP Pong received from %s\n", conn.Key)
+}
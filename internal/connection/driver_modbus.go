@@ -0,0 +1,199 @@
+package connection
+
+import (
+	"fmt"
+	"nd-go/internal/protocols/modbus"
+	"nd-go/pkg/types"
+	"time"
+)
+
+// modbusDriver implements Modbus TCP I/O modules (lock on a coil, door contact
+// on a discrete input). Module is usually paired with reader terminal through
+// reader's "gate=<ID or IP>" setting.
+// Term string settings:
+//   - mb_unit=<n>         - unit identifier (default 1)
+//   - mb_coil=<n>         - coil address of lock relay (default 0)
+//   - mb_pulse=<ms>       - coil pulse time, 0 = grant time (default 0)
+//   - mb_input=<n>        - discrete input address of door contact (default 0)
+//   - mb_in_active=<0|1>  - input level meaning "passed" (default 1)
+//   - mb_poll=<ms>        - discrete inputs poll interval (default 200)
+//   - mb_timeout=<s>      - disconnect after no responses (default 10)
+type modbusDriver struct{}
+
+func init() {
+	RegisterDriver(&modbusDriver{})
+}
+
+// Type returns terminal type handled by driver
+func (d *modbusDriver) Type() types.TerminalType {
+	return types.TTYPE_MODBUS
+}
+
+// Detect is not supported: Modbus servers never send data first
+func (d *modbusDriver) Detect(data []byte) bool {
+	return false
+}
+
+// Init resets Modbus client state
+func (d *modbusDriver) Init(cp *ConnectionPool, conn *Connection) {
+	conn.ModbusConn = modbus.NewModbusConnection()
+}
+
+// Ping ends coil pulse when due and polls discrete inputs
+func (d *modbusDriver) Ping(cp *ConnectionPool, conn *Connection, now time.Time) error {
+	mc := conn.ModbusConn
+	if mc == nil {
+		return nil
+	}
+	unit := uint8(intSetting(conn, "mb_unit", modbus.MODBUS_DEFAULT_UNIT))
+	poll := time.Duration(intSetting(conn, "mb_poll", modbus.MODBUS_DEFAULT_POLL)) * time.Millisecond
+	timeout := time.Duration(intSetting(conn, "mb_timeout", modbus.MODBUS_DEFAULT_TIMEOUT)) * time.Second
+
+	var packets [][]byte
+	mc.Mutex.Lock()
+	if now.Sub(mc.LastReply) >= timeout {
+		mc.Mutex.Unlock()
+		return fmt.Errorf("Modbus response timeout")
+	}
+	if mc.CoilOn && !now.Before(mc.CoilOffAt) {
+		coil := uint16(intSetting(conn, "mb_coil", 0))
+		packets = append(packets, modbus.CreateWriteCoilRequest(mc.NextTransID(), unit, coil, false))
+		mc.CoilOn = false
+	}
+	// Lost response is re-requested after a second
+	if now.Sub(mc.LastPoll) >= poll && (!mc.PollPending || now.Sub(mc.LastPoll) >= time.Second) {
+		input := intSetting(conn, "mb_input", 0)
+		packets = append(packets, modbus.CreateReadBitsRequest(mc.NextTransID(), unit,
+			modbus.MODBUS_FC_READ_DISCRETE_INPUTS, uint16(input), 1))
+		mc.PollPending = true
+		mc.LastPoll = now
+	}
+	mc.Mutex.Unlock()
+
+	for _, packet := range packets {
+		if err := cp.Send(conn.Key, packet); err != nil {
+			fmt.Printf("Failed to send Modbus request to %s: %v\n", conn.Key, err)
+		}
+	}
+	return nil
+}
+
+// Lock is not supported by I/O modules
+func (d *modbusDriver) Lock(conn *Connection, text string) ([]byte, error) {
+	return nil, nil
+}
+
+// Unlock is not supported by I/O modules
+func (d *modbusDriver) Unlock(conn *Connection) ([]byte, error) {
+	return nil, nil
+}
+
+// RelayOpen switches lock coil on, Ping switches it off after pulse time
+func (d *modbusDriver) RelayOpen(conn *Connection, uid string, caption string, timeMs int, cid string) ([]byte, error) {
+	mc := conn.ModbusConn
+	if mc == nil {
+		return nil, fmt.Errorf("Modbus connection not initialized: %s", conn.Key)
+	}
+	unit := uint8(intSetting(conn, "mb_unit", modbus.MODBUS_DEFAULT_UNIT))
+	coil := uint16(intSetting(conn, "mb_coil", 0))
+	pulse := intSetting(conn, "mb_pulse", 0)
+	if pulse <= 0 {
+		pulse = timeMs
+	}
+	if pulse <= 0 {
+		pulse = modbus.MODBUS_DEFAULT_PULSE
+	}
+
+	mc.Mutex.Lock()
+	defer mc.Mutex.Unlock()
+	mc.CoilOn = true
+	mc.CoilOffAt = time.Now().Add(time.Duration(pulse) * time.Millisecond)
+	return modbus.CreateWriteCoilRequest(mc.NextTransID(), unit, coil, true), nil
+}
+
+// Message is not supported: I/O modules have no display
+func (d *modbusDriver) Message(conn *Connection, text string, timeMs int) ([]byte, error) {
+	return nil, nil
+}
+
+// Deny is not supported: I/O modules have no indication
+func (d *modbusDriver) Deny(conn *Connection, text string) ([]byte, error) {
+	return nil, nil
+}
+
+// Process processes Modbus responses
+func (d *modbusDriver) Process(cp *ConnectionPool, conn *Connection) {
+	for len(conn.Buffer) > 0 {
+		n := modbus.FrameLen(conn.Buffer)
+		if n == 0 {
+			return
+		}
+		if n < 0 {
+			// No way to resync on TCP stream, drop garbage
+			fmt.Printf("Invalid Modbus data from %s, dropping %d bytes\n", conn.Key, len(conn.Buffer))
+			conn.Buffer = nil
+			return
+		}
+
+		frame := conn.Buffer[:n]
+		conn.Buffer = conn.Buffer[n:]
+
+		packet, err := modbus.DecodeResponse(frame)
+		if err != nil {
+			fmt.Printf("Failed to decode Modbus response from %s: %v\n", conn.Key, err)
+			continue
+		}
+		d.handleResponse(cp, conn, packet)
+	}
+}
+
+// handleResponse handles decoded Modbus response
+func (d *modbusDriver) handleResponse(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	mc := conn.ModbusConn
+	if mc == nil {
+		return
+	}
+	function, _ := packet.Data["function"].(uint8)
+
+	mc.Mutex.Lock()
+	mc.LastReply = time.Now()
+	if function == modbus.MODBUS_FC_READ_DISCRETE_INPUTS {
+		mc.PollPending = false
+	}
+	mc.Mutex.Unlock()
+
+	if code, ok := packet.Data["exception"].(uint8); ok {
+		fmt.Printf("Modbus exception from %s: function 0x%02X, %s\n", conn.Key, function, modbus.ExceptionText(code))
+		return
+	}
+
+	switch function {
+	case modbus.MODBUS_FC_READ_DISCRETE_INPUTS:
+		d.handleInputs(cp, conn, packet)
+	case modbus.MODBUS_FC_WRITE_SINGLE_COIL:
+		fmt.Printf("Modbus coil %v set to %v on %s\n", packet.Data["address"], packet.Data["on"], conn.Key)
+	}
+}
+
+// handleInputs reports pass when door contact input becomes active
+func (d *modbusDriver) handleInputs(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	bits, _ := packet.Data["bits"].([]bool)
+	if len(bits) == 0 {
+		return
+	}
+	active := intSetting(conn, "mb_in_active", 1) != 0
+
+	mc := conn.ModbusConn
+	mc.Mutex.Lock()
+	prev := mc.Inputs
+	mc.Inputs = bits[:1]
+	mc.Mutex.Unlock()
+
+	// First response only sets initial state
+	if prev == nil || prev[0] == bits[0] || bits[0] != active {
+		return
+	}
+
+	fmt.Printf("Modbus Person passed: key=%s\n", conn.Key)
+	cp.raisePassEvent(conn, true)
+}
@@ -0,0 +1,411 @@
+package connection
+
+import (
+	"bytes"
+	"fmt"
+	"nd-go/internal/protocols/osdp"
+	"nd-go/internal/protocols/wiegand"
+	"nd-go/pkg/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// osdpDriver implements OSDP v2 Access Control Unit for readers behind
+// RS-485-to-TCP bridge. Commands are queued and sent one at a time from
+// Ping, POLL is sent when queue is empty.
+// Term string settings:
+//   - osdp_addr=<0..126>   - PD address (default 0)
+//   - osdp_reader=<n>      - reader number for LED/buzzer/text (default 0)
+//   - osdp_out=<n>         - output (relay) number for pass (default 0)
+//   - osdp_in=<n>          - input number with pass sensor (default 0)
+//   - osdp_in_active=<0|1> - active input level (default 1)
+//   - osdp_fc=keep|strip|<code>, osdp_uid=hex|dec - Wiegand UID format for osdp_RAW
+type osdpDriver struct{}
+
+func init() {
+	RegisterDriver(&osdpDriver{})
+}
+
+// Type returns terminal type handled by driver
+func (d *osdpDriver) Type() types.TerminalType {
+	return types.TTYPE_OSDP
+}
+
+// Detect checks for valid OSDP reply packet
+func (d *osdpDriver) Detect(data []byte) bool {
+	if osdp.FrameLen(data) <= 0 || data[1]&osdp.OSDP_ADDR_REPLY == 0 {
+		return false
+	}
+	_, err := osdp.DecodePacket(data)
+	return err == nil
+}
+
+// Init resets ACU state and queues PD identification and input status requests
+func (d *osdpDriver) Init(cp *ConnectionPool, conn *Connection) {
+	oc := osdp.NewOSDPConnection(uint8(intSetting(conn, "osdp_addr", 0)))
+	oc.Enqueue(osdp.OSDP_CMD_ID, []byte{0x00})
+	oc.Enqueue(osdp.OSDP_CMD_ISTAT, nil)
+	conn.OSDPConn = oc
+}
+
+// Ping sends next queued command, retries unanswered one or polls PD
+func (d *osdpDriver) Ping(cp *ConnectionPool, conn *Connection, now time.Time) error {
+	oc := conn.OSDPConn
+	if oc == nil {
+		return nil
+	}
+
+	oc.Mutex.Lock()
+	var packet []byte
+	if oc.Pending != nil {
+		if now.Sub(oc.LastReply) >= osdp.OSDP_OFFLINE_TIMEOUT {
+			oc.Mutex.Unlock()
+			return fmt.Errorf("OSDP reply timeout")
+		}
+		if now.Sub(oc.SentTime) < osdp.OSDP_REPLY_TIMEOUT {
+			oc.Mutex.Unlock()
+			return nil
+		}
+		// Retry with the same sequence number
+		packet = oc.Pending
+		oc.Retries++
+	} else if len(oc.Queue) > 0 {
+		item := oc.Queue[0]
+		oc.Queue = oc.Queue[1:]
+		packet = osdp.EncodePacket(oc.Address, oc.NextSQN(), item[0], item[1:])
+		oc.PendingCmd = item[0]
+	} else if now.Sub(oc.SentTime) >= osdp.OSDP_POLL_INTERVAL {
+		packet = osdp.EncodePacket(oc.Address, oc.NextSQN(), osdp.OSDP_CMD_POLL, nil)
+		oc.PendingCmd = osdp.OSDP_CMD_POLL
+	}
+	if packet == nil {
+		oc.Mutex.Unlock()
+		return nil
+	}
+	oc.Pending = packet
+	oc.SentTime = now
+	oc.Mutex.Unlock()
+
+	if err := cp.Send(conn.Key, packet); err != nil {
+		fmt.Printf("Failed to send OSDP command to %s: %v\n", conn.Key, err)
+	}
+	return nil
+}
+
+// Lock shows waiting text and sets reader LED to amber
+func (d *osdpDriver) Lock(conn *Connection, text string) ([]byte, error) {
+	oc, err := osdpConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	if text == "" {
+		text = "Ожидание..."
+	}
+	reader := uint8(intSetting(conn, "osdp_reader", 0))
+
+	oc.Mutex.Lock()
+	defer oc.Mutex.Unlock()
+	oc.Enqueue(osdp.OSDP_CMD_LED, osdp.LEDData(reader, 0,
+		osdp.OSDP_LED_TEMP_CANCEL, 0, 0, osdp.OSDP_COLOR_BLACK, osdp.OSDP_COLOR_BLACK, 0,
+		osdp.OSDP_LED_PERM_SET, 1000, 0, osdp.OSDP_COLOR_AMBER, osdp.OSDP_COLOR_BLACK))
+	oc.Enqueue(osdp.OSDP_CMD_TEXT, osdp.TextData(reader, osdp.OSDP_TEXT_PERM_NOWRAP, 0, 1, 1, text))
+	return nil, nil
+}
+
+// Unlock clears text and restores LED
+func (d *osdpDriver) Unlock(conn *Connection) ([]byte, error) {
+	oc, err := osdpConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	reader := uint8(intSetting(conn, "osdp_reader", 0))
+
+	oc.Mutex.Lock()
+	defer oc.Mutex.Unlock()
+	oc.Enqueue(osdp.OSDP_CMD_LED, osdp.LEDData(reader, 0,
+		osdp.OSDP_LED_TEMP_NOP, 0, 0, osdp.OSDP_COLOR_BLACK, osdp.OSDP_COLOR_BLACK, 0,
+		osdp.OSDP_LED_PERM_SET, 1000, 0, osdp.OSDP_COLOR_BLACK, osdp.OSDP_COLOR_BLACK))
+	oc.Enqueue(osdp.OSDP_CMD_TEXT, osdp.TextData(reader, osdp.OSDP_TEXT_PERM_NOWRAP, 0, 1, 1, ""))
+	return nil, nil
+}
+
+// RelayOpen pulses output relay with green LED, short beep and caption
+func (d *osdpDriver) RelayOpen(conn *Connection, uid string, caption string, timeMs int, cid string) ([]byte, error) {
+	oc, err := osdpConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	reader := uint8(intSetting(conn, "osdp_reader", 0))
+	output := uint8(intSetting(conn, "osdp_out", 0))
+	if timeMs <= 0 {
+		timeMs = MESSAGE_DENY_TIME
+	}
+
+	oc.Mutex.Lock()
+	defer oc.Mutex.Unlock()
+	oc.Enqueue(osdp.OSDP_CMD_OUT, osdp.OutputData(output, osdp.OSDP_OUT_TEMP_ON, timeMs))
+	oc.Enqueue(osdp.OSDP_CMD_LED, osdp.LEDData(reader, 0,
+		osdp.OSDP_LED_TEMP_SET, timeMs, 0, osdp.OSDP_COLOR_GREEN, osdp.OSDP_COLOR_BLACK, timeMs,
+		osdp.OSDP_LED_PERM_NOP, 0, 0, 0, 0))
+	oc.Enqueue(osdp.OSDP_CMD_BUZ, osdp.BuzzerData(reader, osdp.OSDP_BUZ_DEFAULT, 200, 0, 1))
+	if caption != "" {
+		oc.Enqueue(osdp.OSDP_CMD_TEXT, osdp.TextData(reader, osdp.OSDP_TEXT_TEMP_NOWRAP, osdpSeconds(timeMs), 1, 1, caption))
+	}
+	return nil, nil
+}
+
+// Message shows temporary text
+func (d *osdpDriver) Message(conn *Connection, text string, timeMs int) ([]byte, error) {
+	oc, err := osdpConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	reader := uint8(intSetting(conn, "osdp_reader", 0))
+
+	oc.Mutex.Lock()
+	defer oc.Mutex.Unlock()
+	oc.Enqueue(osdp.OSDP_CMD_TEXT, osdp.TextData(reader, osdp.OSDP_TEXT_TEMP_NOWRAP, osdpSeconds(timeMs), 1, 1, text))
+	return nil, nil
+}
+
+// Deny flashes red LED, beeps twice and shows deny text
+func (d *osdpDriver) Deny(conn *Connection, text string) ([]byte, error) {
+	oc, err := osdpConn(conn)
+	if err != nil {
+		return nil, err
+	}
+	reader := uint8(intSetting(conn, "osdp_reader", 0))
+
+	oc.Mutex.Lock()
+	defer oc.Mutex.Unlock()
+	oc.Enqueue(osdp.OSDP_CMD_LED, osdp.LEDData(reader, 0,
+		osdp.OSDP_LED_TEMP_SET, 300, 300, osdp.OSDP_COLOR_RED, osdp.OSDP_COLOR_BLACK, MESSAGE_DENY_TIME,
+		osdp.OSDP_LED_PERM_NOP, 0, 0, 0, 0))
+	oc.Enqueue(osdp.OSDP_CMD_BUZ, osdp.BuzzerData(reader, osdp.OSDP_BUZ_DEFAULT, 300, 200, 2))
+	if text != "" {
+		oc.Enqueue(osdp.OSDP_CMD_TEXT, osdp.TextData(reader, osdp.OSDP_TEXT_TEMP_NOWRAP, osdpSeconds(MESSAGE_DENY_TIME), 1, 1, text))
+	}
+	return nil, nil
+}
+
+// Process processes OSDP replies
+func (d *osdpDriver) Process(cp *ConnectionPool, conn *Connection) {
+	for len(conn.Buffer) > 0 {
+		n := osdp.FrameLen(conn.Buffer)
+		if n == 0 {
+			return
+		}
+		if n < 0 {
+			// Resync on next start of message
+			pos := bytes.IndexByte(conn.Buffer[1:], osdp.OSDP_SOM)
+			if pos == -1 {
+				conn.Buffer = nil
+				return
+			}
+			conn.Buffer = conn.Buffer[1+pos:]
+			continue
+		}
+
+		frame := conn.Buffer[:n]
+		conn.Buffer = conn.Buffer[n:]
+
+		packet, err := osdp.DecodePacket(frame)
+		if err != nil {
+			fmt.Printf("Failed to decode OSDP packet from %s: %v\n", conn.Key, err)
+			continue
+		}
+		d.handleReply(cp, conn, packet)
+	}
+}
+
+// handleReply handles decoded OSDP reply
+func (d *osdpDriver) handleReply(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	oc := conn.OSDPConn
+	if oc == nil {
+		return
+	}
+	if reply, _ := packet.Data["reply"].(bool); !reply {
+		return
+	}
+	if addr, _ := packet.Data["address"].(uint8); addr != oc.Address {
+		fmt.Printf("OSDP reply from unexpected address %d on %s\n", addr, conn.Key)
+		return
+	}
+	if _, ok := packet.Data["scb_type"]; ok {
+		fmt.Printf("OSDP Secure Channel reply from %s is not supported\n", conn.Key)
+	}
+
+	sqn, _ := packet.Data["sqn"].(uint8)
+
+	oc.Mutex.Lock()
+	if oc.Pending == nil || oc.Pending[4]&osdp.OSDP_CTRL_SQN_MASK != sqn {
+		oc.Mutex.Unlock()
+		fmt.Printf("OSDP unexpected reply 0x%02X (sqn %d) from %s\n", packet.Cmd, sqn, conn.Key)
+		return
+	}
+	cmd := oc.PendingCmd
+	if packet.Cmd == osdp.OSDP_REPLY_BUSY {
+		// PD asks to repeat the same command later
+		oc.Mutex.Unlock()
+		return
+	}
+	oc.Pending = nil
+	oc.Retries = 0
+	oc.LastReply = time.Now()
+	wasOnline := oc.Online
+	oc.Online = true
+	oc.Mutex.Unlock()
+
+	if !wasOnline {
+		fmt.Printf("OSDP PD online: key=%s, address=%d\n", conn.Key, oc.Address)
+	}
+
+	switch packet.Cmd {
+	case osdp.OSDP_REPLY_ACK:
+		// Command accepted
+	case osdp.OSDP_REPLY_NAK:
+		code, _ := packet.Data["error_code"].(uint8)
+		fmt.Printf("OSDP NAK from %s: command 0x%02X, error %d\n", conn.Key, cmd, code)
+		if code == osdp.OSDP_NAK_SEQ_NUM {
+			oc.Mutex.Lock()
+			oc.ResetSQN()
+			oc.Mutex.Unlock()
+		}
+	case osdp.OSDP_REPLY_PDID:
+		fmt.Printf("OSDP PD ID from %s: vendor=%v, model=%v, serial=%v, firmware=%v\n",
+			conn.Key, packet.Data["vendor"], packet.Data["model"], packet.Data["serial"], packet.Data["firmware"])
+	case osdp.OSDP_REPLY_ISTATR:
+		d.handleInputStatus(cp, conn, packet)
+	case osdp.OSDP_REPLY_RAW:
+		d.handleRawRead(cp, conn, packet)
+	case osdp.OSDP_REPLY_FMT:
+		d.handleFmtRead(cp, conn, packet)
+	case osdp.OSDP_REPLY_KEYPAD:
+		d.handleKeypad(cp, conn, packet)
+	default:
+		fmt.Printf("OSDP reply 0x%02X from %s ignored\n", packet.Cmd, conn.Key)
+	}
+}
+
+// handleRawRead handles osdp_RAW card data
+func (d *osdpDriver) handleRawRead(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	raw, _ := packet.Data["raw"].([]byte)
+	bitCount, _ := packet.Data["bit_count"].(int)
+
+	var uid string
+	if bitCount == 26 || bitCount == 34 {
+		value, err := osdp.RawBits(raw, bitCount)
+		if err != nil {
+			fmt.Printf("Invalid OSDP card data from %s: %v\n", conn.Key, err)
+			return
+		}
+		bits := strconv.FormatUint(value, 2)
+		bits = strings.Repeat("0", bitCount-len(bits)) + bits
+		cfg := wiegand.FrameConfig{
+			Format:   strconv.Itoa(bitCount),
+			Input:    wiegand.WIEGAND_IN_BIN,
+			Facility: wiegandSetting(conn, "osdp_fc", wiegand.WIEGAND_FC_KEEP),
+			UIDFmt:   wiegandSetting(conn, "osdp_uid", wiegand.WIEGAND_UID_HEX),
+		}
+		card, err := wiegand.ParseLine(bits, cfg)
+		if err != nil {
+			fmt.Printf("Invalid OSDP Wiegand data from %s: %v\n", conn.Key, err)
+			return
+		}
+		uid, _ = card.Data["uid"].(string)
+	} else {
+		n := (bitCount + 7) / 8
+		if n <= 0 || n > len(raw) {
+			n = len(raw)
+		}
+		uid = fmt.Sprintf("%X", raw[:n])
+	}
+	if uid == "" {
+		return
+	}
+
+	fmt.Printf("OSDP card read: key=%s, uid=%s, bits=%d\n", conn.Key, uid, bitCount)
+	if cp.onTagRead != nil {
+		cp.onTagRead(conn.Key, uid, 0x01, true)
+	}
+}
+
+// handleFmtRead handles osdp_FMT (ASCII) card data
+func (d *osdpDriver) handleFmtRead(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	text, _ := packet.Data["text"].(string)
+	uid := strings.ToUpper(strings.TrimSpace(text))
+	if uid == "" {
+		return
+	}
+
+	fmt.Printf("OSDP card read: key=%s, uid=%s (formatted)\n", conn.Key, uid)
+	if cp.onTagRead != nil {
+		cp.onTagRead(conn.Key, uid, 0x01, true)
+	}
+}
+
+// handleKeypad collects osdp_KEYPAD digits and reports PIN on '#' key
+// (digits are not logged)
+func (d *osdpDriver) handleKeypad(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	keys, _ := packet.Data["keys"].(string)
+
+	oc := conn.OSDPConn
+	oc.Mutex.Lock()
+	pin, ok := oc.AddKeys(keys, time.Now())
+	oc.Mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	fmt.Printf("OSDP PIN entered: key=%s, uid=%s\n", conn.Key, logUID(pin, types.READER_TYPE_PIN))
+	if cp.onTagRead != nil {
+		cp.onTagRead(conn.Key, pin, types.READER_TYPE_PIN, true)
+	}
+}
+
+// handleInputStatus reports pass when sensor input becomes active
+func (d *osdpDriver) handleInputStatus(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	status, _ := packet.Data["status"].([]byte)
+	input := intSetting(conn, "osdp_in", 0)
+	active := uint8(intSetting(conn, "osdp_in_active", 1))
+
+	oc := conn.OSDPConn
+	oc.Mutex.Lock()
+	prev := oc.Inputs
+	oc.Inputs = status
+	oc.Mutex.Unlock()
+
+	// First status only sets initial state
+	if prev == nil || input < 0 || input >= len(status) || input >= len(prev) {
+		return
+	}
+	if prev[input] == status[input] || status[input] != active {
+		return
+	}
+
+	fmt.Printf("OSDP Person passed: key=%s, input=%d\n", conn.Key, input)
+	if cp.onPassEvent != nil {
+		cp.onPassEvent(conn.Key, true)
+	}
+}
+
+// osdpConn returns OSDP state of connection
+func osdpConn(conn *Connection) (*osdp.OSDPConnection, error) {
+	if conn.OSDPConn == nil {
+		return nil, fmt.Errorf("OSDP connection not initialized: %s", conn.Key)
+	}
+	return conn.OSDPConn, nil
+}
+
+// osdpSeconds converts milliseconds to osdp_TEXT temporary time (seconds)
+func osdpSeconds(ms int) uint8 {
+	s := (ms + 999) / 1000
+	if s < 1 {
+		s = 1
+	}
+	if s > 0xFF {
+		s = 0xFF
+	}
+	return uint8(s)
+}
@@ -0,0 +1,427 @@
+package connection
+
+import (
+	"fmt"
+	"nd-go/internal/protocols/pocket"
+	"nd-go/pkg/types"
+	"nd-go/pkg/utils"
+	"time"
+)
+
+// pocketDriver implements POCKET protocol
+type pocketDriver struct{}
+
+func init() {
+	RegisterDriver(&pocketDriver{})
+}
+
+// Type returns terminal type handled by driver
+func (d *pocketDriver) Type() types.TerminalType {
+	return types.TTYPE_POCKET
+}
+
+// Detect checks for POCKET marker
+func (d *pocketDriver) Detect(data []byte) bool {
+	return len(data) >= 1 && data[0] == pocket.POCKET_MARKER
+}
+
+// Init initializes POCKET ping state
+func (d *pocketDriver) Init(cp *ConnectionPool, conn *Connection) {
+	if conn.PocketPing == nil {
+		conn.PocketPing = &PocketPingState{
+			PingInterval: 10, // Default 10 seconds
+			PingTimeout:  15, // Default 15 seconds
+			PingSent:     false,
+		}
+	}
+
+	// Push configuration profile on connect
+	if cp.config.PocketConfigOnConnect {
+		if name, profile := cp.pocketProfile(conn); profile != nil {
+			if err := cp.pushPocketConfig(conn, name, profile); err != nil {
+				fmt.Printf("Failed to push POCKET config to %s: %v\n", conn.Key, err)
+			}
+		}
+	}
+}
+
+// Ping sends Enquire packet (ping)
+func (d *pocketDriver) Ping(cp *ConnectionPool, conn *Connection, now time.Time) error {
+	pocketPing := conn.PocketPing
+	if pocketPing == nil {
+		return nil
+	}
+
+	due, err := pingDue(conn, pocketPing.PingInterval, pocketPing.PingTimeout, pocketPing.PingSent, pocketPing.LastPingTime, now)
+	if err != nil || !due {
+		return err
+	}
+
+	if err := cp.Send(conn.Key, pocket.CreateEnquirePacket()); err != nil {
+		fmt.Printf("Failed to send POCKET ping to %s: %v\n", conn.Key, err)
+		return nil
+	}
+	pocketPing.PingSent = true
+	pocketPing.PingSinceLast = 0
+	pocketPing.LastPingTime = now
+	fmt.Printf("POCKET ping (Enquire) sent to %s\n", conn.Key)
+	return nil
+}
+
+// Lock builds waiting packet
+func (d *pocketDriver) Lock(conn *Connection, text string) ([]byte, error) {
+	return pocket.CreateLockPacket(text), nil
+}
+
+// Unlock builds clear waiting packet
+func (d *pocketDriver) Unlock(conn *Connection) ([]byte, error) {
+	return pocket.CreateUnlockPacket(), nil
+}
+
+// RelayOpen builds RelayControlEx packet
+func (d *pocketDriver) RelayOpen(conn *Connection, uid string, caption string, timeMs int, cid string) ([]byte, error) {
+	return pocket.CreateRelayOnPacket(uint32(timeMs), 0, caption, uid), nil
+}
+
+// Message builds interactive packet
+func (d *pocketDriver) Message(conn *Connection, text string, timeMs int) ([]byte, error) {
+	payload := pocket.CreateInteractivePacket(text, timeMs, 1, false) // Sound 1 = beep
+	return pocket.CreatePacket(pocket.POCKET_CMD_INTERACTIVE, 0x00, payload), nil
+}
+
+// Deny builds interactive packet with error sound
+func (d *pocketDriver) Deny(conn *Connection, text string) ([]byte, error) {
+	payload := pocket.CreateInteractivePacket(text, MESSAGE_DENY_TIME, 4, true) // Sound 4 = error
+	return pocket.CreatePacket(pocket.POCKET_CMD_INTERACTIVE, 0x00, payload), nil
+}
+
+// Process processes POCKET protocol data
+func (d *pocketDriver) Process(cp *ConnectionPool, conn *Connection) {
+	// Try to find complete packet in buffer
+	for len(conn.Buffer) >= 8 {
+		// Check if we have enough data for header
+		if len(conn.Buffer) < 7 {
+			break
+		}
+
+		// Check marker
+		if conn.Buffer[0] != 0x2A {
+			// Invalid marker, skip byte
+			conn.Buffer = conn.Buffer[1:]
+			continue
+		}
+
+		// Get payload length
+		payloadLen := int(utils.DecodeUint16(conn.Buffer[3:5]))
+		packetLen := 7 + payloadLen
+
+		if len(conn.Buffer) < packetLen {
+			// Not enough data for complete packet
+			break
+		}
+
+		// Extract packet
+		packetData := conn.Buffer[:packetLen]
+		conn.Buffer = conn.Buffer[packetLen:]
+
+		// Process packet
+		d.handlePacket(cp, conn, packetData)
+	}
+}
+
+// handlePacket handles decoded POCKET packet
+func (d *pocketDriver) handlePacket(cp *ConnectionPool, conn *Connection, data []byte) {
+	packet, err := pocket.DecodePacket(data)
+	if err != nil {
+		fmt.Printf("Failed to decode POCKET packet from %s: %v\n", conn.Key, err)
+		return
+	}
+
+	fmt.Printf("POCKET packet from %s: cmd=0x%02X\n", conn.Key, packet.Cmd)
+
+	// Handle specific commands
+	switch packet.Cmd {
+	case 0x02: // ReadTag response
+		d.handleTagRead(cp, conn, packet)
+	case 0x03: // ReadTagExtended (with lockers)
+		d.handleTagReadExtended(cp, conn, packet)
+	case 0x08: // Signal (USART data, e.g. barcode scanner)
+		d.handleSignal(cp, conn, packet)
+	case 0x16: // InputChanged
+		d.handleInputChanged(cp, conn, packet)
+	case 0x15: // RelayControlEx response
+		d.handleRelayResponse(cp, conn, packet)
+	case 0x86: // Enquire response (pong)
+		d.handleEnquireResponse(cp, conn, packet)
+	case 0x85: // SetConfig response
+		d.handleConfigAck(cp, conn, packet)
+	case 0x84: // GetConfig response
+		d.handleConfigRead(cp, conn, packet)
+	}
+}
+
+// handleTagRead handles RFID/biometric tag read (ReadTag - 0x02)
+func (d *pocketDriver) handleTagRead(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	uid, ok := packet.Data["uid"].(string)
+	if !ok || uid == "" {
+		fmt.Printf("No UID in POCKET tag read from %s\n", conn.Key)
+		return
+	}
+
+	// Check if this is a MEMREG device terminal
+	if conn.Settings != nil && conn.Settings.MemRegDev != "" {
+		// Handle MEMREG device (towel/add, towel/take)
+		cp.handleMemRegDevice(conn, packet)
+		return
+	}
+
+	auth, _ := packet.Data["auth"].(bool)
+	readerType, _ := packet.Data["reader_type"].(uint8)
+
+	fmt.Printf("POCKET Tag read: key=%s, uid=%s, auth=%v, reader_type=%s\n",
+		conn.Key, logUID(uid, readerType), auth, pocket.GetReaderTypeString(readerType))
+
+	// Call event handler
+	if cp.onTagRead != nil {
+		cp.onTagRead(conn.Key, uid, readerType, auth)
+	}
+}
+
+// handleTagReadExtended handles RFID/biometric tag read with lockers (ReadTagExtended - 0x03)
+func (d *pocketDriver) handleTagReadExtended(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	uid, ok := packet.Data["uid"].(string)
+	if !ok || uid == "" {
+		fmt.Printf("No UID in POCKET tag read extended from %s\n", conn.Key)
+		return
+	}
+
+	auth, _ := packet.Data["auth"].(bool)
+
+	// Check if this is a MEMREG device terminal
+	if conn.Settings != nil && conn.Settings.MemRegDev != "" {
+		// Handle MEMREG device (towel/add, towel/take)
+		cp.handleMemRegDevice(conn, packet)
+		return
+	}
+
+	// Get lockers data and temp_card
+	var lockers []types.LockerInfo
+	var tempCard bool
+	if lockersData, ok := packet.Data["lockers_data"].([]types.LockerInfo); ok {
+		lockers = lockersData
+	}
+	if tempCardVal, ok := packet.Data["temp_card"].(bool); ok {
+		tempCard = tempCardVal
+	}
+	fmt.Printf("POCKET Tag read extended: key=%s, uid=%s, auth=%v, lockers_count=%d, temp_card=%v\n",
+		conn.Key, uid, auth, len(lockers), tempCard)
+
+	// Store lockers and temp_card in connection for later retrieval
+	// This is a workaround - ideally we'd pass lockers through event handler
+	if len(lockers) > 0 || tempCard {
+		// Store in connection settings extra data
+		if conn.Settings == nil {
+			conn.Settings = &types.TerminalSettings{}
+		}
+		if conn.Settings.Extra == nil {
+			conn.Settings.Extra = make(map[string]interface{})
+		}
+		if len(lockers) > 0 {
+			conn.Settings.Extra["last_lockers"] = lockers
+		}
+		if tempCard {
+			conn.Settings.Extra["last_temp_card"] = true
+		}
+		conn.Settings.Extra["last_uid"] = uid
+	}
+
+	// Call event handler - we'll need to pass lockers through event
+	// For now, use readerType 0 for extended read
+	if cp.onTagRead != nil {
+		cp.onTagRead(conn.Key, uid, 0, auth)
+	}
+}
+
+// handleSignal handles Signal command (0x08) - USART data (barcode scanner)
+func (d *pocketDriver) handleSignal(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	// Signal packet carries raw USART data in payload
+	// The barcode scanner sends: STX(0x02) + data + ETX(0x03) + EOT(0x04)
+	raw := packet.Payload
+
+	// Initialize USART buffer for this connection if needed
+	if conn.Settings == nil {
+		conn.Settings = &types.TerminalSettings{}
+	}
+	if conn.Settings.Extra == nil {
+		conn.Settings.Extra = make(map[string]interface{})
+	}
+
+	// Append to USART buffer
+	usartBuf, _ := conn.Settings.Extra["usart_buf"].(string)
+	usartBuf += raw
+	conn.Settings.Extra["usart_buf"] = usartBuf
+
+	// Try to parse complete barcode frames from buffer
+	d.parseBarcodeStream(cp, conn)
+}
+
+// parseBarcodeStream parses barcode frames from USART buffer
+// Frame format: STX(0x02) + data + ETX(0x03) + EOT(0x04)
+func (d *pocketDriver) parseBarcodeStream(cp *ConnectionPool, conn *Connection) {
+	usartBuf, _ := conn.Settings.Extra["usart_buf"].(string)
+	buf := []byte(usartBuf)
+
+	for {
+		// Find STX (0x02)
+		start := -1
+		for i, b := range buf {
+			if b == 0x02 {
+				start = i
+				break
+			}
+		}
+		if start == -1 {
+			break
+		}
+
+		// Find ETX+EOT (0x03 0x04) after STX
+		end := -1
+		for i := start + 1; i < len(buf)-1; i++ {
+			if buf[i] == 0x03 && buf[i+1] == 0x04 {
+				end = i
+				break
+			}
+		}
+		if end == -1 {
+			break // Incomplete frame, wait for more data
+		}
+
+		// Extract barcode data between STX and ETX
+		data := string(buf[start+1 : end])
+		buf = buf[end+2:]
+
+		if len(data) > 0 {
+			d.processBarcodeData(cp, conn, data)
+		}
+	}
+
+	conn.Settings.Extra["usart_buf"] = string(buf)
+}
+
+// processBarcodeData processes parsed barcode data
+// Format: ]<type><subtype><data>
+func (d *pocketDriver) processBarcodeData(cp *ConnectionPool, conn *Connection, rawData string) {
+	fmt.Printf("Barcode data from %s: %q\n", conn.Key, rawData)
+
+	// Validate barcode format: must start with ']' and be at least 4 chars
+	if len(rawData) < 4 || rawData[0] != ']' {
+		fmt.Printf("Invalid barcode format from %s: too short or no ] prefix\n", conn.Key)
+		return
+	}
+
+	// Validate total length (4-32 chars)
+	if len(rawData) > 32 {
+		fmt.Printf("Invalid barcode format from %s: too long (%d)\n", conn.Key, len(rawData))
+		return
+	}
+
+	// Extract data (skip ] prefix, type byte, subtype byte)
+	barcodeData := rawData[3:]
+
+	fmt.Printf("Barcode parsed from %s: data=%s\n", conn.Key, barcodeData)
+
+	if cp.onBarcodeRead != nil {
+		cp.onBarcodeRead(conn.Key, barcodeData)
+	}
+}
+
+// handleInputChanged handles input state changes (person passed)
+func (d *pocketDriver) handleInputChanged(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	passed, ok := packet.Data["passed"].(bool)
+	if !ok {
+		return
+	}
+
+	fmt.Printf("POCKET Person passed: key=%s, passed=%v\n", conn.Key, passed)
+
+	// Call event handler
+	if cp.onPassEvent != nil {
+		cp.onPassEvent(conn.Key, passed)
+	}
+}
+
+// handleRelayResponse handles relay control response
+func (d *pocketDriver) handleRelayResponse(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	fmt.Printf("POCKET Relay response from %s\n", conn.Key)
+	// Relay operation completed
+}
+
+// handleEnquireResponse handles Enquire response (pong)
+func (d *pocketDriver) handleEnquireResponse(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	// Update last activity time
+	conn.LastActivity = time.Now()
+
+	// Reset ping state if we have it
+	if conn.PocketPing != nil {
+		conn.PocketPing.PingSent = false
+		conn.PocketPing.PingSinceLast = 0
+	}
+
+	fmt.Printf("POCKET Enquire response (pong) from %s\n", conn.Key)
+}
+
+// handleConfigAck handles SetConfig response (status byte, 0 = OK)
+func (d *pocketDriver) handleConfigAck(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	status := -1
+	if len(packet.Payload) > 0 {
+		status = int(packet.Payload[0])
+	}
+
+	state := cp.pocketConfigState(conn)
+	state.Mutex.Lock()
+	state.AckTime = time.Now()
+	state.AckStatus = status
+	state.Mutex.Unlock()
+
+	if status != 0 {
+		errText := fmt.Sprintf("SetConfig rejected, status %d", status)
+		state.Mutex.Lock()
+		state.LastError = errText
+		state.Mutex.Unlock()
+
+		fmt.Printf("POCKET config rejected by %s: status %d\n", conn.Key, status)
+		cp.configEvent(conn, CONFIG_EVENT_ERROR, map[string]interface{}{"error": errText, "status": status})
+		return
+	}
+
+	fmt.Printf("POCKET config accepted by %s\n", conn.Key)
+	cp.configEvent(conn, CONFIG_EVENT_ACK, map[string]interface{}{"status": status})
+}
+
+// handleConfigRead handles GetConfig response and detects drift from pushed profile
+func (d *pocketDriver) handleConfigRead(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	readback, err := pocket.DecodeConfig([]byte(packet.Payload))
+	if err != nil {
+		fmt.Printf("Failed to decode POCKET config from %s: %v\n", conn.Key, err)
+		cp.configEvent(conn, CONFIG_EVENT_ERROR, map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	state := cp.pocketConfigState(conn)
+	state.Mutex.Lock()
+	state.Readback = readback
+	state.ReadTime = time.Now()
+	var drift map[string]interface{}
+	if state.Desired != nil {
+		drift = pocket.DiffConfig(state.Desired, readback)
+	}
+	state.Drift = drift
+	profile := state.Profile
+	state.Mutex.Unlock()
+
+	fmt.Printf("POCKET config read from %s: %d fields, drift %d\n", conn.Key, len(readback), len(drift))
+	cp.configEvent(conn, CONFIG_EVENT_READ, map[string]interface{}{"config": pocket.MaskConfig(readback)})
+	if len(drift) > 0 {
+		cp.configEvent(conn, CONFIG_EVENT_DRIFT, map[string]interface{}{"profile": profile, "drift": maskDrift(drift)})
+	}
+}
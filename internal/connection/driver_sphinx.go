@@ -0,0 +1,298 @@
+package connection
+
+import (
+	"fmt"
+	"nd-go/internal/protocols/sphinx"
+	"nd-go/pkg/types"
+	"strings"
+	"time"
+)
+
+// sphinxDriver implements SPHINX protocol.
+// Term string settings:
+//   - sphinx_events=<0|1> - subscribe to controller events (default 1)
+type sphinxDriver struct{}
+
+func init() {
+	RegisterDriver(&sphinxDriver{})
+}
+
+// Type returns terminal type handled by driver
+func (d *sphinxDriver) Type() types.TerminalType {
+	return types.TTYPE_SPHINX
+}
+
+// Detect checks for valid SPHINX text command line
+func (d *sphinxDriver) Detect(data []byte) bool {
+	pos := strings.Index(string(data), sphinx.SPHINX_DELIMITER)
+	if pos <= 0 {
+		return false
+	}
+	_, err := sphinx.DecodePacket(data[:pos])
+	return err == nil
+}
+
+// Init initializes SPHINX ping state and subscribes to controller events
+func (d *sphinxDriver) Init(cp *ConnectionPool, conn *Connection) {
+	if conn.SphinxPing == nil {
+		conn.SphinxPing = &SphinxPingState{
+			PingInterval: sphinx.SPHINX_PING_INTERVAL, // 5 seconds (as in PHP)
+			PingTimeout:  sphinx.SPHINX_PING_TIMEOUT,  // 10 seconds (as in PHP)
+			PingSent:     false,
+		}
+	}
+
+	if intSetting(conn, "sphinx_events", 1) != 0 && !conn.SphinxPing.subscribed() {
+		if err := cp.Send(conn.Key, sphinx.CreateSubscribePacket(sphinx.SPHINX_EVENT_CHANNEL)); err != nil {
+			fmt.Printf("Failed to subscribe to SPHINX events on %s: %v\n", conn.Key, err)
+			return
+		}
+		conn.SphinxPing.WaitForAnswer = sphinx.SPHINX_WAC_SUBSCRIBE
+	}
+}
+
+// Ping sends DELEGATION_START packet (ping)
+func (d *sphinxDriver) Ping(cp *ConnectionPool, conn *Connection, now time.Time) error {
+	sphinxPing := conn.SphinxPing
+	if sphinxPing == nil {
+		return nil
+	}
+
+	due, err := pingDue(conn, sphinxPing.PingInterval, sphinxPing.PingTimeout, sphinxPing.PingSent, sphinxPing.LastPingTime, now)
+	if err != nil || !due {
+		return err
+	}
+
+	if err := cp.Send(conn.Key, sphinx.CreateDelegationStartPacket()); err != nil {
+		fmt.Printf("Failed to send SPHINX ping to %s: %v\n", conn.Key, err)
+		return nil
+	}
+	sphinxPing.PingSent = true
+	sphinxPing.PingSinceLast = 0
+	sphinxPing.LastPingTime = now
+	fmt.Printf("SPHINX ping (DELEGATION_START) sent to %s\n", conn.Key)
+	return nil
+}
+
+// Lock sends nothing: pending delegation ticket keeps controller waiting,
+// further delegation requests are denied while reader is locked
+func (d *sphinxDriver) Lock(conn *Connection, text string) ([]byte, error) {
+	return nil, nil
+}
+
+// Unlock denies delegation ticket left pending by session without decision
+func (d *sphinxDriver) Unlock(conn *Connection) ([]byte, error) {
+	ticket, accessType, err := takeSphinxTicket(conn)
+	if err != nil {
+		return nil, nil
+	}
+	return sphinx.CreateDelegationDeny(ticket, accessType, "Нет ответа"), nil
+}
+
+// RelayOpen builds DELEGATION_REPLY granting pending ticket
+func (d *sphinxDriver) RelayOpen(conn *Connection, uid string, caption string, timeMs int, cid string) ([]byte, error) {
+	ticket, accessType, err := takeSphinxTicket(conn)
+	if err != nil {
+		return nil, err
+	}
+	return sphinx.CreateDelegationReply(ticket, accessType, sphinx.SPHINX_DR_GRANT), nil
+}
+
+// Message is not supported: SPHINX has no display
+func (d *sphinxDriver) Message(conn *Connection, text string, timeMs int) ([]byte, error) {
+	return nil, fmt.Errorf("SPHINX terminal can't show messages: %s", conn.Key)
+}
+
+// Deny builds DELEGATION_REPLY denying pending ticket with reason
+func (d *sphinxDriver) Deny(conn *Connection, text string) ([]byte, error) {
+	ticket, accessType, err := takeSphinxTicket(conn)
+	if err != nil {
+		return nil, err
+	}
+	return sphinx.CreateDelegationDeny(ticket, accessType, text), nil
+}
+
+// takeSphinxTicket returns pending delegation ticket and clears it
+func takeSphinxTicket(conn *Connection) (string, string, error) {
+	state := conn.SphinxPing
+	if state == nil {
+		return "", "", fmt.Errorf("no pending SPHINX delegation request: %s", conn.Key)
+	}
+	state.Mutex.Lock()
+	defer state.Mutex.Unlock()
+	ticket, accessType := state.Ticket, state.AccessType
+	if ticket == "" {
+		return "", "", fmt.Errorf("no pending SPHINX delegation request: %s", conn.Key)
+	}
+	if accessType == "" {
+		accessType = sphinx.SPHINX_APRT_NORMAL
+	}
+	state.Ticket, state.AccessType = "", ""
+	return ticket, accessType, nil
+}
+
+// SphinxAccessType returns access type of pending delegation request on
+// SPHINX terminal, "" if there is no pending request
+func (cp *ConnectionPool) SphinxAccessType(key string) string {
+	conn, err := cp.getConnected(key)
+	if err != nil || conn.SphinxPing == nil {
+		return ""
+	}
+	conn.SphinxPing.Mutex.Lock()
+	defer conn.SphinxPing.Mutex.Unlock()
+	if conn.SphinxPing.Ticket == "" {
+		return ""
+	}
+	return conn.SphinxPing.AccessType
+}
+
+// Process processes SPHINX protocol data
+func (d *sphinxDriver) Process(cp *ConnectionPool, conn *Connection) {
+	// SPHINX uses text-based protocol with \r\n delimiter
+	bufferStr := string(conn.Buffer)
+
+	// Find complete packets (ending with \r\n)
+	for {
+		delimPos := strings.Index(bufferStr, "\r\n")
+		if delimPos == -1 {
+			// No complete packet found
+			break
+		}
+
+		// Extract packet
+		packetData := []byte(bufferStr[:delimPos+2])
+		bufferStr = bufferStr[delimPos+2:]
+		conn.Buffer = []byte(bufferStr)
+
+		// Process packet
+		d.handlePacket(cp, conn, packetData)
+	}
+}
+
+// handlePacket handles decoded SPHINX packet
+func (d *sphinxDriver) handlePacket(cp *ConnectionPool, conn *Connection, data []byte) {
+	packet, err := sphinx.DecodePacket(data)
+	if err != nil {
+		fmt.Printf("Failed to decode SPHINX packet from %s: %v\n", conn.Key, err)
+		return
+	}
+
+	cmd, ok := packet.Data["command"].(string)
+	if !ok {
+		return
+	}
+
+	fmt.Printf("SPHINX packet from %s: cmd=%s\n", conn.Key, cmd)
+
+	// Handle specific commands
+	switch cmd {
+	case "DELEGATION_START": // Response to our ping
+		d.handleDelegationStartResponse(cp, conn, packet)
+	case "DELEGATION_REQUEST":
+		d.handleDelegationRequest(cp, conn, packet)
+	case "EVENT_CE":
+		d.handleEvent(cp, conn, packet)
+	case "OK":
+		// Generic OK response
+		if conn.SphinxPing != nil {
+			if conn.SphinxPing.WaitForAnswer == sphinx.SPHINX_WAC_SUBSCRIBE {
+				conn.SphinxPing.Mutex.Lock()
+				conn.SphinxPing.Subscribed = true
+				conn.SphinxPing.Mutex.Unlock()
+				fmt.Printf("SPHINX events subscribed on %s\n", conn.Key)
+			}
+			conn.SphinxPing.WaitForAnswer = sphinx.SPHINX_WAC_NONE
+			conn.SphinxPing.PingSent = false
+			conn.SphinxPing.PingSinceLast = 0
+			conn.LastActivity = time.Now()
+		}
+	case "ERROR":
+		if conn.SphinxPing != nil && conn.SphinxPing.WaitForAnswer == sphinx.SPHINX_WAC_SUBSCRIBE {
+			conn.SphinxPing.WaitForAnswer = sphinx.SPHINX_WAC_NONE
+			fmt.Printf("SPHINX events subscription failed on %s: %v\n", conn.Key, packet.Data["raw"])
+		}
+	}
+}
+
+// handleEvent handles controller event (EVENT_CE)
+func (d *sphinxDriver) handleEvent(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	params, _ := packet.Data["params"].([]string)
+	event, err := sphinx.ParseEvent(params)
+	if err != nil {
+		fmt.Printf("Failed to parse SPHINX event from %s: %v\n", conn.Key, err)
+		return
+	}
+
+	fmt.Printf("SPHINX event: key=%s, type=%s, ap=%s, uid=%s\n", conn.Key, event.Type, event.AccessPoint, event.UID)
+
+	if cp.onCtrlEvent != nil {
+		cp.onCtrlEvent(conn.Key, event)
+	}
+}
+
+// handleDelegationStartResponse handles DELEGATION_START response (pong)
+func (d *sphinxDriver) handleDelegationStartResponse(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	// Update last activity time
+	conn.LastActivity = time.Now()
+
+	// Reset ping state if we have it
+	if conn.SphinxPing != nil {
+		conn.SphinxPing.PingSent = false
+		conn.SphinxPing.PingSinceLast = 0
+	}
+
+	fmt.Printf("SPHINX DELEGATION_START response (pong) from %s\n", conn.Key)
+}
+
+// handleDelegationRequest handles DELEGATION_REQUEST command
+func (d *sphinxDriver) handleDelegationRequest(cp *ConnectionPool, conn *Connection, packet *types.Packet) {
+	params, ok := packet.Data["params"].([]string)
+	if !ok || len(params) < 2 {
+		fmt.Printf("Invalid DELEGATION_REQUEST from %s\n", conn.Key)
+		return
+	}
+
+	// Parse ticket and access type
+	ticket := params[0]
+	accessType := params[1]
+
+	fmt.Printf("SPHINX Delegation request: key=%s, ticket=%s, access_type=%s\n",
+		conn.Key, ticket, accessType)
+
+	request, err := sphinx.ParseDelegationRequest(params)
+	if err != nil {
+		fmt.Printf("Failed to parse DELEGATION_REQUEST from %s: %v\n", conn.Key, err)
+		return
+	}
+
+	uid, _ := request["uid"].(string)
+	if uid == "" {
+		uid, _ = request["uid_hex"].(string)
+	}
+	if uid == "" {
+		fmt.Printf("No UID in DELEGATION_REQUEST from %s\n", conn.Key)
+		return
+	}
+
+	// Reader is locked by pending session: deny new request right away
+	if cp.readerLock(conn) != "" {
+		fmt.Printf("SPHINX delegation %s denied: %s is locked by pending session\n", ticket, conn.Key)
+		cp.Send(conn.Key, sphinx.CreateDelegationDeny(ticket, strings.ToUpper(accessType), "Терминал занят"))
+		return
+	}
+
+	// Store pending ticket: reply (grant/deny) is sent when decision is made
+	if conn.SphinxPing == nil {
+		fmt.Printf("SPHINX delegation %s ignored: %s is not initialized\n", ticket, conn.Key)
+		return
+	}
+	conn.SphinxPing.Mutex.Lock()
+	conn.SphinxPing.Ticket = ticket
+	conn.SphinxPing.AccessType = strings.ToUpper(accessType)
+	conn.SphinxPing.Mutex.Unlock()
+
+	// Call event handler
+	if cp.onTagRead != nil {
+		cp.onTagRead(conn.Key, uid, 0x01, true)
+	}
+}
@@ -0,0 +1,151 @@
+package connection
+
+import (
+	"fmt"
+	"nd-go/internal/protocols/wiegand"
+	"nd-go/pkg/types"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// wiegandDriver implements Wiegand-to-Ethernet converters (one card read per line).
+// Term string settings:
+//   - wg_format=auto|26|34|ascii - frame format (default auto)
+//   - wg_input=bin|hex|dec       - encoding of frame value in line (default bin)
+//   - wg_fc=keep|strip|<code>    - facility code handling (default keep)
+//   - wg_uid=hex|dec             - UID output format (default hex)
+//   - wg_relay=<cmd>             - relay open command ({uid}, {time}, {text}, \r, \n, \x3A for ':')
+//   - wg_deny=<cmd>              - optional deny command (e.g. red LED/buzzer)
+//   - wg_ping=<cmd>              - optional keep-alive command
+type wiegandDriver struct{}
+
+func init() {
+	RegisterDriver(&wiegandDriver{})
+}
+
+// Type returns terminal type handled by driver
+func (d *wiegandDriver) Type() types.TerminalType {
+	return types.TTYPE_WIEGAND
+}
+
+// Detect is not supported: converter lines can't be told apart from other text
+func (d *wiegandDriver) Detect(data []byte) bool {
+	return false
+}
+
+// Init does nothing: converters keep no protocol state
+func (d *wiegandDriver) Init(cp *ConnectionPool, conn *Connection) {
+}
+
+// Ping sends configured keep-alive command (converters don't answer it)
+func (d *wiegandDriver) Ping(cp *ConnectionPool, conn *Connection, now time.Time) error {
+	cmd := wiegandSetting(conn, "wg_ping", "")
+	if cmd == "" {
+		return nil
+	}
+	if int(now.Sub(conn.LastActivity).Seconds()) < 10 {
+		return nil
+	}
+	if err := cp.Send(conn.Key, wiegand.EncodeCommand(cmd, nil)); err != nil {
+		fmt.Printf("Failed to send Wiegand ping to %s: %v\n", conn.Key, err)
+	}
+	return nil
+}
+
+// Lock is not supported by converters
+func (d *wiegandDriver) Lock(conn *Connection, text string) ([]byte, error) {
+	return nil, nil
+}
+
+// Unlock is not supported by converters
+func (d *wiegandDriver) Unlock(conn *Connection) ([]byte, error) {
+	return nil, nil
+}
+
+// RelayOpen builds configured relay command
+func (d *wiegandDriver) RelayOpen(conn *Connection, uid string, caption string, timeMs int, cid string) ([]byte, error) {
+	cmd := wiegandSetting(conn, "wg_relay", "")
+	if cmd == "" {
+		return nil, fmt.Errorf("relay command (wg_relay) not configured: %s", conn.Key)
+	}
+	return wiegand.EncodeCommand(cmd, map[string]string{
+		"uid":  uid,
+		"time": strconv.Itoa(timeMs),
+		"text": caption,
+	}), nil
+}
+
+// Message is not supported: converters have no display
+func (d *wiegandDriver) Message(conn *Connection, text string, timeMs int) ([]byte, error) {
+	return nil, nil
+}
+
+// Deny builds configured deny command (if any)
+func (d *wiegandDriver) Deny(conn *Connection, text string) ([]byte, error) {
+	cmd := wiegandSetting(conn, "wg_deny", "")
+	if cmd == "" {
+		return nil, nil
+	}
+	return wiegand.EncodeCommand(cmd, map[string]string{
+		"time": strconv.Itoa(MESSAGE_DENY_TIME),
+		"text": text,
+	}), nil
+}
+
+// Process processes converter lines
+func (d *wiegandDriver) Process(cp *ConnectionPool, conn *Connection) {
+	cfg := wiegand.FrameConfig{
+		Format:   wiegandSetting(conn, "wg_format", wiegand.WIEGAND_FMT_AUTO),
+		Input:    wiegandSetting(conn, "wg_input", wiegand.WIEGAND_IN_BIN),
+		Facility: wiegandSetting(conn, "wg_fc", wiegand.WIEGAND_FC_KEEP),
+		UIDFmt:   wiegandSetting(conn, "wg_uid", wiegand.WIEGAND_UID_HEX),
+	}
+
+	for {
+		pos := strings.Index(string(conn.Buffer), wiegand.WIEGAND_DELIMITER)
+		if pos == -1 {
+			// Guard against converters that never send line breaks
+			if len(conn.Buffer) > 256 {
+				fmt.Printf("Wiegand line too long from %s, dropping\n", conn.Key)
+				conn.Buffer = nil
+			}
+			return
+		}
+
+		line := string(conn.Buffer[:pos])
+		conn.Buffer = conn.Buffer[pos+1:]
+
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		packet, err := wiegand.ParseLine(line, cfg)
+		if err != nil {
+			fmt.Printf("Failed to parse Wiegand line from %s: %v\n", conn.Key, err)
+			continue
+		}
+
+		uid, _ := packet.Data["uid"].(string)
+		fmt.Printf("Wiegand card read: key=%s, uid=%s, format=%v\n", conn.Key, uid, packet.Data["format"])
+
+		if cp.onTagRead != nil {
+			cp.onTagRead(conn.Key, uid, 0x01, true)
+		}
+	}
+}
+
+// wiegandSetting returns term string setting as string
+func wiegandSetting(conn *Connection, key string, def string) string {
+	if conn.Settings == nil || conn.Settings.Extra == nil {
+		return def
+	}
+	switch v := conn.Settings.Extra[key].(type) {
+	case nil, bool:
+		return def
+	case string:
+		return v
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
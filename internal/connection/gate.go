@@ -0,0 +1,80 @@
+package connection
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reader terminals may be paired with separate gate terminal (e.g. Modbus I/O
+// module) through "gate=<terminal ID or IP>" term string setting. Grants for
+// the reader open the gate, pass events from the gate are reported for the reader.
+
+// gateSetting returns gate reference configured for reader connection
+func gateSetting(conn *Connection) string {
+	if conn.Settings == nil || conn.Settings.Extra == nil {
+		return ""
+	}
+	switch v := conn.Settings.Extra["gate"].(type) {
+	case nil, bool:
+		return ""
+	default:
+		return strings.TrimSpace(fmt.Sprintf("%v", v))
+	}
+}
+
+// matchesGate checks whether connection is referenced by gate setting
+func matchesGate(conn *Connection, gate string) bool {
+	if gate == "" {
+		return false
+	}
+	if conn.Key == gate {
+		return true
+	}
+	if conn.Settings == nil {
+		return false
+	}
+	return conn.Settings.ID == gate || conn.Settings.IP == gate
+}
+
+// pairedGate returns key of connected gate terminal paired with reader
+func (cp *ConnectionPool) pairedGate(conn *Connection) string {
+	gate := gateSetting(conn)
+	if gate == "" {
+		return ""
+	}
+
+	cp.mutex.RLock()
+	defer cp.mutex.RUnlock()
+
+	for key, c := range cp.connections {
+		if c != conn && c.Connected && matchesGate(c, gate) {
+			return key
+		}
+	}
+	return ""
+}
+
+// raisePassEvent reports pass event from gate terminal for every reader
+// paired with it, or for the gate itself when it has no readers
+func (cp *ConnectionPool) raisePassEvent(conn *Connection, passed bool) {
+	if cp.onPassEvent == nil {
+		return
+	}
+
+	var readers []string
+	cp.mutex.RLock()
+	for key, c := range cp.connections {
+		if c != conn && matchesGate(conn, gateSetting(c)) {
+			readers = append(readers, key)
+		}
+	}
+	cp.mutex.RUnlock()
+
+	if len(readers) == 0 {
+		cp.onPassEvent(conn.Key, passed)
+		return
+	}
+	for _, key := range readers {
+		cp.onPassEvent(key, passed)
+	}
+}
@@ -0,0 +1,177 @@
+package connection
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"nd-go/internal/protocols/jsp"
+	"nd-go/pkg/types"
+	"net"
+	"strings"
+	"time"
+)
+
+// SetTerminalResolver sets resolver of inbound terminal settings by ID or IP (1C termlist)
+func (cp *ConnectionPool) SetTerminalResolver(resolver func(id, ip string) *types.TerminalSettings) {
+	cp.resolveTerminal = resolver
+}
+
+// StartJSPListener starts listener for JSP terminals dialing in (e.g. from behind NAT).
+// Terminal is identified by source IP or by ID in its first packet.
+func (cp *ConnectionPool) StartJSPListener(addr string, port int) error {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", addr, port))
+	if err != nil {
+		return err
+	}
+	listener, err := net.ListenTCP("tcp", tcpAddr)
+	if err != nil {
+		return err
+	}
+
+	cp.mutex.Lock()
+	cp.listeners[fmt.Sprintf("jsp_%s:%d", addr, port)] = listener
+	cp.mutex.Unlock()
+
+	go cp.acceptJSPConnections(listener)
+	return nil
+}
+
+// acceptJSPConnections accepts inbound JSP terminal connections
+func (cp *ConnectionPool) acceptJSPConnections(listener *net.TCPListener) {
+	for {
+		tcpConn, err := listener.AcceptTCP()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		addr := tcpConn.RemoteAddr().(*net.TCPAddr)
+		ip := addr.IP.String()
+		connection := &Connection{
+			Key:          fmt.Sprintf("%s:%d", ip, addr.Port),
+			Conn:         tcpConn,
+			Addr:         ip,
+			Port:         addr.Port,
+			Connected:    true,
+			Inbound:      true,
+			StartTime:    time.Now(),
+			LastActivity: time.Now(),
+			Buffer:       make([]byte, 0),
+			PendingData:  make([]byte, 0),
+			JSPConn:      jsp.NewJSPConnection(),
+			Settings: &types.TerminalSettings{
+				Type:  types.TTYPE_JSP,
+				IP:    ip,
+				Port:  addr.Port,
+				Extra: make(map[string]interface{}),
+			},
+		}
+
+		// Known source IP identifies terminal right away
+		if settings := cp.resolveInbound("", ip); settings != nil {
+			cp.attachInbound(connection, settings)
+		}
+
+		cp.mutex.Lock()
+		cp.connections[connection.Key] = connection
+		cp.mutex.Unlock()
+
+		GetDriver(types.TTYPE_JSP).Init(cp, connection)
+		fmt.Printf("JSP terminal dialed in from %s (id=%s)\n", connection.Key, connection.Settings.ID)
+
+		go cp.handleConnection(connection)
+	}
+}
+
+// identifyInbound identifies inbound JSP terminal by ID from its first packet.
+// ID claim is accepted when source IP matches terminal IP from 1C termlist or
+// packet carries jsp.listener_secret; unknown terminals and rejected claims
+// are disconnected
+func (cp *ConnectionPool) identifyInbound(conn *Connection, packet map[string]interface{}) bool {
+	if !conn.Inbound || conn.Settings.ID != "" {
+		return true
+	}
+	if !conn.Connected {
+		return false
+	}
+
+	var id string
+	for _, field := range []string{"term_id", "id", "sn"} {
+		if v, ok := packet[field].(string); ok && strings.TrimSpace(v) != "" {
+			id = strings.TrimSpace(v)
+			break
+		}
+	}
+	settings := cp.resolveInbound(id, "")
+	if settings == nil {
+		cp.disconnect(conn.Key, fmt.Sprintf("unknown JSP terminal (id=%q)", id))
+		return false
+	}
+
+	secret, _ := packet["secret"].(string)
+	if err := cp.checkInboundClaim(conn, settings, secret); err != nil {
+		fmt.Printf("JSP terminal %s claim of ID %s rejected: %v\n", conn.Key, settings.ID, err)
+		cp.disconnect(conn.Key, fmt.Sprintf("JSP terminal claim rejected (id=%q)", settings.ID))
+		return false
+	}
+	cp.attachInbound(conn, settings)
+	fmt.Printf("JSP terminal %s identified as %s\n", conn.Key, settings.ID)
+	return true
+}
+
+// checkInboundClaim checks ID claim of inbound connection: claim must be
+// authenticated by shared secret (when configured) or source IP, and
+// must not take over live connection already identified as the terminal
+func (cp *ConnectionPool) checkInboundClaim(conn *Connection, settings *types.TerminalSettings, secret string) error {
+	if want := cp.config.JSPListenerSecret; want != "" {
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(want)) != 1 {
+			return fmt.Errorf("invalid secret")
+		}
+	} else if settings.IP == "" || settings.IP != conn.Addr {
+		return fmt.Errorf("source IP %s doesn't match termlist IP %q", conn.Addr, settings.IP)
+	}
+
+	cp.mutex.RLock()
+	defer cp.mutex.RUnlock()
+	for key, c := range cp.connections {
+		if key != conn.Key && c.Inbound && c.Connected && c.Settings != nil && c.Settings.ID == settings.ID {
+			return fmt.Errorf("terminal is connected from %s", key)
+		}
+	}
+	return nil
+}
+
+// resolveInbound resolves JSP terminal settings by ID or IP
+func (cp *ConnectionPool) resolveInbound(id, ip string) *types.TerminalSettings {
+	if cp.resolveTerminal == nil || (id == "" && ip == "") {
+		return nil
+	}
+	settings := cp.resolveTerminal(id, ip)
+	if settings == nil || settings.Type != types.TTYPE_JSP {
+		return nil
+	}
+	return settings
+}
+
+// attachInbound applies terminal settings to inbound connection and closes
+// stale inbound connections of the same terminal (only identified by source
+// IP or after checkInboundClaim)
+func (cp *ConnectionPool) attachInbound(conn *Connection, settings *types.TerminalSettings) {
+	conn.Settings = settings
+
+	var stale []string
+	cp.mutex.RLock()
+	for key, c := range cp.connections {
+		if key != conn.Key && c.Inbound && c.Settings != nil && c.Settings.ID == settings.ID {
+			stale = append(stale, key)
+		}
+	}
+	cp.mutex.RUnlock()
+
+	for _, key := range stale {
+		cp.disconnect(key, "terminal dialed in again")
+	}
+}
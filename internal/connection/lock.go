@@ -0,0 +1,61 @@
+package connection
+
+import (
+	"fmt"
+	"time"
+)
+
+// Default reader lock timeout (s): lock of a session that died is released
+const LOCK_DEFAULT_TIMEOUT = 30
+
+// readerLock returns ID of session holding reader lock, "" if not locked
+func (cp *ConnectionPool) readerLock(conn *Connection) string {
+	cp.mutex.RLock()
+	defer cp.mutex.RUnlock()
+	if conn.Settings == nil {
+		return ""
+	}
+	sessionID, _ := conn.Settings.Extra["reader_locked"].(string)
+	return sessionID
+}
+
+// releaseLock clears reader lock held by session once its decision (grant/deny)
+// is sent to terminal, the decision packet itself ends terminal busy state.
+// Lock of other session (or "" - decision made without session) is kept.
+func (cp *ConnectionPool) releaseLock(conn *Connection, sessionID string) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	if sessionID == "" || conn.Settings == nil {
+		return
+	}
+	if locked, _ := conn.Settings.Extra["reader_locked"].(string); locked != sessionID {
+		return
+	}
+	delete(conn.Settings.Extra, "reader_locked")
+	conn.LockedAt = time.Time{}
+}
+
+// checkLockTimeout releases reader lock held longer than lock timeout
+// (term string "lock_timeout=<s>" or timeouts.terminal_lock_timeout)
+func (cp *ConnectionPool) checkLockTimeout(conn *Connection, now time.Time) {
+	sessionID := cp.readerLock(conn)
+	if sessionID == "" {
+		return
+	}
+
+	timeout := float64(LOCK_DEFAULT_TIMEOUT)
+	if cp.config != nil && cp.config.TerminalLockTimeout > 0 {
+		timeout = cp.config.TerminalLockTimeout
+	}
+	if v := intSetting(conn, "lock_timeout", 0); v > 0 {
+		timeout = float64(v)
+	}
+	if now.Sub(conn.LockedAt) < time.Duration(timeout*float64(time.Second)) {
+		return
+	}
+
+	fmt.Printf("Reader lock of session %s on %s timed out, releasing\n", sessionID, conn.Key)
+	if err := cp.UnlockTerminal(conn.Key, sessionID); err != nil {
+		fmt.Printf("Failed to release reader lock on %s: %v\n", conn.Key, err)
+	}
+}
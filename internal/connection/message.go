@@ -0,0 +1,81 @@
+package connection
+
+import "fmt"
+
+// Default display time for deny messages (ms)
+const MESSAGE_DENY_TIME = 3000
+
+// ShowMessage shows text on terminal display regardless of terminal type
+func (cp *ConnectionPool) ShowMessage(key string, text string, timeMs int) error {
+	conn, err := cp.getConnected(key)
+	if err != nil {
+		return err
+	}
+	driver, err := connDriver(conn)
+	if err != nil {
+		return err
+	}
+	packet, err := driver.Message(conn, text, timeMs)
+	if err != nil || packet == nil {
+		return err
+	}
+	return cp.Send(key, packet)
+}
+
+// DenyAccess shows deny message on terminal and rejects pending card read,
+// reader lock is released if it is held by session sessionID
+func (cp *ConnectionPool) DenyAccess(key string, sessionID string, text string) error {
+	conn, err := cp.getConnected(key)
+	if err != nil {
+		return err
+	}
+	cp.releaseLock(conn, sessionID)
+	driver, err := connDriver(conn)
+	if err != nil {
+		return err
+	}
+	packet, err := driver.Deny(conn, text)
+	if err != nil || packet == nil {
+		return err
+	}
+	return cp.Send(key, packet)
+}
+
+// OpenRelay grants pass on terminal regardless of terminal type, reader lock
+// is released if it is held by session sessionID
+func (cp *ConnectionPool) OpenRelay(key string, sessionID string, uid string, caption string, timeMs int, cid string) error {
+	conn, err := cp.getConnected(key)
+	if err != nil {
+		return err
+	}
+	cp.releaseLock(conn, sessionID)
+
+	// Paired gate opens the door, reader only shows caption
+	if gateKey := cp.pairedGate(conn); gateKey != "" {
+		if caption != "" {
+			if err := cp.ShowMessage(key, caption, timeMs); err != nil {
+				fmt.Printf("Failed to show grant caption on %s: %v\n", key, err)
+			}
+		}
+		gate, err := cp.getConnected(gateKey)
+		if err != nil {
+			return err
+		}
+		return cp.relayOpen(gate, uid, caption, timeMs, cid)
+	}
+
+	return cp.relayOpen(conn, uid, caption, timeMs, cid)
+}
+
+// relayOpen sends relay open packet built by connection driver
+func (cp *ConnectionPool) relayOpen(conn *Connection, uid string, caption string, timeMs int, cid string) error {
+	driver, err := connDriver(conn)
+	if err != nil {
+		return err
+	}
+	packet, err := driver.RelayOpen(conn, uid, caption, timeMs, cid)
+	if err != nil || packet == nil {
+		return err
+	}
+	return cp.Send(conn.Key, packet)
+}
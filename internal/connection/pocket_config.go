@@ -0,0 +1,228 @@
+package connection
+
+import (
+	"fmt"
+	"nd-go/internal/protocols/pocket"
+	"nd-go/pkg/types"
+	"time"
+)
+
+// Terminal configuration events (recorded in terminal logs)
+const (
+	CONFIG_EVENT_PUSH  = "CONFIG_PUSH"
+	CONFIG_EVENT_ACK   = "CONFIG_ACK"
+	CONFIG_EVENT_READ  = "CONFIG_READ"
+	CONFIG_EVENT_DRIFT = "CONFIG_DRIFT"
+	CONFIG_EVENT_ERROR = "CONFIG_ERROR"
+)
+
+// PushPocketConfig pushes configuration profile to POCKET terminal and requests readback.
+// nil profile pushes profile configured for terminal.
+func (cp *ConnectionPool) PushPocketConfig(key string, profile map[string]interface{}) error {
+	conn, err := cp.getPocketConnection(key)
+	if err != nil {
+		return err
+	}
+
+	name := "api"
+	if profile == nil {
+		name, profile = cp.pocketProfile(conn)
+		if profile == nil {
+			return fmt.Errorf("no POCKET config profile for terminal: %s", key)
+		}
+	}
+	return cp.pushPocketConfig(conn, name, profile)
+}
+
+// ReadPocketConfig requests current configuration from POCKET terminal
+func (cp *ConnectionPool) ReadPocketConfig(key string) error {
+	if _, err := cp.getPocketConnection(key); err != nil {
+		return err
+	}
+	return cp.Send(key, pocket.CreateGetConfigPacket())
+}
+
+// GetPocketConfig returns configuration state of POCKET terminal (sector keys masked)
+func (cp *ConnectionPool) GetPocketConfig(key string) (map[string]interface{}, error) {
+	conn, err := cp.getPocketConnection(key)
+	if err != nil {
+		return nil, err
+	}
+
+	name, profile := cp.pocketProfile(conn)
+	result := map[string]interface{}{
+		"profile_name": name,
+		"profile":      pocket.MaskConfig(profile),
+	}
+
+	state := conn.PocketConfig
+	if state == nil {
+		return result, nil
+	}
+	state.Mutex.Lock()
+	defer state.Mutex.Unlock()
+
+	result["pushed_profile"] = state.Profile
+	result["desired"] = pocket.MaskConfig(state.Desired)
+	result["readback"] = pocket.MaskConfig(state.Readback)
+	result["drift"] = maskDrift(state.Drift)
+	result["error"] = state.LastError
+	if !state.PushTime.IsZero() {
+		result["push_time"] = state.PushTime.Unix() * 1000
+	}
+	if !state.ReadTime.IsZero() {
+		result["read_time"] = state.ReadTime.Unix() * 1000
+	}
+	return result, nil
+}
+
+// WaitPocketConfig waits until configuration pushed after since is accepted by
+// terminal and confirmed by readback. Fields the terminal doesn't report back
+// (e.g. sector keys on some firmware) are confirmed by SetConfig response only.
+func (cp *ConnectionPool) WaitPocketConfig(key string, since time.Time, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := cp.getPocketConnection(key)
+		if err != nil {
+			return err
+		}
+		state := cp.pocketConfigState(conn)
+		state.Mutex.Lock()
+		acked := !state.AckTime.Before(since)
+		status := state.AckStatus
+		read := !state.ReadTime.Before(since)
+		var drift []string
+		for field, d := range state.Drift {
+			if diff, ok := d.(map[string]interface{}); ok && diff["got"] != nil {
+				drift = append(drift, field)
+			}
+		}
+		state.Mutex.Unlock()
+
+		if acked && status != 0 {
+			return fmt.Errorf("SetConfig rejected, status %d", status)
+		}
+		if acked && read {
+			if len(drift) > 0 {
+				return fmt.Errorf("config not applied: %v", drift)
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if !acked {
+				return fmt.Errorf("no SetConfig response from %s", key)
+			}
+			return fmt.Errorf("no config readback from %s", key)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// getPocketConnection returns connected POCKET terminal connection
+func (cp *ConnectionPool) getPocketConnection(key string) (*Connection, error) {
+	conn, err := cp.getConnected(key)
+	if err != nil {
+		return nil, err
+	}
+	if conn.Settings == nil || conn.Settings.Type != types.TTYPE_POCKET {
+		return nil, fmt.Errorf("connection is not POCKET: %s", key)
+	}
+	return conn, nil
+}
+
+// pocketProfile returns configuration profile for terminal:
+// "pcfg" term string setting, then terminal ID, IP and "default"
+func (cp *ConnectionPool) pocketProfile(conn *Connection) (string, map[string]interface{}) {
+	if cp.config == nil || len(cp.config.PocketProfiles) == 0 {
+		return "", nil
+	}
+	var names []string
+	if name := wiegandSetting(conn, "pcfg", ""); name != "" {
+		names = append(names, name)
+	}
+	if conn.Settings != nil {
+		if conn.Settings.ID != "" {
+			names = append(names, conn.Settings.ID)
+		}
+		names = append(names, conn.Settings.IP)
+	}
+	names = append(names, "default")
+
+	for _, name := range names {
+		if profile, ok := cp.config.PocketProfiles[name]; ok {
+			return name, profile
+		}
+	}
+	return "", nil
+}
+
+// pushPocketConfig sends SetConfig and GetConfig packets and records push
+func (cp *ConnectionPool) pushPocketConfig(conn *Connection, name string, profile map[string]interface{}) error {
+	desired, err := pocket.NormalizeConfig(profile)
+	if err != nil {
+		cp.configEvent(conn, CONFIG_EVENT_ERROR, map[string]interface{}{"profile": name, "error": err.Error()})
+		return fmt.Errorf("invalid POCKET config profile %s: %v", name, err)
+	}
+	packet, err := pocket.CreateSetConfigPacket(profile)
+	if err != nil {
+		return err
+	}
+
+	state := cp.pocketConfigState(conn)
+	state.Mutex.Lock()
+	var changes map[string]interface{}
+	if state.Readback != nil {
+		changes = pocket.DiffConfig(desired, state.Readback)
+	}
+	state.Profile = name
+	state.Desired = desired
+	state.PushTime = time.Now()
+	state.LastError = ""
+	state.Mutex.Unlock()
+
+	if err := cp.Send(conn.Key, packet); err != nil {
+		return err
+	}
+
+	params := map[string]interface{}{"profile": name, "config": pocket.MaskConfig(desired)}
+	if changes != nil {
+		params["changes"] = maskDrift(changes)
+	}
+	cp.configEvent(conn, CONFIG_EVENT_PUSH, params)
+	fmt.Printf("POCKET config profile %s pushed to %s\n", name, conn.Key)
+
+	// Read back to detect drift
+	return cp.Send(conn.Key, pocket.CreateGetConfigPacket())
+}
+
+// pocketConfigState returns configuration state of connection, creating it
+func (cp *ConnectionPool) pocketConfigState(conn *Connection) *PocketConfigState {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	if conn.PocketConfig == nil {
+		conn.PocketConfig = &PocketConfigState{}
+	}
+	return conn.PocketConfig
+}
+
+// configEvent reports terminal configuration event
+func (cp *ConnectionPool) configEvent(conn *Connection, event string, params map[string]interface{}) {
+	if cp.onConfigEvent != nil {
+		cp.onConfigEvent(conn.Key, event, params)
+	}
+}
+
+// maskDrift hides sector keys in drift/changes map
+func maskDrift(drift map[string]interface{}) map[string]interface{} {
+	if drift == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(drift))
+	for k, v := range drift {
+		if k == "sector_keys" {
+			v = map[string]interface{}{"want": "***", "got": "***"}
+		}
+		result[k] = v
+	}
+	return result
+}
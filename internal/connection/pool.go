@@ -1,11 +1,11 @@
 package connection
 
 import (
-	"encoding/json"
 	"fmt"
-	"nd-go/internal/protocols/gat"
+	"nd-go/internal/metrics"
 	"nd-go/internal/protocols/jsp"
-	"nd-go/internal/protocols/pocket"
+	"nd-go/internal/protocols/modbus"
+	"nd-go/internal/protocols/osdp"
 	"nd-go/internal/protocols/sphinx"
 	"nd-go/pkg/types"
 	"nd-go/pkg/utils"
@@ -27,6 +27,10 @@ type ConnectionPool struct {
 	onTagRead     func(connKey, uid string, readerType uint8, auth bool)
 	onPassEvent   func(connKey string, passed bool)
 	onBarcodeRead func(connKey, data string)
+	onConfigEvent func(connKey, event string, params map[string]interface{})
+	onCtrlEvent   func(connKey string, event *sphinx.Event)
+	// Inbound terminal settings resolver (1C termlist)
+	resolveTerminal func(id, ip string) *types.TerminalSettings
 }
 
 // Connection represents a single connection
@@ -36,16 +40,21 @@ type Connection struct {
 	Addr          string
 	Port          int
 	Connected     bool
+	Inbound       bool      // Terminal dialed in to listener, not reconnected
+	LockedAt      time.Time // When reader was locked by session
 	LastActivity  time.Time
 	StartTime     time.Time
 	Settings      *types.TerminalSettings
 	Buffer        []byte
 	PendingData   []byte
-	JSPConn       *jsp.JSPConnection // JSP connection state
-	JSPRIDCounter int                // JSP Request ID counter
-	PocketPing    *PocketPingState   // POCKET ping state
-	GatPing       *GatPingState      // GAT ping state
-	SphinxPing    *SphinxPingState   // SPHINX ping state
+	JSPConn       *jsp.JSPConnection       // JSP connection state
+	JSPRIDCounter int                      // JSP Request ID counter
+	PocketPing    *PocketPingState         // POCKET ping state
+	GatPing       *GatPingState            // GAT ping state
+	SphinxPing    *SphinxPingState         // SPHINX ping state
+	OSDPConn      *osdp.OSDPConnection     // OSDP ACU state
+	ModbusConn    *modbus.ModbusConnection // Modbus TCP client state
+	PocketConfig  *PocketConfigState       // POCKET configuration state
 }
 
 // PocketPingState represents POCKET ping state
@@ -65,15 +74,43 @@ type GatPingState struct {
 	PingSinceLast int       // Seconds since last ping
 	LastPingTime  time.Time // Time when ping was sent
 	TerminalType  uint8     // Terminal type (0x01 for ACCESS, etc.)
+	Mutex         sync.Mutex
+	Address       uint8 // Terminal address of last card ident (guarded by Mutex)
 }
 
-// SphinxPingState represents SPHINX ping state
+// SphinxPingState represents SPHINX ping, subscription and delegation state
 type SphinxPingState struct {
 	PingInterval  int       // Interval in seconds
 	PingTimeout   int       // Timeout in seconds
 	PingSent      bool      // Whether ping was sent
 	PingSinceLast int       // Seconds since last ping
 	LastPingTime  time.Time // Time when ping was sent
+	WaitForAnswer int       // SPHINX_WAC_* command waiting for OK/ERROR
+	Mutex         sync.Mutex
+	Subscribed    bool   // Subscribed to controller events (guarded by Mutex)
+	Ticket        string // Pending delegation ticket (guarded by Mutex)
+	AccessType    string // Access type of pending ticket (guarded by Mutex)
+}
+
+// subscribed reports whether connection is subscribed to controller events
+func (s *SphinxPingState) subscribed() bool {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	return s.Subscribed
+}
+
+// PocketConfigState represents POCKET configuration push/readback state
+type PocketConfigState struct {
+	Mutex     sync.Mutex
+	Profile   string                 // Profile name
+	Desired   map[string]interface{} // Last pushed profile (normalized)
+	Readback  map[string]interface{} // Last configuration read from terminal
+	Drift     map[string]interface{} // Pushed fields differing in readback
+	PushTime  time.Time              // When profile was pushed
+	AckTime   time.Time              // When SetConfig response was received
+	AckStatus int                    // SetConfig response status (0 = OK)
+	ReadTime  time.Time              // When readback was received
+	LastError string                 // Last push/readback error
 }
 
 // Note: Reconnection type is now defined in types.Reconnection
@@ -99,6 +136,16 @@ func (cp *ConnectionPool) SetBarcodeHandler(onBarcodeRead func(string, string))
 	cp.onBarcodeRead = onBarcodeRead
 }
 
+// SetControllerEventHandler sets controller event (SPHINX EVENT_CE) handler
+func (cp *ConnectionPool) SetControllerEventHandler(onCtrlEvent func(string, *sphinx.Event)) {
+	cp.onCtrlEvent = onCtrlEvent
+}
+
+// SetConfigHandler sets terminal configuration event handler
+func (cp *ConnectionPool) SetConfigHandler(onConfigEvent func(string, string, map[string]interface{})) {
+	cp.onConfigEvent = onConfigEvent
+}
+
 // StartClient starts client connection
 func (cp *ConnectionPool) StartClient(addr string, port int, timeout float64, errCode *int, errStr *string) (string, error) {
 	cp.mutex.Lock()
@@ -207,43 +254,6 @@ func (cp *ConnectionPool) acceptConnections(listener *net.TCPListener) {
 			JSPRIDCounter: 0,
 		}
 
-		// Initialize JSP connection if it's JSP terminal
-		if connection.Settings != nil && connection.Settings.Type == types.TTYPE_JSP {
-			// Set auto-ping if enabled
-			if cp.config.JSPDevAutoPingEnabled {
-				connection.JSPConn.PingInterval = 10 // Default 10 seconds
-				connection.JSPConn.PingTimeout = 15  // Default 15 seconds
-			}
-		}
-
-		// Initialize POCKET ping state if it's POCKET terminal
-		if connection.Settings != nil && connection.Settings.Type == types.TTYPE_POCKET {
-			connection.PocketPing = &PocketPingState{
-				PingInterval: 10, // Default 10 seconds
-				PingTimeout:  15, // Default 15 seconds
-				PingSent:     false,
-			}
-		}
-
-		// Initialize GAT ping state if it's GAT terminal
-		if connection.Settings != nil && connection.Settings.Type == types.TTYPE_GAT {
-			connection.GatPing = &GatPingState{
-				PingInterval: 10, // Default 10 seconds
-				PingTimeout:  15, // Default 15 seconds
-				PingSent:     false,
-				TerminalType: 0x01, // Default ACCESS terminal type
-			}
-		}
-
-		// Initialize SPHINX ping state if it's SPHINX terminal
-		if connection.Settings != nil && connection.Settings.Type == types.TTYPE_SPHINX {
-			connection.SphinxPing = &SphinxPingState{
-				PingInterval: 5,  // Default 5 seconds (as in PHP)
-				PingTimeout:  10, // Default 10 seconds (as in PHP)
-				PingSent:     false,
-			}
-		}
-
 		cp.mutex.Lock()
 		cp.connections[key] = connection
 		cp.mutex.Unlock()
@@ -277,24 +287,18 @@ func (cp *ConnectionPool) handleConnection(conn *Connection) {
 
 // processData processes received data
 func (cp *ConnectionPool) processData(conn *Connection) {
-	// Process data based on terminal type
-	if conn.Settings != nil {
-		switch conn.Settings.Type {
-		case types.TTYPE_POCKET:
-			cp.processPocketData(conn)
-		case types.TTYPE_GAT:
-			cp.processGatData(conn)
-		case types.TTYPE_SPHINX:
-			cp.processSphinxData(conn)
-		case types.TTYPE_JSP:
-			cp.processJSPData(conn)
-		default:
-			fmt.Printf("Unknown terminal type for connection %s: %s\n", conn.Key, conn.Settings.Type)
-		}
-	} else {
+	if conn.Settings == nil {
 		// Unknown connection type, try to detect from data
 		cp.detectProtocol(conn)
+		return
 	}
+
+	driver, err := connDriver(conn)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	driver.Process(cp, conn)
 }
 
 // Send sends data to connection
@@ -325,6 +329,14 @@ func (cp *ConnectionPool) disconnect(key string, reason string) {
 	if conn, exists := cp.connections[key]; exists {
 		conn.Connected = false
 		conn.Conn.Close()
+		delete(cp.connections, key)
+		metrics.TerminalDisconnects.Inc(key)
+
+		// Inbound terminals dial in again themselves
+		if conn.Inbound {
+			fmt.Printf("Disconnected %s: %s\n", key, reason)
+			return
+		}
 
 		// Schedule reconnection
 		reconn := &types.Reconnection{
@@ -342,7 +354,6 @@ func (cp *ConnectionPool) disconnect(key string, reason string) {
 		}
 
 		cp.reconnections[key] = reconn
-		delete(cp.connections, key)
 	}
 
 	fmt.Printf("Disconnected %s: %s\n", key, reason)
@@ -362,15 +373,13 @@ func (cp *ConnectionPool) calculateReconnectionDelay(count int) time.Duration {
 
 // LockTerminal locks a terminal for a session (shows waiting message)
 func (cp *ConnectionPool) LockTerminal(key string, sessionID string, text string) error {
-	cp.mutex.Lock()
-	defer cp.mutex.Unlock()
-
-	conn, ok := cp.connections[key]
-	if !ok || !conn.Connected {
-		return fmt.Errorf("connection not found or not connected: %s", key)
+	conn, err := cp.getConnected(key)
+	if err != nil {
+		return err
 	}
 
 	// Set reader locked in Extra
+	cp.mutex.Lock()
 	if conn.Settings == nil {
 		conn.Settings = &types.TerminalSettings{}
 	}
@@ -378,64 +387,56 @@ func (cp *ConnectionPool) LockTerminal(key string, sessionID string, text string
 		conn.Settings.Extra = make(map[string]interface{})
 	}
 	conn.Settings.Extra["reader_locked"] = sessionID
+	conn.LockedAt = time.Now()
+	cp.mutex.Unlock()
 
 	// Send lock packet based on terminal type
-	if conn.Settings.Type == types.TTYPE_POCKET {
-		packet := pocket.CreateLockPacket(text)
-		return cp.Send(key, packet)
-	} else if conn.Settings.Type == types.TTYPE_JSP {
-		// For JSP, send message with waiting using JSP protocol
-		if text == "" {
-			text = "Ожидание..."
-		}
-		// Use JSP protocol to send message
-		if conn.JSPConn != nil {
-			packet := jsp.CreateMessagePacket(text, 0) // 0 = wait until removed
-			return cp.Send(key, packet)
-		}
-		return fmt.Errorf("JSP connection not initialized")
+	driver, err := connDriver(conn)
+	if err != nil {
+		return err
 	}
-	// For GAT/SPHINX, just mark as locked
-	return nil
+	packet, err := driver.Lock(conn, text)
+	if err != nil || packet == nil {
+		return err
+	}
+	return cp.Send(key, packet)
 }
 
 // UnlockTerminal unlocks a terminal (clears waiting message)
 func (cp *ConnectionPool) UnlockTerminal(key string, sessionID string) error {
-	cp.mutex.Lock()
-	defer cp.mutex.Unlock()
-
-	conn, ok := cp.connections[key]
-	if !ok || !conn.Connected {
-		return fmt.Errorf("connection not found or not connected: %s", key)
+	conn, err := cp.getConnected(key)
+	if err != nil {
+		return err
 	}
 
 	// Check if this session locked the terminal
+	cp.mutex.Lock()
 	if conn.Settings == nil || conn.Settings.Extra == nil {
+		cp.mutex.Unlock()
 		return fmt.Errorf("terminal not locked")
 	}
 
 	lockedSessionID, ok := conn.Settings.Extra["reader_locked"].(string)
 	if !ok || lockedSessionID != sessionID {
+		cp.mutex.Unlock()
 		return fmt.Errorf("terminal not locked by session: %s", sessionID)
 	}
 
 	// Clear reader locked
 	delete(conn.Settings.Extra, "reader_locked")
+	conn.LockedAt = time.Time{}
+	cp.mutex.Unlock()
 
 	// Send unlock packet based on terminal type
-	if conn.Settings.Type == types.TTYPE_POCKET {
-		packet := pocket.CreateUnlockPacket()
-		return cp.Send(key, packet)
-	} else if conn.Settings.Type == types.TTYPE_JSP {
-		// For JSP, send empty message to clear using JSP protocol
-		if conn.JSPConn != nil {
-			packet := jsp.CreateMessagePacket("", 0)
-			return cp.Send(key, packet)
-		}
-		return fmt.Errorf("JSP connection not initialized")
+	driver, err := connDriver(conn)
+	if err != nil {
+		return err
 	}
-	// For GAT/SPHINX, just clear lock
-	return nil
+	packet, err := driver.Unlock(conn)
+	if err != nil || packet == nil {
+		return err
+	}
+	return cp.Send(key, packet)
 }
 
 // GetConnection returns connection by key as types.Connection
@@ -491,42 +492,55 @@ func (cp *ConnectionPool) GetReconnections() map[string]*types.Reconnection {
 func (cp *ConnectionPool) IdleProc() {
 	now := time.Now()
 
-	// Process reconnections
-	cp.mutex.Lock()
-	for key, reconn := range cp.reconnections {
+	// Collect due reconnections (StartClient takes the lock itself)
+	cp.mutex.RLock()
+	var due []*types.Reconnection
+	for _, reconn := range cp.reconnections {
 		if now.After(reconn.NTime) {
-			fmt.Printf("Attempting reconnection to %s:%d\n", reconn.IP, reconn.Port)
-			// Try to reconnect
-			newKey, err := cp.StartClient(reconn.IP, reconn.Port, cp.config.TerminalConnectTimeout, nil, nil)
-			if err == nil {
-				// Restore settings if available
-				if reconn.Settings != nil {
-					if conn := cp.connections[newKey]; conn != nil {
-						conn.Settings = reconn.Settings
-					}
-				}
-				delete(cp.reconnections, key)
-				fmt.Printf("Reconnected successfully: %s\n", newKey)
-			} else {
-				reconn.Count++
-				reconn.NTime = now.Add(cp.calculateReconnectionDelay(reconn.Count))
-				fmt.Printf("Reconnection failed: %v\n", err)
+			due = append(due, reconn)
+		}
+	}
+	cp.mutex.RUnlock()
+
+	// Process reconnections
+	for _, reconn := range due {
+		fmt.Printf("Attempting reconnection to %s:%d\n", reconn.IP, reconn.Port)
+		// Try to reconnect
+		newKey, err := cp.StartClient(reconn.IP, reconn.Port, cp.config.TerminalConnectTimeout, nil, nil)
+		if err == nil {
+			// Restore settings if available
+			if reconn.Settings != nil {
+				cp.SetSettings(newKey, reconn.Settings)
 			}
+			cp.mutex.Lock()
+			delete(cp.reconnections, reconn.Key)
+			cp.mutex.Unlock()
+			metrics.TerminalReconnects.Inc(reconn.Key, "ok")
+			fmt.Printf("Reconnected successfully: %s\n", newKey)
+		} else {
+			cp.mutex.Lock()
+			reconn.Count++
+			reconn.NTime = now.Add(cp.calculateReconnectionDelay(reconn.Count))
+			cp.mutex.Unlock()
+			metrics.TerminalReconnects.Inc(reconn.Key, "fail")
+			fmt.Printf("Reconnection failed: %v\n", err)
 		}
 	}
-	cp.mutex.Unlock()
 
 	// Check for timeout connections
-	cp.mutex.Lock()
+	timeout := time.Duration(cp.config.ServiceRequestExpireTime * float64(time.Second))
+	var expired []string
+	cp.mutex.RLock()
 	for key, conn := range cp.connections {
-		if conn.Connected {
-			timeout := time.Duration(cp.config.ServiceRequestExpireTime * float64(time.Second))
-			if now.Sub(conn.LastActivity) > timeout {
-				cp.disconnect(key, "activity timeout")
-			}
+		if conn.Connected && now.Sub(conn.LastActivity) > timeout {
+			expired = append(expired, key)
 		}
 	}
-	cp.mutex.Unlock()
+	cp.mutex.RUnlock()
+
+	for _, key := range expired {
+		cp.disconnect(key, "activity timeout")
+	}
 }
 
 // DropConnection closes and removes a specific connection by key
@@ -553,6 +567,9 @@ func (cp *ConnectionPool) Close() {
 	// Close all connections
 	for _, conn := range cp.connections {
 		if conn.Connected {
+			if conn.SphinxPing != nil && conn.SphinxPing.subscribed() {
+				conn.Conn.Write(sphinx.CreateUnsubscribePacket(sphinx.SPHINX_EVENT_CHANNEL))
+			}
 			conn.Conn.Close()
 		}
 	}
@@ -571,802 +588,19 @@ func (cp *ConnectionPool) detectProtocol(conn *Connection) {
 		return
 	}
 
-	// Check for POCKET marker
-	if len(conn.Buffer) >= 1 && conn.Buffer[0] == 0x2A {
-		// Looks like POCKET protocol
-		if conn.Settings == nil {
-			conn.Settings = &types.TerminalSettings{Type: types.TTYPE_POCKET}
-		}
-		cp.processPocketData(conn)
-		return
-	}
-
-	// Check for GAT marker (could be various)
-	if len(conn.Buffer) >= 4 {
-		// Could be GAT or other protocol
-		if conn.Settings == nil {
-			conn.Settings = &types.TerminalSettings{Type: types.TTYPE_GAT}
-		}
-		cp.processGatData(conn)
-		return
-	}
-
-	// Unknown protocol
-	fmt.Printf("Unknown protocol data from %s: %x\n", conn.Key, conn.Buffer[:min(20, len(conn.Buffer))])
-	conn.Buffer = nil // Clear buffer
-}
-
-// processPocketData processes POCKET protocol data
-func (cp *ConnectionPool) processPocketData(conn *Connection) {
-	// Try to find complete packet in buffer
-	for len(conn.Buffer) >= 8 {
-		// Check if we have enough data for header
-		if len(conn.Buffer) < 7 {
-			break
-		}
-
-		// Check marker
-		if conn.Buffer[0] != 0x2A {
-			// Invalid marker, skip byte
-			conn.Buffer = conn.Buffer[1:]
-			continue
-		}
-
-		// Get payload length
-		payloadLen := int(utils.DecodeUint16(conn.Buffer[3:5]))
-		packetLen := 7 + payloadLen
-
-		if len(conn.Buffer) < packetLen {
-			// Not enough data for complete packet
-			break
-		}
-
-		// Extract packet
-		packetData := conn.Buffer[:packetLen]
-		conn.Buffer = conn.Buffer[packetLen:]
-
-		// Process packet
-		cp.handlePocketPacket(conn, packetData)
-	}
-}
-
-// handlePocketPacket handles decoded POCKET packet
-func (cp *ConnectionPool) handlePocketPacket(conn *Connection, data []byte) {
-	packet, err := pocket.DecodePacket(data)
-	if err != nil {
-		fmt.Printf("Failed to decode POCKET packet from %s: %v\n", conn.Key, err)
-		return
-	}
-
-	fmt.Printf("POCKET packet from %s: cmd=0x%02X\n", conn.Key, packet.Cmd)
-
-	// Handle specific commands
-	switch packet.Cmd {
-	case 0x02: // ReadTag response
-		cp.handlePocketTagRead(conn, packet)
-	case 0x03: // ReadTagExtended (with lockers)
-		cp.handlePocketTagReadExtended(conn, packet)
-	case 0x08: // Signal (USART data, e.g. barcode scanner)
-		cp.handlePocketSignal(conn, packet)
-	case 0x16: // InputChanged
-		cp.handlePocketInputChanged(conn, packet)
-	case 0x15: // RelayControlEx response
-		cp.handlePocketRelayResponse(conn, packet)
-	case 0x86: // Enquire response (pong)
-		cp.handlePocketEnquireResponse(conn, packet)
-	}
-}
-
-// handlePocketTagRead handles RFID/biometric tag read (ReadTag - 0x02)
-func (cp *ConnectionPool) handlePocketTagRead(conn *Connection, packet *types.Packet) {
-	uid, ok := packet.Data["uid"].(string)
-	if !ok || uid == "" {
-		fmt.Printf("No UID in POCKET tag read from %s\n", conn.Key)
-		return
-	}
-
-	// Check if this is a MEMREG device terminal
-	if conn.Settings != nil && conn.Settings.MemRegDev != "" {
-		// Handle MEMREG device (towel/add, towel/take)
-		cp.handleMemRegDevice(conn, packet)
-		return
-	}
-
-	auth, _ := packet.Data["auth"].(bool)
-	readerType, _ := packet.Data["reader_type"].(uint8)
-
-	fmt.Printf("POCKET Tag read: key=%s, uid=%s, auth=%v, reader_type=%s\n",
-		conn.Key, uid, auth, pocket.GetReaderTypeString(readerType))
-
-	// Call event handler
-	if cp.onTagRead != nil {
-		cp.onTagRead(conn.Key, uid, readerType, auth)
-	}
-}
-
-// handlePocketTagReadExtended handles RFID/biometric tag read with lockers (ReadTagExtended - 0x03)
-func (cp *ConnectionPool) handlePocketTagReadExtended(conn *Connection, packet *types.Packet) {
-	uid, ok := packet.Data["uid"].(string)
-	if !ok || uid == "" {
-		fmt.Printf("No UID in POCKET tag read extended from %s\n", conn.Key)
-		return
-	}
-
-	auth, _ := packet.Data["auth"].(bool)
-
-	// Check if this is a MEMREG device terminal
-	if conn.Settings != nil && conn.Settings.MemRegDev != "" {
-		// Handle MEMREG device (towel/add, towel/take)
-		cp.handleMemRegDevice(conn, packet)
-		return
-	}
-
-	// Get lockers data and temp_card
-	var lockers []types.LockerInfo
-	var tempCard bool
-	if lockersData, ok := packet.Data["lockers_data"].([]types.LockerInfo); ok {
-		lockers = lockersData
-	}
-	if tempCardVal, ok := packet.Data["temp_card"].(bool); ok {
-		tempCard = tempCardVal
-	}
-	fmt.Printf("POCKET Tag read extended: key=%s, uid=%s, auth=%v, lockers_count=%d, temp_card=%v\n",
-		conn.Key, uid, auth, len(lockers), tempCard)
-
-	// Store lockers and temp_card in connection for later retrieval
-	// This is a workaround - ideally we'd pass lockers through event handler
-	if len(lockers) > 0 || tempCard {
-		// Store in connection settings extra data
-		if conn.Settings == nil {
-			conn.Settings = &types.TerminalSettings{}
-		}
-		if conn.Settings.Extra == nil {
-			conn.Settings.Extra = make(map[string]interface{})
-		}
-		if len(lockers) > 0 {
-			conn.Settings.Extra["last_lockers"] = lockers
-		}
-		if tempCard {
-			conn.Settings.Extra["last_temp_card"] = true
-		}
-		conn.Settings.Extra["last_uid"] = uid
-	}
-
-	// Call event handler - we'll need to pass lockers through event
-	// For now, use readerType 0 for extended read
-	if cp.onTagRead != nil {
-		cp.onTagRead(conn.Key, uid, 0, auth)
-	}
-}
-
-// handlePocketSignal handles Signal command (0x08) - USART data (barcode scanner)
-func (cp *ConnectionPool) handlePocketSignal(conn *Connection, packet *types.Packet) {
-	// Signal packet carries raw USART data in payload
-	// The barcode scanner sends: STX(0x02) + data + ETX(0x03) + EOT(0x04)
-	raw := packet.Payload
-
-	// Initialize USART buffer for this connection if needed
-	if conn.Settings == nil {
-		conn.Settings = &types.TerminalSettings{}
-	}
-	if conn.Settings.Extra == nil {
-		conn.Settings.Extra = make(map[string]interface{})
-	}
-
-	// Append to USART buffer
-	usartBuf, _ := conn.Settings.Extra["usart_buf"].(string)
-	usartBuf += raw
-	conn.Settings.Extra["usart_buf"] = usartBuf
-
-	// Try to parse complete barcode frames from buffer
-	cp.parseBarcodeStream(conn)
-}
-
-// parseBarcodeStream parses barcode frames from USART buffer
-// Frame format: STX(0x02) + data + ETX(0x03) + EOT(0x04)
-func (cp *ConnectionPool) parseBarcodeStream(conn *Connection) {
-	usartBuf, _ := conn.Settings.Extra["usart_buf"].(string)
-	buf := []byte(usartBuf)
-
-	for {
-		// Find STX (0x02)
-		start := -1
-		for i, b := range buf {
-			if b == 0x02 {
-				start = i
-				break
-			}
-		}
-		if start == -1 {
-			break
-		}
-
-		// Find ETX+EOT (0x03 0x04) after STX
-		end := -1
-		for i := start + 1; i < len(buf)-1; i++ {
-			if buf[i] == 0x03 && buf[i+1] == 0x04 {
-				end = i
-				break
-			}
-		}
-		if end == -1 {
-			break // Incomplete frame, wait for more data
-		}
-
-		// Extract barcode data between STX and ETX
-		data := string(buf[start+1 : end])
-		buf = buf[end+2:]
-
-		if len(data) > 0 {
-			cp.processBarcodeData(conn, data)
+	driver := detectDriver(conn.Buffer)
+	if driver == nil {
+		if len(conn.Buffer) > 4096 {
+			// Unknown protocol
+			fmt.Printf("Unknown protocol data from %s: %x\n", conn.Key, conn.Buffer[:min(20, len(conn.Buffer))])
+			conn.Buffer = nil // Clear buffer
 		}
-	}
-
-	conn.Settings.Extra["usart_buf"] = string(buf)
-}
-
-// processBarcodeData processes parsed barcode data
-// Format: ]<type><subtype><data>
-func (cp *ConnectionPool) processBarcodeData(conn *Connection, rawData string) {
-	fmt.Printf("Barcode data from %s: %q\n", conn.Key, rawData)
-
-	// Validate barcode format: must start with ']' and be at least 4 chars
-	if len(rawData) < 4 || rawData[0] != ']' {
-		fmt.Printf("Invalid barcode format from %s: too short or no ] prefix\n", conn.Key)
 		return
 	}
 
-	// Validate total length (4-32 chars)
-	if len(rawData) > 32 {
-		fmt.Printf("Invalid barcode format from %s: too long (%d)\n", conn.Key, len(rawData))
-		return
-	}
-
-	// Extract data (skip ] prefix, type byte, subtype byte)
-	barcodeData := rawData[3:]
-
-	fmt.Printf("Barcode parsed from %s: data=%s\n", conn.Key, barcodeData)
-
-	if cp.onBarcodeRead != nil {
-		cp.onBarcodeRead(conn.Key, barcodeData)
-	}
-}
-
-// handlePocketInputChanged handles input state changes (person passed)
-func (cp *ConnectionPool) handlePocketInputChanged(conn *Connection, packet *types.Packet) {
-	passed, ok := packet.Data["passed"].(bool)
-	if !ok {
-		return
-	}
-
-	fmt.Printf("POCKET Person passed: key=%s, passed=%v\n", conn.Key, passed)
-
-	// Call event handler
-	if cp.onPassEvent != nil {
-		cp.onPassEvent(conn.Key, passed)
-	}
-}
-
-// handlePocketRelayResponse handles relay control response
-func (cp *ConnectionPool) handlePocketRelayResponse(conn *Connection, packet *types.Packet) {
-	fmt.Printf("POCKET Relay response from %s\n", conn.Key)
-	// Relay operation completed
-}
-
-// handlePocketEnquireResponse handles Enquire response (pong)
-func (cp *ConnectionPool) handlePocketEnquireResponse(conn *Connection, packet *types.Packet) {
-	// Update last activity time
-	conn.LastActivity = time.Now()
-
-	// Reset ping state if we have it
-	if conn.PocketPing != nil {
-		conn.PocketPing.PingSent = false
-		conn.PocketPing.PingSinceLast = 0
-	}
-
-	fmt.Printf("POCKET Enquire response (pong) from %s\n", conn.Key)
-}
-
-// processGatData processes GAT protocol data
-func (cp *ConnectionPool) processGatData(conn *Connection) {
-	// Try to find complete packet in buffer
-	for len(conn.Buffer) >= 8 {
-		// Check marker
-		if conn.Buffer[0] != 0x2A {
-			// Invalid marker, skip byte
-			conn.Buffer = conn.Buffer[1:]
-			continue
-		}
-
-		// Get payload length
-		lenLow := int(conn.Buffer[3])
-		lenHigh := int(conn.Buffer[4])
-		payloadLen := lenLow + (lenHigh << 8)
-		packetLen := 8 + payloadLen
-
-		if len(conn.Buffer) < packetLen {
-			// Not enough data for complete packet
-			break
-		}
-
-		// Extract packet
-		packetData := conn.Buffer[:packetLen]
-		conn.Buffer = conn.Buffer[packetLen:]
-
-		// Process packet
-		cp.handleGatPacket(conn, packetData)
-	}
-}
-
-// handleGatPacket handles decoded GAT packet
-func (cp *ConnectionPool) handleGatPacket(conn *Connection, data []byte) {
-	packet, err := gat.DecodePacket(data)
-	if err != nil {
-		fmt.Printf("Failed to decode GAT packet from %s: %v\n", conn.Key, err)
-		return
-	}
-
-	fmt.Printf("GAT packet from %s: cmd=0x%02X\n", conn.Key, packet.Cmd)
-
-	// Handle specific commands
-	switch packet.Cmd {
-	case 0xE5: // REQ_MASTER (ping response)
-		cp.handleGatReqMasterResponse(conn, packet)
-	case 0x80: // CARD_IDENT
-		cp.handleGatCardIdent(conn, packet)
-	}
-}
-
-// handleGatReqMasterResponse handles REQ_MASTER response (pong)
-func (cp *ConnectionPool) handleGatReqMasterResponse(conn *Connection, packet *types.Packet) {
-	// Update last activity time
-	conn.LastActivity = time.Now()
-
-	// Reset ping state if we have it
-	if conn.GatPing != nil {
-		conn.GatPing.PingSent = false
-		conn.GatPing.PingSinceLast = 0
-	}
-
-	fmt.Printf("GAT REQ_MASTER response (pong) from %s\n", conn.Key)
-}
-
-// handleGatCardIdent handles CARD_IDENT command
-func (cp *ConnectionPool) handleGatCardIdent(conn *Connection, packet *types.Packet) {
-	uidHex, ok := packet.Data["uid_hex"].(string)
-	if !ok || uidHex == "" {
-		fmt.Printf("No UID in GAT card ident from %s\n", conn.Key)
-		return
-	}
-
-	readerType, _ := packet.Data["reader_type"].(uint8)
-	terminalType, _ := packet.Data["terminal_type"].(uint8)
-
-	fmt.Printf("GAT Card ident: key=%s, uid=%s, reader_type=%d, terminal_type=%d\n",
-		conn.Key, uidHex, readerType, terminalType)
-
-	// Store GAT-specific data (solar time, price, etc.) in connection extra
-	if terminalType == gat.GAT_TTYPE_TIME {
-		if conn.Settings == nil {
-			conn.Settings = &types.TerminalSettings{}
-		}
-		if conn.Settings.Extra == nil {
-			conn.Settings.Extra = make(map[string]interface{})
-		}
-		conn.Settings.Extra["gat_terminal_type"] = terminalType
-		if solarTime, ok := packet.Data["time"].(uint16); ok {
-			conn.Settings.Extra["gat_solar_time"] = int(solarTime)
-		}
-		if price, ok := packet.Data["price"].(uint32); ok {
-			conn.Settings.Extra["gat_solar_price"] = int(price)
-		}
-		if vendor, ok := packet.Data["vendor"].(uint32); ok {
-			conn.Settings.Extra["gat_solar_vendor"] = int(vendor)
-		}
-		if ares, ok := packet.Data["access_result"].(uint8); ok {
-			conn.Settings.Extra["gat_access_result"] = int(ares)
-		}
-	}
-
-	// Call event handler
-	if cp.onTagRead != nil {
-		cp.onTagRead(conn.Key, uidHex, readerType, true)
-	}
-}
-
-// processSphinxData processes SPHINX protocol data
-func (cp *ConnectionPool) processSphinxData(conn *Connection) {
-	// SPHINX uses text-based protocol with \r\n delimiter
-	bufferStr := string(conn.Buffer)
-
-	// Find complete packets (ending with \r\n)
-	for {
-		delimPos := strings.Index(bufferStr, "\r\n")
-		if delimPos == -1 {
-			// No complete packet found
-			break
-		}
-
-		// Extract packet
-		packetData := []byte(bufferStr[:delimPos+2])
-		bufferStr = bufferStr[delimPos+2:]
-		conn.Buffer = []byte(bufferStr)
-
-		// Process packet
-		cp.handleSphinxPacket(conn, packetData)
-	}
-}
-
-// handleSphinxPacket handles decoded SPHINX packet
-func (cp *ConnectionPool) handleSphinxPacket(conn *Connection, data []byte) {
-	packet, err := sphinx.DecodePacket(data)
-	if err != nil {
-		fmt.Printf("Failed to decode SPHINX packet from %s: %v\n", conn.Key, err)
-		return
-	}
-
-	cmd, ok := packet.Data["command"].(string)
-	if !ok {
-		return
-	}
-
-	fmt.Printf("SPHINX packet from %s: cmd=%s\n", conn.Key, cmd)
-
-	// Handle specific commands
-	switch cmd {
-	case "DELEGATION_START": // Response to our ping
-		cp.handleSphinxDelegationStartResponse(conn, packet)
-	case "DELEGATION_REQUEST":
-		cp.handleSphinxDelegationRequest(conn, packet)
-	case "OK":
-		// Generic OK response
-		if conn.SphinxPing != nil {
-			conn.SphinxPing.PingSent = false
-			conn.SphinxPing.PingSinceLast = 0
-			conn.LastActivity = time.Now()
-		}
-	}
-}
-
-// handleSphinxDelegationStartResponse handles DELEGATION_START response (pong)
-func (cp *ConnectionPool) handleSphinxDelegationStartResponse(conn *Connection, packet *types.Packet) {
-	// Update last activity time
-	conn.LastActivity = time.Now()
-
-	// Reset ping state if we have it
-	if conn.SphinxPing != nil {
-		conn.SphinxPing.PingSent = false
-		conn.SphinxPing.PingSinceLast = 0
-	}
-
-	fmt.Printf("SPHINX DELEGATION_START response (pong) from %s\n", conn.Key)
-}
-
-// handleSphinxDelegationRequest handles DELEGATION_REQUEST command
-func (cp *ConnectionPool) handleSphinxDelegationRequest(conn *Connection, packet *types.Packet) {
-	params, ok := packet.Data["params"].([]string)
-	if !ok || len(params) < 2 {
-		fmt.Printf("Invalid DELEGATION_REQUEST from %s\n", conn.Key)
-		return
-	}
-
-	// Parse ticket and access type
-	ticket := params[0]
-	accessType := params[1]
-
-	fmt.Printf("SPHINX Delegation request: key=%s, ticket=%s, access_type=%s\n",
-		conn.Key, ticket, accessType)
-
-	// TODO: Process delegation request and create session
-	// For now, just log it
-}
-
-// processJSPData processes JSP protocol data
-func (cp *ConnectionPool) processJSPData(conn *Connection) {
-	if conn.JSPConn == nil {
-		conn.JSPConn = jsp.NewJSPConnection()
-	}
-
-	// Add data to JSP buffer
-	conn.JSPConn.Buffer = append(conn.JSPConn.Buffer, conn.Buffer...)
-	conn.Buffer = nil
-
-	// Try to read packets
-	for {
-		result, err := jsp.TryReadPacket(conn.JSPConn)
-		if err != nil {
-			fmt.Printf("JSP packet error from %s: %v\n", conn.Key, err)
-			break
-		}
-
-		// Check result type
-		switch v := result.(type) {
-		case bool:
-			if !v {
-				// No more packets
-				break
-			}
-			continue
-		case int:
-			// Need more data
-			return
-		case map[string]interface{}:
-			// Got packet, process it
-			cp.handleJSPPacket(conn, v)
-		default:
-			// Unknown result
-			break
-		}
-	}
-}
-
-// handleJSPPacket handles decoded JSP packet
-func (cp *ConnectionPool) handleJSPPacket(conn *Connection, packet map[string]interface{}) {
-	packetType, data, err := jsp.ProcessPacket(packet)
-	if err != nil {
-		fmt.Printf("JSP packet processing error from %s: %v\n", conn.Key, err)
-		return
-	}
-
-	fmt.Printf("JSP packet from %s: type=%s\n", conn.Key, packetType)
-
-	switch packetType {
-	case "command":
-		cp.handleJSPCommand(conn, data)
-	case "answer":
-		cp.handleJSPAnswer(conn, data)
-	default:
-		fmt.Printf("Unknown JSP packet type: %s\n", packetType)
-	}
-}
-
-// handleJSPCommand handles JSP command
-func (cp *ConnectionPool) handleJSPCommand(conn *Connection, packet map[string]interface{}) {
-	cmd, ok := packet["cmd"].(string)
-	if !ok {
-		return
-	}
-
-	cmd = strings.ToLower(strings.TrimSpace(cmd))
-
-	switch cmd {
-	case "tag_read":
-		cp.handleJSPTagRead(conn, packet)
-	case "pass_report":
-		cp.handleJSPPassReport(conn, packet)
-	case "pong":
-		// Ping response, just update activity
-		cp.handleJSPPong(conn, packet)
-	default:
-		// Unknown command, send answer if has RID
-		if rid, ok := packet["rid"].(string); ok {
-			answer, _ := jsp.AnswerRequest(rid, map[string]interface{}{"result": false, "error": "unknown command"})
-			cp.Send(conn.Key, answer)
-		}
-	}
-}
-
-// handleJSPTagRead handles JSP tag_read command
-func (cp *ConnectionPool) handleJSPTagRead(conn *Connection, packet map[string]interface{}) {
-	uid, ok := packet["uid"].(string)
-	if !ok {
-		fmt.Printf("JSP tag_read missing UID from %s\n", conn.Key)
-		return
-	}
-
-	// Transform lockers data if present
-	jsp.TransformLockersData(packet)
-
-	// Extract reader type (default to RFID)
-	readerType := uint8(0x01) // Card Reader
-	if rt, ok := packet["reader_type"].(float64); ok {
-		readerType = uint8(rt)
-	}
-
-	auth := true
-	if a, ok := packet["auth"].(bool); ok {
-		auth = a
-	}
-
-	fmt.Printf("JSP Tag read: key=%s, uid=%s, reader_type=%d, auth=%v\n",
-		conn.Key, uid, readerType, auth)
-
-	// Call event handler
-	if cp.onTagRead != nil {
-		cp.onTagRead(conn.Key, uid, readerType, auth)
-	}
-
-	// Store RID if present
-	if rid, ok := packet["rid"].(string); ok {
-		// Store in connection for later use
-		if conn.JSPConn != nil {
-			if conn.JSPConn.Requests == nil {
-				conn.JSPConn.Requests = make(map[string]*jsp.JSPRequest)
-			}
-			conn.JSPConn.Requests[rid] = &jsp.JSPRequest{
-				ID:   rid,
-				RKey: rid,
-				Cmd:  "tag_read",
-				Time: utils.GetMtf(),
-			}
-		}
-	}
-}
-
-// handleJSPPassReport handles JSP pass_report command
-func (cp *ConnectionPool) handleJSPPassReport(conn *Connection, packet map[string]interface{}) {
-	passed := true
-	if p, ok := packet["passed"].(bool); ok {
-		passed = p
-	}
-
-	fmt.Printf("JSP Pass report: key=%s, passed=%v\n", conn.Key, passed)
-
-	// Call event handler
-	if cp.onPassEvent != nil {
-		cp.onPassEvent(conn.Key, passed)
-	}
-}
-
-// handleJSPAnswer handles JSP answer packet
-func (cp *ConnectionPool) handleJSPAnswer(conn *Connection, packet map[string]interface{}) {
-	rid, ok := packet["rid"].(string)
-	if !ok {
-		return
-	}
-
-	// Find request
-	if conn.JSPConn == nil || conn.JSPConn.Requests == nil {
-		return
-	}
-
-	request, exists := conn.JSPConn.Requests[rid]
-	if !exists {
-		return
-	}
-
-	fmt.Printf("JSP Answer for request %s from %s\n", rid, conn.Key)
-
-	// Process answer based on request command
-	switch request.Cmd {
-	case "relay_open":
-		// Relay opened
-		fmt.Printf("JSP Relay opened for request %s\n", rid)
-	case "message":
-		// Message sent
-		fmt.Printf("JSP Message sent for request %s\n", rid)
-	case "ping":
-		// Ping response (pong)
-		fmt.Printf("JSP Pong received for request %s\n", rid)
-		conn.JSPConn.PingSent = false
-		conn.JSPConn.PingSinceLast = 0
-		conn.LastActivity = time.Now()
-	}
-
-	// Remove request
-	delete(conn.JSPConn.Requests, rid)
-}
-
-// handleJSPPong handles JSP pong response
-func (cp *ConnectionPool) handleJSPPong(conn *Connection, packet map[string]interface{}) {
-	conn.JSPConn.PingSent = false
-	conn.JSPConn.PingSinceLast = 0
-	conn.LastActivity = time.Now()
-	fmt.Printf("JSP Pong received from %s\n", conn.Key)
-}
-
-// SendJSPMessage sends message to JSP terminal
-func (cp *ConnectionPool) SendJSPMessage(key string, text string, timeMs int) error {
-	cp.mutex.RLock()
-	conn, exists := cp.connections[key]
-	cp.mutex.RUnlock()
-
-	if !exists || !conn.Connected {
-		return fmt.Errorf("connection not found or not connected: %s", key)
-	}
-
-	if conn.Settings == nil || conn.Settings.Type != types.TTYPE_JSP {
-		return fmt.Errorf("connection is not JSP type: %s", key)
-	}
-
-	if conn.JSPConn == nil {
-		return fmt.Errorf("JSP connection not initialized: %s", key)
-	}
-
-	packet := jsp.CreateMessagePacket(text, timeMs)
-	return cp.Send(key, packet)
-}
-
-// SendJSPRelayOpen sends relay open command to JSP terminal
-func (cp *ConnectionPool) SendJSPRelayOpen(key string, uid string, caption string, timeMs int, cid string) error {
-	cp.mutex.RLock()
-	conn, exists := cp.connections[key]
-	cp.mutex.RUnlock()
-
-	if !exists || !conn.Connected {
-		return fmt.Errorf("connection not found or not connected: %s", key)
-	}
-
-	if conn.Settings == nil || conn.Settings.Type != types.TTYPE_JSP {
-		return fmt.Errorf("connection is not JSP type: %s", key)
-	}
-
-	if conn.JSPConn == nil {
-		return fmt.Errorf("JSP connection not initialized: %s", key)
-	}
-
-	packet, err := jsp.SendRelayOpen(&conn.JSPRIDCounter, uid, caption, timeMs, cid)
-	if err != nil {
-		return err
-	}
-
-	// Store request
-	if conn.JSPConn.Requests == nil {
-		conn.JSPConn.Requests = make(map[string]*jsp.JSPRequest)
-	}
-
-	// Extract RID from packet (parse JSON)
-	var packetData map[string]interface{}
-	if err := json.Unmarshal(packet[5:len(packet)-1], &packetData); err == nil {
-		if rid, ok := packetData["rid"].(string); ok {
-			conn.JSPConn.Requests[rid] = &jsp.JSPRequest{
-				ID:   rid,
-				RKey: rid,
-				Cmd:  "relay_open",
-				Time: utils.GetMtf(),
-			}
-		}
-	}
-
-	return cp.Send(key, packet)
-}
-
-// SendJSPRelayClose sends relay close command to JSP terminal
-func (cp *ConnectionPool) SendJSPRelayClose(key string) error {
-	cp.mutex.RLock()
-	conn, exists := cp.connections[key]
-	cp.mutex.RUnlock()
-
-	if !exists || !conn.Connected {
-		return fmt.Errorf("connection not found or not connected: %s", key)
-	}
-
-	if conn.Settings == nil || conn.Settings.Type != types.TTYPE_JSP {
-		return fmt.Errorf("connection is not JSP type: %s", key)
-	}
-
-	if conn.JSPConn == nil {
-		return fmt.Errorf("JSP connection not initialized: %s", key)
-	}
-
-	packet, err := jsp.SendRelayClose(&conn.JSPRIDCounter)
-	if err != nil {
-		return err
-	}
-
-	// Store request
-	if conn.JSPConn.Requests == nil {
-		conn.JSPConn.Requests = make(map[string]*jsp.JSPRequest)
-	}
-
-	// Extract RID from packet
-	// Packet format: SOF(1) + LENGTH(4) + JSON + EOF(1)
-	var packetData map[string]interface{}
-	if len(packet) > 6 {
-		jsonData := packet[5 : len(packet)-1]
-		if err := json.Unmarshal(jsonData, &packetData); err == nil {
-		if rid, ok := packetData["rid"].(string); ok {
-			conn.JSPConn.Requests[rid] = &jsp.JSPRequest{
-				ID:   rid,
-				RKey: rid,
-				Cmd:  "relay_close",
-				Time: utils.GetMtf(),
-			}
-		}
-		}
-	}
-
-	return cp.Send(key, packet)
+	conn.Settings = &types.TerminalSettings{Type: driver.Type()}
+	driver.Init(cp, conn)
+	driver.Process(cp, conn)
 }
 
 // min returns minimum of two integers
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"nd-go/internal/metrics"
+	"nd-go/internal/tracing"
 	"nd-go/pkg/types"
+	"nd-go/pkg/utils"
 	"net/http"
 	"net/url"
 	"strings"
@@ -16,6 +19,9 @@ import (
 // CRTEventCallback is called when CRT identifies a person
 type CRTEventCallback func(terminalID string, personID string, fio string, camID string, score float64, data map[string]interface{})
 
+// CRTSessionCallback is called when person of verification-mode session request is seen
+type CRTSessionCallback func(req *SessionRequest, data map[string]interface{})
+
 // CRTClient manages Vizir video recognition integration
 type CRTClient struct {
 	config        *types.Config
@@ -28,6 +34,7 @@ type CRTClient struct {
 	personCamBan  map[string]float64                           // "cam_id_pid" -> ban expiry time
 	sessRequests  map[string]*SessionRequest                   // "cam_id_pid" -> session request
 	eventCallback CRTEventCallback
+	sessCallback  CRTSessionCallback
 	initialized   bool
 
 	// idle timers
@@ -71,6 +78,12 @@ func (c *CRTClient) SetEventCallback(cb CRTEventCallback) {
 	c.eventCallback = cb
 }
 
+// SetSessionCallback sets callback for verification-mode session requests,
+// without it session requests fire person identification callback
+func (c *CRTClient) SetSessionCallback(cb CRTSessionCallback) {
+	c.sessCallback = cb
+}
+
 // Init initializes CRT module
 func (c *CRTClient) Init() bool {
 	if !c.config.CRTServiceActive {
@@ -138,14 +151,36 @@ func (c *CRTClient) AddSessionRequest(sessionID string, camID string, pid string
 		PID:       pid,
 	}
 
-	// Check if person is already seen
-	if c.trySessionRequest(camID, pid) {
-		return "" // Already found, callback was fired
-	}
+	// Check if person is already seen (callback is fired right away)
+	c.trySessionRequest(camID, pid)
 
 	return rid
 }
 
+// CancelSessionRequest removes pending session request
+func (c *CRTClient) CancelSessionRequest(rid string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.sessRequests, rid)
+}
+
+// LastSeen returns person last seen by camera within seen_timeout, "" if none
+func (c *CRTClient) LastSeen(camID string) (string, map[string]interface{}) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	threshold := float64(time.Now().UnixMicro())/1e6 - c.config.CRTSeenTimeout
+	var lastPID string
+	var last map[string]interface{}
+	lastMtf := threshold
+	for pid, data := range c.camSeen[strings.TrimSpace(camID)] {
+		if imtf, ok := data["mtf"].(float64); ok && imtf >= lastMtf {
+			lastPID, last, lastMtf = pid, data, imtf
+		}
+	}
+	return lastPID, last
+}
+
 // trySessionRequest checks if person is already in cam_seen and fires callback
 func (c *CRTClient) trySessionRequest(camID string, pid string) bool {
 	rid := camID + "_" + pid
@@ -164,14 +199,15 @@ func (c *CRTClient) trySessionRequest(camID string, pid string) bool {
 	}
 
 	// Person found -- fire callback
-	if c.eventCallback != nil {
+	if c.sessCallback != nil {
+		c.sessCallback(req, data)
+	} else if c.eventCallback != nil {
 		termID, _ := data["term_id"].(string)
 		fio, _ := data["fio"].(string)
 		score, _ := data["score"].(float64)
 		c.eventCallback(termID, pid, fio, camID, score, data)
 	}
 
-	_ = req
 	delete(c.sessRequests, rid)
 	return true
 }
@@ -200,6 +236,35 @@ func (c *CRTClient) TryBanAfterPass(camID string, pid string) {
 	}
 }
 
+// TryFaceEntry starts cardless entry cooldown of person on all cameras (ban_cam_pid_time):
+// face shown to cameras again (e.g. photo) doesn't start new entry until it expires.
+// Returns false if person is in cooldown. Entry which doesn't end with session
+// must be cancelled by CancelFaceEntry.
+func (c *CRTClient) TryFaceEntry(pid string) bool {
+	banTime := c.config.CRTBanCamPidTime
+	if banTime <= 0 {
+		return true
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cpid := "*_" + pid
+	mtf := float64(time.Now().UnixMicro()) / 1e6
+	if c.personCamBan[cpid] > mtf {
+		return false
+	}
+	c.personCamBan[cpid] = mtf + banTime
+	return true
+}
+
+// CancelFaceEntry clears cooldown started by TryFaceEntry when no session was
+// created for person (card lookup failed), so next identification is accepted
+func (c *CRTClient) CancelFaceEntry(pid string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.personCamBan, "*_"+pid)
+}
+
 // pollCameraEvents polls Vizir API for new camera events (stage 1)
 func (c *CRTClient) pollCameraEvents() {
 	c.mutex.RLock()
@@ -225,7 +290,7 @@ func (c *CRTClient) pollCameraEvents() {
 
 	fullURL := baseURL + "?" + params.Encode()
 
-	data, err := c.doRequest(fullURL, 1)
+	data, err := c.doRequest(fullURL, 1, nil)
 	if err != nil {
 		fmt.Printf("CRT poll error: %v\n", err)
 		return
@@ -293,12 +358,19 @@ func (c *CRTClient) pollCameraEvents() {
 	}
 }
 
-// fetchMatchDetails fetches match details for a face card (stage 2)
+// fetchMatchDetails fetches match details for a face card (stage 2), face
+// identification is traced from this stage (camera event of linked camera)
 func (c *CRTClient) fetchMatchDetails(fcid int, camID string, camIDStr string, termID string, event map[string]interface{}) {
+	span := tracing.Start("crt_identify")
+	span.SetAttr("skd.cam_id", camIDStr)
+	span.SetAttr("skd.terminal_id", termID)
+	span.SetAttr("skd.face_card_id", fcid)
+	defer span.End()
+
 	matchURL := fmt.Sprintf("%sMatchDetailMessage/GetItems?criteria.parentFaceCardId=%d&criteria.orderType=1&criteria.take=1",
 		c.config.CRTServiceURL, fcid)
 
-	data, err := c.doRequest(matchURL, 2)
+	data, err := c.doRequest(matchURL, 2, span)
 	if err != nil {
 		fmt.Printf("CRT match detail error: %v\n", err)
 		return
@@ -323,15 +395,15 @@ func (c *CRTClient) fetchMatchDetails(fcid int, camID string, camIDStr string, t
 	score := getFloatValue(msg, "Score")
 
 	// Stage 3: Get person card
-	c.fetchPersonCard(cfcid, camID, camIDStr, termID, score, event, msg)
+	c.fetchPersonCard(cfcid, camID, camIDStr, termID, score, event, msg, span)
 }
 
 // fetchPersonCard fetches person card details (stage 3)
-func (c *CRTClient) fetchPersonCard(cfcid int, camID string, camIDStr string, termID string, score float64, event map[string]interface{}, match map[string]interface{}) {
+func (c *CRTClient) fetchPersonCard(cfcid int, camID string, camIDStr string, termID string, score float64, event map[string]interface{}, match map[string]interface{}, span *tracing.Span) {
 	personURL := fmt.Sprintf("%sPersonCard/GetItems?criteria.faceCardId=%d&criteria.includePersonCardPropertyValues=true&criteria.take=1",
 		c.config.CRTServiceURL, cfcid)
 
-	data, err := c.doRequest(personURL, 3)
+	data, err := c.doRequest(personURL, 3, span)
 	if err != nil {
 		fmt.Printf("CRT person card error: %v\n", err)
 		return
@@ -356,11 +428,14 @@ func (c *CRTClient) fetchPersonCard(cfcid int, camID string, camIDStr string, te
 	}
 
 	// Process identification
-	c.processIdentification(camID, camIDStr, termID, pid, fio, score, pdata)
+	span.SetID("skd.person_id", pid)
+	span.SetAttr("skd.score", score)
+	c.processIdentification(camID, camIDStr, termID, pid, fio, score, pdata, span.TraceParent())
 }
 
-// processIdentification processes identified person (like crt_process_identification in PHP)
-func (c *CRTClient) processIdentification(camID string, camIDStr string, termID string, pid string, fio string, score float64, pdata map[string]interface{}) {
+// processIdentification processes identified person (like crt_process_identification in PHP),
+// traceparent of identification trace is passed in data to continue trace by session
+func (c *CRTClient) processIdentification(camID string, camIDStr string, termID string, pid string, fio string, score float64, pdata map[string]interface{}, traceparent string) {
 	mtf := float64(time.Now().UnixMicro()) / 1e6
 
 	c.mutex.Lock()
@@ -378,6 +453,9 @@ func (c *CRTClient) processIdentification(camID string, camIDStr string, termID
 		"pdata":   pdata,
 		"mtf":     mtf,
 	}
+	if traceparent != "" {
+		identData["traceparent"] = traceparent
+	}
 	c.camSeen[camIDStr][pid] = identData
 
 	if c.personSeen[pid] == nil {
@@ -420,13 +498,27 @@ func (c *CRTClient) processIdentification(camID string, camIDStr string, termID
 	}
 }
 
-// doRequest performs HTTP request to Vizir API
-func (c *CRTClient) doRequest(path string, stage int) (interface{}, error) {
-	fullURL := fmt.Sprintf("http://%s:%d%s", c.config.CRTServiceIP, c.config.CRTServicePort, path)
+// doRequest performs HTTP request to Vizir API, traced as child of parent span
+func (c *CRTClient) doRequest(path string, stage int, parent *tracing.Span) (_ interface{}, err error) {
+	start := time.Now()
+	kind := crtRequestKind(stage)
+	span := parent.Client("crt " + kind)
+	defer func() {
+		metrics.CRTRequestDuration.Observe(time.Since(start).Seconds(), kind)
+		if err != nil {
+			metrics.CRTRequestErrors.Inc(kind)
+			span.SetError(err.Error())
+		}
+		span.End()
+	}()
+
+	host := fmt.Sprintf("%s:%d", c.config.CRTServiceIP, c.config.CRTServicePort)
+	fullURL := "http://" + host + path
+	span.SetRequest("GET", host, utils.StripQuery(path))
 
 	req, err := http.NewRequest("GET", fullURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", utils.URLErrorCause(err))
 	}
 
 	// Set host header
@@ -462,9 +554,10 @@ func (c *CRTClient) doRequest(path string, stage int) (interface{}, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
+		return nil, fmt.Errorf("request %s failed: %v", utils.StripQuery(path), utils.URLErrorCause(err))
 	}
 	defer resp.Body.Close()
+	span.SetAttr("http.response.status_code", resp.StatusCode)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -483,6 +576,19 @@ func (c *CRTClient) doRequest(path string, stage int) (interface{}, error) {
 	return result, nil
 }
 
+// crtRequestKind names request stage for metrics and spans
+func crtRequestKind(stage int) string {
+	switch stage {
+	case 1:
+		return "events"
+	case 2:
+		return "match"
+	case 3:
+		return "person"
+	}
+	return "other"
+}
+
 // idleSeen cleans up expired seen entries
 func (c *CRTClient) idleSeen(mtf float64) {
 	stmo := c.config.CRTSeenTimeout
@@ -11,6 +11,10 @@ import (
 	"time"
 )
 
+// CSV_HEADER_SUFFIX is file name suffix used when day file was started with
+// other columns (e.g. by previous version on upgrade day)
+const CSV_HEADER_SUFFIX = "_new"
+
 // CSVLogger handles CSV logging for sessions
 type CSVLogger struct {
 	logDir    string
@@ -43,6 +47,9 @@ func NewCSVLogger(logDir string) *CSVLogger {
 			"cam_cid",
 			"final_result",
 			"final_msg",
+			"mfa",
+			"stages",
+			"duration_ms",
 		},
 	}
 }
@@ -57,6 +64,9 @@ func (cl *CSVLogger) LogSession(session *types.Session, conn *types.Connection)
 	if filename == "" {
 		return fmt.Errorf("failed to get CSV filename")
 	}
+	if fileExists(filename) && !cl.headerMatches(filename) {
+		filename = cl.getCSVFilename(CSV_HEADER_SUFFIX)
+	}
 
 	// Prepare data row
 	data := cl.prepareSessionData(session, conn)
@@ -170,6 +180,13 @@ func (cl *CSVLogger) prepareSessionData(session *types.Session, conn *types.Conn
 	}
 	data["final_msg"] = nl2comma(finalMsg)
 
+	// mfa - additional factor results (e.g. "pin:OK,face:TIMEOUT")
+	data["mfa"] = session.MFASummary()
+
+	// stages - time in each stage, ms (e.g. "KPO_RESULT:312,LAST_ANSWER:4")
+	data["stages"] = session.StagesSummary()
+	data["duration_ms"] = fmt.Sprintf("%d", session.DurationMs())
+
 	return data
 }
 
@@ -257,3 +274,21 @@ func fileExists(filename string) bool {
 	return err == nil
 }
 
+// headerMatches checks that CSV file starts with header of current columns
+func (cl *CSVLogger) headerMatches(filename string) bool {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = cl.delimiter
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return false
+	}
+	return strings.Join(header, "\x00") == strings.Join(cl.keys, "\x00")
+}
+
@@ -0,0 +1,346 @@
+package daemon
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"nd-go/internal/audit"
+	"nd-go/internal/auth"
+	"nd-go/internal/handler"
+	"nd-go/pkg/protopack"
+)
+
+// Admin TCP port modes (server.mode)
+const (
+	ADMIN_MODE_AUTO      = "auto"      // detect by first byte of connection
+	ADMIN_MODE_TEXT      = "text"      // command lines, for telnet
+	ADMIN_MODE_PROTOPACK = "protopack" // protopack frames
+)
+
+// ADMIN_MAX_FRAME is the largest protopack admin request value (bytes),
+// client sending larger frame is disconnected
+const ADMIN_MAX_FRAME = 64 * 1024
+
+// Reply codes of protopack admin protocol
+const (
+	ADMIN_CODE_OK           = 200
+	ADMIN_CODE_BAD_REQUEST  = 400
+	ADMIN_CODE_UNAUTHORIZED = 401 // change without "auth" command
+	ADMIN_CODE_FORBIDDEN    = 403 // token role is not admin, or not localhost while web auth is off
+	ADMIN_CODE_NOT_FOUND    = 404 // unknown command
+	ADMIN_CODE_ERROR        = 500
+)
+
+// Admin port login command: "auth <token>", token is web API token (skd_...)
+const ADMIN_CMD_AUTH = "auth"
+
+var (
+	errAdminAuthRequired = errors.New("authentication required")
+	errAdminForbidden    = errors.New("forbidden")
+)
+
+// adminClient is admin port connection: remote address and API token given by
+// "auth" command, token is checked again on each change so revoking it takes
+// effect on open connections
+type adminClient struct {
+	source string
+	token  string
+}
+
+// serveProtopack serves protopack admin client. Each frame is one hash:
+//
+//	request: {"id": <any>, "cmd": "settings", "params": {"cmd": "set", "key": "...", "value": ...}}
+//	reply:   {"id": <same>, "code": 200, "data": <result>} or {"id": ..., "code": 4xx/500, "error": "..."}
+//
+// "cmd" may also be a whole console line ("termlist cmd=add ip=..."), its params
+// are merged under "params".
+func (d *Daemon) serveProtopack(reader *bufio.Reader, conn net.Conn, client *adminClient) {
+	for {
+		value, err := protopack.ReadValue(reader, ADMIN_MAX_FRAME)
+		if err != nil {
+			if err != io.EOF {
+				d.logger.Warn(fmt.Sprintf("Admin protopack client %s: %v", client.source, err))
+			}
+			return
+		}
+		if err := protopack.WriteValue(conn, d.protopackRequest(value, client)); err != nil {
+			return
+		}
+	}
+}
+
+// protopackRequest executes one protopack request and builds reply
+func (d *Daemon) protopackRequest(value interface{}, client *adminClient) map[string]interface{} {
+	req, ok := value.(map[string]interface{})
+	if !ok {
+		return adminReply(nil, ADMIN_CODE_BAD_REQUEST, nil, "request must be a hash")
+	}
+	id := req["id"]
+
+	name, params := handler.ParseCommand(protopack.ToString(req["cmd"]))
+	if name == "" {
+		return adminReply(id, ADMIN_CODE_BAD_REQUEST, nil, "cmd required")
+	}
+	if extra, ok := req["params"].(map[string]interface{}); ok {
+		for k, v := range extra {
+			params[strings.ToLower(k)] = v
+		}
+	}
+
+	d.logger.Info(fmt.Sprintf("Admin request from %s: id=%v, cmd=%s", client.source, id, name))
+	result, err := d.adminCall(client, name, params)
+	switch {
+	case errors.Is(err, handler.ErrUnknownCommand):
+		return adminReply(id, ADMIN_CODE_NOT_FOUND, nil, err.Error())
+	case errors.Is(err, errAdminAuthRequired):
+		return adminReply(id, ADMIN_CODE_UNAUTHORIZED, nil, err.Error())
+	case errors.Is(err, errAdminForbidden):
+		return adminReply(id, ADMIN_CODE_FORBIDDEN, nil, err.Error())
+	case err != nil:
+		return adminReply(id, ADMIN_CODE_BAD_REQUEST, nil, err.Error())
+	}
+	return adminReply(id, ADMIN_CODE_OK, protopack.Normalize(result), "")
+}
+
+// adminCall executes admin port command of client, "auth" is handled here as
+// it changes connection state
+func (d *Daemon) adminCall(client *adminClient, name string, params map[string]interface{}) (interface{}, error) {
+	if name == ADMIN_CMD_AUTH {
+		return d.adminLogin(client, params)
+	}
+	params["_source"] = client.source
+	params["_client"] = client
+	return d.handlers.Call(name, params)
+}
+
+// adminLogin handles "auth <token>" (or token=<token>): remembers API token of
+// connection, changes need token of admin role
+func (d *Daemon) adminLogin(client *adminClient, params map[string]interface{}) (interface{}, error) {
+	if d.authStore == nil {
+		return nil, fmt.Errorf("authentication is disabled (web.auth.enabled=false), changes are allowed from localhost only")
+	}
+	token := paramString(params, "token")
+	if token == "" {
+		// "auth <token>": first word is parsed as (lower case) subcommand, tokens are lower case hex
+		token = paramString(params, "cmd")
+	}
+	client.token = ""
+	user, err := d.authStore.CheckToken(token)
+	if err != nil {
+		d.logger.Warn(fmt.Sprintf("Admin port: invalid token from %s", client.source))
+		return nil, fmt.Errorf("%w: invalid token", errAdminAuthRequired)
+	}
+	client.token = token
+	d.logger.Info(fmt.Sprintf("Admin port: %s authenticated as %s (%s)", client.source, user.Username, user.Role))
+	return map[string]interface{}{"user": user.Username, "role": user.Role}, nil
+}
+
+// adminAuthorize checks that client may change terminals and settings: token of
+// admin role, or localhost client when web auth is disabled. User is stored to
+// audit entry.
+func (d *Daemon) adminAuthorize(params map[string]interface{}, e *audit.Entry) error {
+	client, _ := params["_client"].(*adminClient)
+	if client == nil {
+		return errAdminAuthRequired
+	}
+	if d.authStore == nil {
+		if ip := net.ParseIP(client.source); ip != nil && ip.IsLoopback() {
+			return nil
+		}
+		return fmt.Errorf("%w: changes are allowed from localhost only while web auth is disabled", errAdminForbidden)
+	}
+	if client.token == "" {
+		return fmt.Errorf("%w: send auth <token> first", errAdminAuthRequired)
+	}
+	user, err := d.authStore.CheckToken(client.token)
+	if err != nil {
+		client.token = ""
+		return fmt.Errorf("%w: token is revoked", errAdminAuthRequired)
+	}
+	e.User, e.Role = user.Username, user.Role
+	if !auth.RoleAllows(user.Role, auth.ROLE_ADMIN) {
+		return fmt.Errorf("%w: role %s required", errAdminForbidden, auth.ROLE_ADMIN)
+	}
+	return nil
+}
+
+// adminReply builds protopack reply
+func adminReply(id interface{}, code int, data interface{}, errText string) map[string]interface{} {
+	reply := map[string]interface{}{
+		"id":   id,
+		"code": code,
+	}
+	if errText != "" {
+		reply["error"] = errText
+	} else {
+		reply["data"] = data
+	}
+	return reply
+}
+
+// consoleChange runs change requested from admin port by authorized client and
+// records it to audit trail
+func (d *Daemon) consoleChange(params map[string]interface{}, action string, change func(e *audit.Entry) (interface{}, error)) (interface{}, error) {
+	e := &audit.Entry{
+		Time:   time.Now(),
+		Source: audit.SOURCE_TCP,
+		IP:     paramString(params, "_source"),
+		Action: action,
+		Status: http.StatusOK,
+	}
+	if err := d.adminAuthorize(params, e); err != nil {
+		e.Status = http.StatusUnauthorized
+		if errors.Is(err, errAdminForbidden) {
+			e.Status = http.StatusForbidden
+		}
+		e.Detail = err.Error()
+		d.recordAudit(e)
+		return nil, err
+	}
+	result, err := change(e)
+	if err != nil {
+		e.Status = http.StatusBadRequest
+		e.Detail = err.Error()
+	}
+	d.recordAudit(e)
+	return result, err
+}
+
+// consoleTerminals collects terminals of termlist command: protopack "terminals"
+// list of hashes, or ip=/port= params and "ip:port" arguments sharing id, type,
+// role and reg_query params
+func consoleTerminals(params map[string]interface{}) []terminalAddRequest {
+	var result []terminalAddRequest
+	if list, ok := params["terminals"].([]interface{}); ok {
+		for _, item := range list {
+			if m, ok := item.(map[string]interface{}); ok {
+				result = append(result, terminalFromParams(m))
+			}
+		}
+		return result
+	}
+
+	base := terminalFromParams(params)
+	if base.IP != "" {
+		result = append(result, base)
+	}
+	for _, arg := range consoleArgs(params) {
+		t := base
+		t.IP, t.Port = splitAddr(arg)
+		result = append(result, t)
+	}
+	return result
+}
+
+// terminalFromParams builds terminal from params, ip may be given as "ip:port"
+func terminalFromParams(params map[string]interface{}) terminalAddRequest {
+	t := terminalAddRequest{
+		IP:       paramString(params, "ip"),
+		Port:     paramInt(params, "port"),
+		ID:       paramString(params, "id"),
+		Type:     paramString(params, "type"),
+		Role:     paramString(params, "role"),
+		RegQuery: paramBool(params, "reg_query"),
+	}
+	if t.Port == 0 && strings.Contains(t.IP, ":") {
+		t.IP, t.Port = splitAddr(t.IP)
+	}
+	return t
+}
+
+// splitAddr splits "ip:port", invalid address gives zero port
+func splitAddr(addr string) (string, int) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, 0
+	}
+	n, _ := strconv.Atoi(port)
+	return host, n
+}
+
+// consoleArgs returns positional arguments of command
+func consoleArgs(params map[string]interface{}) []string {
+	switch v := params["args"].(type) {
+	case []interface{}:
+		args := make([]string, 0, len(v))
+		for _, arg := range v {
+			args = append(args, protopack.ToString(arg))
+		}
+		return args
+	case string:
+		return strings.Fields(v)
+	}
+	return nil
+}
+
+// paramString returns param as string, "" if missing
+func paramString(params map[string]interface{}, key string) string {
+	if v, ok := params[key]; ok && v != nil {
+		return protopack.ToString(v)
+	}
+	return ""
+}
+
+// paramInt returns numeric param given as number or text, 0 if missing or invalid
+func paramInt(params map[string]interface{}, key string) int {
+	if v, ok := toInt(params[key]); ok {
+		return v
+	}
+	n, _ := strconv.Atoi(paramString(params, key))
+	return n
+}
+
+// paramBool returns flag param given as bool, number or text ("1", "true")
+func paramBool(params map[string]interface{}, key string) bool {
+	if v, ok := toBool(params[key]); ok {
+		return v
+	}
+	v, _ := strconv.ParseBool(paramString(params, key))
+	return v
+}
+
+// stripTelnetIAC removes telnet negotiation sequences (IAC ...) from line
+func stripTelnetIAC(line string) string {
+	const (
+		IAC  = 0xFF
+		SB   = 0xFA
+		SE   = 0xF0
+		WILL = 0xFB
+		DONT = 0xFE
+	)
+	if strings.IndexByte(line, IAC) < 0 {
+		return line
+	}
+	data := []byte(line)
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != IAC || i+1 >= len(data) {
+			out = append(out, data[i])
+			continue
+		}
+		switch cmd := data[i+1]; {
+		case cmd == IAC:
+			out = append(out, IAC)
+			i++
+		case cmd >= WILL && cmd <= DONT:
+			i += 2
+		case cmd == SB:
+			// Skip subnegotiation up to IAC SE
+			j := i + 2
+			for j+1 < len(data) && !(data[j] == IAC && data[j+1] == SE) {
+				j++
+			}
+			i = j + 1
+		default:
+			i++
+		}
+	}
+	return string(out)
+}
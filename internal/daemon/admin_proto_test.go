@@ -0,0 +1,29 @@
+package daemon
+
+import "testing"
+
+func TestStripTelnetIAC(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"plain", "termlist list\r\n", "termlist list\r\n"},
+		{"utf-8", "settings set msg=Проход\n", "settings set msg=Проход\n"},
+		{"will/do", "\xff\xfb\x01\xff\xfd\x03help", "help"},
+		{"wont/dont", "\xff\xfc\x01\xff\xfe\x03help", "help"},
+		{"escaped IAC", "a\xff\xffb", "a\xffb"},
+		{"subnegotiation", "\xff\xfa\x18\x00xterm\xff\xf0help", "help"},
+		{"unterminated subnegotiation", "help\xff\xfa\x18\x00xterm", "help"},
+		{"two byte command", "\xff\xf1help", "help"},
+		{"trailing IAC", "help\xff", "help\xff"},
+		{"truncated option", "help\xff\xfb", "help"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripTelnetIAC(tt.line); got != tt.want {
+				t.Errorf("stripTelnetIAC(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nd-go/internal/audit"
+	"nd-go/pkg/utils"
+)
+
+type auditContextKey struct{}
+
+// requestAudit returns audit entry of changing web request; handlers fill action,
+// target and before/after values, the entry is saved after handler returns
+func requestAudit(r *http.Request) *audit.Entry {
+	if e, ok := r.Context().Value(auditContextKey{}).(*audit.Entry); ok {
+		return e
+	}
+	// Not audited request (e.g. GET): entry is discarded
+	return &audit.Entry{}
+}
+
+// recordAudit writes entry to log and audit trail
+func (d *Daemon) recordAudit(e *audit.Entry) {
+	name, role := e.User, e.Role
+	if name == "" {
+		name = "-"
+	}
+	if role == "" {
+		role = "-"
+	}
+	d.logger.Info(fmt.Sprintf("AUDIT: user=%s, role=%s, source=%s, ip=%s, action=%s, target=%s, status=%d",
+		name, role, e.Source, e.IP, e.Action, e.Target, e.Status))
+
+	if d.auditStore == nil {
+		return
+	}
+	if err := d.auditStore.Record(e); err != nil {
+		d.logger.Warn(fmt.Sprintf("Audit record failed: %v", err))
+	}
+}
+
+// cardListSnapshot returns copy of card list ("global" or "secondary")
+func (d *Daemon) cardListSnapshot(listType string) map[string]string {
+	if listType == "global" {
+		return d.cardList.GetGlobalList()
+	}
+	return d.cardList.GetSecondaryList()
+}
+
+// terminalSnapshot returns connected terminals: key -> "ip:port type id"
+func (d *Daemon) terminalSnapshot() map[string]string {
+	result := make(map[string]string)
+	for key, conn := range d.pool.GetConnections() {
+		desc := fmt.Sprintf("%s:%d", conn.Addr, conn.Port)
+		if conn.Settings != nil {
+			desc += fmt.Sprintf(" %s %s", conn.Settings.Type, conn.Settings.ID)
+		}
+		result[key] = desc
+	}
+	return result
+}
+
+// memregSnapshot returns uids of MEMREG storage with values as text
+func (d *Daemon) memregSnapshot(storage string) map[string]string {
+	result := make(map[string]string)
+	for uid, value := range utils.GetMemRegStorage().Snapshot()[storage] {
+		result[uid] = fmt.Sprintf("%v", value)
+	}
+	return result
+}
+
+// changedEntries returns only changed keys of map before and after change
+func changedEntries(before, after map[string]string) (map[string]string, map[string]string) {
+	b := make(map[string]string)
+	a := make(map[string]string)
+	for k, v := range before {
+		if av, ok := after[k]; !ok || av != v {
+			b[k] = v
+			if ok {
+				a[k] = av
+			}
+		}
+	}
+	for k, v := range after {
+		if _, ok := before[k]; !ok {
+			a[k] = v
+		}
+	}
+	return b, a
+}
+
+// auditChanges records changed entries of snapshot taken before and after handler
+func auditChanges(e *audit.Entry, before map[string]string, snapshot func() map[string]string) {
+	b, a := changedEntries(before, snapshot())
+	e.SetBefore(b)
+	e.SetAfter(a)
+}
+
+// handleAPIAudit serves audit trail (admin):
+// GET /api/audit?user=&action=&target=&source=&ip=&from=&to=&limit=&offset=&format=json|csv
+// action matches by prefix ("cardlist" - all card list changes), from/to are
+// "2006-01-02", "2006-01-02 15:04:05" (local time) or RFC3339
+func (d *Daemon) handleAPIAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if d.auditStore == nil {
+		http.Error(w, `{"error":"audit store not initialized"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := audit.Filter{
+		User:   q.Get("user"),
+		Action: q.Get("action"),
+		Target: q.Get("target"),
+		Source: q.Get("source"),
+		IP:     q.Get("ip"),
+	}
+	var err error
+	if filter.From, err = parseAuditTime(q.Get("from")); err != nil {
+		http.Error(w, `{"error":"invalid from"}`, http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseAuditTime(q.Get("to")); err != nil {
+		http.Error(w, `{"error":"invalid to"}`, http.StatusBadRequest)
+		return
+	}
+	filter.Limit, _ = strconv.Atoi(q.Get("limit"))
+	filter.Offset, _ = strconv.Atoi(q.Get("offset"))
+	if filter.Limit > 10000 {
+		filter.Limit = 10000
+	}
+
+	entries, err := d.auditStore.Query(filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	if q.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit_%s.csv"`, time.Now().Format("20060102_150405")))
+		audit.WriteCSV(w, entries)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": entries})
+}
+
+// parseAuditTime parses filter time, empty is zero time
+func parseAuditTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
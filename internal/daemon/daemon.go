@@ -1,18 +1,25 @@
 package daemon
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"nd-go/config"
+	"nd-go/internal/audit"
+	"nd-go/internal/auth"
 	"nd-go/internal/cardlist"
 	"nd-go/internal/connection"
 	"nd-go/internal/crt"
+	"nd-go/internal/face"
 	"nd-go/internal/csvlogger"
 	"nd-go/internal/gtime"
 	"nd-go/internal/handler"
 	"nd-go/internal/helios"
 	"nd-go/internal/httpclient"
+	"nd-go/internal/keyring"
 	"nd-go/internal/logging"
+	"nd-go/internal/metrics"
 	"nd-go/internal/protocols/gat"
 	"nd-go/internal/protocols/jsp"
 	"nd-go/internal/protocols/pocket"
@@ -20,18 +27,23 @@ import (
 	"nd-go/internal/session"
 	"nd-go/internal/storage"
 	"nd-go/internal/termlogs"
+	"nd-go/internal/tracing"
 	"nd-go/internal/email"
+	"nd-go/pkg/protopack"
 	"nd-go/pkg/types"
 	"nd-go/pkg/utils"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+	"unicode/utf8"
 )
 
 // Daemon represents main application daemon
@@ -43,19 +55,25 @@ type Daemon struct {
 	httpClient   *httpclient.HTTPClient
 	heliosClient *helios.HeliosClient
 	crtClient    *crt.CRTClient
+	faces        *face.Registry
 	cardList     *cardlist.CardList
 	gtimeLogger  *gtime.GTimeLogger
 	termLogs     *termlogs.TermLogs
 	sessionMgr   *session.SessionManager
 	csvLogger    *csvlogger.CSVLogger
 	storageStore *storage.SQLiteStore
+	keyring      *keyring.Keyring
+	auditStore   *audit.Store
+	authStore    *auth.Store
 	running      bool
 	mutex        sync.RWMutex
 	server       *net.TCPListener
 	webServer    *http.Server
+	webRedirect  *http.Server // HTTP -> HTTPS redirect
 	shutdownCh   chan bool
 	startTime    time.Time
-	eventCh      chan map[string]interface{} // Канал для real-time событий
+	events       *eventBroadcaster // Real-time события для подписчиков /api/events
+	faceHold     *faceEventHold    // Face results which came before session stored request ID
 }
 
 // NewDaemon creates new daemon instance with default config
@@ -122,15 +140,22 @@ func NewDaemonWithConfig(configPath string, cmdArgs map[string]string) *Daemon {
 
 	fmt.Println("Creating Helios client...")
 	heliosClient := helios.NewHeliosClient(cfg)
-	heliosClient.SetEventCallback(func(request *helios.HeliosRequest, eventType helios.HeliosEventType, data map[string]interface{}) {
-		// This will be set after daemon is created
-	})
 	fmt.Println("Helios client created")
 
 	fmt.Println("Creating CRT client...")
 	crtClient := crt.NewCRTClient(cfg)
 	fmt.Println("CRT client created")
 
+	// Face recognition providers selectable per terminal
+	faceProvider := cfg.CamServiceProvider
+	if faceProvider != face.PROVIDER_HELIOS && faceProvider != face.PROVIDER_CRT {
+		fmt.Printf("Warning: unknown face provider %q, using %s\n", faceProvider, face.PROVIDER_HELIOS)
+		faceProvider = face.PROVIDER_HELIOS
+	}
+	faces := face.NewRegistry(faceProvider)
+	faces.Register(face.NewHeliosProvider(heliosClient))
+	faces.Register(face.NewCRTProvider(crtClient))
+
 	fmt.Println("Creating term logs...")
 	termLogsStore := termlogs.NewTermLogs(cfg.LogEventCount)
 	fmt.Println("Term logs created")
@@ -150,6 +175,27 @@ func NewDaemonWithConfig(configPath string, cmdArgs map[string]string) *Daemon {
 	}
 	fmt.Println("Card list created")
 
+	fmt.Println("Creating audit store...")
+	auditStore := audit.NewStore(cfg.StorageAuditPath)
+	if err := auditStore.Open(); err != nil {
+		fmt.Printf("Warning: audit store open failed: %v\n", err)
+		auditStore = nil
+	} else {
+		fmt.Println("Audit store created")
+	}
+
+	var keyRing *keyring.Keyring
+	if cfg.PocketKeysMasterKey != "" {
+		var err error
+		keyRing, err = keyring.NewKeyring(cfg.PocketKeysFile, cfg.PocketKeysMasterKey, cfg.PocketKeySectors)
+		if err != nil {
+			fmt.Printf("Warning: POCKET keyring disabled: %v\n", err)
+		} else if err := keyRing.Load(); err != nil {
+			fmt.Printf("Warning: failed to load POCKET keyring: %v\n", err)
+			keyRing = nil
+		}
+	}
+
 	daemon := &Daemon{
 		config:       cfg,
 		pool:         pool,
@@ -158,28 +204,34 @@ func NewDaemonWithConfig(configPath string, cmdArgs map[string]string) *Daemon {
 		httpClient:   httpClient,
 		heliosClient: heliosClient,
 		crtClient:    crtClient,
+		faces:        faces,
 		cardList:     cardListMgr,
 		gtimeLogger:  gtimeLogger,
 		termLogs:     termLogsStore,
 		sessionMgr:   sessionMgr,
 		csvLogger:    csvLogger,
 		storageStore: storageStore,
+		keyring:      keyRing,
+		auditStore:   auditStore,
 		running:      false,
 		shutdownCh:   make(chan bool),
 		startTime:    time.Now(),
-		eventCh:      make(chan map[string]interface{}, 100),
+		events:       newEventBroadcaster(),
+		faceHold:     newFaceEventHold(),
 	}
 
 	// Set event handlers for connection pool
 	pool.SetEventHandlers(daemon.ProcessTagRead, daemon.ProcessPassEvent)
 	pool.SetBarcodeHandler(daemon.ProcessBarcodeRead)
+	pool.SetConfigHandler(daemon.ProcessConfigEvent)
+	pool.SetControllerEventHandler(daemon.ProcessControllerEvent)
+	pool.SetTerminalResolver(daemon.resolveTerminal)
 
-	// Set Helios event callback and client
-	heliosClient.SetEventCallback(daemon.handleHeliosEvent)
-	sessionMgr.SetHeliosClient(heliosClient)
+	// Set face provider event handler
+	faces.Subscribe(daemon.handleFaceEvent)
+	sessionMgr.SetFaceProviders(faces)
 
-	// Set CRT event callback and initialize
-	crtClient.SetEventCallback(daemon.handleCRTIdentification)
+	// Initialize CRT
 	if cfg.CRTServiceActive {
 		if crtClient.Init() {
 			fmt.Println("CRT (Vizir) service initialized")
@@ -188,6 +240,8 @@ func NewDaemonWithConfig(configPath string, cmdArgs map[string]string) *Daemon {
 		}
 	}
 
+	daemon.registerMetrics()
+
 	return daemon
 }
 
@@ -207,6 +261,9 @@ func (d *Daemon) Start() error {
 	// Initialize handlers
 	d.initHandlers()
 
+	// Start export of session traces
+	d.startTracing()
+
 	// Start TCP server for commands
 	if err := d.startServer(); err != nil {
 		return fmt.Errorf("failed to start server: %v", err)
@@ -214,12 +271,20 @@ func (d *Daemon) Start() error {
 
 	d.logger.Info(fmt.Sprintf("Server started on %s:%d", d.config.ServerAddr, d.config.ServerPort))
 
+	// Start listener for JSP terminals dialing in
+	if port := d.jspListenerPort(); port > 0 {
+		if err := d.pool.StartJSPListener(d.config.ServerAddr, port); err != nil {
+			return fmt.Errorf("failed to start JSP listener: %v", err)
+		}
+		d.logger.Info(fmt.Sprintf("JSP listener started on %s:%d", d.config.ServerAddr, port))
+	}
+
 	// Start web server if enabled
 	if d.config.WebEnabled {
 		if err := d.startWebServer(); err != nil {
 			return fmt.Errorf("failed to start web server: %v", err)
 		}
-		d.logger.Info(fmt.Sprintf("Web server started on %s:%d", d.config.WebAddr, d.config.WebPort))
+		d.logger.Info(fmt.Sprintf("Web server started on %s://%s:%d", d.webScheme(), d.config.WebAddr, d.config.WebPort))
 	}
 
 	// Handle signals
@@ -246,11 +311,21 @@ func (d *Daemon) Stop() {
 	if d.webServer != nil {
 		d.webServer.Close()
 	}
+	if d.webRedirect != nil {
+		d.webRedirect.Close()
+	}
+	if d.authStore != nil {
+		d.authStore.Close()
+	}
+	if d.auditStore != nil {
+		d.auditStore.Close()
+	}
 
 	d.pool.Close()
 	if d.storageStore != nil {
 		d.storageStore.Close()
 	}
+	tracing.Shutdown()
 	d.logger.Close()
 
 	close(d.shutdownCh)
@@ -291,17 +366,8 @@ func (d *Daemon) idleProc() {
 	// Process active sessions
 	d.processSessions()
 
-	// Process JSP auto-ping
-	d.processJSPAutoPing()
-
-	// Process POCKET auto-ping
-	d.processPocketAutoPing()
-
-	// Process GAT auto-ping
-	d.processGatAutoPing()
-
-	// Process SPHINX auto-ping
-	d.processSphinxAutoPing()
+	// Process terminal auto-ping (per protocol driver)
+	d.pool.PingProc()
 
 	// Process CRT (Vizir) polling
 	if d.crtClient != nil {
@@ -370,192 +436,6 @@ func getStr(m map[string]string, k string) string {
 	return ""
 }
 
-// processJSPAutoPing processes JSP auto-ping for connections
-func (d *Daemon) processJSPAutoPing() {
-	now := time.Now()
-	connections := d.pool.GetConnections()
-
-	for key, conn := range connections {
-		if conn.Settings == nil || conn.Settings.Type != types.TTYPE_JSP {
-			continue
-		}
-
-		if conn.JSPConn == nil {
-			continue
-		}
-
-		jspConn := conn.JSPConn
-
-		// Check ping timeout
-		if jspConn.PingSent && jspConn.PingTimeout > 0 {
-			elapsed := int(now.Sub(conn.LastActivity).Seconds())
-			if elapsed >= jspConn.PingTimeout {
-				d.logger.Warn(fmt.Sprintf("JSP ping timeout for %s, disconnecting", key))
-				// Close connection
-				conn.Conn.Close()
-				conn.Connected = false
-				continue
-			}
-		}
-
-		// Check if we need to send ping
-		if !jspConn.PingSent && jspConn.PingInterval > 0 {
-			elapsed := int(now.Sub(conn.LastActivity).Seconds())
-			if elapsed >= jspConn.PingInterval {
-				// Send ping
-				packet, err := jsp.SendPing(&conn.JSPRIDCounter)
-				if err == nil {
-					d.pool.Send(key, packet)
-					jspConn.PingSent = true
-					jspConn.PingSinceLast = 0
-					d.logger.Info(fmt.Sprintf("JSP ping sent to %s", key))
-				}
-			}
-		}
-	}
-}
-
-// processPocketAutoPing processes POCKET auto-ping for connections
-func (d *Daemon) processPocketAutoPing() {
-	now := time.Now()
-	connections := d.pool.GetConnections()
-
-	for key, conn := range connections {
-		if conn.Settings == nil || conn.Settings.Type != types.TTYPE_POCKET {
-			continue
-		}
-
-		if conn.PocketPing == nil {
-			continue
-		}
-
-		pocketPing := conn.PocketPing
-
-		// Check ping timeout
-		if pocketPing.PingSent && pocketPing.PingTimeout > 0 {
-			elapsed := int(now.Sub(pocketPing.LastPingTime).Seconds())
-			if elapsed >= pocketPing.PingTimeout {
-				d.logger.Warn(fmt.Sprintf("POCKET ping timeout for %s, disconnecting", key))
-				// Close connection
-				conn.Conn.Close()
-				conn.Connected = false
-				continue
-			}
-		}
-
-		// Check if we need to send ping
-		if !pocketPing.PingSent && pocketPing.PingInterval > 0 {
-			elapsed := int(now.Sub(conn.LastActivity).Seconds())
-			if elapsed >= pocketPing.PingInterval {
-				// Send Enquire packet (ping)
-				packet := pocket.CreateEnquirePacket()
-				if err := d.pool.Send(key, packet); err == nil {
-					pocketPing.PingSent = true
-					pocketPing.PingSinceLast = 0
-					pocketPing.LastPingTime = now
-					d.logger.Info(fmt.Sprintf("POCKET ping (Enquire) sent to %s", key))
-				} else {
-					d.logger.Warn(fmt.Sprintf("Failed to send POCKET ping to %s: %v", key, err))
-				}
-			}
-		}
-	}
-}
-
-// processGatAutoPing processes GAT auto-ping for connections
-func (d *Daemon) processGatAutoPing() {
-	now := time.Now()
-	connections := d.pool.GetConnections()
-
-	for key, conn := range connections {
-		if conn.Settings == nil || conn.Settings.Type != types.TTYPE_GAT {
-			continue
-		}
-
-		if conn.GatPing == nil {
-			continue
-		}
-
-		gatPing := conn.GatPing
-
-		// Check ping timeout
-		if gatPing.PingSent && gatPing.PingTimeout > 0 {
-			elapsed := int(now.Sub(gatPing.LastPingTime).Seconds())
-			if elapsed >= gatPing.PingTimeout {
-				d.logger.Warn(fmt.Sprintf("GAT ping timeout for %s, disconnecting", key))
-				// Close connection
-				conn.Conn.Close()
-				conn.Connected = false
-				continue
-			}
-		}
-
-		// Check if we need to send ping
-		if !gatPing.PingSent && gatPing.PingInterval > 0 {
-			elapsed := int(now.Sub(conn.LastActivity).Seconds())
-			if elapsed >= gatPing.PingInterval {
-				// Send REQ_MASTER packet (ping)
-				packet := gat.CreateReqMasterPacket(0x00, gatPing.TerminalType) // 0x00 = broadcast address
-				if err := d.pool.Send(key, packet); err == nil {
-					gatPing.PingSent = true
-					gatPing.PingSinceLast = 0
-					gatPing.LastPingTime = now
-					d.logger.Info(fmt.Sprintf("GAT ping (REQ_MASTER) sent to %s", key))
-				} else {
-					d.logger.Warn(fmt.Sprintf("Failed to send GAT ping to %s: %v", key, err))
-				}
-			}
-		}
-	}
-}
-
-// processSphinxAutoPing processes SPHINX auto-ping for connections
-func (d *Daemon) processSphinxAutoPing() {
-	now := time.Now()
-	connections := d.pool.GetConnections()
-
-	for key, conn := range connections {
-		if conn.Settings == nil || conn.Settings.Type != types.TTYPE_SPHINX {
-			continue
-		}
-
-		if conn.SphinxPing == nil {
-			continue
-		}
-
-		sphinxPing := conn.SphinxPing
-
-		// Check ping timeout
-		if sphinxPing.PingSent && sphinxPing.PingTimeout > 0 {
-			elapsed := int(now.Sub(sphinxPing.LastPingTime).Seconds())
-			if elapsed >= sphinxPing.PingTimeout {
-				d.logger.Warn(fmt.Sprintf("SPHINX ping timeout for %s, disconnecting", key))
-				// Close connection
-				conn.Conn.Close()
-				conn.Connected = false
-				continue
-			}
-		}
-
-		// Check if we need to send ping
-		if !sphinxPing.PingSent && sphinxPing.PingInterval > 0 {
-			elapsed := int(now.Sub(conn.LastActivity).Seconds())
-			if elapsed >= sphinxPing.PingInterval {
-				// Send DELEGATION_START packet (ping)
-				packet := sphinx.CreateDelegationStartPacket()
-				if err := d.pool.Send(key, packet); err == nil {
-					sphinxPing.PingSent = true
-					sphinxPing.PingSinceLast = 0
-					sphinxPing.LastPingTime = now
-					d.logger.Info(fmt.Sprintf("SPHINX ping (DELEGATION_START) sent to %s", key))
-				} else {
-					d.logger.Warn(fmt.Sprintf("Failed to send SPHINX ping to %s: %v", key, err))
-				}
-			}
-		}
-	}
-}
-
 // processHTTPTimeouts processes HTTP request timeouts
 func (d *Daemon) processHTTPTimeouts() {
 	now := time.Now()
@@ -711,171 +591,273 @@ func (d *Daemon) acceptConnections() {
 	}
 }
 
-// handleConnection handles client connection
+// handleConnection handles admin port client: protopack frames or text lines
+// (server.mode, "auto" detects by first byte)
 func (d *Daemon) handleConnection(conn *net.TCPConn) {
 	defer conn.Close()
 
-	buffer := make([]byte, 4096)
-	for {
-		n, err := conn.Read(buffer)
+	source := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(source); err == nil {
+		source = host
+	}
+
+	reader := bufio.NewReader(conn)
+	mode := d.config.ServerMode
+	if mode != ADMIN_MODE_TEXT && mode != ADMIN_MODE_PROTOPACK {
+		first, err := reader.Peek(1)
 		if err != nil {
-			break
+			return
+		}
+		mode = ADMIN_MODE_TEXT
+		if protopack.IsTag(first[0]) {
+			mode = ADMIN_MODE_PROTOPACK
 		}
+	}
+
+	client := &adminClient{source: source}
+	if mode == ADMIN_MODE_PROTOPACK {
+		d.serveProtopack(reader, conn, client)
+		return
+	}
 
-		if n > 0 {
-			// Process command
-			response := d.processCommand(buffer[:n])
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			response := d.processCommand([]byte(line), client)
 			if len(response) > 0 {
-				conn.Write(response)
+				conn.Write(append(response, '\n'))
 			}
 		}
+		if err != nil {
+			break
+		}
 	}
 }
 
-// processCommand processes incoming command
-func (d *Daemon) processCommand(data []byte) []byte {
-	// Convert to string and clean up
+// processCommand processes text command line of admin port client
+func (d *Daemon) processCommand(data []byte, client *adminClient) []byte {
 	rawCmd := string(data)
 
-	// Log raw bytes for debugging
-	d.logger.Info(fmt.Sprintf("Raw command bytes: %q (len=%d)", data, len(data)))
-
-	// Remove non-printable characters and control characters
+	// Remove telnet negotiation and control characters
 	cleanCmd := strings.Map(func(r rune) rune {
-		if r >= 32 && r < 127 { // Keep only printable ASCII characters
-			return r
+		if r < 32 || r == 127 || r == utf8.RuneError {
+			return -1
 		}
-		return -1 // Remove character
-	}, rawCmd)
+		return r
+	}, stripTelnetIAC(rawCmd))
 
 	// Remove common protocol prefixes that might come from different clients
 	cleanCmd = strings.TrimPrefix(cleanCmd, "*")
 	cleanCmd = strings.TrimPrefix(cleanCmd, "+")
-
-	// Try to find the actual command by looking for known command starts
-	knownCommands := []string{"system", "stats", "termlist", "settings"}
-	for _, cmd := range knownCommands {
-		if idx := strings.Index(cleanCmd, cmd); idx >= 0 {
-			cleanCmd = cleanCmd[idx:]
-			break
-		}
-	}
-
-	// Trim whitespace
 	cleanCmd = strings.TrimSpace(cleanCmd)
 
-	d.logger.Info(fmt.Sprintf("Received command: raw='%s' cleaned='%s'", rawCmd, cleanCmd))
-
 	// Skip empty commands
 	if cleanCmd == "" {
-		d.logger.Warn("Empty command after cleaning")
-		return []byte("ERROR: Empty command")
+		return nil
 	}
 
-	// Parse and execute command
-	d.logger.Info(fmt.Sprintf("Executing command: '%s'", cleanCmd))
-	result := d.handlers.Execute(cleanCmd, nil)
-	d.logger.Info(fmt.Sprintf("Command result: '%s'", result))
+	name, params := handler.ParseCommand(cleanCmd)
+	if name == ADMIN_CMD_AUTH {
+		// Token is not logged
+		d.logger.Info(fmt.Sprintf("Received command from %s: %s", client.source, ADMIN_CMD_AUTH))
+	} else {
+		d.logger.Info(fmt.Sprintf("Received command from %s: raw=%q cleaned='%s'", client.source, rawCmd, cleanCmd))
+	}
+	result, err := d.adminCall(client, name, params)
+	var text string
+	switch {
+	case errors.Is(err, handler.ErrUnknownCommand):
+		text = fmt.Sprintf("ERROR: Unknown command '%s'", name)
+	case err != nil:
+		text = fmt.Sprintf("ERROR: %v", err)
+	default:
+		text = handler.FormatResult(result)
+	}
+	d.logger.Info(fmt.Sprintf("Command result: '%s'", text))
 
-	return []byte(result)
+	return []byte(text)
 }
 
 // initHandlers initializes command handlers
 func (d *Daemon) initHandlers() {
 	// System commands
-	d.handlers.Register("system", d.handleSystemCommand)
-	d.handlers.Register("termlist", d.handleTermlistCommand)
-	d.handlers.Register("settings", d.handleSettingsCommand)
-	d.handlers.Register("stats", d.handleStatsCommand)
+	d.handlers.RegisterCommand("system", d.handleSystemCommand)
+	d.handlers.RegisterCommand("termlist", d.handleTermlistCommand)
+	d.handlers.RegisterCommand("settings", d.handleSettingsCommand)
+	d.handlers.RegisterCommand("stats", d.handleStatsCommand)
 }
 
 // handleSystemCommand handles system commands
-func (d *Daemon) handleSystemCommand(params map[string]interface{}) string {
+func (d *Daemon) handleSystemCommand(params map[string]interface{}) (interface{}, error) {
 	// If no specific command, show system info
 	if cmd, ok := params["cmd"].(string); ok {
 		switch cmd {
 		case "check_db":
 			// Manually trigger terminal list check
-			if d.config.HTTPServiceActive {
-				terminals, err := d.httpClient.GetTerminalList()
-				if err != nil {
-					return fmt.Sprintf("Error getting terminal list: %v", err)
-				}
-				result := fmt.Sprintf("Terminal list check successful. Found %d terminals:\n", len(terminals))
-				for i, term := range terminals {
-					id := utils.GetStringValue(term, "ID", utils.GetStringValue(term, "id", "unknown"))
-					ip := utils.GetStringValue(term, "IP", utils.GetStringValue(term, "ip", "unknown"))
-					port := utils.GetStringValue(term, "PORT", utils.GetStringValue(term, "port", "0"))
-					result += fmt.Sprintf("  %d. ID: %s, IP: %s, Port: %s\n", i+1, id, ip, port)
-				}
-				return result
+			if !d.config.HTTPServiceActive {
+				return nil, fmt.Errorf("HTTP service not active")
+			}
+			terminals, err := d.httpClient.GetTerminalList()
+			if err != nil {
+				return nil, fmt.Errorf("getting terminal list: %v", err)
+			}
+			result := make([]map[string]interface{}, 0, len(terminals))
+			for _, term := range terminals {
+				result = append(result, map[string]interface{}{
+					"id":   utils.GetStringValue(term, "ID", utils.GetStringValue(term, "id", "unknown")),
+					"ip":   utils.GetStringValue(term, "IP", utils.GetStringValue(term, "ip", "unknown")),
+					"port": utils.GetStringValue(term, "PORT", utils.GetStringValue(term, "port", "0")),
+				})
 			}
-			return "HTTP service not active"
+			return result, nil
 		case "parse":
-			if term, ok := params["term"].(string); ok {
-				settings, err := utils.ParseTerm(term)
-				if err != nil {
-					return fmt.Sprintf("Error: %v", err)
-				}
-				return fmt.Sprintf("Parsed: %+v", settings)
+			term := paramString(params, "term")
+			if args := consoleArgs(params); term == "" && len(args) > 0 {
+				term = args[0]
+			}
+			if term == "" {
+				return nil, fmt.Errorf("usage: system parse term=<terminal_string>")
 			}
-			return "Usage: system parse term=<terminal_string>"
+			return utils.ParseTerm(term)
 		case "info":
-			return fmt.Sprintf("СКД - Система контроля доступа:\n  Версия: %s\n  PID: %d\n  Время работы: %.1f секунд\n  Версия Go: %s",
-				"dev", os.Getpid(),
-				time.Since(d.config.Stats["start_time"].(time.Time)).Seconds(),
-				"go1.25")
+			return map[string]interface{}{
+				"name":    "СКД - Система контроля доступа",
+				"version": "dev",
+				"pid":     os.Getpid(),
+				"uptime":  time.Since(d.config.Stats["start_time"].(time.Time)).Seconds(),
+				"go":      runtime.Version(),
+			}, nil
 		}
-		return fmt.Sprintf("Unknown system command: %s\nAvailable: check_db, parse, info", cmd)
+		return nil, fmt.Errorf("unknown system command: %s (available: check_db, parse, info)", cmd)
 	}
 
 	// Show available system commands
-	return "System commands:\n  system info - Show system information\n  system check_db - Check database connectivity\n  system parse term=<string> - Parse terminal configuration"
+	return "System commands:\n  system info - Show system information\n  system check_db - Check database connectivity\n  system parse term=<string> - Parse terminal configuration", nil
 }
 
-// handleTermlistCommand handles termlist commands
-func (d *Daemon) handleTermlistCommand(params map[string]interface{}) string {
+// handleTermlistCommand handles termlist commands:
+//
+//	termlist                                   - connected terminals
+//	termlist add <ip:port>... [id=] [type=] [role=] [reg_query=]
+//	termlist del <key|ip:port>...
+//	termlist check <ip:port>... [type=]        - keep only listed terminals
+func (d *Daemon) handleTermlistCommand(params map[string]interface{}) (interface{}, error) {
 	if cmd, ok := params["cmd"].(string); ok {
 		switch cmd {
 		case "add":
-			return "Terminal added"
+			terminals := consoleTerminals(params)
+			if len(terminals) == 0 {
+				return nil, fmt.Errorf("usage: termlist add <ip:port>... [id=<id>] [type=<type>] [role=<role>] [reg_query=1]")
+			}
+			return d.consoleChange(params, "terminals.add", func(e *audit.Entry) (interface{}, error) {
+				defer auditChanges(e, d.terminalSnapshot(), d.terminalSnapshot)
+				added := d.addTerminals(terminals)
+				if len(added) == 0 {
+					return nil, fmt.Errorf("no valid terminals to add")
+				}
+				return added, nil
+			})
 		case "del":
-			return "Terminal removed"
+			keys := consoleArgs(params)
+			if key := paramString(params, "key"); key != "" {
+				keys = append(keys, key)
+			}
+			if len(keys) == 0 {
+				return nil, fmt.Errorf("usage: termlist del <key|ip:port>...")
+			}
+			return d.consoleChange(params, "terminals.del", func(e *audit.Entry) (interface{}, error) {
+				defer auditChanges(e, d.terminalSnapshot(), d.terminalSnapshot)
+				removed := d.delTerminals(keys)
+				if len(removed) == 0 {
+					return nil, fmt.Errorf("no valid terminals to remove")
+				}
+				return removed, nil
+			})
 		case "check":
-			return "Terminal check initiated"
+			// Empty list would drop all terminals, console requires explicit list
+			terminals := consoleTerminals(params)
+			if len(terminals) == 0 {
+				return nil, fmt.Errorf("usage: termlist check <ip:port>... [type=<type>]")
+			}
+			return d.consoleChange(params, "terminals.check", func(e *audit.Entry) (interface{}, error) {
+				defer auditChanges(e, d.terminalSnapshot(), d.terminalSnapshot)
+				return d.checkTerminals(terminals), nil
+			})
+		default:
+			return nil, fmt.Errorf("unknown termlist command: %s (available: add, del, check)", cmd)
 		}
 	}
 
 	// Return active connections
 	connections := d.pool.GetConnections()
-	result := "Active connections:\n"
+	result := make([]map[string]interface{}, 0, len(connections))
 	for key, conn := range connections {
-		result += fmt.Sprintf("  %s: %s:%d (%s)\n", key, conn.Addr, conn.Port, conn.Settings.Type)
+		item := map[string]interface{}{
+			"key":  key,
+			"addr": conn.Addr,
+			"port": conn.Port,
+		}
+		if conn.Settings != nil {
+			item["type"] = string(conn.Settings.Type)
+			item["id"] = conn.Settings.ID
+		}
+		result = append(result, item)
 	}
-	return result
+	sort.Slice(result, func(i, j int) bool { return result[i]["key"].(string) < result[j]["key"].(string) })
+	return result, nil
 }
 
-// handleSettingsCommand handles settings commands
-func (d *Daemon) handleSettingsCommand(params map[string]interface{}) string {
+// handleSettingsCommand handles settings commands:
+//
+//	settings                     - all runtime settings
+//	settings get <key>
+//	settings set <key> <value>   (or key=<key> value=<value>)
+func (d *Daemon) handleSettingsCommand(params map[string]interface{}) (interface{}, error) {
 	if cmd, ok := params["cmd"].(string); ok {
+		args := consoleArgs(params)
+		key := paramString(params, "key")
+		if key == "" && len(args) > 0 {
+			key, args = args[0], args[1:]
+		}
+
 		switch cmd {
 		case "get":
-			if key, ok := params["key"].(string); ok {
-				// Return setting value
-				return fmt.Sprintf("Setting %s: not implemented", key)
+			if key == "" {
+				return nil, fmt.Errorf("usage: settings get <key>")
+			}
+			value := d.getSettingValue(key)
+			if value == nil {
+				return nil, fmt.Errorf("setting %s not found", key)
 			}
+			return map[string]interface{}{"key": key, "value": value}, nil
 		case "set":
-			// Set setting value
-			return "Setting updated"
+			value, ok := params["value"]
+			if !ok && len(args) > 0 {
+				value, ok = args[0], true
+			}
+			if key == "" || !ok {
+				return nil, fmt.Errorf("usage: settings set <key> <value>")
+			}
+			if text, isText := value.(string); isText {
+				value = parseSettingValue(text)
+			}
+			return d.consoleChange(params, "settings.set", func(e *audit.Entry) (interface{}, error) {
+				if err := d.changeSetting(e, key, value); err != nil {
+					return nil, err
+				}
+				return map[string]interface{}{"key": key, "value": d.getSettingValue(key)}, nil
+			})
+		default:
+			return nil, fmt.Errorf("unknown settings command: %s (available: get, set)", cmd)
 		}
 	}
 
-	// Return all settings
-	return fmt.Sprintf("Settings: %+v", d.config)
+	// Return all runtime settings
+	return d.runtimeSettings(), nil
 }
 
 // handleStatsCommand handles stats command
-func (d *Daemon) handleStatsCommand(params map[string]interface{}) string {
+func (d *Daemon) handleStatsCommand(params map[string]interface{}) (interface{}, error) {
 	sessionStats := d.sessionMgr.GetSessionStats()
 	stats := map[string]interface{}{
 		"connections":   len(d.pool.GetConnections()),
@@ -890,7 +872,7 @@ func (d *Daemon) handleStatsCommand(params map[string]interface{}) string {
 		stats[k] = v
 	}
 
-	return fmt.Sprintf("Stats: %+v", stats)
+	return stats, nil
 }
 
 // handleSignals handles OS signals
@@ -927,19 +909,19 @@ func (d *Daemon) GetPool() *connection.ConnectionPool {
 	return d.pool
 }
 
-// processTerminalFrom1C processes terminal data from 1C and creates connection
-func (d *Daemon) processTerminalFrom1C(termData map[string]interface{}) {
+// terminalSettingsFrom1C parses terminal settings from 1C terminal data, nil if invalid
+func (d *Daemon) terminalSettingsFrom1C(termData map[string]interface{}) *types.TerminalSettings {
 	// Extract terminal information (try both lowercase and uppercase keys)
 	id := utils.GetStringValue(termData, "ID", utils.GetStringValue(termData, "id", ""))
 	if id == "" {
 		d.logger.Warn("Terminal data missing 'id' or 'ID' field")
-		return
+		return nil
 	}
 
 	ipStr := utils.GetStringValue(termData, "IP", utils.GetStringValue(termData, "ip", ""))
 	if ipStr == "" {
 		d.logger.Warn(fmt.Sprintf("Terminal %s missing 'ip' or 'IP' field", id))
-		return
+		return nil
 	}
 
 	// Parse terminal string (format: "IP:PORT:type=pocket:deny_ct=true" or "IP:PORT" or just "IP")
@@ -959,7 +941,7 @@ func (d *Daemon) processTerminalFrom1C(termData map[string]interface{}) {
 			ip := parts[0]
 			if net.ParseIP(ip) == nil {
 				d.logger.Warn(fmt.Sprintf("Terminal %s has invalid IP address: %s", id, ip))
-				return
+				return nil
 			}
 			port := 8080
 			if len(parts) > 1 {
@@ -1048,10 +1030,6 @@ func (d *Daemon) processTerminalFrom1C(termData map[string]interface{}) {
 		settings.Type = utils.ParseTType(typeStr)
 	}
 	
-	ip := settings.IP
-	port := settings.Port
-	termType := settings.Type
-
 	// Extract additional settings from termData
 	if utfVal, ok := termData["utf"].(bool); ok {
 		settings.UTF = utfVal
@@ -1067,8 +1045,27 @@ func (d *Daemon) processTerminalFrom1C(termData map[string]interface{}) {
 	// These are parsed from the IP string like "type=pocket:deny_ct=true:ctrole=card_taker"
 	// Additional parsing can be done here if needed
 
+	return settings
+}
+
+// processTerminalFrom1C processes terminal data from 1C and creates connection
+func (d *Daemon) processTerminalFrom1C(termData map[string]interface{}) {
+	settings := d.terminalSettingsFrom1C(termData)
+	if settings == nil {
+		return
+	}
+	id := settings.ID
+	ip := settings.IP
+	port := settings.Port
+	termType := settings.Type
+
 	d.logger.Info(fmt.Sprintf("Processing terminal: %s (%s:%d, type: %s)", id, ip, port, termType))
 
+	// Terminal dials in to JSP listener itself
+	if termType == types.TTYPE_JSP && d.jspListenerPort() > 0 && isDialIn(settings) {
+		return
+	}
+
 	// Create connection key
 	connKey := fmt.Sprintf("%s_%s_%d", termType, ip, port)
 
@@ -1107,8 +1104,8 @@ func (d *Daemon) processTerminalFrom1C(termData map[string]interface{}) {
 	}
 
 	// Set terminal settings for connection
-	if conn := d.pool.GetConnections()[key]; conn != nil {
-		conn.Settings = settings
+	if err := d.pool.SetSettings(key, settings); err != nil {
+		d.logger.Warn(fmt.Sprintf("Failed to set terminal settings for %s: %v", key, err))
 	}
 
 	d.logger.Info(fmt.Sprintf("Successfully connected to terminal %s as %s", id, key))
@@ -1117,6 +1114,51 @@ func (d *Daemon) processTerminalFrom1C(termData map[string]interface{}) {
 	d.updateTerminalConnectionStatus(id, ip, port, true, "")
 }
 
+// jspListenerPort returns JSP listener port, 0 if disabled (JSPListenerPort is false or int)
+func (d *Daemon) jspListenerPort() int {
+	if port, ok := d.config.JSPListenerPort.(int); ok {
+		return port
+	}
+	return 0
+}
+
+// isDialIn checks "dialin" term string setting
+func isDialIn(settings *types.TerminalSettings) bool {
+	switch v := settings.Extra["dialin"].(type) {
+	case int:
+		return v != 0
+	case bool:
+		return v
+	}
+	return false
+}
+
+// resolveTerminal finds settings of terminal by ID or IP in 1C terminal list.
+// IP shared by several terminals (same NAT) doesn't identify terminal.
+func (d *Daemon) resolveTerminal(id, ip string) *types.TerminalSettings {
+	d.mutex.RLock()
+	termList := d.config.TerminalList
+	d.mutex.RUnlock()
+
+	var found *types.TerminalSettings
+	for _, termData := range termList {
+		settings := d.terminalSettingsFrom1C(termData)
+		if settings == nil {
+			continue
+		}
+		if id != "" && settings.ID == id {
+			return settings
+		}
+		if id == "" && ip != "" && settings.IP == ip {
+			if found != nil {
+				return nil
+			}
+			found = settings
+		}
+	}
+	return found
+}
+
 // calculateReconnectionDelay calculates delay for reconnection
 func (d *Daemon) calculateReconnectionDelay(count int) time.Duration {
 	delay := float64(count) * d.config.ReconnectionWaitTimeStep
@@ -1164,26 +1206,47 @@ func (d *Daemon) updateTerminalConnectionStatus(id, ip string, port int, connect
 
 // ProcessTagRead processes RFID/biometric tag read event
 func (d *Daemon) ProcessTagRead(connKey string, uid string, readerType uint8, auth bool) {
+	// PIN entered on keypad is additional factor of pending session (card+PIN)
+	if readerType == types.READER_TYPE_PIN {
+		if err := d.sessionMgr.HandlePIN(connKey, uid); err != nil {
+			d.logger.Info(fmt.Sprintf("PIN entry ignored: conn=%s: %v", connKey, err))
+		}
+		return
+	}
+
 	d.logger.Info(fmt.Sprintf("Tag read: conn=%s, uid=%s, reader_type=%d, auth=%v", connKey, uid, readerType, auth))
+	metrics.TagReads.Inc(connKey, "card")
+	trace := startReadTrace(connKey, uid, "card", "", map[string]interface{}{
+		"skd.reader_type": int(readerType),
+		"skd.auth":        auth,
+	})
+	d.startCardSession(connKey, uid, readerType, auth, "", trace)
+}
 
+// startCardSession checks card deny lists and starts access session of card UID,
+// returns nil if session is not started. Trace is root span of read (nil if not traced).
+func (d *Daemon) startCardSession(connKey string, uid string, readerType uint8, auth bool, tagType string, trace *tracing.Span) *types.Session {
 	// Get connection
 	conn := d.pool.GetConnection(connKey)
 	if conn == nil || conn.Settings == nil {
 		d.logger.Warn(fmt.Sprintf("Connection not found or settings missing: %s", connKey))
-		return
+		trace.SetError("connection not found")
+		trace.End()
+		return nil
 	}
+	checks := startLocalChecks(trace)
 
 	// Check gmclist (global card deny list) FIRST
 	uidHex := strings.ToUpper(uid)
 	if d.cardList != nil {
 		if msg := d.cardList.CheckGlobal(uidHex); msg != "" {
 			d.logger.Info(fmt.Sprintf("Card deny (gmclist): uid=%s, message=%s", uidHex, msg))
-			if conn.Settings.Type == types.TTYPE_POCKET {
-				interactivePayload := pocket.CreateInteractivePacket(msg, 3000, 4, true)
-				pkt := pocket.CreatePacket(pocket.POCKET_CMD_INTERACTIVE, 0x00, interactivePayload)
-				d.pool.Send(connKey, pkt)
+			metrics.AccessDenied.Inc(connKey, types.DENY_REASON_GMCLIST)
+			endDeniedTrace(trace, checks, types.DENY_REASON_GMCLIST)
+			if err := d.pool.DenyAccess(connKey, "", msg); err != nil {
+				d.logger.Warn(fmt.Sprintf("Failed to send deny message to %s: %v", connKey, err))
 			}
-			return
+			return nil
 		}
 	}
 
@@ -1195,17 +1258,12 @@ func (d *Daemon) ProcessTagRead(connKey string, uid string, readerType uint8, au
 			// Deny access - storage has value (e.g., towel not returned)
 			message := getMemRegDenyMessage(conn.Settings.MemRegDeny)
 			d.logger.Warn(fmt.Sprintf("MEMREG deny: storage=%s, uid=%s - access denied", conn.Settings.MemRegDeny, uid))
+			metrics.AccessDenied.Inc(connKey, types.DENY_REASON_MEMREG)
+			endDeniedTrace(trace, checks, types.DENY_REASON_MEMREG)
 			
 			// Send denial message to terminal
-			if conn.Settings.Type == types.TTYPE_POCKET {
-				interactivePayload := pocket.CreateInteractivePacket(message, 3000, 4, true) // Sound 4 = error
-				pkt := pocket.CreatePacket(0x06, 0x00, interactivePayload) // 0x00 = RT_MAIN flags
-				d.pool.Send(connKey, pkt)
-			} else if conn.Settings.Type == types.TTYPE_JSP {
-				// Send denial message to JSP terminal
-				if err := d.pool.SendJSPMessage(connKey, message, 3000); err != nil {
-					d.logger.Warn(fmt.Sprintf("Failed to send JSP denial message: %v", err))
-				}
+			if err := d.pool.DenyAccess(connKey, "", message); err != nil {
+				d.logger.Warn(fmt.Sprintf("Failed to send deny message to %s: %v", connKey, err))
 			}
 			
 			// If role=checkout and ctrole=card_taker, take the card
@@ -1220,7 +1278,7 @@ func (d *Daemon) ProcessTagRead(connKey string, uid string, readerType uint8, au
 				d.pool.Send(connKey, signalPkt)
 			}
 			
-			return // Don't create session - access denied
+			return nil // Don't create session - access denied
 		}
 	}
 
@@ -1249,20 +1307,22 @@ func (d *Daemon) ProcessTagRead(connKey string, uid string, readerType uint8, au
 	if d.cardList != nil {
 		if msg := d.cardList.CheckSecondary(uidHex); msg != "" {
 			d.logger.Info(fmt.Sprintf("Card deny (mclist): uid=%s, message=%s", uidHex, msg))
-			if conn.Settings.Type == types.TTYPE_POCKET {
-				interactivePayload := pocket.CreateInteractivePacket(msg, 3000, 4, true)
-				pkt := pocket.CreatePacket(pocket.POCKET_CMD_INTERACTIVE, 0x00, interactivePayload)
-				d.pool.Send(connKey, pkt)
+			metrics.AccessDenied.Inc(connKey, types.DENY_REASON_MCLIST)
+			endDeniedTrace(trace, checks, types.DENY_REASON_MCLIST)
+			if err := d.pool.DenyAccess(connKey, "", msg); err != nil {
+				d.logger.Warn(fmt.Sprintf("Failed to send deny message to %s: %v", connKey, err))
 			}
-			return
+			return nil
 		}
 	}
 
+	checks.End()
+
 	// Start new access session
-	session, err := d.sessionMgr.StartSession(uid, connKey, "MAIN", lockers)
+	session, err := d.sessionMgr.StartTagSession(uid, connKey, "MAIN", tagType, lockers, trace)
 	if err != nil {
 		d.logger.Error(fmt.Sprintf("Failed to start session for UID %s: %v", uid, err))
-		return
+		return nil
 	}
 
 	// Store temp_card in session if available
@@ -1332,6 +1392,7 @@ func (d *Daemon) ProcessTagRead(connKey string, uid string, readerType uint8, au
 		"uid":        uid,
 		"key":        connKey,
 	})
+	return session
 }
 
 // getMemRegDenyMessage returns denial message for MEMREG storage
@@ -1348,6 +1409,7 @@ func getMemRegDenyMessage(storage string) string {
 // ProcessBarcodeRead processes barcode/QR code read event
 func (d *Daemon) ProcessBarcodeRead(connKey string, data string) {
 	d.logger.Info(fmt.Sprintf("Barcode read: conn=%s, data=%s", connKey, data))
+	metrics.TagReads.Inc(connKey, "barcode")
 
 	// Get connection
 	conn := d.pool.GetConnection(connKey)
@@ -1363,7 +1425,8 @@ func (d *Daemon) ProcessBarcodeRead(connKey string, data string) {
 	}
 
 	// Start session with barcode data
-	session, err := d.sessionMgr.StartSession(data, connKey, "MAIN", nil)
+	trace := startReadTrace(connKey, data, "barcode", "", nil)
+	session, err := d.sessionMgr.StartTagSession(data, connKey, "MAIN", "", nil, trace)
 	if err != nil {
 		d.logger.Error(fmt.Sprintf("Failed to start barcode session: %v", err))
 		return
@@ -1395,13 +1458,20 @@ func (d *Daemon) ProcessBarcodeRead(connKey string, data string) {
 	})
 }
 
-// handleHeliosEvent handles Helios WebSocket events
-func (d *Daemon) handleHeliosEvent(request *helios.HeliosRequest, eventType helios.HeliosEventType, data map[string]interface{}) {
-	session := d.sessionMgr.GetSession(request.SessionID)
+// handleFaceEvent handles face provider events: verification results of session
+// camera requests and person identifications
+func (d *Daemon) handleFaceEvent(event *face.Event) {
+	if event.Type == face.EVENT_IDENT && event.RequestID == "" {
+		d.handleCRTIdentification(event.TerminalID, event.PersonID, event.FIO, event.CamID, event.Score, event.Data)
+		return
+	}
+
+	session := d.sessionMgr.GetSession(event.SessionID)
 	if session == nil {
-		d.logger.Warn(fmt.Sprintf("Session not found for Helios event: %s", request.SessionID))
+		d.logger.Warn(fmt.Sprintf("Session not found for %s event: %s", event.Provider, event.SessionID))
 		return
 	}
+	data := event.Data
 
 	// Check if this request matches session's camera request
 	camData, ok := session.Data["cam"].(map[string]interface{})
@@ -1411,47 +1481,56 @@ func (d *Daemon) handleHeliosEvent(request *helios.HeliosRequest, eventType heli
 	}
 
 	camRKey, _ := camData["rkey"].(string)
-	if camRKey != request.ID {
-		d.logger.Warn(fmt.Sprintf("Helios request ID mismatch: session=%s, request=%s, expected=%s", session.ID, request.ID, camRKey))
+	if camRKey != event.RequestID {
+		if shouldHoldFaceEvent(event, camData) {
+			// Result came before session stored request ID: replayed by processSessions
+			d.logger.Info(fmt.Sprintf("Face (%s) result of request %s held for session %s", event.Provider, event.RequestID, session.ID))
+			d.faceHold.hold(event)
+			return
+		}
+		d.logger.Warn(fmt.Sprintf("Face request ID mismatch: session=%s, request=%s, expected=%s", session.ID, event.RequestID, camRKey))
 		return
 	}
 
 	// Process event based on type
-	switch eventType {
-	case helios.HELIOS_EVENT_YES:
-		d.logger.Info(fmt.Sprintf("Helios person VERIFIED for session %s", session.ID))
+	switch event.Type {
+	case face.EVENT_YES:
+		d.logger.Info(fmt.Sprintf("Face (%s) person VERIFIED for session %s", event.Provider, session.ID))
 		camData["result"] = types.CAM_RES_YES
 		camData["answer_data"] = data
 		d.sessionMgr.ProcessSessionStage(session.ID)
 		d.sendEvent("helios_event", map[string]interface{}{
 			"session_id": session.ID,
+			"provider":   event.Provider,
 			"event_type": "YES",
 			"data":       data,
 		})
 
-	case helios.HELIOS_EVENT_NO:
-		d.logger.Info(fmt.Sprintf("Helios person NOT RECOGNIZED for session %s", session.ID))
+	case face.EVENT_NO:
+		d.logger.Info(fmt.Sprintf("Face (%s) person NOT RECOGNIZED for session %s", event.Provider, session.ID))
 		camData["result"] = types.CAM_RES_NO
 		camData["answer_data"] = data
 		d.sessionMgr.ProcessSessionStage(session.ID)
 		d.sendEvent("helios_event", map[string]interface{}{
 			"session_id": session.ID,
+			"provider":   event.Provider,
 			"event_type": "NO",
 			"data":       data,
 		})
 
-	case helios.HELIOS_EVENT_NF:
-		d.logger.Info(fmt.Sprintf("Helios person NOT FOUND for session %s", session.ID))
+	case face.EVENT_NF:
+		d.logger.Info(fmt.Sprintf("Face (%s) person NOT FOUND for session %s", event.Provider, session.ID))
 		camData["result"] = types.CAM_RES_NF
 		camData["answer_data"] = data
 		d.sessionMgr.ProcessSessionStage(session.ID)
 		d.sendEvent("helios_event", map[string]interface{}{
 			"session_id": session.ID,
+			"provider":   event.Provider,
 			"event_type": "NF",
 			"data":       data,
 		})
 
-	case helios.HELIOS_EVENT_COR:
+	case face.EVENT_COR:
 		// Correlation update - show progress
 		if correlations, ok := data["correlations"].(map[string]interface{}); ok {
 			corKeys := make([]string, 0, len(correlations))
@@ -1476,7 +1555,7 @@ func (d *Daemon) handleHeliosEvent(request *helios.HeliosRequest, eventType heli
 					if maxCorr, ok := camData["max_correlation"].(int); !ok || maxKoef > maxCorr {
 						camData["max_correlation"] = maxKoef
 					}
-					d.logger.Info(fmt.Sprintf("Helios correlation update for session %s: %d%%", session.ID, maxKoef))
+					d.logger.Info(fmt.Sprintf("Face (%s) correlation update for session %s: %d%%", event.Provider, session.ID, maxKoef))
 					d.sendEvent("helios_correlation", map[string]interface{}{
 						"session_id":  session.ID,
 						"correlation": maxKoef,
@@ -1485,20 +1564,18 @@ func (d *Daemon) handleHeliosEvent(request *helios.HeliosRequest, eventType heli
 			}
 		}
 
-	case helios.HELIOS_EVENT_FAIL:
-		d.logger.Warn(fmt.Sprintf("Helios request FAILED for session %s", session.ID))
+	case face.EVENT_FAIL:
+		d.logger.Warn(fmt.Sprintf("Face (%s) request FAILED for session %s", event.Provider, session.ID))
 		camData["result"] = types.CAM_RES_FAIL
 		camData["answer_data"] = data
 		d.sessionMgr.ProcessSessionStage(session.ID)
 		d.sendEvent("helios_event", map[string]interface{}{
 			"session_id": session.ID,
+			"provider":   event.Provider,
 			"event_type": "FAIL",
 			"data":       data,
 		})
 	}
-
-	// Mark request as processed
-	request.Processed = true
 }
 
 // validateFaceIDData validates face ID person data
@@ -1517,7 +1594,7 @@ func validateFaceIDData(pid string) (bool, string) {
 	return true, ""
 }
 
-// handleCRTIdentification handles CRT person identification event
+// handleCRTIdentification handles person identification pushed by face provider (CRT)
 func (d *Daemon) handleCRTIdentification(terminalID string, personID string, fio string, camID string, score float64, data map[string]interface{}) {
 	d.logger.Info(fmt.Sprintf("CRT identification: terminal=%s, person=%s, fio=%s, cam=%s, score=%.2f", terminalID, personID, fio, camID, score))
 
@@ -1557,8 +1634,19 @@ func (d *Daemon) handleCRTIdentification(terminalID string, personID string, fio
 		}
 	}
 
-	// Create FaceID session
-	session, err := d.sessionMgr.StartSession(personID, connKey, "MAIN", nil)
+	// Cardless entry: face identifies card of person in 1C
+	if minScore, ok := d.faceEntryScore(conn); ok {
+		d.startFaceEntry(connKey, terminalID, personID, fio, camID, score, minScore, data)
+		return
+	}
+
+	// Create FaceID session (trace continues face identification trace)
+	traceparent, _ := data["traceparent"].(string)
+	trace := startReadTrace(connKey, personID, "face", traceparent, map[string]interface{}{
+		"skd.cam_id": camID,
+		"skd.score":  score,
+	})
+	session, err := d.sessionMgr.StartTagSession(personID, connKey, "MAIN", "", nil, trace)
 	if err != nil {
 		d.logger.Error(fmt.Sprintf("CRT: failed to start session: %v", err))
 		return
@@ -1587,7 +1675,7 @@ func (d *Daemon) handleCRTIdentification(terminalID string, personID string, fio
 			"message":         msg,
 			"kpo_answer_data": "autofix",
 		}
-		session.Stage = types.SESSION_STAGE_KPO_RESULT
+		d.sessionMgr.UpdateSession(session.ID, map[string]interface{}{"stage": types.SESSION_STAGE_KPO_RESULT})
 		session.Data["ap_mode"] = true
 		session.Data["no_report"] = true
 	}
@@ -1605,6 +1693,93 @@ func (d *Daemon) handleCRTIdentification(terminalID string, personID string, fio
 	})
 }
 
+// faceEntryScore returns min identification score (0..1) of terminal with cardless
+// face entry (term string "face_entry=1"): term string "face_score=<percent|fraction>"
+// or crt.face_min_score
+func (d *Daemon) faceEntryScore(conn *types.Connection) (float64, bool) {
+	extra := conn.Settings.Extra
+	if v, _ := extra["face_entry"].(int); v == 0 {
+		return 0, false
+	}
+	minScore := d.config.CRTFaceMinScore
+	switch v := extra["face_score"].(type) {
+	case int:
+		minScore = float64(v) / 100
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			minScore = f
+		}
+	}
+	return minScore, true
+}
+
+// startFaceEntry starts access session of card of person identified by face
+func (d *Daemon) startFaceEntry(connKey, terminalID, personID, fio, camID string, score, minScore float64, data map[string]interface{}) {
+	if score < minScore {
+		d.logger.Info(fmt.Sprintf("CRT face entry: person %s score %.2f below %.2f on terminal %s, ignored", personID, score, minScore, terminalID))
+		return
+	}
+	// Cooldown is reserved while card is looked up and kept only if session starts
+	if d.crtClient != nil && !d.crtClient.TryFaceEntry(personID) {
+		d.logger.Info(fmt.Sprintf("CRT face entry: person %s in cooldown, ignored", personID))
+		return
+	}
+	started := false
+	defer func() {
+		if !started && d.crtClient != nil {
+			d.crtClient.CancelFaceEntry(personID)
+		}
+	}()
+	metrics.TagReads.Inc(connKey, "face")
+
+	// Trace continues face identification trace
+	traceparent, _ := data["traceparent"].(string)
+	trace := startReadTrace(connKey, "", "face", traceparent, map[string]interface{}{
+		"skd.cam_id": camID,
+		"skd.score":  score,
+	})
+	trace.SetID("skd.person_id", personID)
+
+	uid, err := d.httpClient.WithSpan(trace).(*httpclient.HTTPClient).GetCIDUID(personID)
+	if err != nil {
+		d.logger.Warn(fmt.Sprintf("CRT face entry: no card for person %s: %v", personID, err))
+		metrics.AccessDenied.Inc(connKey, types.DENY_REASON_NO_CARD)
+		endDeniedTrace(trace, nil, types.DENY_REASON_NO_CARD)
+		if err := d.pool.DenyAccess(connKey, "", d.config.CamServiceResultMsgNf); err != nil {
+			d.logger.Warn(fmt.Sprintf("Failed to send deny message to %s: %v", connKey, err))
+		}
+		return
+	}
+
+	d.logger.Info(fmt.Sprintf("CRT face entry: person %s (%s) card %s on terminal %s", personID, fio, uid, terminalID))
+	trace.SetID("skd.uid", uid)
+	session := d.startCardSession(connKey, uid, types.READER_TYPE_BIOMETRIC, true, "faceid", trace)
+	if session == nil {
+		return
+	}
+	started = true
+	session.CID = personID
+	session.Data["faceid"] = map[string]interface{}{
+		"reader_type":      16,
+		"reader_type_name": "MAIN",
+		"pid":              personID,
+		"fio":              fio,
+		"cam_id":           camID,
+		"data":             personID,
+		"crt":              data,
+	}
+
+	d.sendEvent("crt_identification", map[string]interface{}{
+		"session_id":  session.ID,
+		"terminal_id": terminalID,
+		"person_id":   personID,
+		"fio":         fio,
+		"cam_id":      camID,
+		"score":       score,
+		"uid":         uid,
+	})
+}
+
 // ProcessPassEvent processes person passed event
 func (d *Daemon) ProcessPassEvent(connKey string, passed bool) {
 	d.logger.Info(fmt.Sprintf("Pass event: conn=%s, passed=%v", connKey, passed))
@@ -1646,26 +1821,88 @@ func (d *Daemon) ProcessPassEvent(connKey string, passed bool) {
 	}
 }
 
-// sendEvent sends event to web interface via eventCh
-func (d *Daemon) sendEvent(eventType string, data map[string]interface{}) {
-	event := map[string]interface{}{
-		"type":      eventType,
-		"timestamp": time.Now().Unix(),
-		"data":      data,
+// ProcessConfigEvent records terminal configuration push/readback in terminal logs
+func (d *Daemon) ProcessConfigEvent(connKey string, event string, params map[string]interface{}) {
+	if event == connection.CONFIG_EVENT_DRIFT || event == connection.CONFIG_EVENT_ERROR {
+		d.logger.Warn(fmt.Sprintf("Terminal config %s: conn=%s, params=%v", event, connKey, params))
+	} else {
+		d.logger.Info(fmt.Sprintf("Terminal config %s: conn=%s", event, connKey))
+	}
+
+	if d.termLogs != nil {
+		d.termLogs.Add(connKey, termlogs.TermLogEntry{
+			TKey:   connKey,
+			Type:   event,
+			Time:   time.Now(),
+			Params: params,
+		})
+	}
+
+	d.sendEvent("terminal_config", map[string]interface{}{
+		"conn_key": connKey,
+		"event":    event,
+		"params":   params,
+	})
+}
+
+// ProcessControllerEvent records event decided by controller itself (SPHINX EVENT_CE)
+func (d *Daemon) ProcessControllerEvent(connKey string, event *sphinx.Event) {
+	termID := ""
+	if conn := d.pool.GetConnection(connKey); conn != nil && conn.Settings != nil {
+		termID = conn.Settings.ID
 	}
 
-	// Non-blocking send
-	select {
-	case d.eventCh <- event:
-		// Event sent successfully
+	switch event.Type {
+	case sphinx.SPHINX_EVT_DOOR_FORCED, sphinx.SPHINX_EVT_DOOR_HELD, sphinx.SPHINX_EVT_ALARM:
+		d.logger.Warn(fmt.Sprintf("Controller event %s: conn=%s, ap=%s", event.Type, connKey, event.AccessPoint))
 	default:
-		// Channel is full, skip event (don't block)
-		d.logger.Debug(fmt.Sprintf("Event channel full, skipping event: %s", eventType))
+		d.logger.Info(fmt.Sprintf("Controller event %s: conn=%s, ap=%s, uid=%s", event.Type, connKey, event.AccessPoint, event.UID))
+	}
+
+	params := map[string]interface{}{
+		"code":         event.Code,
+		"access_point": event.AccessPoint,
+		"key_type":     event.KeyType,
+		"direction":    event.Direction,
+	}
+	if d.termLogs != nil {
+		d.termLogs.Add(connKey, termlogs.TermLogEntry{
+			TKey:   connKey,
+			Type:   "SPHINX_" + event.Type,
+			Time:   event.Time,
+			UID:    event.UID,
+			Params: params,
+		})
+	}
+
+	if d.storageStore != nil {
+		if err := d.storageStore.RegisterControllerEvent(map[string]string{
+			"event_time":   event.Time.Format("2006-01-02 15:04:05"),
+			"conn_key":     connKey,
+			"term_id":      termID,
+			"type":         event.Type,
+			"code":         event.Code,
+			"access_point": event.AccessPoint,
+			"key_type":     event.KeyType,
+			"uid":          event.UID,
+			"direction":    event.Direction,
+			"raw":          event.Raw,
+		}); err != nil {
+			d.logger.Warn(fmt.Sprintf("Failed to store controller event: %v", err))
+		}
 	}
+
+	d.sendEvent("controller_event", map[string]interface{}{
+		"conn_key":    connKey,
+		"terminal_id": termID,
+		"event":       event,
+	})
 }
 
 // processSessions processes all active sessions
 func (d *Daemon) processSessions() {
+	d.replayFaceEvents()
+
 	// Get all active sessions from session manager
 	sessions := d.sessionMgr.GetAllSessions()
 
@@ -1681,43 +1918,91 @@ func (d *Daemon) processSessions() {
 
 // startWebServer starts the web interface server
 func (d *Daemon) startWebServer() error {
+	if d.config.WebAuthEnabled {
+		if err := d.openAuthStore(); err != nil {
+			return fmt.Errorf("failed to open web users database: %v", err)
+		}
+	} else {
+		d.logger.Warn("Web authentication disabled: web UI and REST API are open to everyone")
+	}
+
 	mux := http.NewServeMux()
+	viewer := func(h http.HandlerFunc) http.HandlerFunc {
+		return d.withRole(auth.ROLE_VIEWER, auth.ROLE_ADMIN, h)
+	}
+	operator := func(h http.HandlerFunc) http.HandlerFunc {
+		return d.withRole(auth.ROLE_VIEWER, auth.ROLE_OPERATOR, h)
+	}
+	admin := func(h http.HandlerFunc) http.HandlerFunc {
+		return d.withRole(auth.ROLE_ADMIN, auth.ROLE_ADMIN, h)
+	}
 
-	// Register web handlers
+	// Register web handlers: viewer reads, operator manages card lists,
+	// admin controls terminals, settings, keys and users
 	mux.HandleFunc("/", d.handleWebIndex)
-	mux.HandleFunc("/api/stats", d.handleAPIStats)
-	mux.HandleFunc("/api/connections", d.handleAPIConnections)
-	mux.HandleFunc("/api/sessions", d.handleAPISessions)
-	mux.HandleFunc("/api/session/", d.handleAPISessionDetail)
-	mux.HandleFunc("/api/terminals", d.handleAPITerminals)
-	mux.HandleFunc("/api/terminal/", d.handleAPITerminalDetail)
-	mux.HandleFunc("/api/logs", d.handleAPILogs)
-	mux.HandleFunc("/api/config", d.handleAPIConfig)
-	mux.HandleFunc("/api/events", d.handleAPIEvents) // SSE для real-time обновлений
-	mux.HandleFunc("/api/cardlist", d.handleAPICardList)
-	mux.HandleFunc("/api/cardlist/", d.handleAPICardList)
-	mux.HandleFunc("/api/system/halt", d.handleAPIHalt)
-	mux.HandleFunc("/api/system/settings", d.handleAPISettings)
-	mux.HandleFunc("/api/system/settings/", d.handleAPISettings)
-	mux.HandleFunc("/api/terminals/add", d.handleAPITerminalsAdd)
-	mux.HandleFunc("/api/terminals/del", d.handleAPITerminalsDel)
-	mux.HandleFunc("/api/terminals/check", d.handleAPITerminalsCheck)
-	mux.HandleFunc("/api/tlogs", d.handleAPITermLogs)
-	mux.HandleFunc("/api/tlogs/", d.handleAPITermLogs)
+	mux.HandleFunc("/api/auth/login", d.handleAPILogin)
+	mux.HandleFunc("/api/auth/logout", d.handleAPILogout)
+	mux.HandleFunc("/api/auth/me", viewer(d.handleAPIMe))
+	mux.HandleFunc("/api/auth/users", admin(d.handleAPIUsers))
+	mux.HandleFunc("/api/auth/users/", admin(d.handleAPIUsers))
+	mux.HandleFunc("/api/auth/tokens", admin(d.handleAPITokens))
+	mux.HandleFunc("/api/auth/tokens/", admin(d.handleAPITokens))
+	mux.HandleFunc("/api/stats", viewer(d.handleAPIStats))
+	mux.HandleFunc("/api/connections", viewer(d.handleAPIConnections))
+	mux.HandleFunc("/api/sessions", viewer(d.handleAPISessions))
+	mux.HandleFunc("/api/session/", viewer(d.handleAPISessionDetail))
+	mux.HandleFunc("/api/terminals", viewer(d.handleAPITerminals))
+	mux.HandleFunc("/api/terminal/", viewer(d.handleAPITerminalDetail))
+	mux.HandleFunc("/api/logs", viewer(d.handleAPILogs))
+	mux.HandleFunc("/api/config", viewer(d.handleAPIConfig))
+	mux.HandleFunc("/api/events", viewer(d.handleAPIEvents)) // SSE для real-time обновлений
+	mux.HandleFunc("/api/cardlist", operator(d.handleAPICardList))
+	mux.HandleFunc("/api/cardlist/", operator(d.handleAPICardList))
+	mux.HandleFunc("/api/memreg", operator(d.handleAPIMemReg))
+	mux.HandleFunc("/api/memreg/", operator(d.handleAPIMemReg))
+	mux.HandleFunc("/api/system/halt", admin(d.handleAPIHalt))
+	mux.HandleFunc("/api/system/settings", viewer(d.handleAPISettings))
+	mux.HandleFunc("/api/system/settings/", viewer(d.handleAPISettings))
+	mux.HandleFunc("/api/terminals/add", admin(d.handleAPITerminalsAdd))
+	mux.HandleFunc("/api/terminals/del", admin(d.handleAPITerminalsDel))
+	mux.HandleFunc("/api/terminals/check", admin(d.handleAPITerminalsCheck))
+	mux.HandleFunc("/api/tlogs", viewer(d.handleAPITermLogs))
+	mux.HandleFunc("/api/tlogs/", viewer(d.handleAPITermLogs))
+	mux.HandleFunc("/api/keys", admin(d.handleAPIKeys))
+	mux.HandleFunc("/api/keys/", admin(d.handleAPIKeys))
+	mux.HandleFunc("/api/audit", admin(d.handleAPIAudit))
+	mux.HandleFunc("/metrics", viewer(d.handleMetrics)) // Prometheus
 
 	// Create server
 	d.webServer = &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", d.config.WebAddr, d.config.WebPort),
 		Handler: mux,
 	}
+	if d.config.WebTLSEnabled {
+		tlsConfig, err := d.webTLSConfig()
+		if err != nil {
+			return err
+		}
+		d.webServer.TLSConfig = tlsConfig
+	}
 
 	// Start server in goroutine
 	go func() {
-		if err := d.webServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if d.webServer.TLSConfig != nil {
+			err = d.webServer.ListenAndServeTLS("", "")
+		} else {
+			err = d.webServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			d.logger.Error(fmt.Sprintf("Web server error: %v", err))
 		}
 	}()
 
+	if d.config.WebTLSEnabled && d.config.WebTLSRedirectPort > 0 {
+		d.startWebRedirect()
+	}
+
 	return nil
 }
 
@@ -1751,6 +2036,7 @@ func (d *Daemon) handleAPIStats(w http.ResponseWriter, r *http.Request) {
 		"sessions":      len(sessions),
 		"start_time":    d.startTime.Unix(),
 		"uptime":        uptime,
+		"latency":       d.sessionMgr.GetLatencyStats(), // p50/p95 by terminal, ms
 	}
 
 	json.NewEncoder(w).Encode(stats)
@@ -0,0 +1,133 @@
+package daemon
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"nd-go/internal/metrics"
+)
+
+// Event broadcaster settings
+const (
+	EVENT_RING_SIZE     = 1000 // last events kept for Last-Event-ID replay
+	EVENT_CLIENT_BUFFER = 256  // events queued per subscriber before it is disconnected
+)
+
+var sseSlowDisconnects = metrics.NewCounter("skd_sse_slow_disconnects_total",
+	"/api/events subscribers disconnected because their buffer was full")
+
+// webEvent is event encoded once for all subscribers
+type webEvent struct {
+	ID   uint64
+	Data []byte
+}
+
+// eventSubscriber receives events of /api/events stream
+type eventSubscriber struct {
+	ch chan webEvent // closed when subscriber is too slow
+}
+
+// eventBroadcaster fans out web events to all subscribers and keeps ring of
+// last events. Publishing never blocks: subscriber whose buffer is full is
+// disconnected and resumes by Last-Event-ID from ring.
+type eventBroadcaster struct {
+	mutex  sync.Mutex
+	lastID uint64
+	ring   []webEvent // up to EVENT_RING_SIZE events, oldest at ring[start]
+	start  int
+	subs   map[*eventSubscriber]struct{}
+}
+
+func newEventBroadcaster() *eventBroadcaster {
+	return &eventBroadcaster{
+		ring: make([]webEvent, 0, EVENT_RING_SIZE),
+		subs: make(map[*eventSubscriber]struct{}),
+	}
+}
+
+// publish assigns next ID to event, stores it in ring and sends to subscribers
+func (b *eventBroadcaster) publish(event map[string]interface{}) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.lastID++
+	event["id"] = b.lastID
+	data, err := json.Marshal(event)
+	if err != nil {
+		data, _ = json.Marshal(map[string]interface{}{"id": b.lastID, "type": event["type"], "error": err.Error()})
+	}
+	ev := webEvent{ID: b.lastID, Data: data}
+
+	if len(b.ring) < EVENT_RING_SIZE {
+		b.ring = append(b.ring, ev)
+	} else {
+		b.ring[b.start] = ev
+		b.start = (b.start + 1) % EVENT_RING_SIZE
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			// Slow subscriber: disconnect, it replays missed events on reconnect
+			close(sub.ch)
+			delete(b.subs, sub)
+			sseSlowDisconnects.Inc()
+		}
+	}
+}
+
+// subscribe adds subscriber and returns events after lastEventID (value of
+// Last-Event-ID) from ring, missed is true if part of them is not in ring
+// anymore. Unknown or newer ID (daemon restarted) replays whole ring, "" - nothing.
+func (b *eventBroadcaster) subscribe(lastEventID string) (sub *eventSubscriber, backlog []webEvent, missed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	sub = &eventSubscriber{ch: make(chan webEvent, EVENT_CLIENT_BUFFER)}
+	b.subs[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil, false
+	}
+	after, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil || after > b.lastID {
+		after = 0
+	}
+	for i := 0; i < len(b.ring); i++ {
+		ev := b.ring[(b.start+i)%len(b.ring)]
+		if ev.ID > after {
+			backlog = append(backlog, ev)
+		}
+	}
+	missed = len(backlog) > 0 && backlog[0].ID > after+1
+	return sub, backlog, missed
+}
+
+// unsubscribe removes subscriber (no-op if it was disconnected as slow)
+func (b *eventBroadcaster) unsubscribe(sub *eventSubscriber) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// subscribers returns number of connected subscribers
+func (b *eventBroadcaster) subscribers() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.subs)
+}
+
+// sendEvent publishes event to /api/events subscribers
+func (d *Daemon) sendEvent(eventType string, data map[string]interface{}) {
+	d.events.publish(map[string]interface{}{
+		"type":      eventType,
+		"timestamp": time.Now().Unix(),
+		"data":      data,
+	})
+}
@@ -0,0 +1,80 @@
+package daemon
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"nd-go/internal/face"
+	"nd-go/pkg/types"
+)
+
+// FACE_HOLD_TIME is max time verification result waits for session to store
+// request ID of its face request
+const FACE_HOLD_TIME = 10 * time.Second
+
+// heldFaceEvent is verification result which came before session stored request
+// ID: provider may answer before Verify returns (CRT person already seen)
+type heldFaceEvent struct {
+	event *face.Event
+	time  time.Time
+}
+
+// faceEventHold keeps early verification results by session ID
+type faceEventHold struct {
+	mutex  sync.Mutex
+	events map[string]heldFaceEvent
+}
+
+func newFaceEventHold() *faceEventHold {
+	return &faceEventHold{events: make(map[string]heldFaceEvent)}
+}
+
+// hold keeps result of session's pending face request
+func (h *faceEventHold) hold(event *face.Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.events[event.SessionID] = heldFaceEvent{event: event, time: time.Now()}
+}
+
+// shouldHoldFaceEvent checks if result of other request than session stored
+// may belong to face request being started: session waits for result
+func shouldHoldFaceEvent(event *face.Event, camData map[string]interface{}) bool {
+	switch event.Type {
+	case face.EVENT_YES, face.EVENT_NO, face.EVENT_NF, face.EVENT_FAIL:
+	default:
+		return false
+	}
+	result, ok := camData["result"].(types.CamResult)
+	return ok && result == types.CAM_RES_UNDEF
+}
+
+// replayFaceEvents passes held results to sessions which stored their request
+// ID, results of removed sessions and expired ones are dropped
+func (d *Daemon) replayFaceEvents() {
+	d.faceHold.mutex.Lock()
+	var ready []*face.Event
+	for sessionID, held := range d.faceHold.events {
+		session := d.sessionMgr.GetSession(sessionID)
+		if session == nil {
+			delete(d.faceHold.events, sessionID)
+			continue
+		}
+		camData, _ := session.Data["cam"].(map[string]interface{})
+		if rkey, _ := camData["rkey"].(string); rkey == held.event.RequestID {
+			ready = append(ready, held.event)
+			delete(d.faceHold.events, sessionID)
+			continue
+		}
+		if time.Since(held.time) > FACE_HOLD_TIME {
+			d.logger.Warn(fmt.Sprintf("Face (%s) result of request %s dropped: session %s didn't start it",
+				held.event.Provider, held.event.RequestID, sessionID))
+			delete(d.faceHold.events, sessionID)
+		}
+	}
+	d.faceHold.mutex.Unlock()
+
+	for _, event := range ready {
+		d.handleFaceEvent(event)
+	}
+}
@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"nd-go/internal/metrics"
+)
+
+// registerMetrics registers gauges collected at scrape time from daemon state
+func (d *Daemon) registerMetrics() {
+	metrics.NewGaugeFunc("skd_terminal_connected",
+		"Terminal connection state (1 - connected)", []string{"terminal", "id", "type"},
+		func() []metrics.Sample {
+			return d.terminalSamples("connected")
+		})
+	metrics.NewGaugeFunc("skd_terminal_reconnecting",
+		"Terminal is in reconnection queue (1 - reconnecting)", []string{"terminal", "id", "type"},
+		func() []metrics.Sample {
+			return d.terminalSamples("reconnecting")
+		})
+	metrics.NewGaugeFunc("skd_cardlist_size",
+		"Cards in deny list", []string{"list"},
+		func() []metrics.Sample {
+			if d.cardList == nil {
+				return nil
+			}
+			return []metrics.Sample{
+				{Labels: []string{"gmclist"}, Value: float64(len(d.cardList.GetGlobalList()))},
+				{Labels: []string{"mclist"}, Value: float64(len(d.cardList.GetSecondaryList()))},
+			}
+		})
+	metrics.NewGaugeFunc("skd_sse_subscribers",
+		"Connected /api/events subscribers", nil,
+		func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(d.events.subscribers())}}
+		})
+	metrics.NewGaugeFunc("skd_sessions_active",
+		"Sessions in progress", nil,
+		func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(len(d.sessionMgr.GetActiveSessions()))}}
+		})
+	metrics.NewGaugeFunc("skd_uptime_seconds",
+		"Time since daemon start", nil,
+		func() []metrics.Sample {
+			return []metrics.Sample{{Value: time.Since(d.startTime).Seconds()}}
+		})
+	metrics.NewGaugeFunc("go_goroutines",
+		"Number of goroutines", nil,
+		func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(runtime.NumGoroutine())}}
+		})
+}
+
+// terminalSamples returns 0/1 samples of boolean field ("connected" or
+// "reconnecting") of terminal list. Connections and reconnections hidden from
+// list by term_list filter are added too.
+func (d *Daemon) terminalSamples(field string) []metrics.Sample {
+	var samples []metrics.Sample
+	listed := make(map[string]bool)
+	add := func(terminal, id, termType string, on bool) {
+		if listed[terminal] {
+			return
+		}
+		listed[terminal] = true
+		value := 0.0
+		if on {
+			value = 1
+		}
+		samples = append(samples, metrics.Sample{Labels: []string{terminal, id, termType}, Value: value})
+	}
+
+	for _, t := range d.pool.GetTerminalList() {
+		on, _ := t[field].(bool)
+		add(fmt.Sprintf("%v:%v", t["ip"], t["port"]), fmt.Sprintf("%v", t["id"]), fmt.Sprintf("%v", t["type"]), on)
+	}
+	for key, conn := range d.pool.GetConnections() {
+		id, termType := "", ""
+		if conn.Settings != nil {
+			id, termType = conn.Settings.ID, string(conn.Settings.Type)
+		}
+		add(key, id, termType, field == "connected" && conn.Connected)
+	}
+	for _, reconn := range d.pool.GetReconnections() {
+		id, termType := "", ""
+		if reconn.Settings != nil {
+			id, termType = reconn.Settings.ID, string(reconn.Settings.Type)
+		}
+		add(fmt.Sprintf("%s:%d", reconn.IP, reconn.Port), id, termType, field == "reconnecting")
+	}
+	return samples
+}
+
+// handleMetrics serves metrics in Prometheus text format
+func (d *Daemon) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := metrics.Default.WriteText(w); err != nil {
+		d.logger.Warn(fmt.Sprintf("Metrics write error: %v", err))
+	}
+}
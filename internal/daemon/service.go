@@ -0,0 +1,178 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+
+	"nd-go/internal/audit"
+	"nd-go/pkg/types"
+)
+
+// Terminal list and runtime settings operations shared by REST API and TCP console
+
+// terminalAddRequest represents a terminal add request
+type terminalAddRequest struct {
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Role     string `json:"role"`
+	RegQuery bool   `json:"reg_query"`
+}
+
+// addr returns terminal address "ip:port"
+func (t terminalAddRequest) addr() string {
+	return fmt.Sprintf("%s:%d", t.IP, t.Port)
+}
+
+// terminalType converts terminal type name, pocket by default
+func terminalType(name string) types.TerminalType {
+	switch strings.ToLower(name) {
+	case "gat":
+		return types.TTYPE_GAT
+	case "sphinx":
+		return types.TTYPE_SPHINX
+	case "jsp":
+		return types.TTYPE_JSP
+	case "wiegand":
+		return types.TTYPE_WIEGAND
+	case "osdp":
+		return types.TTYPE_OSDP
+	case "modbus":
+		return types.TTYPE_MODBUS
+	}
+	return types.TTYPE_POCKET
+}
+
+// startTerminal connects to terminal and applies its settings, returns connection key
+func (d *Daemon) startTerminal(t terminalAddRequest) (string, error) {
+	var errCode int
+	var errStr string
+	key, err := d.pool.StartClient(t.IP, t.Port, d.config.TerminalConnectTimeout, &errCode, &errStr)
+	if err != nil {
+		return "", err
+	}
+
+	if conn := d.pool.GetConnection(key); conn != nil {
+		settings := conn.Settings
+		if settings == nil {
+			settings = &types.TerminalSettings{}
+		}
+		settings.Type = terminalType(t.Type)
+		settings.ID = t.ID
+		settings.CTRole = t.Role
+		settings.RegQuery = t.RegQuery
+		d.pool.SetSettings(key, settings)
+	}
+	return key, nil
+}
+
+// addTerminals connects terminals, skipping invalid and unreachable ones
+func (d *Daemon) addTerminals(terminals []terminalAddRequest) []map[string]interface{} {
+	var added []map[string]interface{}
+	for _, t := range terminals {
+		if t.IP == "" || t.Port <= 0 {
+			continue
+		}
+		key, err := d.startTerminal(t)
+		if err != nil {
+			d.logger.Warn(fmt.Sprintf("Failed to add terminal %s: %v", t.addr(), err))
+			continue
+		}
+		added = append(added, map[string]interface{}{
+			"key":  key,
+			"ip":   t.IP,
+			"port": t.Port,
+			"id":   t.ID,
+			"type": t.Type,
+		})
+	}
+	return added
+}
+
+// delTerminals drops terminals by connection key or "ip:port", returns removed keys
+func (d *Daemon) delTerminals(keys []string) []string {
+	var removed []string
+	for _, key := range keys {
+		// Try to find by ip:port if key contains ':' or '.'
+		if strings.Contains(key, ":") || strings.Contains(key, ".") {
+			for connKey, conn := range d.pool.GetConnections() {
+				if fmt.Sprintf("%s:%d", conn.Addr, conn.Port) == key {
+					key = connKey
+					break
+				}
+			}
+		}
+
+		if d.pool.GetConnection(key) != nil {
+			d.pool.DropConnection(key)
+			removed = append(removed, key)
+		}
+	}
+	return removed
+}
+
+// checkTerminals syncs connected terminals with list: drops missing ones and
+// connects new ones. Result: {"add": [...], "del": [...], "upd": [...]}
+func (d *Daemon) checkTerminals(terminals []terminalAddRequest) map[string]interface{} {
+	// Build incoming set
+	incoming := make(map[string]terminalAddRequest)
+	for _, t := range terminals {
+		if t.IP != "" && t.Port > 0 {
+			incoming[t.addr()] = t
+		}
+	}
+
+	result := map[string]interface{}{
+		"add": []interface{}{},
+		"del": []interface{}{},
+		"upd": []interface{}{},
+	}
+
+	// Find terminals to delete (exist in pool but not in incoming)
+	connected := make(map[string]bool)
+	var toDel []string
+	for connKey, conn := range d.pool.GetConnections() {
+		addr := fmt.Sprintf("%s:%d", conn.Addr, conn.Port)
+		connected[addr] = true
+		if _, exists := incoming[addr]; !exists {
+			toDel = append(toDel, connKey)
+		}
+	}
+	for _, key := range toDel {
+		d.pool.DropConnection(key)
+	}
+	if len(toDel) > 0 {
+		result["del"] = toDel
+	}
+
+	// Add missing terminals
+	var added []map[string]interface{}
+	for addr, t := range incoming {
+		if connected[addr] {
+			continue
+		}
+		key, err := d.startTerminal(t)
+		if err != nil {
+			continue
+		}
+		added = append(added, map[string]interface{}{"key": key, "ip": t.IP, "port": t.Port, "id": t.ID})
+	}
+	if len(added) > 0 {
+		result["add"] = added
+	}
+	return result
+}
+
+// changeSetting sets runtime setting, filling audit entry with values before and after
+func (d *Daemon) changeSetting(e *audit.Entry, key string, value interface{}) error {
+	e.Action = "settings.set"
+	e.Target = key
+	e.SetBefore(d.getSettingValue(key))
+	if !d.setSettingValue(key, value) {
+		e.SetAfter(value)
+		return fmt.Errorf("setting %s not found or invalid value", key)
+	}
+	e.SetAfter(d.getSettingValue(key))
+	return nil
+}
@@ -0,0 +1,69 @@
+package daemon
+
+import (
+	"fmt"
+
+	"nd-go/internal/session"
+	"nd-go/internal/tracing"
+)
+
+// startTracing enables export of access session traces to OTLP collector
+// and/or file (tracing.enabled)
+func (d *Daemon) startTracing() {
+	if !d.config.TracingEnabled {
+		return
+	}
+
+	var exporters []tracing.Exporter
+	if d.config.TracingOTLPURL != "" {
+		exporter, err := tracing.NewOTLPExporter(d.config.TracingOTLPURL, d.config.TracingOTLPHeaders)
+		if err != nil {
+			d.logger.Warn(fmt.Sprintf("Tracing: OTLP exporter disabled: %v", err))
+		} else {
+			exporters = append(exporters, exporter)
+		}
+	}
+	if d.config.TracingFile != "" {
+		exporter, err := tracing.NewFileExporter(d.config.TracingFile)
+		if err != nil {
+			d.logger.Warn(fmt.Sprintf("Tracing: file exporter disabled: %v", err))
+		} else {
+			exporters = append(exporters, exporter)
+		}
+	}
+	if len(exporters) == 0 {
+		d.logger.Warn("Tracing enabled but no exporter configured (tracing.otlp_url, tracing.file)")
+		return
+	}
+
+	tracing.SetupIDs(d.config.TracingIncludeIDs, d.config.TracingIDKey)
+	tracing.Setup(d.config.TracingServiceName, exporters...)
+	d.logger.Info(fmt.Sprintf("Tracing started: otlp=%q, file=%q", d.config.TracingOTLPURL, d.config.TracingFile))
+}
+
+// startReadTrace starts trace of identifier read on terminal (kind: "card",
+// "barcode", "face") with tag_read span, parent is traceparent of face
+// identification or ""
+func startReadTrace(connKey string, uid string, kind string, parent string, attrs map[string]interface{}) *tracing.Span {
+	trace := session.StartTrace(connKey, uid, kind, parent)
+	read := trace.Child(session.SPAN_TAG_READ)
+	for key, value := range attrs {
+		read.SetAttr(key, value)
+	}
+	read.End()
+	return trace
+}
+
+// startLocalChecks starts span of deny list checks of read
+func startLocalChecks(trace *tracing.Span) *tracing.Span {
+	return trace.Child(session.SPAN_LOCAL_CHECKS)
+}
+
+// endDeniedTrace ends trace of read denied before session start
+func endDeniedTrace(trace *tracing.Span, checks *tracing.Span, reason string) {
+	checks.SetAttr("skd.deny_reason", reason)
+	checks.End()
+	trace.SetAttr("skd.result", "deny")
+	trace.SetAttr("skd.deny_reason", reason)
+	trace.End()
+}
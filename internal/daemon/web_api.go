@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"nd-go/internal/cardlist"
 	"nd-go/pkg/types"
+	"nd-go/pkg/utils"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -51,6 +53,7 @@ func (d *Daemon) handleAPISessionDetail(w http.ResponseWriter, r *http.Request)
 		"completed":   session.Completed,
 		"report_sent": session.ReportSent,
 		"data":        session.Data,
+		"timeline":    sessionTimeline(session),
 	}
 
 	if conn != nil {
@@ -65,6 +68,37 @@ func (d *Daemon) handleAPISessionDetail(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(result)
 }
 
+// sessionTimeline returns session stages with start offsets from tag read, ms.
+// Last item is current stage (duration so far) or DONE of completed session.
+func sessionTimeline(session *types.Session) []map[string]interface{} {
+	offset := func(t time.Time) float64 {
+		return float64(t.Sub(session.ReqTime).Microseconds()) / 1000
+	}
+	timeline := make([]map[string]interface{}, 0, len(session.Stages)+1)
+	for _, st := range session.Stages {
+		timeline = append(timeline, map[string]interface{}{
+			"stage":       st.Stage,
+			"start":       st.Start.UnixMilli(),
+			"offset_ms":   offset(st.Start),
+			"duration_ms": st.Duration,
+		})
+	}
+	if !session.StageTime.IsZero() {
+		current := map[string]interface{}{
+			"stage":       session.Stage.String(),
+			"start":       session.StageTime.UnixMilli(),
+			"offset_ms":   offset(session.StageTime),
+			"duration_ms": 0.0,
+			"current":     !session.Completed,
+		}
+		if !session.Completed {
+			current["duration_ms"] = float64(time.Since(session.StageTime).Microseconds()) / 1000
+		}
+		timeline = append(timeline, current)
+	}
+	return timeline
+}
+
 // handleAPITerminals serves terminals list with management options
 func (d *Daemon) handleAPITerminals(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -118,6 +152,12 @@ func (d *Daemon) handleAPITerminalDetail(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Terminal configuration: /api/terminal/{id}/config[/read]
+	if pos := strings.Index(path, "/config"); pos > 0 {
+		d.handleAPITerminalConfig(w, r, path[:pos], strings.TrimPrefix(path[pos:], "/config"))
+		return
+	}
+
 	// Decode URL-encoded key
 	key, err := url.QueryUnescape(path)
 	if err != nil {
@@ -166,6 +206,162 @@ func (d *Daemon) handleAPITerminalDetail(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(result)
 }
 
+// handleAPITerminalConfig handles POCKET terminal configuration:
+// GET - profile, readback and drift; POST - push profile (body or configured);
+// POST .../read - request readback
+func (d *Daemon) handleAPITerminalConfig(w http.ResponseWriter, r *http.Request, id string, action string) {
+	if unescaped, err := url.QueryUnescape(id); err == nil {
+		id = unescaped
+	}
+	key := d.findTerminalKey(id)
+	if key == "" {
+		http.Error(w, fmt.Sprintf(`{"error":"Terminal not found: %s"}`, id), http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		state, err := d.pool.GetPocketConfig(key)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": state})
+
+	case action == "" && r.Method == http.MethodPost:
+		var profile map[string]interface{}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+				http.Error(w, `{"error":"Invalid JSON"}`, http.StatusBadRequest)
+				return
+			}
+		}
+		if err := d.pool.PushPocketConfig(key, profile); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			return
+		}
+		d.logger.Info(fmt.Sprintf("POCKET config pushed to %s via API", key))
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": "Конфигурация отправлена"})
+
+	case action == "/read" && r.Method == http.MethodPost:
+		if err := d.pool.ReadPocketConfig(key); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": "Запрос конфигурации отправлен"})
+
+	default:
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// findTerminalKey finds connection key by key, "ip:port" or terminal ID
+func (d *Daemon) findTerminalKey(id string) string {
+	for k, c := range d.pool.GetConnections() {
+		if k == id || c.Addr+":"+strconv.Itoa(c.Port) == id {
+			return k
+		}
+		if c.Settings != nil && c.Settings.ID != "" && c.Settings.ID == id {
+			return k
+		}
+	}
+	return ""
+}
+
+// keysRequest is body of /api/keys/rotate and /api/keys/import
+type keysRequest struct {
+	Keys      map[string]string `json:"keys"`      // sector -> key hex
+	Terminals []string          `json:"terminals"` // rotate only, default all connected POCKET terminals
+}
+
+// handleAPIKeys serves MIFARE sector key generations of POCKET readers:
+// GET /api/keys, POST /api/keys/rotate, POST /api/keys/import
+func (d *Daemon) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if d.keyring == nil {
+		http.Error(w, `{"error":"keyring not initialized (set POCKET_KEYS_MASTER_KEY)"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/keys"), "/")
+	if r.Method == http.MethodGet && action == "" {
+		status := d.keyring.Status()
+		status["connected"] = d.pocketTerminalKeys()
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": status})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req keysRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"Invalid JSON"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	keys := make(map[int]string, len(req.Keys))
+	for sector, key := range req.Keys {
+		n, err := strconv.Atoi(sector)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"invalid sector: %s"}`, sector), http.StatusBadRequest)
+			return
+		}
+		keys[n] = key
+	}
+
+	switch action {
+	case "rotate":
+		terminals := d.pocketTerminalKeys()
+		if len(req.Terminals) > 0 {
+			terminals = nil
+			for _, id := range req.Terminals {
+				key := d.findTerminalKey(id)
+				if key == "" {
+					http.Error(w, fmt.Sprintf(`{"error":"Terminal not found: %s"}`, id), http.StatusNotFound)
+					return
+				}
+				terminals = append(terminals, key)
+			}
+		}
+		timeout := time.Duration(d.config.PocketKeyConfirmTimeout * float64(time.Second))
+		gen, err := d.keyring.Rotate(keys, terminals, d.pool, timeout)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusConflict)
+			return
+		}
+		d.logger.Info(fmt.Sprintf("POCKET key generation %d rollout started via API (%d terminals)", gen, len(terminals)))
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": map[string]interface{}{"gen": gen}})
+
+	case "import":
+		gen, err := d.keyring.Import(keys)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			return
+		}
+		d.logger.Info(fmt.Sprintf("POCKET key generation %d imported via API", gen))
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": map[string]interface{}{"gen": gen}})
+
+	default:
+		http.Error(w, `{"error":"unknown action (rotate/import)"}`, http.StatusBadRequest)
+	}
+}
+
+// pocketTerminalKeys returns sorted keys of connected POCKET terminals
+func (d *Daemon) pocketTerminalKeys() []string {
+	keys := []string{}
+	for k, c := range d.pool.GetConnections() {
+		if c.Connected && c.Settings != nil && c.Settings.Type == types.TTYPE_POCKET {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // handleAPILogs serves log entries
 func (d *Daemon) handleAPILogs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -211,17 +407,40 @@ func (d *Daemon) handleAPIConfig(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(safeConfig)
 }
 
-// handleAPIEvents serves Server-Sent Events for real-time updates
+// handleAPIEvents serves Server-Sent Events for real-time updates. Events have
+// increasing IDs, reconnecting client gets events missed after Last-Event-ID
+// header (or last_event_id query parameter) from ring of last events.
 func (d *Daemon) handleAPIEvents(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
-	// Send initial connection message
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	sub, backlog, missed := d.events.subscribe(lastEventID)
+	defer d.events.unsubscribe(sub)
+
+	flush := func() {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	writeEvent := func(ev webEvent) {
+		fmt.Fprintf(w, "id: %d\ndata: %s\n\n", ev.ID, ev.Data)
+	}
+
+	// Send initial connection message and replay of missed events
 	fmt.Fprintf(w, "data: {\"type\":\"connected\"}\n\n")
-	if f, ok := w.(http.Flusher); ok {
-		f.Flush()
+	if missed {
+		// Part of missed events is not in ring anymore
+		fmt.Fprintf(w, "data: {\"type\":\"events_lost\",\"data\":{\"first_id\":%d}}\n\n", backlog[0].ID)
+	}
+	for _, ev := range backlog {
+		writeEvent(ev)
 	}
+	flush()
 
 	// Send events
 	ticker := time.NewTicker(5 * time.Second)
@@ -229,18 +448,17 @@ func (d *Daemon) handleAPIEvents(w http.ResponseWriter, r *http.Request) {
 
 	for {
 		select {
-		case event := <-d.eventCh:
-			data, _ := json.Marshal(event)
-			fmt.Fprintf(w, "data: %s\n\n", data)
-			if f, ok := w.(http.Flusher); ok {
-				f.Flush()
+		case ev, ok := <-sub.ch:
+			if !ok {
+				// Disconnected as slow subscriber, client reconnects with Last-Event-ID
+				return
 			}
+			writeEvent(ev)
+			flush()
 		case <-ticker.C:
 			// Send heartbeat
 			fmt.Fprintf(w, ": heartbeat\n\n")
-			if f, ok := w.(http.Flusher); ok {
-				f.Flush()
-			}
+			flush()
 		case <-r.Context().Done():
 			return
 		}
@@ -258,7 +476,6 @@ func (d *Daemon) handleAPIEvents(w http.ResponseWriter, r *http.Request) {
 // POST   /api/cardlist/secondary/del - remove from mclist
 func (d *Daemon) handleAPICardList(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	if d.cardList == nil {
 		http.Error(w, `{"error":"card list not initialized"}`, http.StatusInternalServerError)
@@ -299,6 +516,11 @@ func (d *Daemon) handleAPICardList(w http.ResponseWriter, r *http.Request) {
 	listType := pathParts[1] // "global" or "secondary"
 	action := pathParts[2]   // "add", "del", "sync"
 
+	e := requestAudit(r)
+	e.Action = "cardlist." + action
+	e.Target = listType
+	defer auditChanges(e, d.cardListSnapshot(listType), func() map[string]string { return d.cardListSnapshot(listType) })
+
 	switch action {
 	case "add":
 		var entries []cardListEntry
@@ -365,6 +587,63 @@ type cardlistEntryConvert struct {
 	Message string
 }
 
+// handleAPIMemReg handles MEMREG storages (registered towels, keys...)
+// GET  /api/memreg                 - all storages: storage -> uid -> value
+// GET  /api/memreg/{storage}       - uids of storage
+// POST /api/memreg/{storage}/del   - remove uids: body ["uid1", "uid2"]
+func (d *Daemon) handleAPIMemReg(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/memreg"), "/"), "/")
+	storage := parts[0]
+	memreg := utils.GetMemRegStorage()
+
+	if r.Method == http.MethodGet {
+		all := memreg.Snapshot()
+		if storage == "" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": all})
+			return
+		}
+		uids := all[storage]
+		if uids == nil {
+			uids = map[string]interface{}{}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": uids})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if storage == "" || len(parts) < 2 || parts[1] != "del" {
+		http.Error(w, `{"error":"use POST /api/memreg/{storage}/del"}`, http.StatusBadRequest)
+		return
+	}
+
+	var uids []string
+	if err := json.NewDecoder(r.Body).Decode(&uids); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	e := requestAudit(r)
+	e.Action = "memreg.del"
+	e.Target = storage
+	defer auditChanges(e, d.memregSnapshot(storage), func() map[string]string { return d.memregSnapshot(storage) })
+
+	var removed []string
+	for _, uid := range uids {
+		if has, err := memreg.Has(storage, uid); err != nil || !has {
+			continue
+		}
+		if err := memreg.Del(storage, uid); err == nil {
+			removed = append(removed, uid)
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": removed})
+}
+
 // handleAPITermLogs handles terminal logs API with pagination (hndl_tlogs equivalent)
 // GET /api/tlogs                          - list all terminal keys with counts
 // GET /api/tlogs/{key}                    - get all entries for terminal
@@ -374,7 +653,6 @@ type cardlistEntryConvert struct {
 // GET /api/tlogs/{key}/page_r/{size}/{page} - get specific page (reversed)
 func (d *Daemon) handleAPITermLogs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	if d.termLogs == nil {
 		http.Error(w, `{"error":"term logs not initialized"}`, http.StatusInternalServerError)
@@ -451,7 +729,6 @@ func (d *Daemon) handleAPITermLogs(w http.ResponseWriter, r *http.Request) {
 // POST /api/system/settings/{key}        - set specific setting (body: {"value": ...})
 func (d *Daemon) handleAPISettings(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	path := strings.TrimPrefix(r.URL.Path, "/api/system/settings")
 	path = strings.Trim(path, "/")
@@ -460,15 +737,7 @@ func (d *Daemon) handleAPISettings(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		if path == "" {
 			// Return all editable settings
-			settings := map[string]interface{}{
-				"term_list_check_time": d.config.TermListCheckTime,
-				"log_event_count":     d.config.LogEventCount,
-				"log_dev_event_count": d.config.LogDevEventCount,
-				"cam_service_active":  d.config.CamServiceActive,
-				"crt_service_active":  d.config.CRTServiceActive,
-				"crt_check_time":      d.config.CRTCheckTime,
-			}
-			json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": settings})
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": d.runtimeSettings()})
 			return
 		}
 
@@ -497,8 +766,7 @@ func (d *Daemon) handleAPISettings(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		ok := d.setSettingValue(path, body.Value)
-		if !ok {
+		if err := d.changeSetting(requestAudit(r), path, body.Value); err != nil {
 			http.Error(w, `{"code":505,"error":"setting not found or invalid value"}`, http.StatusBadRequest)
 			return
 		}
@@ -509,6 +777,18 @@ func (d *Daemon) handleAPISettings(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 }
 
+// runtimeSettings returns all settings editable at runtime
+func (d *Daemon) runtimeSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"term_list_check_time": d.config.TermListCheckTime,
+		"log_event_count":      d.config.LogEventCount,
+		"log_dev_event_count":  d.config.LogDevEventCount,
+		"cam_service_active":   d.config.CamServiceActive,
+		"crt_service_active":   d.config.CRTServiceActive,
+		"crt_check_time":       d.config.CRTCheckTime,
+	}
+}
+
 // getSettingValue returns a runtime setting value by key
 func (d *Daemon) getSettingValue(key string) interface{} {
 	switch key {
@@ -566,6 +846,17 @@ func (d *Daemon) setSettingValue(key string, value interface{}) bool {
 	return false
 }
 
+// parseSettingValue converts text value (TCP console) to number, bool or string
+func parseSettingValue(s string) interface{} {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseBool(s); err == nil {
+		return v
+	}
+	return s
+}
+
 func toFloat64(v interface{}) (float64, bool) {
 	switch val := v.(type) {
 	case float64:
@@ -598,6 +889,8 @@ func toBool(v interface{}) (bool, bool) {
 		return val != 0, true
 	case int:
 		return val != 0, true
+	case int64:
+		return val != 0, true
 	}
 	return false, false
 }
@@ -614,13 +907,13 @@ func toCardEntries(entries []cardlistEntryConvert) []cardlist.CardEntry {
 // POST /api/system/halt
 func (d *Daemon) handleAPIHalt(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	if r.Method != http.MethodPost {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
 
+	requestAudit(r).Action = "system.halt"
 	json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": "shutting down"})
 
 	// Initiate shutdown in background
@@ -630,80 +923,28 @@ func (d *Daemon) handleAPIHalt(w http.ResponseWriter, r *http.Request) {
 	}()
 }
 
-// terminalAddRequest represents a terminal add request
-type terminalAddRequest struct {
-	IP       string `json:"ip"`
-	Port     int    `json:"port"`
-	ID       string `json:"id"`
-	Type     string `json:"type"`
-	Role     string `json:"role"`
-	RegQuery bool   `json:"reg_query"`
-}
-
 // handleAPITerminalsAdd adds terminals (ecmdh_termlist add equivalent)
 // POST /api/terminals/add
 // Body: [{"ip":"1.2.3.4", "port":9000, "id":"T001", "type":"pocket"}]
 func (d *Daemon) handleAPITerminalsAdd(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	if r.Method != http.MethodPost {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
 
+	e := requestAudit(r)
+	e.Action = "terminals.add"
+	defer auditChanges(e, d.terminalSnapshot(), d.terminalSnapshot)
+
 	var terminals []terminalAddRequest
 	if err := json.NewDecoder(r.Body).Decode(&terminals); err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
 		return
 	}
 
-	var added []map[string]interface{}
-	for _, t := range terminals {
-		if t.IP == "" || t.Port <= 0 {
-			continue
-		}
-
-		// Start client connection
-		var errCode int
-		var errStr string
-		key, err := d.pool.StartClient(t.IP, t.Port, d.config.TerminalConnectTimeout, &errCode, &errStr)
-		if err != nil {
-			d.logger.Warn(fmt.Sprintf("Failed to add terminal %s:%d: %v", t.IP, t.Port, err))
-			continue
-		}
-
-		// Set terminal settings
-		termType := types.TTYPE_POCKET
-		switch strings.ToLower(t.Type) {
-		case "gat":
-			termType = types.TTYPE_GAT
-		case "sphinx":
-			termType = types.TTYPE_SPHINX
-		case "jsp":
-			termType = types.TTYPE_JSP
-		}
-
-		conn := d.pool.GetConnection(key)
-		if conn != nil {
-			if conn.Settings == nil {
-				conn.Settings = &types.TerminalSettings{}
-			}
-			conn.Settings.Type = termType
-			conn.Settings.ID = t.ID
-			conn.Settings.CTRole = t.Role
-			conn.Settings.RegQuery = t.RegQuery
-		}
-
-		added = append(added, map[string]interface{}{
-			"key":  key,
-			"ip":   t.IP,
-			"port": t.Port,
-			"id":   t.ID,
-			"type": t.Type,
-		})
-	}
-
+	added := d.addTerminals(terminals)
 	if len(added) == 0 {
 		json.NewEncoder(w).Encode(map[string]interface{}{"code": 505, "error": "no valid terminals to add"})
 		return
@@ -717,40 +958,23 @@ func (d *Daemon) handleAPITerminalsAdd(w http.ResponseWriter, r *http.Request) {
 // Body: ["key1", "key2"] or ["ip:port", "ip:port"]
 func (d *Daemon) handleAPITerminalsDel(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	if r.Method != http.MethodPost {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
 
+	e := requestAudit(r)
+	e.Action = "terminals.del"
+	defer auditChanges(e, d.terminalSnapshot(), d.terminalSnapshot)
+
 	var keys []string
 	if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
 		return
 	}
 
-	var removed []string
-	for _, key := range keys {
-		// Try to find by ip:port if key contains ':' or '.'
-		if strings.Contains(key, ":") || strings.Contains(key, ".") {
-			// Find connection key by address
-			connections := d.pool.GetConnections()
-			for connKey, conn := range connections {
-				addr := fmt.Sprintf("%s:%d", conn.Addr, conn.Port)
-				if addr == key {
-					key = connKey
-					break
-				}
-			}
-		}
-
-		if d.pool.GetConnection(key) != nil {
-			d.pool.DropConnection(key)
-			removed = append(removed, key)
-		}
-	}
-
+	removed := d.delTerminals(keys)
 	if len(removed) == 0 {
 		json.NewEncoder(w).Encode(map[string]interface{}{"code": 505, "error": "no valid terminals to remove"})
 		return
@@ -764,99 +988,21 @@ func (d *Daemon) handleAPITerminalsDel(w http.ResponseWriter, r *http.Request) {
 // Body: [{"ip":"1.2.3.4", "port":9000, "id":"T001", "type":"pocket"}]
 func (d *Daemon) handleAPITerminalsCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	if r.Method != http.MethodPost {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
 
+	e := requestAudit(r)
+	e.Action = "terminals.check"
+	defer auditChanges(e, d.terminalSnapshot(), d.terminalSnapshot)
+
 	var terminals []terminalAddRequest
 	if err := json.NewDecoder(r.Body).Decode(&terminals); err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
 		return
 	}
 
-	// Build incoming set
-	incoming := make(map[string]terminalAddRequest)
-	for _, t := range terminals {
-		if t.IP != "" && t.Port > 0 {
-			key := fmt.Sprintf("%s:%d", t.IP, t.Port)
-			incoming[key] = t
-		}
-	}
-
-	result := map[string]interface{}{
-		"add": []interface{}{},
-		"del": []interface{}{},
-		"upd": []interface{}{},
-	}
-
-	// Find terminals to delete (exist in pool but not in incoming)
-	connections := d.pool.GetConnections()
-	var toDel []string
-	for connKey, conn := range connections {
-		addr := fmt.Sprintf("%s:%d", conn.Addr, conn.Port)
-		if _, exists := incoming[addr]; !exists {
-			toDel = append(toDel, connKey)
-		}
-	}
-	for _, key := range toDel {
-		d.pool.DropConnection(key)
-	}
-	if len(toDel) > 0 {
-		result["del"] = toDel
-	}
-
-	// Find terminals to add (in incoming but not in pool)
-	var toAdd []terminalAddRequest
-	for addr, t := range incoming {
-		found := false
-		for _, conn := range connections {
-			connAddr := fmt.Sprintf("%s:%d", conn.Addr, conn.Port)
-			if connAddr == addr {
-				found = true
-				break
-			}
-		}
-		if !found {
-			toAdd = append(toAdd, t)
-		}
-	}
-
-	// Add missing terminals
-	var addedResults []map[string]interface{}
-	for _, t := range toAdd {
-		var errCode int
-		var errStr string
-		key, err := d.pool.StartClient(t.IP, t.Port, d.config.TerminalConnectTimeout, &errCode, &errStr)
-		if err != nil {
-			continue
-		}
-		termType := types.TTYPE_POCKET
-		switch strings.ToLower(t.Type) {
-		case "gat":
-			termType = types.TTYPE_GAT
-		case "sphinx":
-			termType = types.TTYPE_SPHINX
-		case "jsp":
-			termType = types.TTYPE_JSP
-		}
-		conn := d.pool.GetConnection(key)
-		if conn != nil && conn.Settings == nil {
-			conn.Settings = &types.TerminalSettings{}
-		}
-		if conn != nil && conn.Settings != nil {
-			conn.Settings.Type = termType
-			conn.Settings.ID = t.ID
-			conn.Settings.CTRole = t.Role
-			conn.Settings.RegQuery = t.RegQuery
-		}
-		addedResults = append(addedResults, map[string]interface{}{"key": key, "ip": t.IP, "port": t.Port, "id": t.ID})
-	}
-	if len(addedResults) > 0 {
-		result["add"] = addedResults
-	}
-
-	json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": result})
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": d.checkTerminals(terminals)})
 }
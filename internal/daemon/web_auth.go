@@ -0,0 +1,449 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"nd-go/internal/audit"
+	"nd-go/internal/auth"
+)
+
+// Web session cookie name
+const SESSION_COOKIE = "skd_session"
+
+type authContextKey struct{}
+
+// openAuthStore opens users database and creates first admin if there are no users
+func (d *Daemon) openAuthStore() error {
+	store := auth.NewStore(d.config.WebAuthDBPath, time.Duration(d.config.WebSessionTTL)*time.Second)
+	if err := store.Open(); err != nil {
+		return err
+	}
+	generated, err := store.Bootstrap(d.config.WebAdminUser, d.config.WebAdminPassword)
+	if err != nil {
+		store.Close()
+		return fmt.Errorf("create admin user: %v", err)
+	}
+	if generated != "" {
+		// Shown once on console only, not written to log files
+		fmt.Printf("Web: created user %s (admin) with password: %s\n", d.config.WebAdminUser, generated)
+		fmt.Println("Web: change it by POST /api/auth/users/update or set web.auth.admin_password before first start")
+	}
+	d.authStore = store
+	return nil
+}
+
+// requestUser returns authenticated user of request: session cookie or API token
+// (Authorization: Bearer <token>)
+func (d *Daemon) requestUser(r *http.Request) *auth.User {
+	if user, ok := r.Context().Value(authContextKey{}).(*auth.User); ok {
+		return user
+	}
+	if d.authStore == nil {
+		return nil
+	}
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		user, err := d.authStore.CheckToken(strings.TrimSpace(strings.TrimPrefix(h, "Bearer ")))
+		if err != nil {
+			return nil
+		}
+		return user
+	}
+	if c, err := r.Cookie(SESSION_COOKIE); err == nil {
+		return d.authStore.Session(c.Value)
+	}
+	return nil
+}
+
+// withRole protects handler: reads (GET, HEAD) need readRole, other methods need
+// writeRole. Changing requests are written to audit trail.
+func (d *Daemon) withRole(readRole string, writeRole string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead
+		required := writeRole
+		if read {
+			required = readRole
+		}
+
+		var user *auth.User
+		if d.authStore != nil {
+			user = d.requestUser(r)
+			if user == nil {
+				w.Header().Set("Content-Type", "application/json")
+				http.Error(w, `{"error":"authentication required"}`, http.StatusUnauthorized)
+				return
+			}
+			if !auth.RoleAllows(user.Role, required) {
+				if !read {
+					e := newWebAudit(r, user)
+					e.Status = http.StatusForbidden
+					d.recordAudit(e)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				http.Error(w, fmt.Sprintf(`{"error":"role %s required"}`, required), http.StatusForbidden)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), authContextKey{}, user))
+		}
+
+		if read {
+			h(w, r)
+			return
+		}
+		e := newWebAudit(r, user)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h(rec, r.WithContext(context.WithValue(r.Context(), auditContextKey{}, e)))
+		e.Status = rec.status
+		d.recordAudit(e)
+	}
+}
+
+// newWebAudit creates audit entry of web request, action defaults to method and path
+func newWebAudit(r *http.Request, user *auth.User) *audit.Entry {
+	e := &audit.Entry{
+		Time:   time.Now(),
+		Source: audit.SOURCE_WEB,
+		IP:     clientIP(r),
+		Action: r.Method + " " + r.URL.Path,
+	}
+	if user != nil {
+		e.User, e.Role = user.Username, user.Role
+	}
+	return e
+}
+
+// statusRecorder keeps response status for audit log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// clientIP returns remote IP of request
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// handleAPILogin starts web session: POST /api/auth/login {"username":"...","password":"..."}
+func (d *Daemon) handleAPILogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if d.authStore == nil {
+		http.Error(w, `{"error":"authentication disabled"}`, http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	user, err := d.authStore.Authenticate(req.Username, req.Password)
+	if err != nil {
+		d.logger.Warn(fmt.Sprintf("AUDIT: login failed, user=%s, ip=%s", req.Username, clientIP(r)))
+		http.Error(w, `{"error":"invalid username or password"}`, http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SESSION_COOKIE,
+		Value:    d.authStore.NewSession(user),
+		Path:     "/",
+		MaxAge:   int(d.authStore.SessionTTL().Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	d.logger.Info(fmt.Sprintf("AUDIT: login, user=%s, role=%s, ip=%s", user.Username, user.Role, clientIP(r)))
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": user})
+}
+
+// handleAPILogout ends web session: POST /api/auth/logout
+func (d *Daemon) handleAPILogout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if c, err := r.Cookie(SESSION_COOKIE); err == nil && d.authStore != nil {
+		d.authStore.DeleteSession(c.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SESSION_COOKIE,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": "ok"})
+}
+
+// handleAPIMe serves current user: GET /api/auth/me
+func (d *Daemon) handleAPIMe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user := d.requestUser(r)
+	if user == nil {
+		// Authentication disabled
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": map[string]interface{}{}})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": user})
+}
+
+// userRequest is body of user management requests
+type userRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// handleAPIUsers manages web users (admin):
+// GET  /api/auth/users        - list users
+// POST /api/auth/users/add    - add user:    {"username":"...","password":"...","role":"viewer|operator|admin"}
+// POST /api/auth/users/update - change user: {"username":"...","password":"...","role":"..."} (empty fields kept)
+// POST /api/auth/users/del    - remove user: {"username":"..."}
+func (d *Daemon) handleAPIUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if d.authStore == nil {
+		http.Error(w, `{"error":"authentication disabled"}`, http.StatusNotFound)
+		return
+	}
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/auth/users"), "/")
+	if r.Method == http.MethodGet && action == "" {
+		users, err := d.authStore.ListUsers()
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": users})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req userRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" {
+		http.Error(w, `{"error":"username required"}`, http.StatusBadRequest)
+		return
+	}
+
+	e := requestAudit(r)
+	e.Action = "users." + action
+	e.Target = req.Username
+	if u, err := d.authStore.GetUser(req.Username); err == nil {
+		e.SetBefore(map[string]interface{}{"role": u.Role})
+	}
+	if action != "del" {
+		e.SetAfter(map[string]interface{}{"role": req.Role, "password_changed": req.Password != ""})
+	}
+
+	var err error
+	switch action {
+	case "add":
+		err = d.authStore.CreateUser(req.Username, req.Password, req.Role)
+	case "update":
+		err = d.authStore.UpdateUser(req.Username, req.Password, req.Role)
+	case "del":
+		if me := d.requestUser(r); me != nil && me.Username == req.Username {
+			http.Error(w, `{"error":"cannot delete yourself"}`, http.StatusBadRequest)
+			return
+		}
+		err = d.authStore.DeleteUser(req.Username)
+	default:
+		http.Error(w, `{"error":"unknown action (add/update/del)"}`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": req.Username})
+}
+
+// handleAPITokens manages API tokens (admin):
+// GET  /api/auth/tokens     - list tokens
+// POST /api/auth/tokens/add - create token: {"username":"...","name":"..."} (default current user),
+// token value is returned only once
+// POST /api/auth/tokens/del - revoke token: {"id":1}
+func (d *Daemon) handleAPITokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if d.authStore == nil {
+		http.Error(w, `{"error":"authentication disabled"}`, http.StatusNotFound)
+		return
+	}
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/auth/tokens"), "/")
+	if r.Method == http.MethodGet && action == "" {
+		tokens, err := d.authStore.ListTokens(r.URL.Query().Get("username"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": tokens})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+		Name     string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid JSON: %v"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	e := requestAudit(r)
+	e.Action = "tokens." + action
+
+	switch action {
+	case "add":
+		if req.Username == "" {
+			if me := d.requestUser(r); me != nil {
+				req.Username = me.Username
+			}
+		}
+		e.Target = req.Username
+		value, token, err := d.authStore.CreateToken(req.Username, req.Name)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": map[string]interface{}{
+			"token": value,
+			"info":  token,
+		}})
+	case "del":
+		e.Target = strconv.FormatInt(req.ID, 10)
+		if err := d.authStore.DeleteToken(req.ID, ""); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%v"}`, err), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "data": req.ID})
+	default:
+		http.Error(w, `{"error":"unknown action (add/del)"}`, http.StatusBadRequest)
+	}
+}
+
+// loginPageHTML is shown instead of web UI until user logs in
+const loginPageHTML = `<!DOCTYPE html>
+<html lang="ru">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>СКД - Вход</title>
+    <style>
+        body {
+            margin: 0;
+            min-height: 100vh;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
+        }
+        .login {
+            background: #ffffff;
+            border-radius: 12px;
+            padding: 30px;
+            width: 320px;
+            box-shadow: 0 10px 25px rgba(0, 0, 0, 0.15);
+        }
+        h1 {
+            margin: 0 0 20px;
+            font-size: 1.8rem;
+            color: #764ba2;
+        }
+        input, button {
+            width: 100%;
+            box-sizing: border-box;
+            padding: 10px;
+            margin-bottom: 12px;
+            font-size: 1rem;
+            border-radius: 8px;
+            border: 1px solid #e9ecef;
+        }
+        button {
+            background: #667eea;
+            color: #ffffff;
+            border: none;
+            cursor: pointer;
+        }
+        .error {
+            color: #dc3545;
+            min-height: 1.2em;
+        }
+    </style>
+</head>
+<body>
+    <form class="login" id="loginForm">
+        <h1>🚪 СКД</h1>
+        <input type="text" id="username" placeholder="Пользователь" autocomplete="username" required autofocus>
+        <input type="password" id="password" placeholder="Пароль" autocomplete="current-password" required>
+        <button type="submit">Войти</button>
+        <div class="error" id="error"></div>
+    </form>
+    <script>
+        document.getElementById('loginForm').addEventListener('submit', function(e) {
+            e.preventDefault();
+            fetch('/api/auth/login', {
+                method: 'POST',
+                headers: {'Content-Type': 'application/json'},
+                body: JSON.stringify({
+                    username: document.getElementById('username').value,
+                    password: document.getElementById('password').value
+                })
+            })
+            .then(r => {
+                if (r.ok) {
+                    window.location.reload();
+                    return;
+                }
+                document.getElementById('error').textContent = 'Неверный пользователь или пароль';
+            })
+            .catch(() => {
+                document.getElementById('error').textContent = 'Сервер недоступен';
+            });
+        });
+    </script>
+</body>
+</html>`
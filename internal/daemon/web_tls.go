@@ -0,0 +1,157 @@
+package daemon
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Self-signed certificate lifetime
+const SELF_SIGNED_CERT_DAYS = 825
+
+// webTLSConfig loads web certificate, generating self-signed one if files are
+// missing and web.tls.self_signed is set
+func (d *Daemon) webTLSConfig() (*tls.Config, error) {
+	certFile, keyFile := d.config.WebTLSCertFile, d.config.WebTLSKeyFile
+	if !fileExists(certFile) || !fileExists(keyFile) {
+		if !d.config.WebTLSSelfSigned {
+			return nil, fmt.Errorf("certificate %s or key %s not found", certFile, keyFile)
+		}
+		if err := d.generateSelfSignedCert(certFile, keyFile); err != nil {
+			return nil, fmt.Errorf("generate self-signed certificate: %v", err)
+		}
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %v", err)
+	}
+	if len(cert.Certificate) > 0 {
+		sum := sha256.Sum256(cert.Certificate[0])
+		d.logger.Info(fmt.Sprintf("Web certificate %s, SHA-256 fingerprint %s", certFile, hex.EncodeToString(sum[:])))
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// generateSelfSignedCert creates ECDSA P-256 certificate for host name, localhost
+// and all local IP addresses
+func (d *Daemon) generateSelfSignedCert(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hostname, Organization: []string{"СКД"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(0, 0, SELF_SIGNED_CERT_DAYS),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if hostname != "" && hostname != "localhost" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	if ip := net.ParseIP(d.config.WebAddr); ip != nil && !ip.IsUnspecified() && !ip.IsLoopback() {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				template.IPAddresses = append(template.IPAddresses, ipNet.IP)
+			}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{certFile, keyFile} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+
+	d.logger.Info(fmt.Sprintf("Web: generated self-signed certificate %s (%s, %d IP addresses), browsers will warn until it is trusted",
+		certFile, hostname, len(template.IPAddresses)))
+	return nil
+}
+
+// startWebRedirect starts HTTP listener redirecting to HTTPS web port
+func (d *Daemon) startWebRedirect() {
+	httpsPort := strconv.Itoa(d.config.WebPort)
+	d.webRedirect = &http.Server{
+		Addr: fmt.Sprintf("%s:%d", d.config.WebAddr, d.config.WebTLSRedirectPort),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(r.Host); err == nil {
+				host = h
+			}
+			target := "https://" + net.JoinHostPort(host, httpsPort) + r.URL.RequestURI()
+			code := http.StatusMovedPermanently
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				code = http.StatusPermanentRedirect // keep method and body
+			}
+			http.Redirect(w, r, target, code)
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := d.webRedirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			d.logger.Error(fmt.Sprintf("Web redirect server error: %v", err))
+		}
+	}()
+	d.logger.Info(fmt.Sprintf("Web HTTP->HTTPS redirect on %s:%d", d.config.WebAddr, d.config.WebTLSRedirectPort))
+}
+
+// webScheme returns web interface URL scheme
+func (d *Daemon) webScheme() string {
+	if d.config.WebTLSEnabled {
+		return "https"
+	}
+	return "http"
+}
+
+// fileExists checks if regular file exists
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
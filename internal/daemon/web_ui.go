@@ -8,6 +8,11 @@ import (
 func (d *Daemon) handleWebIndex(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	if d.authStore != nil && d.requestUser(r) == nil {
+		w.Write([]byte(loginPageHTML))
+		return
+	}
+
 	html := `<!DOCTYPE html>
 <html lang="ru">
 <head>
@@ -77,6 +82,16 @@ func (d *Daemon) handleWebIndex(w http.ResponseWriter, r *http.Request) {
             font-size: 1.1rem;
         }
 
+        .header .user {
+            float: right;
+            color: var(--text-secondary);
+        }
+
+        .header .user a {
+            margin-left: 10px;
+            color: #667eea;
+        }
+
         .stats-grid {
             display: grid;
             grid-template-columns: repeat(auto-fit, minmax(250px, 1fr));
@@ -430,6 +445,7 @@ func (d *Daemon) handleWebIndex(w http.ResponseWriter, r *http.Request) {
 <body>
     <div class="container">
         <div class="header">
+            <div class="user" id="currentUser"></div>
             <h1>🚪 СКД</h1>
             <p>Система контроля доступа - Мониторинг и управление</p>
         </div>
@@ -589,6 +605,38 @@ func (d *Daemon) handleWebIndex(w http.ResponseWriter, r *http.Request) {
     </div>
 
     <script>
+        // Session expired or logged out - back to login page
+        const apiFetch = window.fetch.bind(window);
+        window.fetch = function(url, options) {
+            return apiFetch(url, options).then(response => {
+                if (response.status === 401) {
+                    window.location.reload();
+                }
+                return response;
+            });
+        };
+
+        function loadCurrentUser() {
+            fetch('/api/auth/me')
+                .then(r => r.json())
+                .then(data => {
+                    if (!data.data || !data.data.username) return;
+                    const roles = {viewer: 'наблюдатель', operator: 'оператор', admin: 'администратор'};
+                    const el = document.getElementById('currentUser');
+                    el.textContent = data.data.username + ' (' + (roles[data.data.role] || data.data.role) + ')';
+                    const link = document.createElement('a');
+                    link.href = '#';
+                    link.textContent = 'Выйти';
+                    link.onclick = () => { logout(); return false; };
+                    el.appendChild(link);
+                })
+                .catch(() => {});
+        }
+
+        function logout() {
+            fetch('/api/auth/logout', {method: 'POST'}).finally(() => window.location.reload());
+        }
+
         function formatUptime(seconds) {
             const days = Math.floor(seconds / 86400);
             const hours = Math.floor((seconds % 86400) / 3600);
@@ -897,6 +945,8 @@ func (d *Daemon) handleWebIndex(w http.ResponseWriter, r *http.Request) {
             loadLogs();
         }, 100);
 
+        loadCurrentUser();
+
         // Auto refresh - terminals more frequently for status updates
         setInterval(updateStatus, 30000);
         setInterval(loadConnections, 30000);
@@ -0,0 +1,102 @@
+package face
+
+import (
+	"fmt"
+	"nd-go/internal/crt"
+	"time"
+)
+
+// CRTProvider is face provider on Vizir camera identifications: verification waits
+// for person to be seen by camera (crt.seen_timeout), identification is the person
+// last seen by camera
+type CRTProvider struct {
+	client  *crt.CRTClient
+	handler EventHandler
+}
+
+// NewCRTProvider creates CRT (Vizir) face provider
+func NewCRTProvider(client *crt.CRTClient) *CRTProvider {
+	return &CRTProvider{client: client}
+}
+
+// Name returns provider name
+func (p *CRTProvider) Name() string {
+	return PROVIDER_CRT
+}
+
+// Verify adds verification-mode session request for camera
+func (p *CRTProvider) Verify(sessionID string, camID string, personID string) (string, error) {
+	rid := p.client.AddSessionRequest(sessionID, camID, personID)
+	if rid == "" {
+		return "", fmt.Errorf("CRT session request not added (cam=%s, person=%s)", camID, personID)
+	}
+	return rid, nil
+}
+
+// Identify returns person last seen by camera as identification event
+func (p *CRTProvider) Identify(sessionID string, camID string) (string, error) {
+	pid, data := p.client.LastSeen(camID)
+	if pid == "" {
+		return "", fmt.Errorf("no person seen by camera %s", camID)
+	}
+	rid := fmt.Sprintf("crt_%d_%s", time.Now().UnixNano(), sessionID)
+	fio, _ := data["fio"].(string)
+	score, _ := data["score"].(float64)
+	termID, _ := data["term_id"].(string)
+	event := &Event{
+		Provider:   PROVIDER_CRT,
+		Type:       EVENT_IDENT,
+		RequestID:  rid,
+		SessionID:  sessionID,
+		TerminalID: termID,
+		CamID:      camID,
+		PersonID:   pid,
+		FIO:        fio,
+		Score:      score,
+		Data:       data,
+	}
+	p.emit(event)
+	return rid, nil
+}
+
+// CloseRequest removes pending session request
+func (p *CRTProvider) CloseRequest(requestID string) {
+	p.client.CancelSessionRequest(requestID)
+}
+
+// Subscribe sets handler of CRT identifications and session request results
+func (p *CRTProvider) Subscribe(handler EventHandler) {
+	p.handler = handler
+	p.client.SetEventCallback(func(terminalID string, personID string, fio string, camID string, score float64, data map[string]interface{}) {
+		handler(&Event{
+			Provider:   PROVIDER_CRT,
+			Type:       EVENT_IDENT,
+			TerminalID: terminalID,
+			CamID:      camID,
+			PersonID:   personID,
+			FIO:        fio,
+			Score:      score,
+			Data:       data,
+		})
+	})
+	p.client.SetSessionCallback(func(req *crt.SessionRequest, data map[string]interface{}) {
+		score, _ := data["score"].(float64)
+		p.emit(&Event{
+			Provider:  PROVIDER_CRT,
+			Type:      EVENT_YES,
+			RequestID: req.RID,
+			SessionID: req.SessionID,
+			CamID:     req.CamID,
+			PersonID:  req.PID,
+			Score:     score,
+			Data:      data,
+		})
+	})
+}
+
+// emit sends event to handler outside of CRT client lock
+func (p *CRTProvider) emit(event *Event) {
+	if p.handler != nil {
+		go p.handler(event)
+	}
+}
@@ -0,0 +1,48 @@
+package face
+
+import "nd-go/internal/helios"
+
+// HeliosProvider is face provider verifying persons by Helios WebSocket requests
+type HeliosProvider struct {
+	client *helios.HeliosClient
+}
+
+// NewHeliosProvider creates Helios face provider
+func NewHeliosProvider(client *helios.HeliosClient) *HeliosProvider {
+	return &HeliosProvider{client: client}
+}
+
+// Name returns provider name
+func (p *HeliosProvider) Name() string {
+	return PROVIDER_HELIOS
+}
+
+// Verify starts Helios verification request
+func (p *HeliosProvider) Verify(sessionID string, camID string, personID string) (string, error) {
+	return p.client.StartVerification(sessionID, camID, personID)
+}
+
+// Identify is not supported by Helios
+func (p *HeliosProvider) Identify(sessionID string, camID string) (string, error) {
+	return "", ErrNotSupported
+}
+
+// CloseRequest closes Helios request connection
+func (p *HeliosProvider) CloseRequest(requestID string) {
+	p.client.CloseRequest(requestID)
+}
+
+// Subscribe sets handler of Helios verification events
+func (p *HeliosProvider) Subscribe(handler EventHandler) {
+	p.client.SetEventCallback(func(request *helios.HeliosRequest, eventType helios.HeliosEventType, data map[string]interface{}) {
+		handler(&Event{
+			Provider:  PROVIDER_HELIOS,
+			Type:      EventType(eventType),
+			RequestID: request.ID,
+			SessionID: request.SessionID,
+			CamID:     request.CamPID,
+			PersonID:  request.PersonID,
+			Data:      data,
+		})
+	})
+}
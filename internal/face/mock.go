@@ -0,0 +1,137 @@
+package face
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MockProvider is local face provider for tests without recognition service:
+// verification gives Result after Delay, identification returns person set for
+// camera. It is not registered by daemon (camera.provider can't select it).
+type MockProvider struct {
+	Result  EventType     // verification result (EVENT_YES by default)
+	Delay   time.Duration // time to answer
+	handler EventHandler
+	persons map[string]string // camera ID -> person ID
+	pending map[string]bool   // open request IDs
+	idGen   int
+	mutex   sync.Mutex
+}
+
+// NewMockProvider creates mock face provider verifying everyone after delay
+func NewMockProvider(delay time.Duration) *MockProvider {
+	return &MockProvider{
+		Result:  EVENT_YES,
+		Delay:   delay,
+		persons: make(map[string]string),
+		pending: make(map[string]bool),
+	}
+}
+
+// Name returns provider name
+func (p *MockProvider) Name() string {
+	return PROVIDER_MOCK
+}
+
+// SetPerson sets person in front of camera ("" removes)
+func (p *MockProvider) SetPerson(camID string, personID string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if personID == "" {
+		delete(p.persons, camID)
+		return
+	}
+	p.persons[camID] = personID
+}
+
+// Verify answers with Result after Delay
+func (p *MockProvider) Verify(sessionID string, camID string, personID string) (string, error) {
+	rid := p.open(sessionID)
+	go p.answer(&Event{
+		Provider:  PROVIDER_MOCK,
+		Type:      p.Result,
+		RequestID: rid,
+		SessionID: sessionID,
+		CamID:     camID,
+		PersonID:  personID,
+		Score:     1,
+		Data:      map[string]interface{}{"mock": true},
+	})
+	return rid, nil
+}
+
+// Identify answers with person set for camera after Delay
+func (p *MockProvider) Identify(sessionID string, camID string) (string, error) {
+	p.mutex.Lock()
+	personID := p.persons[camID]
+	p.mutex.Unlock()
+	if personID == "" {
+		return "", fmt.Errorf("no person at mock camera %s", camID)
+	}
+
+	rid := p.open(sessionID)
+	go p.answer(&Event{
+		Provider:  PROVIDER_MOCK,
+		Type:      EVENT_IDENT,
+		RequestID: rid,
+		SessionID: sessionID,
+		CamID:     camID,
+		PersonID:  personID,
+		Score:     1,
+		Data:      map[string]interface{}{"mock": true},
+	})
+	return rid, nil
+}
+
+// Identified pushes identification of person at terminal as camera service does
+func (p *MockProvider) Identified(terminalID string, camID string, personID string, fio string, score float64) {
+	if p.handler != nil {
+		p.handler(&Event{
+			Provider:   PROVIDER_MOCK,
+			Type:       EVENT_IDENT,
+			TerminalID: terminalID,
+			CamID:      camID,
+			PersonID:   personID,
+			FIO:        fio,
+			Score:      score,
+			Data:       map[string]interface{}{"mock": true},
+		})
+	}
+}
+
+// CloseRequest cancels request, its answer is not sent
+func (p *MockProvider) CloseRequest(requestID string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.pending, requestID)
+}
+
+// Subscribe sets handler of mock events
+func (p *MockProvider) Subscribe(handler EventHandler) {
+	p.handler = handler
+}
+
+// open registers new request
+func (p *MockProvider) open(sessionID string) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.idGen++
+	rid := fmt.Sprintf("mock_%d_%s", p.idGen, sessionID)
+	p.pending[rid] = true
+	return rid
+}
+
+// answer sends event of request after delay unless request is closed
+func (p *MockProvider) answer(event *Event) {
+	time.Sleep(p.Delay)
+
+	p.mutex.Lock()
+	open := p.pending[event.RequestID]
+	delete(p.pending, event.RequestID)
+	p.mutex.Unlock()
+
+	if open && p.handler != nil {
+		p.handler(event)
+	}
+}
@@ -0,0 +1,103 @@
+package face
+
+import (
+	"errors"
+	"sync"
+)
+
+// Provider names (camera.provider, term string "face_provider=<name>")
+const (
+	PROVIDER_HELIOS = "helios"
+	PROVIDER_CRT    = "crt"
+	PROVIDER_MOCK   = "mock" // Tests only, not selectable by camera.provider
+)
+
+// EventType represents face recognition event type
+type EventType string
+
+const (
+	EVENT_YES   EventType = "YES"   // Person verified
+	EVENT_NO    EventType = "NO"    // Person not recognized
+	EVENT_NF    EventType = "NF"    // Person not found
+	EVENT_COR   EventType = "COR"   // Correlation update
+	EVENT_FAIL  EventType = "FAIL"  // Request failed
+	EVENT_IDENT EventType = "IDENT" // Person identified by camera
+)
+
+// ErrNotSupported is returned for operations provider doesn't support
+var ErrNotSupported = errors.New("operation not supported by face provider")
+
+// Event is a verification result or identification of person by provider
+type Event struct {
+	Provider   string
+	Type       EventType
+	RequestID  string // Verify/Identify request, "" for identification pushed by provider
+	SessionID  string
+	TerminalID string
+	CamID      string
+	PersonID   string
+	FIO        string
+	Score      float64 // 0..1
+	Data       map[string]interface{}
+}
+
+// EventHandler is called on provider events
+type EventHandler func(event *Event)
+
+// Provider is a face recognition service. Verify and Identify start requests,
+// their results come as events to subscribed handler (possibly before the call
+// returns). Identification result is EVENT_IDENT with RequestID of Identify;
+// EVENT_IDENT without RequestID is identification pushed by provider itself
+// (CRT camera seeing person, used for entry by face).
+type Provider interface {
+	Name() string
+	// Verify checks that person in front of camera is personID
+	Verify(sessionID string, camID string, personID string) (string, error)
+	// Identify finds person in front of camera (ErrNotSupported if provider can't)
+	Identify(sessionID string, camID string) (string, error)
+	// CloseRequest cancels Verify/Identify request
+	CloseRequest(requestID string)
+	// Subscribe sets handler of provider events
+	Subscribe(handler EventHandler)
+}
+
+// Registry holds face providers selectable per terminal
+type Registry struct {
+	providers   map[string]Provider
+	defaultName string
+	mutex       sync.RWMutex
+}
+
+// NewRegistry creates registry with default provider name
+func NewRegistry(defaultName string) *Registry {
+	return &Registry{
+		providers:   make(map[string]Provider),
+		defaultName: defaultName,
+	}
+}
+
+// Register adds provider to registry
+func (r *Registry) Register(provider Provider) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.providers[provider.Name()] = provider
+}
+
+// Get returns provider by name (default provider if name is empty), nil if not registered
+func (r *Registry) Get(name string) Provider {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if name == "" {
+		name = r.defaultName
+	}
+	return r.providers[name]
+}
+
+// Subscribe sets handler of events of all registered providers
+func (r *Registry) Subscribe(handler EventHandler) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, provider := range r.providers {
+		provider.Subscribe(handler)
+	}
+}
@@ -0,0 +1,120 @@
+package face
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var (
+	_ Provider = (*HeliosProvider)(nil)
+	_ Provider = (*CRTProvider)(nil)
+	_ Provider = (*MockProvider)(nil)
+)
+
+// waitEvent returns next provider event or nil after timeout
+func waitEvent(events chan *Event, timeout time.Duration) *Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(timeout):
+		return nil
+	}
+}
+
+func subscribeChan(p Provider) chan *Event {
+	events := make(chan *Event, 4)
+	p.Subscribe(func(event *Event) { events <- event })
+	return events
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(PROVIDER_MOCK)
+	mock := NewMockProvider(0)
+	r.Register(mock)
+
+	if r.Get("") != mock {
+		t.Error("Get(\"\") doesn't return default provider")
+	}
+	if r.Get(PROVIDER_MOCK) != mock {
+		t.Error("Get(mock) doesn't return registered provider")
+	}
+	if p := r.Get(PROVIDER_CRT); p != nil {
+		t.Errorf("Get(crt) = %v, want nil for not registered provider", p)
+	}
+
+	events := make(chan *Event, 1)
+	r.Subscribe(func(event *Event) { events <- event })
+	if _, err := mock.Verify("s1", "cam1", "p1"); err != nil {
+		t.Fatal(err)
+	}
+	if waitEvent(events, time.Second) == nil {
+		t.Error("registry handler didn't get provider event")
+	}
+}
+
+func TestMockVerify(t *testing.T) {
+	mock := NewMockProvider(0)
+	events := subscribeChan(mock)
+
+	rid, err := mock.Verify("s1", "cam1", "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := waitEvent(events, time.Second)
+	if event == nil {
+		t.Fatal("no verification event")
+	}
+	if event.Type != EVENT_YES || event.RequestID != rid || event.SessionID != "s1" || event.PersonID != "p1" {
+		t.Errorf("event = %+v", event)
+	}
+
+	mock.Result = EVENT_NO
+	rid, _ = mock.Verify("s2", "cam1", "p2")
+	if event := waitEvent(events, time.Second); event == nil || event.Type != EVENT_NO || event.RequestID != rid {
+		t.Errorf("event = %+v, want NO for %s", event, rid)
+	}
+}
+
+func TestMockCloseRequest(t *testing.T) {
+	mock := NewMockProvider(50 * time.Millisecond)
+	events := subscribeChan(mock)
+
+	rid, _ := mock.Verify("s1", "cam1", "p1")
+	mock.CloseRequest(rid)
+	if event := waitEvent(events, 200*time.Millisecond); event != nil {
+		t.Errorf("event of closed request: %+v", event)
+	}
+}
+
+func TestMockIdentify(t *testing.T) {
+	mock := NewMockProvider(0)
+	events := subscribeChan(mock)
+
+	if _, err := mock.Identify("s1", "cam1"); err == nil {
+		t.Error("Identify succeeded with nobody at camera")
+	}
+
+	mock.SetPerson("cam1", "p7")
+	rid, err := mock.Identify("s1", "cam1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := waitEvent(events, time.Second)
+	if event == nil || event.Type != EVENT_IDENT || event.RequestID != rid || event.PersonID != "p7" {
+		t.Errorf("event = %+v", event)
+	}
+
+	// Pushed identification has no request ID
+	mock.Identified("10", "cam1", "p8", "Test", 0.9)
+	event = waitEvent(events, time.Second)
+	if event == nil || event.Type != EVENT_IDENT || event.RequestID != "" || event.TerminalID != "10" {
+		t.Errorf("pushed event = %+v", event)
+	}
+}
+
+func TestHeliosIdentifyNotSupported(t *testing.T) {
+	if _, err := NewHeliosProvider(nil).Identify("s1", "cam1"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Identify error = %v, want ErrNotSupported", err)
+	}
+}
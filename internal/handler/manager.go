@@ -1,24 +1,36 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 )
 
 // HandlerFunc represents command handler function
 type HandlerFunc func(params map[string]interface{}) string
 
+// CommandFunc represents typed command handler: result is sent as is in binary
+// (protopack) replies and formatted as text for console
+type CommandFunc func(params map[string]interface{}) (interface{}, error)
+
+// ErrUnknownCommand is returned by Call for unregistered command
+var ErrUnknownCommand = errors.New("unknown command")
+
 // HandlerManager manages command handlers
 type HandlerManager struct {
 	handlers map[string]HandlerFunc
+	commands map[string]CommandFunc
 }
 
 // NewHandlerManager creates new handler manager
 func NewHandlerManager() *HandlerManager {
 	return &HandlerManager{
 		handlers: make(map[string]HandlerFunc),
+		commands: make(map[string]CommandFunc),
 	}
 }
 
@@ -27,49 +39,254 @@ func (hm *HandlerManager) Register(command string, handler HandlerFunc) {
 	hm.handlers[strings.ToLower(command)] = handler
 }
 
+// RegisterCommand registers typed command handler
+func (hm *HandlerManager) RegisterCommand(command string, handler CommandFunc) {
+	hm.commands[strings.ToLower(command)] = handler
+}
+
+// Call executes command with params, returns typed result
+func (hm *HandlerManager) Call(command string, params map[string]interface{}) (interface{}, error) {
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+	name := strings.ToLower(command)
+	if handler, exists := hm.commands[name]; exists {
+		return handler(params)
+	}
+	if handler, exists := hm.handlers[name]; exists {
+		return handler(params), nil
+	}
+	return nil, fmt.Errorf("%w '%s'", ErrUnknownCommand, name)
+}
+
 // Execute executes command
 func (hm *HandlerManager) Execute(command string, params map[string]interface{}) string {
+	cmdName, parsed := ParseCommand(command)
+	if cmdName == "" {
+		return "ERROR: Empty command"
+	}
 	if params == nil {
 		params = make(map[string]interface{})
 	}
+	for k, v := range parsed {
+		params[k] = v
+	}
 
-	// Parse command
-	parts := strings.Fields(strings.TrimSpace(command))
-	if len(parts) == 0 {
-		return "ERROR: Empty command"
+	// Execute handler
+	result, err := hm.Call(cmdName, params)
+	if errors.Is(err, ErrUnknownCommand) {
+		return fmt.Sprintf("ERROR: Unknown command '%s'", cmdName)
 	}
+	if err != nil {
+		return fmt.Sprintf("ERROR: %v", err)
+	}
+	return FormatResult(result)
+}
 
-	cmdName := strings.ToLower(parts[0])
+// ParseCommand splits console line into lowercase command name and params.
+// Words are separated by spaces, "double" or 'single' quotes keep spaces inside
+// a word, backslash escapes next character. key=value words are params (key in
+// lower case), the first other word is "cmd" (unless given as cmd=...) and the
+// rest are collected to "args" list:
+//
+//	termlist add 10.0.0.5:9000 id="Main gate"
+//	-> "termlist", {"cmd": "add", "args": ["10.0.0.5:9000"], "id": "Main gate"}
+func ParseCommand(line string) (string, map[string]interface{}) {
+	params := make(map[string]interface{})
+	words := splitWords(line)
+	if len(words) == 0 {
+		return "", params
+	}
 
-	// Parse parameters
-	for i := 1; i < len(parts); i++ {
-		if strings.Contains(parts[i], "=") {
-			kv := strings.SplitN(parts[i], "=", 2)
-			if len(kv) == 2 {
-				params[strings.ToLower(kv[0])] = kv[1]
-			}
-		} else {
-			params[fmt.Sprintf("arg%d", i)] = parts[i]
+	var positional []string
+	for _, w := range words[1:] {
+		if w.eq > 0 && isParamKey(w.text[:w.eq]) {
+			params[strings.ToLower(w.text[:w.eq])] = w.text[w.eq+1:]
+			continue
+		}
+		positional = append(positional, w.text)
+	}
+	if _, ok := params["cmd"]; !ok && len(positional) > 0 {
+		params["cmd"] = positional[0]
+		positional = positional[1:]
+	}
+	if cmd, ok := params["cmd"].(string); ok {
+		params["cmd"] = strings.ToLower(cmd)
+	}
+	if len(positional) > 0 {
+		args := make([]interface{}, len(positional))
+		for i, arg := range positional {
+			args[i] = arg
 		}
+		params["args"] = args
 	}
+	return strings.ToLower(words[0].text), params
+}
 
-	// Execute handler
-	if handler, exists := hm.handlers[cmdName]; exists {
-		return handler(params)
+// SplitLine splits console line into words with the same quoting rules as ParseCommand
+func SplitLine(line string) []string {
+	words := splitWords(line)
+	result := make([]string, len(words))
+	for i, w := range words {
+		result[i] = w.text
+	}
+	return result
+}
+
+// word is console line word, eq is position of first unquoted '=' or -1
+type word struct {
+	text string
+	eq   int
+}
+
+// splitWords splits line into words honoring quotes and backslash escapes,
+// unterminated quote lasts till end of line
+func splitWords(line string) []word {
+	var words []word
+	var cur strings.Builder
+	inWord, eq := false, -1
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			if inWord {
+				words = append(words, word{text: cur.String(), eq: eq})
+				cur.Reset()
+				inWord, eq = false, -1
+			}
+		default:
+			if r == '=' && eq < 0 {
+				eq = cur.Len()
+			}
+			cur.WriteRune(r)
+			inWord = true
+		}
 	}
+	if inWord {
+		words = append(words, word{text: cur.String(), eq: eq})
+	}
+	return words
+}
 
-	return fmt.Sprintf("ERROR: Unknown command '%s'", cmdName)
+// isParamKey checks if word part before '=' is param name
+func isParamKey(key string) bool {
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-') {
+			return false
+		}
+	}
+	return key != ""
 }
 
 // GetCommands returns list of available commands
 func (hm *HandlerManager) GetCommands() []string {
-	commands := make([]string, 0, len(hm.handlers))
+	commands := make([]string, 0, len(hm.handlers)+len(hm.commands))
 	for cmd := range hm.handlers {
 		commands = append(commands, cmd)
 	}
+	for cmd := range hm.commands {
+		if _, dup := hm.handlers[cmd]; !dup {
+			commands = append(commands, cmd)
+		}
+	}
+	sort.Strings(commands)
 	return commands
 }
 
+// FormatResult formats typed command result as console text: maps as
+// "key: value" lines sorted by key, lists as "- item" lines
+func FormatResult(result interface{}) string {
+	switch v := result.(type) {
+	case nil:
+		return "OK"
+	case string:
+		return v
+	case error:
+		return "ERROR: " + v.Error()
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Sprintf("%v", result)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return string(data)
+	}
+	var b strings.Builder
+	formatValue(&b, generic, "")
+	if b.Len() == 0 {
+		return "(empty)"
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// formatValue writes value with indent
+func formatValue(b *strings.Builder, value interface{}, indent string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if isScalar(v[k]) {
+				fmt.Fprintf(b, "%s%s: %s\n", indent, k, scalarText(v[k]))
+				continue
+			}
+			fmt.Fprintf(b, "%s%s:\n", indent, k)
+			formatValue(b, v[k], indent+"  ")
+		}
+	case []interface{}:
+		for _, item := range v {
+			if isScalar(item) {
+				fmt.Fprintf(b, "%s- %s\n", indent, scalarText(item))
+				continue
+			}
+			fmt.Fprintf(b, "%s-\n", indent)
+			formatValue(b, item, indent+"  ")
+		}
+	default:
+		fmt.Fprintf(b, "%s%s\n", indent, scalarText(v))
+	}
+}
+
+// isScalar checks if decoded JSON value is not map or list
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// scalarText formats decoded JSON scalar
+func scalarText(value interface{}) string {
+	if value == nil {
+		return "-"
+	}
+	if f, ok := value.(float64); ok && f == float64(int64(f)) {
+		return fmt.Sprintf("%d", int64(f))
+	}
+	return fmt.Sprintf("%v", value)
+}
+
 // AutoRegister automatically registers all handler methods
 func (hm *HandlerManager) AutoRegister(handler interface{}) {
 	v := reflect.ValueOf(handler)
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		line       string
+		wantName   string
+		wantParams map[string]interface{}
+	}{
+		{"", "", map[string]interface{}{}},
+		{"   ", "", map[string]interface{}{}},
+		{"HELP", "help", map[string]interface{}{}},
+		{"termlist list", "termlist", map[string]interface{}{"cmd": "list"}},
+		{"termlist ADD 10.0.0.5:9000", "termlist", map[string]interface{}{
+			"cmd": "add", "args": []interface{}{"10.0.0.5:9000"},
+		}},
+		{`termlist add 10.0.0.5:9000 id="Main gate" Type=pocket`, "termlist", map[string]interface{}{
+			"cmd": "add", "args": []interface{}{"10.0.0.5:9000"}, "id": "Main gate", "type": "pocket",
+		}},
+		{"termlist cmd=Check ip=10.0.0.5 port=9000", "termlist", map[string]interface{}{
+			"cmd": "check", "ip": "10.0.0.5", "port": "9000",
+		}},
+		{"settings set key=a value=x=y", "settings", map[string]interface{}{
+			"cmd": "set", "key": "a", "value": "x=y",
+		}},
+		{`settings set "a=b c"`, "settings", map[string]interface{}{
+			"cmd": "set", "args": []interface{}{"a=b c"},
+		}},
+		{"settings set ?x=1", "settings", map[string]interface{}{
+			"cmd": "set", "args": []interface{}{"?x=1"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			name, params := ParseCommand(tt.line)
+			if name != tt.wantName {
+				t.Errorf("name = %q, want %q", name, tt.wantName)
+			}
+			if !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("params = %#v, want %#v", params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		line string
+		want []word
+	}{
+		{"", nil},
+		{" \t\r\n", nil},
+		{"a  b\tc", []word{{"a", -1}, {"b", -1}, {"c", -1}}},
+		{`"a b" 'c d'`, []word{{"a b", -1}, {"c d", -1}}},
+		{`a\ b c\"d`, []word{{"a b", -1}, {`c"d`, -1}}},
+		{`""`, []word{{"", -1}}},
+		{`id="x y"`, []word{{"id=x y", 2}}},
+		{`"a=b"`, []word{{"a=b", -1}}},
+		{"k=v=w", []word{{"k=v=w", 1}}},
+		{`'unterminated quote`, []word{{"unterminated quote", -1}}},
+		{`trailing\`, []word{{"trailing", -1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got := splitWords(tt.line); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitWords(%q) = %#v, want %#v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
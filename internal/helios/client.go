@@ -1,9 +1,8 @@
 package helios
 
 import (
-	"crypto/sha1"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"nd-go/pkg/types"
 	"net/http"
@@ -70,23 +69,6 @@ func (hc *HeliosClient) SetEventCallback(callback HeliosEventCallback) {
 	hc.eventCallback = callback
 }
 
-// generateSecKey generates WebSocket security key
-func generateSecKey() string {
-	key := make([]byte, 16)
-	for i := range key {
-		key[i] = byte(time.Now().UnixNano() % 256)
-	}
-	return base64.StdEncoding.EncodeToString(key)
-}
-
-// generateAccept generates WebSocket accept key from sec key
-func generateAccept(secKey string) string {
-	const magicString = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
-	h := sha1.New()
-	h.Write([]byte(secKey + magicString))
-	return base64.StdEncoding.EncodeToString(h.Sum(nil))
-}
-
 // StartVerification starts Helios verification request
 func (hc *HeliosClient) StartVerification(sessionID string, camPID string, personID string) (string, error) {
 	if !hc.config.CamServiceActive {
@@ -97,15 +79,9 @@ func (hc *HeliosClient) StartVerification(sessionID string, camPID string, perso
 	url := fmt.Sprintf("ws://%s:%d/api/cameras/%s/verify?person_id=%s&subscribe=&max_mps=10&detect_face=none&correlation_face=none",
 		hc.config.CamServiceIP, hc.config.CamServicePort, camPID, personID)
 
-	// Generate WebSocket handshake headers
-	secKey := generateSecKey()
-	secAccept := generateAccept(secKey)
-
+	// WebSocket handshake headers (key, version and accept check are done by dialer)
 	headers := http.Header{}
 	headers.Set("Origin", "pkdaemon")
-	headers.Set("Sec-WebSocket-Key", secKey)
-	headers.Set("Sec-WebSocket-Protocol", "verification")
-	headers.Set("Sec-WebSocket-Version", "13")
 
 	// Add extra headers from config
 	if hc.config.CamServiceRequestExtraHeaders != nil && len(hc.config.CamServiceRequestExtraHeaders) > 0 {
@@ -117,6 +93,7 @@ func (hc *HeliosClient) StartVerification(sessionID string, camPID string, perso
 	// Create dialer
 	dialer := websocket.Dialer{
 		HandshakeTimeout: time.Duration(hc.config.ServiceRequestExpireTime * float64(time.Second)),
+		Subprotocols:     []string{"verification"},
 	}
 
 	// Connect to WebSocket
@@ -126,13 +103,6 @@ func (hc *HeliosClient) StartVerification(sessionID string, camPID string, perso
 	}
 	defer resp.Body.Close()
 
-	// Verify accept header
-	acceptHeader := resp.Header.Get("Sec-WebSocket-Accept")
-	if acceptHeader != secAccept {
-		conn.Close()
-		return "", fmt.Errorf("invalid WebSocket accept header")
-	}
-
 	// Create request
 	requestID := fmt.Sprintf("helios_%d_%s", time.Now().UnixNano(), sessionID)
 	request := &HeliosRequest{
@@ -169,10 +139,21 @@ func (hc *HeliosClient) readMessages(request *HeliosRequest) {
 	for {
 		messageType, message, err := request.Conn.ReadMessage()
 		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				request.CloseReason = fmt.Sprintf("%d:%s", closeErr.Code, closeErr.Text)
+			}
 			if !request.Processed {
-				hc.handleEvent(request, HELIOS_EVENT_FAIL, map[string]interface{}{
-					"error": err.Error(),
-				})
+				// Close 4002 with text: person has no photo in Helios
+				if closeErr != nil && closeErr.Code == 4002 && len(closeErr.Text) > 0 {
+					hc.handleEvent(request, HELIOS_EVENT_NF, map[string]interface{}{
+						"error": closeErr.Text,
+					})
+				} else {
+					hc.handleEvent(request, HELIOS_EVENT_FAIL, map[string]interface{}{
+						"error": err.Error(),
+					})
+				}
 			}
 			return
 		}
@@ -192,6 +173,10 @@ func (hc *HeliosClient) readMessages(request *HeliosRequest) {
 			}
 
 			if terminated, ok := jsonData["terminated"].(bool); ok && terminated {
+				// Verification finished without match
+				if verified, ok := jsonData["verified"].(bool); ok && !verified {
+					hc.handleEvent(request, HELIOS_EVENT_NO, jsonData)
+				}
 				hc.closeConnection(request, 1000, "autoclose")
 				return
 			}
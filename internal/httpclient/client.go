@@ -1,12 +1,16 @@
 package httpclient
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"nd-go/internal/metrics"
+	"nd-go/internal/tracing"
 	"nd-go/pkg/types"
 	"nd-go/pkg/utils"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -15,7 +19,10 @@ import (
 type HTTPClientInterface interface {
 	CheckAccess(uid string, terminalID string, tagType string, lockers []types.LockerInfo) (*types.KPOResult, string, error)
 	CheckSolarAccess(uid string, terminalID string, solarTime int, regQuery int) (*types.KPOResult, string, error)
+	CheckPIN(uid string, terminalID string, pin string) (*types.KPOResult, string, error)
 	SendAccessReport(uid string, terminalID string, result bool, message string) error
+	SendEscortReport(uid string, escortUID string, terminalID string, result bool) error
+	SendMFAReport(uid string, terminalID string, result bool, mfa string) error
 	GetUserCID(uid string) (string, error)
 }
 
@@ -23,6 +30,7 @@ type HTTPClientInterface interface {
 type HTTPClient struct {
 	client *http.Client
 	config *types.Config
+	span   *tracing.Span // parent span of requests (nil - not traced)
 }
 
 // HTTPResponse represents HTTP response
@@ -42,8 +50,26 @@ func NewHTTPClient(config *types.Config) *HTTPClient {
 	}
 }
 
-// Request1C sends request to 1C service with retry mechanism
+// WithSpan returns client tracing requests as child spans of parent (client
+// shares connections and settings with hc)
+func (hc *HTTPClient) WithSpan(parent *tracing.Span) interface{} {
+	traced := *hc
+	traced.span = parent
+	return &traced
+}
+
+// Request1C sends request to 1C service with retry mechanism, params (if not
+// nil) are posted as JSON body. Path without query is traced as route, so
+// personal data must be passed in query or params.
 func (hc *HTTPClient) Request1C(path string, params map[string]interface{}) (*HTTPResponse, error) {
+	return hc.request1C("other", path, path, params)
+}
+
+// request1C sends request with retries, kind labels request in metrics, route
+// is path template traced and logged instead of path (e.g. "/pin/{id}/{uid}",
+// query is dropped)
+func (hc *HTTPClient) request1C(kind string, route string, path string, params map[string]interface{}) (*HTTPResponse, error) {
+	route = utils.StripQuery(route)
 	var lastErr error
 	maxRetries := hc.config.HTTPRequestRetryCount
 	if maxRetries < 0 {
@@ -57,7 +83,20 @@ func (hc *HTTPClient) Request1C(path string, params map[string]interface{}) (*HT
 			time.Sleep(delay)
 		}
 		
-		resp, err := hc.request1COnce(path, params)
+		start := time.Now()
+		span := hc.span.Client("1c " + kind)
+		span.SetAttr("skd.attempt", attempt+1)
+		resp, err := hc.request1COnce(route, path, params, span)
+		metrics.Request1CDuration.Observe(time.Since(start).Seconds(), kind)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			metrics.Request1CErrors.Inc(kind)
+		}
+		if err != nil {
+			span.SetError(err.Error())
+		} else if resp.StatusCode != http.StatusOK {
+			span.SetError(fmt.Sprintf("HTTP %d", resp.StatusCode))
+		}
+		span.End()
 		if err == nil {
 			return resp, nil
 		}
@@ -72,13 +111,29 @@ func (hc *HTTPClient) Request1C(path string, params map[string]interface{}) (*HT
 	return nil, fmt.Errorf("HTTP request failed after %d attempts: %v", maxRetries+1, lastErr)
 }
 
-// request1COnce sends single HTTP request to 1C service
-func (hc *HTTPClient) request1COnce(path string, params map[string]interface{}) (*HTTPResponse, error) {
-	url := fmt.Sprintf("http://%s%s", hc.config.HTTPServiceName, path)
+// request1COnce sends single HTTP request to 1C service: GET or POST of params
+// as JSON (values which must not get into URL and logs, e.g. PIN), span gets
+// request attributes. Errors hold route, not URL.
+func (hc *HTTPClient) request1COnce(route string, path string, params map[string]interface{}, span *tracing.Span) (*HTTPResponse, error) {
+	reqURL := fmt.Sprintf("http://%s%s", hc.config.HTTPServiceName, path)
+	method := "GET"
+	var reqBody io.Reader
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %v", err)
+		}
+		method = "POST"
+		reqBody = bytes.NewReader(data)
+	}
+	span.SetRequest(method, hc.config.HTTPServiceName, route)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequest(method, reqURL, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", utils.URLErrorCause(err))
+	}
+	if params != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
 	// Set default headers
@@ -104,9 +159,10 @@ func (hc *HTTPClient) request1COnce(path string, params map[string]interface{})
 
 	resp, err := hc.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %v", err)
+		return nil, fmt.Errorf("HTTP request %s %s failed: %v", method, route, utils.URLErrorCause(err))
 	}
 	defer resp.Body.Close()
+	span.SetAttr("http.response.status_code", resp.StatusCode)
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -134,7 +190,7 @@ func (hc *HTTPClient) request1COnce(path string, params map[string]interface{})
 // GetTerminalList requests terminal list from 1C
 func (hc *HTTPClient) GetTerminalList() ([]map[string]interface{}, error) {
 	path := hc.config.HTTPServiceTermlistPath
-	resp, err := hc.Request1C(path, nil)
+	resp, err := hc.request1C("termlist", path, path, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get terminal list: %v", err)
 	}
@@ -208,7 +264,7 @@ func (hc *HTTPClient) CheckSolarAccess(uid string, terminalID string, solarTime
 
 	path := fmt.Sprintf("%s/%s/%s/%d/%d", hc.config.HTTPServiceSolarPath, terminalID, uid, solarTime, regQuery)
 
-	resp, err := hc.Request1C(path, nil)
+	resp, err := hc.request1C("solar", hc.config.HTTPServiceSolarPath+"/{id}/{uid}/{time}/{reg_query}", path, nil)
 	if err != nil {
 		return nil, "", fmt.Errorf("solar access check failed: %v", err)
 	}
@@ -251,9 +307,42 @@ func (hc *HTTPClient) CheckSolarAccess(uid string, terminalID string, solarTime
 	return &result, message, nil
 }
 
+// CheckPIN checks PIN entered after card (card+PIN terminals) via 1C
+// Format: POST pin_path/id/uid with body {"pin": "<pin>"} (PIN is kept out of URL)
+func (hc *HTTPClient) CheckPIN(uid string, terminalID string, pin string) (*types.KPOResult, string, error) {
+	if hc.config.HTTPServicePINPath == "" {
+		return nil, "", fmt.Errorf("PIN path not configured")
+	}
+
+	path := fmt.Sprintf("%s/%s/%s", hc.config.HTTPServicePINPath, terminalID, uid)
+
+	resp, err := hc.request1C("pin", hc.config.HTTPServicePINPath+"/{id}/{uid}", path, map[string]interface{}{"pin": pin})
+	if err != nil {
+		return nil, "", fmt.Errorf("PIN check failed: %v", err)
+	}
+
+	result := types.KPO_RES_NO
+	message := ""
+	if resp.StatusCode == 200 {
+		if resultVal, ok := resp.Data["RESULTVAL"].(float64); ok {
+			if int(resultVal) > 0 {
+				result = types.KPO_RES_YES
+			}
+			message = utils.GetStringValue(resp.Data, "MSGSTR", "")
+		} else if resultVal, ok := resp.Data["RESULT"].(float64); ok {
+			if int(resultVal) > 0 {
+				result = types.KPO_RES_YES
+			}
+			message = utils.GetStringValue(resp.Data, "MESSAGE", "")
+		}
+	}
+
+	return &result, message, nil
+}
+
 // CheckAccessWithRole checks user access via 1C with optional role parameter
 func (hc *HTTPClient) CheckAccessWithRole(uid string, terminalID string, tagType string, role string, lockers []types.LockerInfo) (*types.KPOResult, string, error) {
-	var path string
+	var path, route string
 
 	// Normalize tagType (default to rfid)
 	if tagType == "" {
@@ -276,9 +365,11 @@ func (hc *HTTPClient) CheckAccessWithRole(uid string, terminalID string, tagType
 			tagTypeNum = "2"
 		}
 		path = fmt.Sprintf("%s/%s/%s/%s/0/0/%s/0", hc.config.HTTPServiceIdentPath, terminalID, uid, tagTypeNum, lockersStr)
+		route = hc.config.HTTPServiceIdentPath + "/{id}/{uid}/{tag_type}/0/0/{lockers}/0"
 	case "a&a":
 		// Format: /verify/id/uid
 		path = fmt.Sprintf("%s/verify/%s/%s", hc.config.HTTPServiceIdentPath, terminalID, uid)
+		route = hc.config.HTTPServiceIdentPath + "/verify/{id}/{uid}"
 	case "1c_m":
 		// Format: /checkaccess?id=...&uid=...&tagtype=...
 		path = fmt.Sprintf("%s/checkaccess?id=%s&uid=%s&tagtype=%s", hc.config.HTTPServiceIdentPath, terminalID, uid, tagType)
@@ -301,7 +392,10 @@ func (hc *HTTPClient) CheckAccessWithRole(uid string, terminalID string, tagType
 		path = fmt.Sprintf("%s/checking.php?id=%s&uid=%s&lockers=%s", hc.config.HTTPServiceIdentPath, terminalID, uid, lockersStr)
 	}
 
-	resp, err := hc.Request1C(path, nil)
+	if route == "" {
+		route = path // identifiers are in query
+	}
+	resp, err := hc.request1C("access", route, path, nil)
 	if err != nil {
 		return nil, "", fmt.Errorf("access check failed: %v", err)
 	}
@@ -369,7 +463,37 @@ func (hc *HTTPClient) SendAccessReport(uid string, terminalID string, result boo
 
 // SendAccessReportWithParams sends access event report to 1C with additional parameters
 func (hc *HTTPClient) SendAccessReportWithParams(uid string, terminalID string, result bool, message string, tagType string, role string) error {
-	var path string
+	return hc.sendReport(hc.accessReportPath(uid, terminalID, result, tagType, role))
+}
+
+// SendEscortReport sends escorted access report to 1C: visitor uid with escort card UID
+// in "escort" query parameter
+func (hc *HTTPClient) SendEscortReport(uid string, escortUID string, terminalID string, result bool) error {
+	route, path := hc.accessReportPath(uid, terminalID, result, "rfid", "")
+	if strings.Contains(path, "?") {
+		path += "&escort=" + escortUID
+	} else {
+		path += "?escort=" + escortUID
+	}
+	return hc.sendReport(route, path)
+}
+
+// SendMFAReport sends access report to 1C with additional factor results
+// ("pin:OK,face:OK") in "mfa" query parameter
+func (hc *HTTPClient) SendMFAReport(uid string, terminalID string, result bool, mfa string) error {
+	route, path := hc.accessReportPath(uid, terminalID, result, "rfid", "")
+	if strings.Contains(path, "?") {
+		path += "&mfa=" + url.QueryEscape(mfa)
+	} else {
+		path += "?mfa=" + url.QueryEscape(mfa)
+	}
+	return hc.sendReport(route, path)
+}
+
+// accessReportPath builds access report request route (traced template) and
+// path for configured URL format
+func (hc *HTTPClient) accessReportPath(uid string, terminalID string, result bool, tagType string, role string) (string, string) {
+	var path, route string
 
 	// Normalize tagType
 	if tagType == "" {
@@ -384,9 +508,11 @@ func (hc *HTTPClient) SendAccessReportWithParams(uid string, terminalID string,
 			regParam = "1"
 		}
 		path = fmt.Sprintf("%s/%s/%s/%s/0/0/0/0", hc.config.HTTPServiceIdentPath, terminalID, uid, regParam)
+		route = hc.config.HTTPServiceIdentPath + "/{id}/{uid}/{reg}/0/0/0/0"
 	case "a&a":
 		// Format: /check/id/uid
 		path = fmt.Sprintf("%s/check/%s/%s", hc.config.HTTPServiceIdentPath, terminalID, uid)
+		route = hc.config.HTTPServiceIdentPath + "/check/{id}/{uid}"
 	case "1c_m":
 		// Format: /event?id=...&uid=...&tagtype=...
 		path = fmt.Sprintf("%s/event?id=%s&uid=%s&tagtype=%s", hc.config.HTTPServiceIdentPath, terminalID, uid, tagType)
@@ -407,8 +533,15 @@ func (hc *HTTPClient) SendAccessReportWithParams(uid string, terminalID string,
 		}
 		path = fmt.Sprintf("%s/checking.php?id=%s&uid=%s%s", hc.config.HTTPServiceIdentPath, terminalID, uid, regParam)
 	}
+	if route == "" {
+		route = path // identifiers are in query
+	}
+	return route, path
+}
 
-	resp, err := hc.Request1C(path, nil)
+// sendReport sends report request to 1C
+func (hc *HTTPClient) sendReport(route string, path string) error {
+	resp, err := hc.request1C("report", route, path, nil)
 	if err != nil {
 		return fmt.Errorf("access report failed: %v", err)
 	}
@@ -424,7 +557,7 @@ func (hc *HTTPClient) SendAccessReportWithParams(uid string, terminalID string,
 func (hc *HTTPClient) GetUserCID(uid string) (string, error) {
 	path := fmt.Sprintf("%s/%s", hc.config.HTTPServiceUIDPath, uid)
 
-	resp, err := hc.Request1C(path, nil)
+	resp, err := hc.request1C("cid", hc.config.HTTPServiceUIDPath+"/{uid}", path, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to get user CID: %v", err)
 	}
@@ -446,3 +579,29 @@ func (hc *HTTPClient) GetUserCID(uid string) (string, error) {
 
 	return "", fmt.Errorf("CID not found in response")
 }
+
+// GetCIDUID gets card UID of client by client ID (face recognition person ID) from 1C
+func (hc *HTTPClient) GetCIDUID(cid string) (string, error) {
+	if hc.config.HTTPServiceCIDPath == "" {
+		return "", fmt.Errorf("CID path not configured")
+	}
+	path := fmt.Sprintf("%s/%s", hc.config.HTTPServiceCIDPath, cid)
+
+	resp, err := hc.request1C("uid", hc.config.HTTPServiceCIDPath+"/{cid}", path, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to get client UID: %v", err)
+	}
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("client UID request failed with status %d", resp.StatusCode)
+	}
+
+	// Try different field names
+	for _, field := range []string{"UID", "CARD", "CARD_UID"} {
+		if uid, ok := resp.Data[field].(string); ok && uid != "" {
+			return strings.ToUpper(uid), nil
+		}
+	}
+
+	return "", fmt.Errorf("UID not found in response")
+}
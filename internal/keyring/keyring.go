@@ -0,0 +1,460 @@
+package keyring
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"nd-go/pkg/utils"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key generation states
+const (
+	GEN_STATE_PENDING = "pending" // Created, rollout in progress
+	GEN_STATE_ACTIVE  = "active"  // Installed on the fleet
+	GEN_STATE_RETIRED = "retired" // Replaced by newer generation
+	GEN_STATE_FAILED  = "failed"  // Rollout failed and was rolled back
+)
+
+// Terminal key statuses
+const (
+	TERM_STATUS_OK          = "ok"
+	TERM_STATUS_UPDATING    = "updating"
+	TERM_STATUS_ROLLED_BACK = "rolled_back"
+	TERM_STATUS_FAILED      = "failed"
+)
+
+// KEY_LEN is MIFARE sector key length in bytes
+const KEY_LEN = 6
+
+// ConfigPusher pushes configuration to POCKET terminals and waits for confirmation
+type ConfigPusher interface {
+	PushPocketConfig(key string, profile map[string]interface{}) error
+	WaitPocketConfig(key string, since time.Time, timeout time.Duration) error
+}
+
+// Generation is a set of sector keys, keys are encrypted with master key
+type Generation struct {
+	Gen     int               `json:"gen"`
+	Created time.Time         `json:"created"`
+	State   string            `json:"state"`
+	Keys    map[string]string `json:"keys"` // sector -> encrypted key
+}
+
+// TerminalKey is key generation installed on terminal
+type TerminalKey struct {
+	Gen     int       `json:"gen"`
+	Status  string    `json:"status"`
+	Updated time.Time `json:"updated"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Rollout is state of key generation rollout
+type Rollout struct {
+	Gen      int       `json:"gen"`
+	Running  bool      `json:"running"`
+	Current  string    `json:"current,omitempty"`
+	Done     []string  `json:"done"`
+	Pending  []string  `json:"pending"`
+	Failed   string    `json:"failed,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+}
+
+// Keyring manages MIFARE sector key generations of POCKET readers.
+// Keys are stored in file encrypted with AES-GCM, new generation is rolled
+// out terminal-by-terminal and rolled back on first failure.
+type Keyring struct {
+	file      string
+	master    []byte
+	sectors   []int
+	mutex     sync.Mutex
+	gens      []*Generation
+	terminals map[string]*TerminalKey
+	rollout   *Rollout
+}
+
+type persistData struct {
+	Generations []*Generation           `json:"generations"`
+	Terminals   map[string]*TerminalKey `json:"terminals"`
+}
+
+// NewKeyring creates keyring stored in file, encrypted with master key
+// (64 hex chars). sectors are sectors that get random keys when rotating
+// without explicit keys.
+func NewKeyring(file string, masterKey string, sectors []int) (*Keyring, error) {
+	master, err := utils.CryptKey(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %v", err)
+	}
+	return &Keyring{
+		file:      file,
+		master:    master,
+		sectors:   sectors,
+		terminals: make(map[string]*TerminalKey),
+	}, nil
+}
+
+// Load loads keyring file and checks that master key decrypts it
+func (k *Keyring) Load() error {
+	fileData, err := os.ReadFile(k.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read keyring file: %v", err)
+	}
+
+	var data persistData
+	if err := json.Unmarshal(fileData, &data); err != nil {
+		return fmt.Errorf("failed to parse keyring file: %v", err)
+	}
+	for _, gen := range data.Generations {
+		for sector, enc := range gen.Keys {
+			if _, err := utils.CryptDecrypt(k.master, enc); err != nil {
+				return fmt.Errorf("failed to decrypt key generation %d sector %s: wrong master key?", gen.Gen, sector)
+			}
+		}
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.gens = data.Generations
+	if data.Terminals != nil {
+		k.terminals = data.Terminals
+	}
+	fmt.Printf("Keyring: loaded %d key generations, %d terminals\n", len(k.gens), len(k.terminals))
+	return nil
+}
+
+// save writes keyring file, must be called with mutex held
+func (k *Keyring) save() error {
+	jsonData, err := json.MarshalIndent(persistData{Generations: k.gens, Terminals: k.terminals}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := k.file + ".tmp"
+	if err := os.WriteFile(tmp, jsonData, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, k.file)
+}
+
+// saveLocked saves keyring and logs error
+func (k *Keyring) saveLocked() {
+	if err := k.save(); err != nil {
+		fmt.Printf("Keyring: failed to save: %v\n", err)
+	}
+}
+
+// Import stores keys currently installed on the fleet as active generation
+// without pushing them (initial keys or keys changed outside of the system)
+func (k *Keyring) Import(keys map[int]string) (int, error) {
+	if len(keys) == 0 {
+		return 0, fmt.Errorf("no keys to import")
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if k.rollout != nil && k.rollout.Running {
+		return 0, fmt.Errorf("key rollout in progress")
+	}
+
+	gen, err := k.newGeneration(keys)
+	if err != nil {
+		return 0, err
+	}
+	k.activate(gen)
+	if err := k.save(); err != nil {
+		return 0, err
+	}
+	fmt.Printf("Keyring: imported key generation %d\n", gen.Gen)
+	return gen.Gen, nil
+}
+
+// Rotate creates new key generation (random keys when keys is empty) and
+// starts its rollout to terminals one by one. Keys currently installed on
+// terminals must be imported first: without them failed rollout can't be
+// rolled back
+func (k *Keyring) Rotate(keys map[int]string, terminals []string, pusher ConfigPusher, timeout time.Duration) (int, error) {
+	if len(keys) == 0 {
+		if len(k.sectors) == 0 {
+			return 0, fmt.Errorf("no sectors configured for key rotation")
+		}
+		keys = make(map[int]string, len(k.sectors))
+		for _, sector := range k.sectors {
+			key := make([]byte, KEY_LEN)
+			if _, err := rand.Read(key); err != nil {
+				return 0, err
+			}
+			keys[sector] = strings.ToUpper(hex.EncodeToString(key))
+		}
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if k.rollout != nil && k.rollout.Running {
+		return 0, fmt.Errorf("key rollout in progress")
+	}
+	if k.activeGen() == 0 {
+		return 0, fmt.Errorf("no active key generation: import current keys first")
+	}
+
+	gen, err := k.newGeneration(keys)
+	if err != nil {
+		return 0, err
+	}
+	k.rollout = &Rollout{
+		Gen:     gen.Gen,
+		Running: true,
+		Done:    []string{},
+		Pending: append([]string{}, terminals...),
+		Started: time.Now(),
+	}
+	k.saveLocked()
+
+	fmt.Printf("Keyring: rolling out key generation %d to %d terminals\n", gen.Gen, len(terminals))
+	go k.run(gen.Gen, terminals, pusher, timeout)
+	return gen.Gen, nil
+}
+
+// run rolls out generation terminal-by-terminal, on failure rolls back
+// failed terminal and all terminals already updated
+func (k *Keyring) run(gen int, terminals []string, pusher ConfigPusher, timeout time.Duration) {
+	previous := make(map[string]int)
+	var updated []string
+
+	for _, term := range terminals {
+		k.mutex.Lock()
+		prev := k.activeGen()
+		if tk, ok := k.terminals[term]; ok && tk.Gen > 0 {
+			prev = tk.Gen
+		}
+		previous[term] = prev
+		k.rollout.Current = term
+		k.rollout.Pending = k.rollout.Pending[1:]
+		k.setTerminal(term, prev, TERM_STATUS_UPDATING, "")
+		k.mutex.Unlock()
+
+		err := k.apply(term, gen, pusher, timeout)
+
+		k.mutex.Lock()
+		if err == nil {
+			k.setTerminal(term, gen, TERM_STATUS_OK, "")
+			k.rollout.Done = append(k.rollout.Done, term)
+			k.saveLocked()
+			k.mutex.Unlock()
+			updated = append(updated, term)
+			fmt.Printf("Keyring: key generation %d installed on %s\n", gen, term)
+			continue
+		}
+		k.rollout.Failed = term
+		k.rollout.Error = err.Error()
+		k.mutex.Unlock()
+
+		fmt.Printf("Keyring: key generation %d failed on %s: %v, rolling back\n", gen, term, err)
+		k.rollback(term, previous[term], err, pusher, timeout)
+		for i := len(updated) - 1; i >= 0; i-- {
+			k.rollback(updated[i], previous[updated[i]], fmt.Errorf("rollout failed on %s", term), pusher, timeout)
+		}
+		k.finish(gen, GEN_STATE_FAILED)
+		return
+	}
+
+	k.finish(gen, GEN_STATE_ACTIVE)
+	fmt.Printf("Keyring: key generation %d rolled out\n", gen)
+}
+
+// rollback restores previous generation on terminal
+func (k *Keyring) rollback(term string, prev int, cause error, pusher ConfigPusher, timeout time.Duration) {
+	var err error
+	if prev == 0 {
+		err = fmt.Errorf("no previous key generation")
+	} else {
+		err = k.apply(term, prev, pusher, timeout)
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if err != nil {
+		fmt.Printf("Keyring: rollback of %s failed: %v\n", term, err)
+		k.setTerminal(term, 0, TERM_STATUS_FAILED, fmt.Sprintf("%v; rollback: %v", cause, err))
+		return
+	}
+	k.setTerminal(term, prev, TERM_STATUS_ROLLED_BACK, cause.Error())
+}
+
+// finish completes rollout with generation state
+func (k *Keyring) finish(gen int, state string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if g := k.generation(gen); g != nil {
+		if state == GEN_STATE_ACTIVE {
+			k.activate(g)
+		} else {
+			g.State = state
+		}
+	}
+	k.rollout.Running = false
+	k.rollout.Current = ""
+	k.rollout.Finished = time.Now()
+	k.saveLocked()
+}
+
+// apply pushes generation keys to terminal and waits for confirmation
+func (k *Keyring) apply(term string, gen int, pusher ConfigPusher, timeout time.Duration) error {
+	k.mutex.Lock()
+	profile, err := k.profile(gen)
+	k.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	since := time.Now()
+	if err := pusher.PushPocketConfig(term, profile); err != nil {
+		return err
+	}
+	return pusher.WaitPocketConfig(term, since, timeout)
+}
+
+// profile returns POCKET config profile with decrypted generation keys
+func (k *Keyring) profile(gen int) (map[string]interface{}, error) {
+	g := k.generation(gen)
+	if g == nil {
+		return nil, fmt.Errorf("key generation %d not found", gen)
+	}
+	var items []string
+	for sector, enc := range g.Keys {
+		key, err := utils.CryptDecrypt(k.master, enc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key generation %d: %v", gen, err)
+		}
+		items = append(items, sector+":"+string(key))
+	}
+	sort.Strings(items)
+	return map[string]interface{}{"sector_keys": items}, nil
+}
+
+// newGeneration validates, encrypts and appends generation, must be called with mutex held.
+// New generation must cover all sectors of active one, otherwise rollback
+// couldn't restore sectors missing in it
+func (k *Keyring) newGeneration(keys map[int]string) (*Generation, error) {
+	if active := k.generation(k.activeGen()); active != nil {
+		var missing []string
+		for sector := range active.Keys {
+			n, _ := strconv.Atoi(sector)
+			if _, ok := keys[n]; !ok {
+				missing = append(missing, sector)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return nil, fmt.Errorf("keys for sectors %s of generation %d are missing", strings.Join(missing, ", "), active.Gen)
+		}
+	}
+
+	gen := &Generation{
+		Gen:     1,
+		Created: time.Now(),
+		State:   GEN_STATE_PENDING,
+		Keys:    make(map[string]string, len(keys)),
+	}
+	if len(k.gens) > 0 {
+		gen.Gen = k.gens[len(k.gens)-1].Gen + 1
+	}
+	for sector, key := range keys {
+		key = strings.ToUpper(strings.TrimSpace(key))
+		raw, err := hex.DecodeString(key)
+		if err != nil || len(raw) != KEY_LEN {
+			return nil, fmt.Errorf("sector %d key must be %d bytes hex", sector, KEY_LEN)
+		}
+		if sector < 0 || sector > 0xFF {
+			return nil, fmt.Errorf("invalid sector number: %d", sector)
+		}
+		enc, err := utils.CryptEncrypt(k.master, []byte(key))
+		if err != nil {
+			return nil, err
+		}
+		gen.Keys[strconv.Itoa(sector)] = enc
+	}
+	k.gens = append(k.gens, gen)
+	return gen, nil
+}
+
+// activate makes generation active and retires previous one
+func (k *Keyring) activate(gen *Generation) {
+	for _, g := range k.gens {
+		if g.State == GEN_STATE_ACTIVE {
+			g.State = GEN_STATE_RETIRED
+		}
+	}
+	gen.State = GEN_STATE_ACTIVE
+}
+
+// activeGen returns active generation number, 0 if none
+func (k *Keyring) activeGen() int {
+	for i := len(k.gens) - 1; i >= 0; i-- {
+		if k.gens[i].State == GEN_STATE_ACTIVE {
+			return k.gens[i].Gen
+		}
+	}
+	return 0
+}
+
+// generation finds generation by number
+func (k *Keyring) generation(gen int) *Generation {
+	for _, g := range k.gens {
+		if g.Gen == gen {
+			return g
+		}
+	}
+	return nil
+}
+
+// setTerminal updates terminal key state
+func (k *Keyring) setTerminal(term string, gen int, status string, errText string) {
+	k.terminals[term] = &TerminalKey{Gen: gen, Status: status, Updated: time.Now(), Error: errText}
+}
+
+// Status returns generations (without keys), terminal generations and rollout state
+func (k *Keyring) Status() map[string]interface{} {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	gens := make([]map[string]interface{}, 0, len(k.gens))
+	for _, g := range k.gens {
+		sectors := make([]int, 0, len(g.Keys))
+		for sector := range g.Keys {
+			n, _ := strconv.Atoi(sector)
+			sectors = append(sectors, n)
+		}
+		sort.Ints(sectors)
+		gens = append(gens, map[string]interface{}{
+			"gen":     g.Gen,
+			"created": g.Created.Unix() * 1000,
+			"state":   g.State,
+			"sectors": sectors,
+		})
+	}
+
+	terminals := make(map[string]TerminalKey, len(k.terminals))
+	for term, tk := range k.terminals {
+		terminals[term] = *tk
+	}
+
+	result := map[string]interface{}{
+		"active":      k.activeGen(),
+		"generations": gens,
+		"terminals":   terminals,
+	}
+	if k.rollout != nil {
+		result["rollout"] = *k.rollout
+	}
+	return result
+}
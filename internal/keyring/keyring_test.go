@@ -0,0 +1,164 @@
+package keyring
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testMasterKey = "000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F"
+
+// fakePusher records pushed sector keys and fails confirmation for chosen terminals
+type fakePusher struct {
+	mutex  sync.Mutex
+	fail   map[string]bool
+	pushes []string // "term=sector:key,..."
+}
+
+func (p *fakePusher) PushPocketConfig(key string, profile map[string]interface{}) error {
+	items, _ := profile["sector_keys"].([]string)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.pushes = append(p.pushes, key+"="+strings.Join(items, ","))
+	return nil
+}
+
+func (p *fakePusher) WaitPocketConfig(key string, since time.Time, timeout time.Duration) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.fail[key] {
+		p.fail[key] = false // rollback push is confirmed
+		return fmt.Errorf("config confirmation timeout")
+	}
+	return nil
+}
+
+func newTestKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(filepath.Join(t.TempDir(), "keys.json"), testMasterKey, []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// waitRollout waits until rollout is finished
+func waitRollout(t *testing.T, k *Keyring) Rollout {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if r, ok := k.Status()["rollout"].(Rollout); ok && !r.Running {
+			return r
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("rollout not finished")
+	return Rollout{}
+}
+
+func TestNewKeyringRejectsPassphrase(t *testing.T) {
+	if _, err := NewKeyring("keys.json", "secret passphrase", nil); err == nil {
+		t.Error("NewKeyring accepted passphrase as master key")
+	}
+}
+
+func TestRotateRequiresActiveGeneration(t *testing.T) {
+	k := newTestKeyring(t)
+	if _, err := k.Rotate(nil, []string{"t1"}, &fakePusher{}, time.Second); err == nil {
+		t.Fatal("Rotate succeeded without imported keys")
+	}
+	if len(k.gens) != 0 {
+		t.Errorf("generation created: %d", len(k.gens))
+	}
+}
+
+func TestNewGenerationCoversActiveSectors(t *testing.T) {
+	k := newTestKeyring(t)
+	if _, err := k.Import(map[int]string{1: "A0A1A2A3A4A5", 2: "B0B1B2B3B4B5"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := k.Rotate(map[int]string{1: "C0C1C2C3C4C5"}, []string{"t1"}, &fakePusher{}, time.Second); err == nil {
+		t.Error("Rotate accepted generation without sector 2")
+	}
+	if _, err := k.Import(map[int]string{2: "C0C1C2C3C4C5", 3: "D0D1D2D3D4D5"}); err == nil {
+		t.Error("Import accepted generation without sector 1")
+	}
+	if got := k.Status()["active"]; got != 1 {
+		t.Errorf("active generation = %v, want 1", got)
+	}
+}
+
+func TestRotateRollback(t *testing.T) {
+	k := newTestKeyring(t)
+	if _, err := k.Import(map[int]string{1: "A0A1A2A3A4A5"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pusher := &fakePusher{fail: map[string]bool{"t2": true}}
+	gen, err := k.Rotate(map[int]string{1: "C0C1C2C3C4C5"}, []string{"t1", "t2", "t3"}, pusher, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := waitRollout(t, k)
+
+	if r.Failed != "t2" || !reflect.DeepEqual(r.Done, []string{"t1"}) {
+		t.Errorf("rollout = %+v", r)
+	}
+	wantPushes := []string{
+		"t1=1:C0C1C2C3C4C5",
+		"t2=1:C0C1C2C3C4C5",
+		"t2=1:A0A1A2A3A4A5", // failed terminal rolled back first
+		"t1=1:A0A1A2A3A4A5", // then updated ones in reverse order
+	}
+	if !reflect.DeepEqual(pusher.pushes, wantPushes) {
+		t.Errorf("pushes = %q, want %q", pusher.pushes, wantPushes)
+	}
+
+	status := k.Status()
+	if status["active"] != 1 {
+		t.Errorf("active generation = %v, want 1", status["active"])
+	}
+	if g := k.generation(gen); g == nil || g.State != GEN_STATE_FAILED {
+		t.Errorf("generation %d state = %+v, want failed", gen, g)
+	}
+	terminals := status["terminals"].(map[string]TerminalKey)
+	for _, term := range []string{"t1", "t2"} {
+		if tk := terminals[term]; tk.Gen != 1 || tk.Status != TERM_STATUS_ROLLED_BACK {
+			t.Errorf("%s = %+v, want generation 1 rolled back", term, tk)
+		}
+	}
+	if _, ok := terminals["t3"]; ok {
+		t.Error("t3 touched after failure")
+	}
+
+	// Keyring file keeps state after reload
+	loaded, _ := NewKeyring(k.file, testMasterKey, nil)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Status()["active"] != 1 || len(loaded.gens) != 2 {
+		t.Errorf("reloaded keyring = %v", loaded.Status())
+	}
+}
+
+func TestRotateSuccess(t *testing.T) {
+	k := newTestKeyring(t)
+	if _, err := k.Import(map[int]string{1: "A0A1A2A3A4A5"}); err != nil {
+		t.Fatal(err)
+	}
+	gen, err := k.Rotate(nil, []string{"t1"}, &fakePusher{}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitRollout(t, k)
+	if got := k.Status()["active"]; got != gen {
+		t.Errorf("active generation = %v, want %d", got, gen)
+	}
+	if g := k.generation(1); g.State != GEN_STATE_RETIRED {
+		t.Errorf("previous generation state = %s, want retired", g.State)
+	}
+}
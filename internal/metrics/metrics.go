@@ -0,0 +1,300 @@
+// Package metrics implements counters, histograms and scrape-time gauges
+// exported in Prometheus text format (version 0.0.4) without client library.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types
+const (
+	TYPE_COUNTER   = "counter"
+	TYPE_GAUGE     = "gauge"
+	TYPE_HISTOGRAM = "histogram"
+)
+
+// DefBuckets are histogram buckets for request latencies, seconds
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Sample is metric value with label values (in order of metric labels)
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// collector writes metric family
+type collector interface {
+	describe() (name, help, typ string)
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics of process
+type Registry struct {
+	metrics map[string]collector
+	mutex   sync.RWMutex
+}
+
+// Default is registry of process metrics, served by /metrics
+var Default = NewRegistry()
+
+// NewRegistry creates empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]collector)}
+}
+
+// register adds metric, metric with same name is replaced
+func (r *Registry) register(c collector) {
+	name, _, _ := c.describe()
+	r.mutex.Lock()
+	r.metrics[name] = c
+	r.mutex.Unlock()
+}
+
+// WriteText writes all metrics in Prometheus text format, sorted by name
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mutex.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, r.metrics[name])
+	}
+	r.mutex.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		name, help, typ := c.describe()
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, escapeHelp(help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, typ)
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// desc is common part of metrics
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// seriesKey joins label values to map key
+func seriesKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// checkLabels panics on wrong number of label values (programming error)
+func (d *desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+}
+
+// Counter is monotonically increasing value per label values
+type Counter struct {
+	desc
+	values map[string]*Sample
+	mutex  sync.Mutex
+}
+
+// NewCounter creates counter registered in Default
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, labels}, values: make(map[string]*Sample)}
+	Default.register(c)
+	return c
+}
+
+// Inc increments counter of label values by 1
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds non-negative value to counter of label values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.checkLabels(labelValues)
+	if v < 0 {
+		return
+	}
+	key := seriesKey(labelValues)
+	c.mutex.Lock()
+	s, ok := c.values[key]
+	if !ok {
+		s = &Sample{Labels: append([]string(nil), labelValues...)}
+		c.values[key] = s
+	}
+	s.Value += v
+	c.mutex.Unlock()
+}
+
+func (c *Counter) describe() (string, string, string) {
+	return c.name, c.help, TYPE_COUNTER
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mutex.Lock()
+	samples := make([]Sample, 0, len(c.values))
+	for _, s := range c.values {
+		samples = append(samples, *s)
+	}
+	c.mutex.Unlock()
+	writeSamples(w, c.name, c.labels, samples)
+}
+
+// Histogram counts observations in cumulative buckets per label values
+type Histogram struct {
+	desc
+	buckets []float64
+	series  map[string]*histogramSeries
+	mutex   sync.Mutex
+}
+
+type histogramSeries struct {
+	labels []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates histogram with upper bounds of buckets, registered in Default
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	h := &Histogram{desc: desc{name, help, labels}, buckets: b, series: make(map[string]*histogramSeries)}
+	Default.register(h)
+	return h
+}
+
+// Observe adds observation of label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.checkLabels(labelValues)
+	key := seriesKey(labelValues)
+	h.mutex.Lock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labels: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+	h.mutex.Unlock()
+}
+
+func (h *Histogram) describe() (string, string, string) {
+	return h.name, h.help, TYPE_HISTOGRAM
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	series := make([]histogramSeries, 0, len(h.series))
+	for _, s := range h.series {
+		c := *s
+		c.counts = append([]uint64(nil), s.counts...)
+		series = append(series, c)
+	}
+	h.mutex.Unlock()
+	sort.Slice(series, func(i, j int) bool { return seriesKey(series[i].labels) < seriesKey(series[j].labels) })
+
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, s := range series {
+		var cumulative uint64
+		values := append(append([]string(nil), s.labels...), "")
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			values[len(values)-1] = formatValue(bound)
+			writeSample(w, h.name+"_bucket", bucketLabels, values, float64(cumulative))
+		}
+		values[len(values)-1] = "+Inf"
+		writeSample(w, h.name+"_bucket", bucketLabels, values, float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, s.labels, s.sum)
+		writeSample(w, h.name+"_count", h.labels, s.labels, float64(s.count))
+	}
+}
+
+// GaugeFunc is gauge collected at scrape time
+type GaugeFunc struct {
+	desc
+	collect func() []Sample
+}
+
+// NewGaugeFunc registers gauge in Default, collect returns current samples
+func NewGaugeFunc(name, help string, labels []string, collect func() []Sample) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name, help, labels}, collect: collect}
+	Default.register(g)
+	return g
+}
+
+func (g *GaugeFunc) describe() (string, string, string) {
+	return g.name, g.help, TYPE_GAUGE
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	writeSamples(w, g.name, g.labels, g.collect())
+}
+
+// writeSamples writes samples sorted by label values
+func writeSamples(w *bufio.Writer, name string, labels []string, samples []Sample) {
+	sort.Slice(samples, func(i, j int) bool { return seriesKey(samples[i].Labels) < seriesKey(samples[j].Labels) })
+	for _, s := range samples {
+		if len(s.Labels) != len(labels) {
+			continue
+		}
+		writeSample(w, name, labels, s.Labels, s.Value)
+	}
+}
+
+// writeSample writes one line: name{label="value",...} value
+func writeSample(w *bufio.Writer, name string, labels, values []string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, escapeLabel(values[i]))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatValue(v))
+	w.WriteByte('\n')
+}
+
+// formatValue formats sample value
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,36 @@
+package metrics
+
+// StageBuckets are histogram buckets for session stages and sessions, seconds
+var StageBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// СКД metrics updated by daemon, session manager, pool and service clients.
+// Scrape-time gauges (terminal state, card lists, SSE subscribers) are
+// registered by daemon.
+var (
+	TagReads = NewCounter("skd_tag_reads_total",
+		"Identifiers read by terminals", "terminal", "kind")
+	AccessAllowed = NewCounter("skd_access_allowed_total",
+		"Passes allowed", "terminal")
+	AccessDenied = NewCounter("skd_access_denied_total",
+		"Passes denied by reason", "terminal", "reason")
+
+	SessionStageDuration = NewHistogram("skd_session_stage_duration_seconds",
+		"Time spent by session in stage", StageBuckets, "stage")
+	SessionDuration = NewHistogram("skd_session_duration_seconds",
+		"Session time from creation to completion", StageBuckets, "result")
+
+	Request1CDuration = NewHistogram("skd_1c_request_duration_seconds",
+		"1C request latency (each attempt)", DefBuckets, "request")
+	Request1CErrors = NewCounter("skd_1c_request_errors_total",
+		"Failed 1C requests (network error or non-200 status)", "request")
+
+	CRTRequestDuration = NewHistogram("skd_crt_request_duration_seconds",
+		"CRT request latency", DefBuckets, "request")
+	CRTRequestErrors = NewCounter("skd_crt_request_errors_total",
+		"Failed CRT requests (network error or non-200 status)", "request")
+
+	TerminalDisconnects = NewCounter("skd_terminal_disconnects_total",
+		"Terminal connections lost", "terminal")
+	TerminalReconnects = NewCounter("skd_terminal_reconnects_total",
+		"Terminal reconnect attempts by result", "terminal", "result")
+)
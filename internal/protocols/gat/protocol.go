@@ -21,6 +21,17 @@ const (
 	GAT_TTYPE_TIME   = 0x02
 
 	GAT_ARES_USED = 0x01
+
+	// Host control flags (GAT_CMD_HOST_CONTROL payload, LE16).
+	// There is no GAT protocol document or capture in this tree: command code
+	// and LE16 control word are the ones DecodePacket already parses
+	// (control_data), bit assignments below are UNVERIFIED and must be checked
+	// against vendor documentation or terminal capture before production use
+	GAT_HC_NONE         = 0x0000
+	GAT_HC_ACCESS_DENY  = 0x0001
+	GAT_HC_ACCESS_GRANT = 0x0002
+	GAT_HC_BUSY         = 0x0004
+	GAT_HC_MESSAGE      = 0x0008
 )
 
 // Terminal types
@@ -313,3 +324,19 @@ func CreateReqMasterPacket(address uint8, terminalType uint8) []byte {
 	payload := []byte{terminalType}
 	return EncodePacket(GAT_CMD_REQ_MASTER, address, 0, payload)
 }
+
+// CreateCancelPacket creates CANCEL packet aborting pending card transaction
+func CreateCancelPacket(address uint8) []byte {
+	return EncodePacket(GAT_CMD_CANCEL, address, 0, nil)
+}
+
+// CreateHostControlPacket creates HOST_CONTROL packet
+// control: GAT_HC_* flags (unverified, see above), text: optional message for terminal display
+func CreateHostControlPacket(address uint8, control uint16, text string) []byte {
+	payload := make([]byte, 2)
+	binary.LittleEndian.PutUint16(payload, control)
+	if text != "" {
+		payload = append(payload, []byte(text)...)
+	}
+	return EncodePacket(GAT_CMD_HOST_CONTROL, address, 0, payload)
+}
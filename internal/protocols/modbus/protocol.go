@@ -0,0 +1,192 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"nd-go/pkg/types"
+	"sync"
+	"time"
+)
+
+// Modbus TCP protocol constants
+const (
+	MODBUS_PROTOCOL_ID = 0x0000
+	MODBUS_MBAP_LEN    = 7   // Transaction ID(2), Protocol ID(2), Length(2), Unit ID(1)
+	MODBUS_MAX_ADU     = 260 // MBAP + max PDU (253)
+
+	// Function codes
+	MODBUS_FC_READ_COILS           = 0x01
+	MODBUS_FC_READ_DISCRETE_INPUTS = 0x02
+	MODBUS_FC_READ_HOLDING         = 0x03
+	MODBUS_FC_READ_INPUT_REGS      = 0x04
+	MODBUS_FC_WRITE_SINGLE_COIL    = 0x05
+	MODBUS_FC_WRITE_MULTIPLE_COILS = 0x0F
+	MODBUS_FC_EXCEPTION            = 0x80 // Set in function code of exception response
+
+	// Coil values for Write Single Coil
+	MODBUS_COIL_ON  = 0xFF00
+	MODBUS_COIL_OFF = 0x0000
+
+	// Exception codes
+	MODBUS_EX_ILLEGAL_FUNCTION = 0x01
+	MODBUS_EX_ILLEGAL_ADDRESS  = 0x02
+	MODBUS_EX_ILLEGAL_VALUE    = 0x03
+	MODBUS_EX_DEVICE_FAILURE   = 0x04
+	MODBUS_EX_ACKNOWLEDGE      = 0x05
+	MODBUS_EX_DEVICE_BUSY      = 0x06
+	MODBUS_EX_GATEWAY_PATH     = 0x0A
+	MODBUS_EX_GATEWAY_TARGET   = 0x0B
+
+	// Defaults
+	MODBUS_DEFAULT_UNIT    = 1
+	MODBUS_DEFAULT_PULSE   = 1000 // ms
+	MODBUS_DEFAULT_POLL    = 200  // ms
+	MODBUS_DEFAULT_TIMEOUT = 10   // seconds without responses
+)
+
+// ModbusConnection represents Modbus TCP client state of I/O module connection
+type ModbusConnection struct {
+	Mutex       sync.Mutex
+	TransID     uint16
+	PollPending bool      // Read Discrete Inputs request awaiting response
+	LastPoll    time.Time // When inputs were last requested
+	LastReply   time.Time // When last response was received
+	Inputs      []bool    // Last known discrete inputs
+	CoilOn      bool      // Coil pulse in progress
+	CoilOffAt   time.Time // When pulse ends
+}
+
+// NewModbusConnection creates new Modbus connection state
+func NewModbusConnection() *ModbusConnection {
+	return &ModbusConnection{
+		LastReply: time.Now(),
+	}
+}
+
+// NextTransID returns next transaction identifier
+func (mc *ModbusConnection) NextTransID() uint16 {
+	mc.TransID++
+	return mc.TransID
+}
+
+// EncodeRequest encodes Modbus TCP request (MBAP header + PDU)
+func EncodeRequest(transID uint16, unit uint8, function uint8, data []byte) []byte {
+	packet := make([]byte, MODBUS_MBAP_LEN+1+len(data))
+	binary.BigEndian.PutUint16(packet[0:2], transID)
+	binary.BigEndian.PutUint16(packet[2:4], MODBUS_PROTOCOL_ID)
+	binary.BigEndian.PutUint16(packet[4:6], uint16(2+len(data))) // Unit ID + function + data
+	packet[6] = unit
+	packet[7] = function
+	copy(packet[8:], data)
+	return packet
+}
+
+// CreateReadBitsRequest creates Read Coils / Read Discrete Inputs request
+func CreateReadBitsRequest(transID uint16, unit uint8, function uint8, start, count uint16) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint16(data[0:2], start)
+	binary.BigEndian.PutUint16(data[2:4], count)
+	return EncodeRequest(transID, unit, function, data)
+}
+
+// CreateWriteCoilRequest creates Write Single Coil request
+func CreateWriteCoilRequest(transID uint16, unit uint8, address uint16, on bool) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint16(data[0:2], address)
+	if on {
+		binary.BigEndian.PutUint16(data[2:4], MODBUS_COIL_ON)
+	} else {
+		binary.BigEndian.PutUint16(data[2:4], MODBUS_COIL_OFF)
+	}
+	return EncodeRequest(transID, unit, MODBUS_FC_WRITE_SINGLE_COIL, data)
+}
+
+// FrameLen returns length of complete ADU at buffer start,
+// 0 if more data is needed and -1 if buffer doesn't start with valid header
+func FrameLen(buf []byte) int {
+	if len(buf) < 6 {
+		return 0
+	}
+	if binary.BigEndian.Uint16(buf[2:4]) != MODBUS_PROTOCOL_ID {
+		return -1
+	}
+	length := int(binary.BigEndian.Uint16(buf[4:6]))
+	if length < 2 || 6+length > MODBUS_MAX_ADU {
+		return -1
+	}
+	if len(buf) < 6+length {
+		return 0
+	}
+	return 6 + length
+}
+
+// DecodeResponse decodes Modbus TCP response
+func DecodeResponse(data []byte) (*types.Packet, error) {
+	total := FrameLen(data)
+	if total <= 0 {
+		return nil, fmt.Errorf("incomplete or invalid Modbus packet")
+	}
+	data = data[:total]
+
+	function := data[7]
+	pdu := data[8:]
+	packetData := map[string]interface{}{
+		"transaction_id": binary.BigEndian.Uint16(data[0:2]),
+		"unit":           data[6],
+		"function":       function & 0x7F,
+	}
+
+	if function&MODBUS_FC_EXCEPTION != 0 {
+		if len(pdu) < 1 {
+			return nil, fmt.Errorf("exception response without code")
+		}
+		packetData["exception"] = pdu[0]
+		return &types.Packet{Cmd: function, Data: packetData}, nil
+	}
+
+	switch function {
+	case MODBUS_FC_READ_COILS, MODBUS_FC_READ_DISCRETE_INPUTS:
+		if len(pdu) < 1 || int(pdu[0]) > len(pdu)-1 {
+			return nil, fmt.Errorf("invalid byte count in read bits response")
+		}
+		count := int(pdu[0])
+		bits := make([]bool, count*8)
+		for i := range bits {
+			bits[i] = pdu[1+i/8]&(1<<uint(i%8)) != 0 // LSB of first byte is first bit
+		}
+		packetData["bits"] = bits
+
+	case MODBUS_FC_WRITE_SINGLE_COIL:
+		if len(pdu) < 4 {
+			return nil, fmt.Errorf("invalid write coil response length: %d", len(pdu))
+		}
+		packetData["address"] = binary.BigEndian.Uint16(pdu[0:2])
+		packetData["on"] = binary.BigEndian.Uint16(pdu[2:4]) == MODBUS_COIL_ON
+	}
+
+	return &types.Packet{Cmd: function, Data: packetData}, nil
+}
+
+// ExceptionText returns description of exception code
+func ExceptionText(code uint8) string {
+	switch code {
+	case MODBUS_EX_ILLEGAL_FUNCTION:
+		return "illegal function"
+	case MODBUS_EX_ILLEGAL_ADDRESS:
+		return "illegal data address"
+	case MODBUS_EX_ILLEGAL_VALUE:
+		return "illegal data value"
+	case MODBUS_EX_DEVICE_FAILURE:
+		return "server device failure"
+	case MODBUS_EX_ACKNOWLEDGE:
+		return "acknowledge"
+	case MODBUS_EX_DEVICE_BUSY:
+		return "server device busy"
+	case MODBUS_EX_GATEWAY_PATH:
+		return "gateway path unavailable"
+	case MODBUS_EX_GATEWAY_TARGET:
+		return "gateway target device failed to respond"
+	default:
+		return fmt.Sprintf("exception 0x%02X", code)
+	}
+}
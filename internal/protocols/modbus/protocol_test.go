@@ -0,0 +1,105 @@
+package modbus
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// Request and response PDUs are examples of Modbus Application Protocol
+// Specification V1.1b3 (sections 6.2 and 6.5)
+
+func TestEncodeRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet []byte
+		want   []byte
+	}{
+		{"read discrete inputs", CreateReadBitsRequest(0x0102, 1, MODBUS_FC_READ_DISCRETE_INPUTS, 0x00C4, 0x0016),
+			[]byte{0x01, 0x02, 0x00, 0x00, 0x00, 0x06, 0x01, 0x02, 0x00, 0xC4, 0x00, 0x16}},
+		{"read coils", CreateReadBitsRequest(7, 0xFF, MODBUS_FC_READ_COILS, 0x0013, 0x0013),
+			[]byte{0x00, 0x07, 0x00, 0x00, 0x00, 0x06, 0xFF, 0x01, 0x00, 0x13, 0x00, 0x13}},
+		{"write coil on", CreateWriteCoilRequest(1, 1, 0x00AC, true),
+			[]byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x05, 0x00, 0xAC, 0xFF, 0x00}},
+		{"write coil off", CreateWriteCoilRequest(1, 1, 0x00AC, false),
+			[]byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x05, 0x00, 0xAC, 0x00, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !bytes.Equal(tt.packet, tt.want) {
+				t.Errorf("got % X, want % X", tt.packet, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrameLen(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		want int
+	}{
+		{"empty", nil, 0},
+		{"short header", []byte{0x00, 0x01, 0x00, 0x00, 0x00}, 0},
+		{"incomplete", []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x05}, 0},
+		{"complete", []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x03, 0x01, 0x82, 0x02}, 9},
+		{"followed by next frame", []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x03, 0x01, 0x82, 0x02, 0x00, 0x02}, 9},
+		{"bad protocol id", []byte{0x00, 0x01, 0x00, 0x01, 0x00, 0x03, 0x01, 0x82, 0x02}, -1},
+		{"length too small", []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x01}, -1},
+		{"max length", []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0xFE}, 0},
+		{"length too large", []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0xFF}, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FrameLen(tt.buf); got != tt.want {
+				t.Errorf("FrameLen(% X) = %d, want %d", tt.buf, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	bits := func(bytes ...byte) []bool {
+		result := make([]bool, 0, len(bytes)*8)
+		for _, b := range bytes {
+			for i := 0; i < 8; i++ {
+				result = append(result, b&(1<<uint(i)) != 0)
+			}
+		}
+		return result
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{"read discrete inputs", []byte{0x01, 0x02, 0x00, 0x00, 0x00, 0x06, 0x01, 0x02, 0x03, 0xAC, 0xDB, 0x35},
+			map[string]interface{}{"transaction_id": uint16(0x0102), "unit": uint8(1), "function": uint8(0x02), "bits": bits(0xAC, 0xDB, 0x35)}, false},
+		{"write coil echo", []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x05, 0x00, 0xAC, 0xFF, 0x00},
+			map[string]interface{}{"transaction_id": uint16(1), "unit": uint8(1), "function": uint8(0x05), "address": uint16(0xAC), "on": true}, false},
+		{"exception", []byte{0x00, 0x09, 0x00, 0x00, 0x00, 0x03, 0x01, 0x82, 0x02},
+			map[string]interface{}{"transaction_id": uint16(9), "unit": uint8(1), "function": uint8(0x02), "exception": uint8(MODBUS_EX_ILLEGAL_ADDRESS)}, false},
+		{"exception without code", []byte{0x00, 0x09, 0x00, 0x00, 0x00, 0x02, 0x01, 0x82}, nil, true},
+		{"byte count too large", []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x01, 0x02, 0x03, 0xAC}, nil, true},
+		{"short write coil echo", []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x04, 0x01, 0x05, 0x00, 0xAC}, nil, true},
+		{"incomplete", []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x05}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet, err := DecodeResponse(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", packet.Data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(packet.Data, tt.want) {
+				t.Errorf("got %#v, want %#v", packet.Data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,432 @@
+package osdp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"nd-go/pkg/types"
+	"sync"
+	"time"
+)
+
+// OSDP v2 protocol constants
+const (
+	OSDP_SOM = 0x53 // Start of message
+
+	OSDP_ADDR_REPLY     = 0x80 // Reply flag in address byte
+	OSDP_ADDR_BROADCAST = 0x7F
+
+	// CTRL byte
+	OSDP_CTRL_SQN_MASK = 0x03
+	OSDP_CTRL_CRC      = 0x04 // CRC-16 (otherwise 8-bit checksum)
+	OSDP_CTRL_SCB      = 0x08 // Security control block present
+
+	OSDP_HEADER_LEN = 5 // SOM, ADDR, LEN_LSB, LEN_MSB, CTRL
+	OSDP_MAX_LEN    = 1440
+
+	// Commands (ACU -> PD)
+	OSDP_CMD_POLL   = 0x60
+	OSDP_CMD_ID     = 0x61
+	OSDP_CMD_CAP    = 0x62
+	OSDP_CMD_LSTAT  = 0x64
+	OSDP_CMD_ISTAT  = 0x65
+	OSDP_CMD_OSTAT  = 0x66
+	OSDP_CMD_RSTAT  = 0x67
+	OSDP_CMD_OUT    = 0x68
+	OSDP_CMD_LED    = 0x69
+	OSDP_CMD_BUZ    = 0x6A
+	OSDP_CMD_TEXT   = 0x6B
+	OSDP_CMD_COMSET = 0x6E
+
+	// Replies (PD -> ACU)
+	OSDP_REPLY_ACK    = 0x40
+	OSDP_REPLY_NAK    = 0x41
+	OSDP_REPLY_PDID   = 0x45
+	OSDP_REPLY_PDCAP  = 0x46
+	OSDP_REPLY_LSTATR = 0x48
+	OSDP_REPLY_ISTATR = 0x49
+	OSDP_REPLY_OSTATR = 0x4A
+	OSDP_REPLY_RSTATR = 0x4B
+	OSDP_REPLY_RAW    = 0x50
+	OSDP_REPLY_FMT    = 0x51
+	OSDP_REPLY_KEYPAD = 0x53
+	OSDP_REPLY_COM    = 0x54
+	OSDP_REPLY_BUSY   = 0x79
+
+	// NAK error codes
+	OSDP_NAK_CHECK_CHAR = 0x01
+	OSDP_NAK_CMD_LEN    = 0x02
+	OSDP_NAK_UNKNOWN    = 0x03
+	OSDP_NAK_SEQ_NUM    = 0x04
+	OSDP_NAK_SC_UNSUP   = 0x05
+	OSDP_NAK_SC_COND    = 0x06
+
+	// osdp_RAW format codes
+	OSDP_RAW_FMT_NOT_SPECIFIED = 0x00
+	OSDP_RAW_FMT_WIEGAND       = 0x01
+
+	// osdp_OUT control codes
+	OSDP_OUT_NOP          = 0x00
+	OSDP_OUT_PERM_OFF     = 0x01
+	OSDP_OUT_PERM_ON      = 0x02
+	OSDP_OUT_PERM_OFF_TMR = 0x03
+	OSDP_OUT_PERM_ON_TMR  = 0x04
+	OSDP_OUT_TEMP_ON      = 0x05
+	OSDP_OUT_TEMP_OFF     = 0x06
+
+	// osdp_LED control codes
+	OSDP_LED_TEMP_NOP    = 0x00
+	OSDP_LED_TEMP_CANCEL = 0x01
+	OSDP_LED_TEMP_SET    = 0x02
+	OSDP_LED_PERM_NOP    = 0x00
+	OSDP_LED_PERM_SET    = 0x01
+
+	// LED colors
+	OSDP_COLOR_BLACK = 0x00
+	OSDP_COLOR_RED   = 0x01
+	OSDP_COLOR_GREEN = 0x02
+	OSDP_COLOR_AMBER = 0x03
+	OSDP_COLOR_BLUE  = 0x04
+
+	// osdp_BUZ tone codes
+	OSDP_BUZ_NONE    = 0x00
+	OSDP_BUZ_OFF     = 0x01
+	OSDP_BUZ_DEFAULT = 0x02
+
+	// osdp_TEXT commands
+	OSDP_TEXT_PERM_NOWRAP = 0x01
+	OSDP_TEXT_PERM_WRAP   = 0x02
+	OSDP_TEXT_TEMP_NOWRAP = 0x03
+	OSDP_TEXT_TEMP_WRAP   = 0x04
+
+	// osdp_KEYPAD special keys ('#' and '*' on keypad)
+	OSDP_KEY_ENTER = 0x0D
+	OSDP_KEY_CLEAR = 0x7F
+	OSDP_PIN_MAX   = 16 // Maximum buffered PIN digits
+
+	// Timing
+	OSDP_POLL_INTERVAL   = 200 * time.Millisecond
+	OSDP_REPLY_TIMEOUT   = 500 * time.Millisecond
+	OSDP_OFFLINE_TIMEOUT = 8 * time.Second
+	OSDP_KEYPAD_TIMEOUT  = 10 * time.Second // Buffered digits are dropped after pause
+)
+
+// OSDPConnection represents ACU state for one PD on connection
+type OSDPConnection struct {
+	Mutex      sync.Mutex
+	Address    uint8
+	SQN        uint8     // Next sequence number (0 only after reset)
+	Pending    []byte    // Command awaiting reply
+	PendingCmd uint8     // Pending command code
+	SentTime   time.Time // When pending command was sent
+	Retries    int
+	LastReply  time.Time
+	Queue      [][]byte // Commands data (cmd + payload) waiting to be sent
+	Inputs     []byte   // Last known input status
+	Online     bool
+	Keys       []byte    // PIN digits entered before terminator key
+	LastKey    time.Time // When last key was received
+}
+
+// NewOSDPConnection creates new OSDP connection state
+func NewOSDPConnection(address uint8) *OSDPConnection {
+	return &OSDPConnection{
+		Address:   address & 0x7F,
+		SQN:       0,
+		LastReply: time.Now(),
+	}
+}
+
+// NextSQN returns sequence number for next command (1..3 after initial 0)
+func (oc *OSDPConnection) NextSQN() uint8 {
+	sqn := oc.SQN
+	oc.SQN = oc.SQN%3 + 1
+	return sqn
+}
+
+// ResetSQN restarts sequence from 0 (PD resets its state on SQN 0)
+func (oc *OSDPConnection) ResetSQN() {
+	oc.SQN = 0
+}
+
+// Enqueue adds command to queue
+func (oc *OSDPConnection) Enqueue(cmd uint8, data []byte) {
+	oc.Queue = append(oc.Queue, append([]byte{cmd}, data...))
+}
+
+// AddKeys buffers osdp_KEYPAD digits and returns entered PIN when terminator
+// key is received. Clear key and pause longer than OSDP_KEYPAD_TIMEOUT drop
+// buffered digits, other keys are ignored
+func (oc *OSDPConnection) AddKeys(keys string, now time.Time) (string, bool) {
+	if len(oc.Keys) > 0 && now.Sub(oc.LastKey) >= OSDP_KEYPAD_TIMEOUT {
+		oc.Keys = oc.Keys[:0]
+	}
+	oc.LastKey = now
+	for i := 0; i < len(keys); i++ {
+		switch c := keys[i]; {
+		case c >= '0' && c <= '9':
+			if len(oc.Keys) < OSDP_PIN_MAX {
+				oc.Keys = append(oc.Keys, c)
+			}
+		case c == OSDP_KEY_CLEAR:
+			oc.Keys = oc.Keys[:0]
+		case c == OSDP_KEY_ENTER:
+			if len(oc.Keys) == 0 {
+				continue
+			}
+			pin := string(oc.Keys)
+			oc.Keys = oc.Keys[:0]
+			return pin, true
+		}
+	}
+	return "", false
+}
+
+// CRC16 calculates OSDP CRC-16 (CRC-16/AUG-CCITT: poly 0x1021, init 0x1D0F)
+func CRC16(data []byte) uint16 {
+	crc := uint16(0x1D0F)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// Checksum calculates OSDP 8-bit checksum (two's complement of sum)
+func Checksum(data []byte) uint8 {
+	var sum uint8
+	for _, b := range data {
+		sum += b
+	}
+	return uint8(-int8(sum))
+}
+
+// EncodePacket encodes OSDP command packet with CRC-16
+func EncodePacket(address uint8, sqn uint8, cmd uint8, data []byte) []byte {
+	total := OSDP_HEADER_LEN + 1 + len(data) + 2
+	packet := make([]byte, 0, total)
+	packet = append(packet, OSDP_SOM, address&0x7F, uint8(total&0xFF), uint8(total>>8))
+	packet = append(packet, (sqn&OSDP_CTRL_SQN_MASK)|OSDP_CTRL_CRC)
+	packet = append(packet, cmd)
+	packet = append(packet, data...)
+	crc := CRC16(packet)
+	return append(packet, uint8(crc&0xFF), uint8(crc>>8))
+}
+
+// FrameLen returns length of complete packet at buffer start,
+// 0 if more data is needed and -1 if buffer doesn't start with valid packet
+func FrameLen(buf []byte) int {
+	if len(buf) < 1 {
+		return 0
+	}
+	if buf[0] != OSDP_SOM {
+		return -1
+	}
+	if len(buf) < OSDP_HEADER_LEN {
+		return 0
+	}
+	total := int(binary.LittleEndian.Uint16(buf[2:4]))
+	if total < OSDP_HEADER_LEN+2 || total > OSDP_MAX_LEN {
+		return -1
+	}
+	if len(buf) < total {
+		return 0
+	}
+	return total
+}
+
+// DecodePacket decodes OSDP packet (reply from PD or command)
+func DecodePacket(data []byte) (*types.Packet, error) {
+	total := FrameLen(data)
+	if total <= 0 {
+		return nil, fmt.Errorf("incomplete or invalid OSDP packet")
+	}
+	data = data[:total]
+
+	ctrl := data[4]
+	end := total
+	if ctrl&OSDP_CTRL_CRC != 0 {
+		end -= 2
+		crc := binary.LittleEndian.Uint16(data[end:])
+		if calc := CRC16(data[:end]); calc != crc {
+			return nil, fmt.Errorf("CRC mismatch: calculated 0x%04X, received 0x%04X", calc, crc)
+		}
+	} else {
+		end--
+		if calc := Checksum(data[:end]); calc != data[end] {
+			return nil, fmt.Errorf("checksum mismatch: calculated 0x%02X, received 0x%02X", calc, data[end])
+		}
+	}
+
+	offset := OSDP_HEADER_LEN
+	packetData := map[string]interface{}{
+		"address": data[1] & 0x7F,
+		"reply":   data[1]&OSDP_ADDR_REPLY != 0,
+		"sqn":     ctrl & OSDP_CTRL_SQN_MASK,
+	}
+
+	// Security control block (Secure Channel is not supported, only skipped)
+	if ctrl&OSDP_CTRL_SCB != 0 {
+		if offset+2 > end {
+			return nil, fmt.Errorf("packet too short for SCB")
+		}
+		scbLen := int(data[offset])
+		if scbLen < 2 || offset+scbLen > end {
+			return nil, fmt.Errorf("invalid SCB length: %d", scbLen)
+		}
+		packetData["scb_type"] = data[offset+1]
+		offset += scbLen
+	}
+
+	if offset >= end {
+		return nil, fmt.Errorf("packet too short for command code")
+	}
+	code := data[offset]
+	payload := data[offset+1 : end]
+	parseReplyData(code, payload, packetData)
+
+	return &types.Packet{
+		Cmd:     code,
+		Payload: string(payload),
+		Data:    packetData,
+	}, nil
+}
+
+// parseReplyData parses known reply payloads into packet data
+func parseReplyData(code uint8, payload []byte, packetData map[string]interface{}) {
+	switch code {
+	case OSDP_REPLY_NAK:
+		if len(payload) >= 1 {
+			packetData["error_code"] = payload[0]
+		}
+
+	case OSDP_REPLY_PDID:
+		if len(payload) >= 12 {
+			packetData["vendor"] = fmt.Sprintf("%02X%02X%02X", payload[0], payload[1], payload[2])
+			packetData["model"] = payload[3]
+			packetData["version"] = payload[4]
+			packetData["serial"] = binary.LittleEndian.Uint32(payload[5:9])
+			packetData["firmware"] = fmt.Sprintf("%d.%d.%d", payload[9], payload[10], payload[11])
+		}
+
+	case OSDP_REPLY_ISTATR, OSDP_REPLY_OSTATR:
+		status := make([]byte, len(payload))
+		copy(status, payload)
+		packetData["status"] = status
+
+	case OSDP_REPLY_RAW:
+		// reader(1), format(1), bit count(2 LE), data (MSB first)
+		if len(payload) >= 4 {
+			bitCount := int(binary.LittleEndian.Uint16(payload[2:4]))
+			raw := make([]byte, len(payload)-4)
+			copy(raw, payload[4:])
+			packetData["reader"] = payload[0]
+			packetData["format"] = payload[1]
+			packetData["bit_count"] = bitCount
+			packetData["raw"] = raw
+		}
+
+	case OSDP_REPLY_FMT:
+		// reader(1), direction(1), length(1), ASCII data
+		if len(payload) >= 3 {
+			n := int(payload[2])
+			if n > len(payload)-3 {
+				n = len(payload) - 3
+			}
+			packetData["reader"] = payload[0]
+			packetData["direction"] = payload[1]
+			packetData["text"] = string(payload[3 : 3+n])
+		}
+
+	case OSDP_REPLY_KEYPAD:
+		// reader(1), count(1), digits
+		if len(payload) >= 2 {
+			n := int(payload[1])
+			if n > len(payload)-2 {
+				n = len(payload) - 2
+			}
+			packetData["reader"] = payload[0]
+			packetData["keys"] = string(payload[2 : 2+n])
+		}
+	}
+}
+
+// RawBits returns first bitCount bits of osdp_RAW data as integer (MSB first)
+func RawBits(raw []byte, bitCount int) (uint64, error) {
+	if bitCount <= 0 || bitCount > 64 || len(raw)*8 < bitCount {
+		return 0, fmt.Errorf("invalid raw data: %d bits in %d bytes", bitCount, len(raw))
+	}
+	var value uint64
+	for i := 0; i < bitCount; i++ {
+		bit := (raw[i/8] >> uint(7-i%8)) & 1
+		value = value<<1 | uint64(bit)
+	}
+	return value, nil
+}
+
+// OutputData builds osdp_OUT record
+// timeMs is rounded to 100 ms units for temporary/timed control codes
+func OutputData(output uint8, controlCode uint8, timeMs int) []byte {
+	units := (timeMs + 99) / 100
+	if units > 0xFFFF {
+		units = 0xFFFF
+	}
+	return []byte{output, controlCode, uint8(units & 0xFF), uint8(units >> 8)}
+}
+
+// LEDData builds osdp_LED record with temporary and permanent settings
+// Times are in milliseconds (converted to 100 ms units)
+func LEDData(reader, led uint8, tempCode uint8, tempOn, tempOff int, tempOnColor, tempOffColor uint8, tempTimeMs int,
+	permCode uint8, permOn, permOff int, permOnColor, permOffColor uint8) []byte {
+	timer := (tempTimeMs + 99) / 100
+	if timer > 0xFFFF {
+		timer = 0xFFFF
+	}
+	return []byte{
+		reader, led,
+		tempCode, units100(tempOn), units100(tempOff), tempOnColor, tempOffColor,
+		uint8(timer & 0xFF), uint8(timer >> 8),
+		permCode, units100(permOn), units100(permOff), permOnColor, permOffColor,
+	}
+}
+
+// BuzzerData builds osdp_BUZ record (times in milliseconds)
+func BuzzerData(reader uint8, tone uint8, onMs, offMs int, count uint8) []byte {
+	return []byte{reader, tone, units100(onMs), units100(offMs), count}
+}
+
+// TextData builds osdp_TEXT record (ASCII only, max 32 chars)
+func TextData(reader uint8, command uint8, tempSeconds uint8, row, col uint8, text string) []byte {
+	ascii := make([]byte, 0, len(text))
+	for _, c := range text {
+		if c == '\n' || c == '\r' {
+			c = ' '
+		}
+		if c < 0x20 || c > 0x7E {
+			c = '?'
+		}
+		ascii = append(ascii, byte(c))
+	}
+	if len(ascii) > 32 {
+		ascii = ascii[:32]
+	}
+	data := []byte{reader, command, tempSeconds, row, col, uint8(len(ascii))}
+	return append(data, ascii...)
+}
+
+// units100 converts milliseconds to 100 ms units (single byte)
+func units100(ms int) uint8 {
+	u := (ms + 99) / 100
+	if u > 0xFF {
+		u = 0xFF
+	}
+	if u < 0 {
+		u = 0
+	}
+	return uint8(u)
+}
@@ -0,0 +1,140 @@
+package osdp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want uint16
+	}{
+		// CRC-16/AUG-CCITT check value from CRC catalogue
+		{"check", []byte("123456789"), 0xE5CC},
+		{"empty", nil, 0x1D0F},
+		{"poll header", []byte{0x53, 0x00, 0x08, 0x00, 0x04, 0x60}, 0xAAEB},
+	}
+	for _, tt := range tests {
+		if got := CRC16(tt.data); got != tt.want {
+			t.Errorf("%s: CRC16 = 0x%04X, want 0x%04X", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want uint8
+	}{
+		{nil, 0x00},
+		{[]byte{0x01}, 0xFF},
+		{[]byte{0x53, 0x80, 0x07, 0x00, 0x00, 0x40}, 0xE6},
+		{[]byte{0x80, 0x80}, 0x00},
+	}
+	for _, tt := range tests {
+		if got := Checksum(tt.data); got != tt.want {
+			t.Errorf("Checksum(% X) = 0x%02X, want 0x%02X", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestEncodePacket(t *testing.T) {
+	got := EncodePacket(0x80, 4, OSDP_CMD_POLL, nil)
+	want := []byte{0x53, 0x00, 0x08, 0x00, 0x04, 0x60, 0xEB, 0xAA}
+	if !bytes.Equal(got, want) {
+		t.Errorf("EncodePacket = % X, want % X", got, want)
+	}
+}
+
+func TestFrameLen(t *testing.T) {
+	poll := []byte{0x53, 0x00, 0x08, 0x00, 0x04, 0x60, 0xEB, 0xAA}
+	tests := []struct {
+		name string
+		buf  []byte
+		want int
+	}{
+		{"empty", nil, 0},
+		{"no SOM", []byte{0x00, 0x53}, -1},
+		{"short header", poll[:4], 0},
+		{"partial", poll[:7], 0},
+		{"complete", poll, 8},
+		{"two frames", append(append([]byte{}, poll...), poll...), 8},
+		{"length too small", []byte{0x53, 0x00, 0x06, 0x00, 0x04}, -1},
+		{"length too large", []byte{0x53, 0x00, 0xA1, 0x05, 0x04}, -1},
+	}
+	for _, tt := range tests {
+		if got := FrameLen(tt.buf); got != tt.want {
+			t.Errorf("%s: FrameLen = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodePacket(t *testing.T) {
+	// osdp_ACK with checksum from PD 0
+	ack := []byte{0x53, 0x80, 0x07, 0x00, 0x00, 0x40, 0xE6}
+	packet, err := DecodePacket(ack)
+	if err != nil {
+		t.Fatalf("DecodePacket(ACK): %v", err)
+	}
+	if packet.Cmd != OSDP_REPLY_ACK || packet.Data["reply"] != true || packet.Data["address"] != uint8(0) {
+		t.Errorf("DecodePacket(ACK) = %+v", packet)
+	}
+
+	bad := append([]byte{}, ack...)
+	bad[6] ^= 0xFF
+	if _, err := DecodePacket(bad); err == nil {
+		t.Error("DecodePacket accepted wrong checksum")
+	}
+
+	// osdp_KEYPAD "12#" with CRC, sqn 1
+	keypad := []byte{0x53, 0x81, 0x0D, 0x00, 0x05, OSDP_REPLY_KEYPAD, 0x00, 0x03, '1', '2', OSDP_KEY_ENTER}
+	crc := CRC16(keypad)
+	keypad = append(keypad, uint8(crc&0xFF), uint8(crc>>8))
+	packet, err = DecodePacket(keypad)
+	if err != nil {
+		t.Fatalf("DecodePacket(KEYPAD): %v", err)
+	}
+	if packet.Data["keys"] != "12\r" || packet.Data["sqn"] != uint8(1) || packet.Data["address"] != uint8(1) {
+		t.Errorf("DecodePacket(KEYPAD) data = %v", packet.Data)
+	}
+
+	keypad[len(keypad)-1] ^= 0x01
+	if _, err := DecodePacket(keypad); err == nil {
+		t.Error("DecodePacket accepted wrong CRC")
+	}
+}
+
+func TestAddKeys(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		chunks []string
+		pause  time.Duration
+		pin    string
+		ok     bool
+	}{
+		{"single reply", []string{"1234\r"}, 0, "1234", true},
+		{"split replies", []string{"12", "34", "\r"}, 0, "1234", true},
+		{"no terminator", []string{"1234"}, 0, "", false},
+		{"clear key", []string{"99\x7f", "12\r"}, 0, "12", true},
+		{"empty enter", []string{"\r"}, 0, "", false},
+		{"other keys ignored", []string{"1A2*3\r"}, 0, "123", true},
+		{"pause drops digits", []string{"99", "12\r"}, OSDP_KEYPAD_TIMEOUT, "12", true},
+	}
+	for _, tt := range tests {
+		oc := NewOSDPConnection(0)
+		var pin string
+		var ok bool
+		at := now
+		for _, chunk := range tt.chunks {
+			pin, ok = oc.AddKeys(chunk, at)
+			at = at.Add(tt.pause)
+		}
+		if pin != tt.pin || ok != tt.ok {
+			t.Errorf("%s: AddKeys = %q, %v, want %q, %v", tt.name, pin, ok, tt.pin, tt.ok)
+		}
+	}
+}
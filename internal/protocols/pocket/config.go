@@ -0,0 +1,406 @@
+package pocket
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"nd-go/pkg/utils"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// POCKET configuration commands.
+// There is no EPROTO document or capture with SetConfig/GetConfig frames in
+// this tree: the opcodes follow the EPROTO command numbering used by the other
+// POCKET commands (0x02 ReadTag, 0x03 ReadTagExtended, 0x06 Enquire, replies
+// with bit 0x80 set, see protocol.go), tag values are POCKET_CFG_TAG_*.
+// UNVERIFIED: check against a terminal capture before pushing profiles or keys
+// to production terminals. With wrong opcode terminal doesn't confirm: key
+// rotation rolls back on confirm timeout, profile stays reported as drifted.
+const (
+	POCKET_CMD_GET_CONFIG  = 0x04
+	POCKET_CMD_SET_CONFIG  = 0x05
+	POCKET_RESP_GET_CONFIG = 0x84 // 0x04 | 0x80
+	POCKET_RESP_SET_CONFIG = 0x85 // 0x05 | 0x80
+
+	POCKET_SECTOR_KEY_LEN = 6 // MIFARE key length
+)
+
+// Configuration value kinds
+const (
+	cfgKindU8 = iota
+	cfgKindU16
+	cfgKindBool
+	cfgKindIP
+	cfgKindMAC
+	cfgKindLang
+	cfgKindSectorKeys
+	cfgKindLockers
+)
+
+// configField describes configuration tag in profile
+type configField struct {
+	Name     string
+	Tag      uint8
+	Kind     int
+	ReadOnly bool
+}
+
+// configFields lists profile names of configuration tags in push order
+var configFields = []configField{
+	{"mac", POCKET_CFG_TAG_MAC, cfgKindMAC, true},
+	{"ip", POCKET_CFG_TAG_IP, cfgKindIP, false},
+	{"mask", POCKET_CFG_TAG_MASK, cfgKindIP, false},
+	{"gateway", POCKET_CFG_TAG_GATEWAY, cfgKindIP, false},
+	{"udp_port", POCKET_CFG_TAG_UDP_PORT, cfgKindU16, false},
+	{"tcp_port", POCKET_CFG_TAG_TCP_PORT, cfgKindU16, false},
+	{"debug_port", POCKET_CFG_TAG_DEBUG_PORT, cfgKindU16, false},
+	{"net", POCKET_CFG_TAG_NET, cfgKindU8, false},
+	{"ping_control", POCKET_CFG_TAG_PING_CONTROL, cfgKindU8, false},
+	{"behavior", POCKET_CFG_TAG_BEHAVIOR, cfgKindU8, false},
+	{"lang", POCKET_CFG_TAG_LANG, cfgKindLang, false},
+	{"progress_mode", POCKET_CFG_TAG_PROGRESS_MODE, cfgKindU8, false},
+	{"wait_tmo", POCKET_CFG_TAG_WAIT_TMO, cfgKindU16, false},
+	{"block_cab", POCKET_CFG_TAG_BLOCK_CAB, cfgKindU16, false},
+	{"block_cell", POCKET_CFG_TAG_BLOCK_CELL, cfgKindU8, false},
+	{"no_finger", POCKET_CFG_TAG_NO_FINGER, cfgKindBool, false},
+	{"no_finger_hw_test", POCKET_CFG_TAG_NO_FINGER_HW_TEST, cfgKindBool, false},
+	{"buzzer_duty", POCKET_CFG_TAG_BUZZER_DUTY, cfgKindU8, false},
+	{"led_brightness", POCKET_CFG_TAG_LED_BRIGHTNESS, cfgKindU8, false},
+	{"sector_keys", POCKET_CFG_TAG_SECTOR_KEYS, cfgKindSectorKeys, false},
+	{"lockers_list", POCKET_CFG_TAG_LOCKERS_LIST, cfgKindLockers, false},
+}
+
+// configFieldByName returns configuration field by profile name
+func configFieldByName(name string) (configField, bool) {
+	for _, f := range configFields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return configField{}, false
+}
+
+// configFieldByTag returns configuration field by tag
+func configFieldByTag(tag uint8) (configField, bool) {
+	for _, f := range configFields {
+		if f.Tag == tag {
+			return f, true
+		}
+	}
+	return configField{}, false
+}
+
+// EncodeConfig encodes configuration profile into TLV sequence.
+// Profile keys are field names ("ip", "wait_tmo", "lang", "sector_keys"...),
+// values come from JSON (numbers, strings, bools, arrays).
+func EncodeConfig(profile map[string]interface{}) ([]byte, error) {
+	for name := range profile {
+		f, ok := configFieldByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown config field: %s", name)
+		}
+		if f.ReadOnly {
+			return nil, fmt.Errorf("config field is read-only: %s", name)
+		}
+	}
+
+	var result []byte
+	for _, f := range configFields {
+		value, ok := profile[f.Name]
+		if !ok {
+			continue
+		}
+		encoded, err := encodeConfigValue(f, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		if len(encoded) > 0xFF {
+			return nil, fmt.Errorf("%s: value too long (%d bytes)", f.Name, len(encoded))
+		}
+		result = append(result, f.Tag, uint8(len(encoded)))
+		result = append(result, encoded...)
+	}
+	return result, nil
+}
+
+// encodeConfigValue encodes single configuration value
+func encodeConfigValue(f configField, value interface{}) ([]byte, error) {
+	switch f.Kind {
+	case cfgKindU8:
+		n, err := configInt(value, 0xFF)
+		if err != nil {
+			return nil, err
+		}
+		return []byte{uint8(n)}, nil
+
+	case cfgKindU16:
+		n, err := configInt(value, 0xFFFF)
+		if err != nil {
+			return nil, err
+		}
+		return utils.EncodeUint16(uint16(n)), nil
+
+	case cfgKindBool:
+		b, ok := value.(bool)
+		if !ok {
+			n, err := configInt(value, 1)
+			if err != nil {
+				return nil, fmt.Errorf("boolean expected")
+			}
+			b = n != 0
+		}
+		if b {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+
+	case cfgKindIP:
+		s, _ := value.(string)
+		ip := net.ParseIP(strings.TrimSpace(s)).To4()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv4 address: %v", value)
+		}
+		return []byte(ip), nil
+
+	case cfgKindLang:
+		s, _ := value.(string)
+		if len(s) != 2 {
+			return nil, fmt.Errorf("two-letter language code expected: %v", value)
+		}
+		return []byte(strings.ToLower(s)), nil
+
+	case cfgKindSectorKeys:
+		// "sector:KEYHEX" items, e.g. "1:A0A1A2A3A4A5"
+		items, err := configStrings(value)
+		if err != nil {
+			return nil, err
+		}
+		var result []byte
+		for _, item := range items {
+			parts := strings.SplitN(item, ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("sector key must be \"sector:KEY\": %s", item)
+			}
+			sector, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+			if err != nil || sector < 0 || sector > 0xFF {
+				return nil, fmt.Errorf("invalid sector number: %s", parts[0])
+			}
+			key, err := hex.DecodeString(strings.TrimSpace(parts[1]))
+			if err != nil || len(key) != POCKET_SECTOR_KEY_LEN {
+				return nil, fmt.Errorf("sector key must be %d bytes hex", POCKET_SECTOR_KEY_LEN)
+			}
+			result = append(result, uint8(sector))
+			result = append(result, key...)
+		}
+		return result, nil
+
+	case cfgKindLockers:
+		list, ok := value.([]interface{})
+		if !ok {
+			if ints, ok := value.([]int); ok {
+				for _, n := range ints {
+					list = append(list, n)
+				}
+			} else {
+				return nil, fmt.Errorf("list of cabinet numbers expected")
+			}
+		}
+		var result []byte
+		for _, item := range list {
+			n, err := configInt(item, 0x7FFF)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, utils.EncodeUint16(uint16(n))...)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unsupported field kind")
+}
+
+// DecodeConfig decodes TLV sequence from GetConfig response into profile
+func DecodeConfig(data []byte) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for offset := 0; offset < len(data); {
+		tag, value, n := utils.DecodeTLV(data[offset:])
+		if n == 0 {
+			return result, fmt.Errorf("truncated config TLV at offset %d", offset)
+		}
+		offset += n
+
+		f, ok := configFieldByTag(tag)
+		if !ok {
+			result[fmt.Sprintf("tag_%02x", tag)] = hex.EncodeToString(value)
+			continue
+		}
+		decoded, err := decodeConfigValue(f, value)
+		if err != nil {
+			return result, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		result[f.Name] = decoded
+	}
+	return result, nil
+}
+
+// decodeConfigValue decodes single configuration value
+func decodeConfigValue(f configField, value []byte) (interface{}, error) {
+	switch f.Kind {
+	case cfgKindU8:
+		if len(value) != 1 {
+			return nil, fmt.Errorf("1 byte expected, got %d", len(value))
+		}
+		return int(value[0]), nil
+	case cfgKindU16:
+		if len(value) != 2 {
+			return nil, fmt.Errorf("2 bytes expected, got %d", len(value))
+		}
+		return int(binary.LittleEndian.Uint16(value)), nil
+	case cfgKindBool:
+		if len(value) != 1 {
+			return nil, fmt.Errorf("1 byte expected, got %d", len(value))
+		}
+		return value[0] != 0, nil
+	case cfgKindIP:
+		if len(value) != 4 {
+			return nil, fmt.Errorf("4 bytes expected, got %d", len(value))
+		}
+		return net.IP(value).String(), nil
+	case cfgKindMAC:
+		return strings.ToUpper(net.HardwareAddr(value).String()), nil
+	case cfgKindLang:
+		return string(value), nil
+	case cfgKindSectorKeys:
+		if len(value)%(1+POCKET_SECTOR_KEY_LEN) != 0 {
+			return nil, fmt.Errorf("invalid sector keys length: %d", len(value))
+		}
+		keys := make([]string, 0)
+		for i := 0; i < len(value); i += 1 + POCKET_SECTOR_KEY_LEN {
+			keys = append(keys, fmt.Sprintf("%d:%s", value[i], strings.ToUpper(hex.EncodeToString(value[i+1:i+1+POCKET_SECTOR_KEY_LEN]))))
+		}
+		return keys, nil
+	case cfgKindLockers:
+		if len(value)%2 != 0 {
+			return nil, fmt.Errorf("invalid lockers list length: %d", len(value))
+		}
+		lockers := make([]int, 0)
+		for i := 0; i < len(value); i += 2 {
+			lockers = append(lockers, int(binary.LittleEndian.Uint16(value[i:i+2])))
+		}
+		return lockers, nil
+	}
+	return nil, fmt.Errorf("unsupported field kind")
+}
+
+// NormalizeConfig validates profile and converts values to decoded form
+// (so that profile from JSON can be compared with terminal readback)
+func NormalizeConfig(profile map[string]interface{}) (map[string]interface{}, error) {
+	data, err := EncodeConfig(profile)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeConfig(data)
+}
+
+// DiffConfig returns fields of wanted profile that differ in actual config
+// as name -> {"want": ..., "got": ...}
+func DiffConfig(want, got map[string]interface{}) map[string]interface{} {
+	diff := make(map[string]interface{})
+	for name, w := range want {
+		g, ok := got[name]
+		if !ok || !reflect.DeepEqual(w, g) {
+			diff[name] = map[string]interface{}{"want": w, "got": g}
+		}
+	}
+	return diff
+}
+
+// MaskConfig returns copy of config with sector keys hidden (for logs and API)
+func MaskConfig(cfg map[string]interface{}) map[string]interface{} {
+	if cfg == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		if k == "sector_keys" {
+			if keys, ok := v.([]string); ok {
+				masked := make([]string, len(keys))
+				for i, key := range keys {
+					if pos := strings.Index(key, ":"); pos >= 0 {
+						masked[i] = key[:pos+1] + "***"
+					} else {
+						masked[i] = "***"
+					}
+				}
+				v = masked
+			} else {
+				v = "***"
+			}
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// CreateSetConfigPacket creates SetConfig packet from profile
+func CreateSetConfigPacket(profile map[string]interface{}) ([]byte, error) {
+	payload, err := EncodeConfig(profile)
+	if err != nil {
+		return nil, err
+	}
+	return CreatePacket(POCKET_CMD_SET_CONFIG, 0x00, payload), nil
+}
+
+// CreateGetConfigPacket creates GetConfig packet (no tags = all tags)
+func CreateGetConfigPacket(tags ...uint8) []byte {
+	return CreatePacket(POCKET_CMD_GET_CONFIG, 0x00, tags)
+}
+
+// configInt converts JSON/term value to integer within range
+func configInt(value interface{}, max int) (int, error) {
+	var n int
+	switch v := value.(type) {
+	case int:
+		n = v
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("integer expected: %v", v)
+		}
+		n = int(v)
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("integer expected: %s", v)
+		}
+		n = i
+	default:
+		return 0, fmt.Errorf("integer expected: %v", value)
+	}
+	if n < 0 || n > max {
+		return 0, fmt.Errorf("value out of range 0..%d: %d", max, n)
+	}
+	return n, nil
+}
+
+// configStrings converts JSON list (or single string) to string slice
+func configStrings(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("string expected: %v", item)
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("list of strings expected")
+}
@@ -420,6 +420,11 @@ func RelayOnEx(onTime uint32, flags uint8, caption string, uid string) []byte {
 	return val
 }
 
+// CreateRelayOnPacket creates RelayControlEx packet opening relay
+func CreateRelayOnPacket(onTime uint32, flags uint8, caption string, uid string) []byte {
+	return CreatePacket(POCKET_CMD_RELAY_CONTROL_EX, POCKET_PK_FLAGS_RT_MAIN, RelayOnEx(onTime, flags, caption, uid))
+}
+
 // ParseUID extracts UID from various formats
 func ParseUID(data interface{}) string {
 	if data == nil {
@@ -479,6 +484,8 @@ func GetReaderTypeString(readerType uint8) string {
 const (
 	POCKET_CMD_ENQUIRE = 0x06
 	POCKET_RESP_ENQUIRE = 0x86 // 0x06 | 0x80
+
+	POCKET_CMD_RELAY_CONTROL_EX = 0x15
 )
 
 // CreateEnquirePacket creates Enquire packet for ping
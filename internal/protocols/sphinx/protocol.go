@@ -23,10 +23,50 @@ const (
 	SPHINX_APRT_NORMAL = "NORMAL"
 	SPHINX_APRT_ESCORT = "ESCORT"
 
+	// Delegation reply results
+	SPHINX_DR_DENY  = 0
+	SPHINX_DR_GRANT = 1
+
 	SPHINX_PING_INTERVAL = 5
 	SPHINX_PING_TIMEOUT  = 10
+
+	// Controller events channel (SUBSCRIBE CE / UNSUBSCRIBE CE)
+	SPHINX_EVENT_CHANNEL = "CE"
+
+	// Controller event types
+	SPHINX_EVT_ACCESS_GRANTED = "ACCESS_GRANTED" // Controller granted access on its own
+	SPHINX_EVT_ACCESS_DENIED  = "ACCESS_DENIED"
+	SPHINX_EVT_DOOR_FORCED    = "DOOR_FORCED" // Door opened without access
+	SPHINX_EVT_DOOR_HELD      = "DOOR_HELD"   // Door left open too long
+	SPHINX_EVT_ALARM          = "ALARM"
+	SPHINX_EVT_UNKNOWN        = "UNKNOWN"
 )
 
+// Event codes sent by controllers mapped to event types
+var SphinxEventTypes = map[string]string{
+	"ACCESS_GRANTED":   SPHINX_EVT_ACCESS_GRANTED,
+	"GRANTED":          SPHINX_EVT_ACCESS_GRANTED,
+	"ACCESS_DENIED":    SPHINX_EVT_ACCESS_DENIED,
+	"DENIED":           SPHINX_EVT_ACCESS_DENIED,
+	"DOOR_FORCED":      SPHINX_EVT_DOOR_FORCED,
+	"DOOR_FORCED_OPEN": SPHINX_EVT_DOOR_FORCED,
+	"DOOR_HELD":        SPHINX_EVT_DOOR_HELD,
+	"DOOR_HELD_OPEN":   SPHINX_EVT_DOOR_HELD,
+	"ALARM":            SPHINX_EVT_ALARM,
+}
+
+// Event represents controller event (EVENT_CE line)
+type Event struct {
+	Type        string    `json:"type"` // SPHINX_EVT_*
+	Code        string    `json:"code"` // Event code as sent by controller
+	Time        time.Time `json:"time"`
+	AccessPoint string    `json:"access_point"`
+	KeyType     string    `json:"key_type,omitempty"`
+	UID         string    `json:"uid,omitempty"`
+	Direction   string    `json:"direction,omitempty"`
+	Raw         string    `json:"raw"`
+}
+
 // SphinxConnection represents SPHINX connection state
 type SphinxConnection struct {
 	WaitForAnswer int       `json:"wait_for_answer"`
@@ -94,6 +134,7 @@ func isValidCommand(cmd string) bool {
 		"OK", "ERROR", "LOGIN", "LOGOUT", "SUBSCRIBE", "UNSUBSCRIBE",
 		"DELEGATION_START", "DELEGATION_STOP", "DELEGATION_REQUEST",
 		"DELEGATION_REPLY", "GETAPLIST", "GETZONEINFO", "PING", "PONG",
+		"EVENT_CE",
 	}
 
 	for _, validCmd := range validCommands {
@@ -116,6 +157,80 @@ func CreateDelegationReply(ticket, accessType string, result int, flags ...strin
 	return EncodePacket("DELEGATION_REPLY", params...)
 }
 
+// CreateDelegationDeny creates DELEGATION_REPLY packet denying access with reason
+func CreateDelegationDeny(ticket, accessType, reason string) []byte {
+	if reason == "" {
+		return CreateDelegationReply(ticket, accessType, SPHINX_DR_DENY)
+	}
+	reason = strings.ReplaceAll(reason, "\n", " ")
+	reason = strings.ReplaceAll(reason, "\"", "'")
+	return CreateDelegationReply(ticket, accessType, SPHINX_DR_DENY, fmt.Sprintf("\"%s\"", reason))
+}
+
+// CreateSubscribePacket creates SUBSCRIBE packet for events channel
+func CreateSubscribePacket(channel string) []byte {
+	return EncodePacket("SUBSCRIBE", channel)
+}
+
+// CreateUnsubscribePacket creates UNSUBSCRIBE packet for events channel
+func CreateUnsubscribePacket(channel string) []byte {
+	return EncodePacket("UNSUBSCRIBE", channel)
+}
+
+// ParseEvent parses EVENT_CE parameters:
+// <date> <time> <code> <access point> [W26 <fc> <card> | W34 <hex> | ID <id>] [direction]
+func ParseEvent(params []string) (*Event, error) {
+	if len(params) < 4 {
+		return nil, fmt.Errorf("insufficient parameters for event")
+	}
+
+	code := strings.ToUpper(params[2])
+	event := &Event{
+		Type:        SPHINX_EVT_UNKNOWN,
+		Code:        code,
+		AccessPoint: params[3],
+		Raw:         strings.Join(params, " "),
+	}
+	if t, ok := SphinxEventTypes[code]; ok {
+		event.Type = t
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", params[0]+" "+params[1], time.Local); err == nil {
+		event.Time = t
+	} else {
+		event.Time = time.Now()
+	}
+
+	rest := params[4:]
+	if len(rest) > 0 {
+		switch strings.ToUpper(rest[0]) {
+		case "W26":
+			if len(rest) < 3 {
+				return nil, fmt.Errorf("invalid W26 key in event")
+			}
+			facility, err1 := strconv.Atoi(rest[1])
+			card, err2 := strconv.Atoi(rest[2])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid W26 key in event")
+			}
+			event.KeyType = "W26"
+			event.UID = fmt.Sprintf("%02X%04X", facility&0xFF, card&0xFFFF)
+			rest = rest[3:]
+		case "W34", "ID":
+			if len(rest) < 2 {
+				return nil, fmt.Errorf("invalid %s key in event", rest[0])
+			}
+			event.KeyType = strings.ToUpper(rest[0])
+			event.UID = strings.ToUpper(rest[1])
+			rest = rest[2:]
+		}
+	}
+	if len(rest) > 0 {
+		event.Direction = strings.ToUpper(rest[0])
+	}
+
+	return event, nil
+}
+
 // CreateDelegationStartPacket creates DELEGATION_START packet for ping
 func CreateDelegationStartPacket() []byte {
 	return EncodePacket("DELEGATION_START")
@@ -140,7 +255,7 @@ func ParseDelegationRequest(params []string) (map[string]interface{}, error) {
 			facility, err1 := strconv.Atoi(params[3])
 			card, err2 := strconv.Atoi(params[4])
 			if err1 == nil && err2 == nil {
-				uid := utils.GenID() // Generate UID from facility and card
+				uid := fmt.Sprintf("%02X%04X", facility&0xFF, card&0xFFFF) // UID from facility and card
 				result["key_type"] = "W26"
 				result["facility_code"] = facility
 				result["card_number"] = card
@@ -0,0 +1,295 @@
+package wiegand
+
+import (
+	"fmt"
+	"nd-go/pkg/types"
+	"strconv"
+	"strings"
+)
+
+// Wiegand converter protocol constants
+const (
+	WIEGAND_DELIMITER = "\n"
+
+	// Frame formats
+	WIEGAND_FMT_AUTO  = "auto"
+	WIEGAND_FMT_W26   = "26"
+	WIEGAND_FMT_W34   = "34"
+	WIEGAND_FMT_ASCII = "ascii"
+
+	// Input encoding of frame value in converter line
+	WIEGAND_IN_BIN = "bin" // bit string with parity bits ("0101...", 26/34 chars)
+	WIEGAND_IN_HEX = "hex" // hex with parity (7/9 digits) or data bits only (6/8 digits)
+	WIEGAND_IN_DEC = "dec" // decimal value of data bits, needs W26/W34 format
+
+	// Facility code handling
+	WIEGAND_FC_KEEP  = "keep"  // UID = facility code + card number
+	WIEGAND_FC_STRIP = "strip" // UID = card number only
+
+	// UID output formats
+	WIEGAND_UID_HEX = "hex"
+	WIEGAND_UID_DEC = "dec"
+)
+
+// FrameConfig describes how converter lines are turned into UIDs
+type FrameConfig struct {
+	Format   string // WIEGAND_FMT_*
+	Input    string // WIEGAND_IN_*
+	Facility string // WIEGAND_FC_KEEP, WIEGAND_FC_STRIP or required facility code
+	UIDFmt   string // WIEGAND_UID_*
+}
+
+// DefaultFrameConfig returns default frame configuration
+func DefaultFrameConfig() FrameConfig {
+	return FrameConfig{
+		Format:   WIEGAND_FMT_AUTO,
+		Input:    WIEGAND_IN_BIN,
+		Facility: WIEGAND_FC_KEEP,
+		UIDFmt:   WIEGAND_UID_HEX,
+	}
+}
+
+// DecodeBits decodes Wiegand frame bits into facility code and card number.
+// value may include parity bits (n = 26/34) or data bits only (n = 24/32).
+func DecodeBits(value uint64, n int) (uint32, uint32, error) {
+	switch n {
+	case 26, 34:
+		dataBits := n - 2
+		half := dataBits / 2
+		data := (value >> 1) & (1<<uint(dataBits) - 1)
+		leading := (value >> uint(n-1)) & 1
+		trailing := value & 1
+
+		// Leading parity: even over first half of data bits
+		if (countBits(data>>uint(half))+leading)%2 != 0 {
+			return 0, 0, fmt.Errorf("W%d leading parity error", n)
+		}
+		// Trailing parity: odd over second half of data bits
+		if (countBits(data&(1<<uint(half)-1))+trailing)%2 != 1 {
+			return 0, 0, fmt.Errorf("W%d trailing parity error", n)
+		}
+		return DecodeBits(data, dataBits)
+	case 24:
+		return uint32(value>>16) & 0xFF, uint32(value) & 0xFFFF, nil
+	case 32:
+		return uint32(value>>16) & 0xFFFF, uint32(value) & 0xFFFF, nil
+	default:
+		return 0, 0, fmt.Errorf("unsupported Wiegand frame length: %d", n)
+	}
+}
+
+// countBits counts set bits
+func countBits(v uint64) uint64 {
+	var c uint64
+	for ; v != 0; v >>= 1 {
+		c += v & 1
+	}
+	return c
+}
+
+// ParseLine parses single converter line into packet with UID. Line holds
+// frame value in cfg.Input encoding with optional "W26"/"W34" prefix, or any
+// text for ASCII format. In auto format lines which are not frames are taken
+// as ASCII card numbers.
+func ParseLine(line string, cfg FrameConfig) (*types.Packet, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, fmt.Errorf("empty line")
+	}
+
+	data := map[string]interface{}{
+		"raw": line,
+	}
+
+	format := strings.ToLower(cfg.Format)
+	if format == "" {
+		format = WIEGAND_FMT_AUTO
+	}
+
+	if format == WIEGAND_FMT_ASCII {
+		data["format"] = WIEGAND_FMT_ASCII
+		data["uid"] = strings.ToUpper(line)
+		return &types.Packet{Payload: line, Data: data}, nil
+	}
+
+	value, bits, err := parseFrameValue(line, format, strings.ToLower(cfg.Input))
+	if err != nil {
+		if format == WIEGAND_FMT_AUTO {
+			// Not a Wiegand frame: plain ASCII card number
+			data["format"] = WIEGAND_FMT_ASCII
+			data["uid"] = strings.ToUpper(line)
+			return &types.Packet{Payload: line, Data: data}, nil
+		}
+		return nil, err
+	}
+
+	fc, card, err := DecodeBits(value, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	frameBits := bits
+	if bits == 24 || bits == 32 {
+		frameBits = bits + 2
+	}
+	data["format"] = strconv.Itoa(frameBits)
+	data["facility_code"] = int(fc)
+	data["card_number"] = int(card)
+
+	// Facility code handling
+	var uidValue uint64
+	uidDigits := 0
+	switch cfg.Facility {
+	case "", WIEGAND_FC_KEEP:
+		uidValue = uint64(fc)<<16 | uint64(card)
+		uidDigits = (frameBits - 2) / 4 // 6 hex digits for W26, 8 for W34
+	case WIEGAND_FC_STRIP:
+		uidValue = uint64(card)
+		uidDigits = 4
+	default:
+		required, err := strconv.Atoi(cfg.Facility)
+		if err != nil {
+			return nil, fmt.Errorf("invalid facility code setting: %s", cfg.Facility)
+		}
+		if int(fc) != required {
+			return nil, fmt.Errorf("facility code mismatch: got %d, expected %d", fc, required)
+		}
+		uidValue = uint64(card)
+		uidDigits = 4
+	}
+
+	if strings.ToLower(cfg.UIDFmt) == WIEGAND_UID_DEC {
+		data["uid"] = strconv.FormatUint(uidValue, 10)
+	} else {
+		data["uid"] = fmt.Sprintf("%0*X", uidDigits, uidValue)
+	}
+
+	return &types.Packet{Payload: line, Data: data}, nil
+}
+
+// parseFrameValue extracts frame value and bit count (with parity bits, or
+// data bits only) from line in given input encoding
+func parseFrameValue(line string, format string, input string) (uint64, int, error) {
+	upper := strings.ToUpper(line)
+
+	// Explicit frame prefix overrides auto format
+	if strings.HasPrefix(upper, "W26") || strings.HasPrefix(upper, "W34") {
+		if format == WIEGAND_FMT_AUTO {
+			format = upper[1:3]
+		}
+		upper = strings.TrimLeft(upper[3:], " :=,;")
+	}
+	if format != WIEGAND_FMT_AUTO && format != WIEGAND_FMT_W26 && format != WIEGAND_FMT_W34 {
+		return 0, 0, fmt.Errorf("unknown frame format: %s", format)
+	}
+
+	// frameBits returns W26/W34 frame length of value given with n bits or digits
+	// (n26/n34), 0 if n doesn't fit format
+	frameBits := func(n, n26, n34 int) int {
+		switch {
+		case n == n26 && format != WIEGAND_FMT_W34:
+			return 26
+		case n == n34 && format != WIEGAND_FMT_W26:
+			return 34
+		}
+		return 0
+	}
+
+	switch input {
+	case "", WIEGAND_IN_BIN:
+		if !isBinary(upper) {
+			return 0, 0, fmt.Errorf("not a bit string: %s", line)
+		}
+		bits := frameBits(len(upper), 26, 34)
+		if bits == 0 {
+			return 0, 0, fmt.Errorf("bit string length %d doesn't match format W%s", len(upper), format)
+		}
+		value, err := strconv.ParseUint(upper, 2, 64)
+		return value, bits, err
+	case WIEGAND_IN_HEX:
+		value, err := strconv.ParseUint(upper, 16, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("not a hex value: %s", line)
+		}
+		if bits := frameBits(len(upper), 7, 9); bits > 0 {
+			return value, bits, nil
+		}
+		if bits := frameBits(len(upper), 6, 8); bits > 0 {
+			return value, bits - 2, nil
+		}
+		return 0, 0, fmt.Errorf("hex value length %d doesn't match format W%s", len(upper), format)
+	case WIEGAND_IN_DEC:
+		if format == WIEGAND_FMT_AUTO {
+			return 0, 0, fmt.Errorf("decimal input needs W26/W34 format: %s", line)
+		}
+		bits, _ := strconv.Atoi(format)
+		value, err := strconv.ParseUint(upper, 10, 64)
+		if err != nil || value >= 1<<uint(bits-2) {
+			return 0, 0, fmt.Errorf("invalid W%s decimal value: %s", format, line)
+		}
+		return value, bits - 2, nil
+	}
+	return 0, 0, fmt.Errorf("unknown input encoding: %s", input)
+}
+
+// isBinary checks that string consists of 0/1 only
+func isBinary(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c != '0' && c != '1' {
+			return false
+		}
+	}
+	return true
+}
+
+// EncodeCommand expands command template for converter.
+// Placeholders: {uid}, {time} (ms), {text}; escapes \r, \n, \t and \xHH are
+// unescaped (term string is split on ':', so colon is written as \x3A).
+// CRLF is appended when template doesn't end with CR or LF.
+func EncodeCommand(template string, params map[string]string) []byte {
+	cmd := unescape(template)
+	for k, v := range params {
+		cmd = strings.ReplaceAll(cmd, "{"+k+"}", v)
+	}
+	if !strings.HasSuffix(cmd, "\n") && !strings.HasSuffix(cmd, "\r") {
+		cmd += "\r\n"
+	}
+	return []byte(cmd)
+}
+
+// unescape replaces \r, \n, \t and \xHH escapes, other backslashes are kept
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'x':
+			if i+4 <= len(s) {
+				if v, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+					b.WriteByte(byte(v))
+					i += 3
+					continue
+				}
+			}
+			b.WriteByte(s[i])
+			continue
+		default:
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package wiegand
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeBits(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   uint64
+		bits    int
+		fc      uint32
+		card    uint32
+		wantErr string
+	}{
+		{"W26", 0b10111101110110010011011101, 26, 123, 45678, ""},
+		{"W26 zero", 0b00000000000000000000000001, 26, 0, 0, ""},
+		{"W26 leading parity", 0b00111101110110010011011101, 26, 0, 0, "leading parity"},
+		{"W26 trailing parity", 0b10111101110110010011011100, 26, 0, 0, "trailing parity"},
+		{"W26 data bit flipped", 0b10111101110110010011011111, 26, 0, 0, "trailing parity"},
+		{"W34", 0x22469579B, 34, 0x1234, 0xABCD, ""},
+		{"W34 leading parity", 0x22469579B ^ 1<<33, 34, 0, 0, "leading parity"},
+		{"W34 trailing parity", 0x22469579B ^ 1, 34, 0, 0, "trailing parity"},
+		{"24 data bits", 0x7BB26E, 24, 123, 45678, ""},
+		{"32 data bits", 0x1234ABCD, 32, 0x1234, 0xABCD, ""},
+		{"unsupported length", 0, 37, 0, 0, "unsupported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc, card, err := DecodeBits(tt.value, tt.bits)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fc != tt.fc || card != tt.card {
+				t.Errorf("got fc=%d card=%d, want fc=%d card=%d", fc, card, tt.fc, tt.card)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		cfg     FrameConfig
+		uid     string
+		format  string
+		wantErr string
+	}{
+		{"bin auto", "10111101110110010011011101", FrameConfig{}, "7BB26E", "26", ""},
+		{"bin W34", "1000100100011010010101011110011011", FrameConfig{}, "1234ABCD", "34", ""},
+		{"bin with prefix", "W26:10111101110110010011011101\r", FrameConfig{}, "7BB26E", "26", ""},
+		{"bin parity error", "10111101110110010011011100", FrameConfig{Format: WIEGAND_FMT_W26}, "", "", "parity"},
+		{"bin length mismatch", "1000100100011010010101011110011011", FrameConfig{Format: WIEGAND_FMT_W26}, "", "", "doesn't match"},
+		{"bin auto not a frame", "ABC123", FrameConfig{}, "ABC123", WIEGAND_FMT_ASCII, ""},
+		{"bin explicit not a frame", "00123456", FrameConfig{Format: WIEGAND_FMT_W26}, "", "", "not a bit string"},
+		{"ascii", "card 42", FrameConfig{Format: WIEGAND_FMT_ASCII}, "CARD 42", WIEGAND_FMT_ASCII, ""},
+
+		{"hex with parity", "2F764DD", FrameConfig{Input: WIEGAND_IN_HEX}, "7BB26E", "26", ""},
+		{"hex data bits", "7bb26e", FrameConfig{Input: WIEGAND_IN_HEX}, "7BB26E", "26", ""},
+		{"hex W34 data bits", "00123456", FrameConfig{Input: WIEGAND_IN_HEX}, "00123456", "34", ""},
+		{"hex W34 with parity", "22469579B", FrameConfig{Input: WIEGAND_IN_HEX, Format: WIEGAND_FMT_W34}, "1234ABCD", "34", ""},
+		{"hex length mismatch", "00123456", FrameConfig{Input: WIEGAND_IN_HEX, Format: WIEGAND_FMT_W26}, "", "", "doesn't match"},
+		{"hex parity error", "2F764DC", FrameConfig{Input: WIEGAND_IN_HEX, Format: WIEGAND_FMT_W26}, "", "", "parity"},
+
+		// Zero-padded decimal must not be read as hex
+		{"dec W26", "00123456", FrameConfig{Input: WIEGAND_IN_DEC, Format: WIEGAND_FMT_W26}, "01E240", "26", ""},
+		{"dec prefix", "W34 305441741", FrameConfig{Input: WIEGAND_IN_DEC}, "1234ABCD", "34", ""},
+		{"dec out of range", "16777216", FrameConfig{Input: WIEGAND_IN_DEC, Format: WIEGAND_FMT_W26}, "", "", "invalid W26 decimal"},
+		{"unknown format", "123456", FrameConfig{Input: WIEGAND_IN_DEC, Format: "37"}, "", "", "unknown frame format"},
+		{"dec auto falls back to ascii", "123456", FrameConfig{Input: WIEGAND_IN_DEC}, "123456", WIEGAND_FMT_ASCII, ""},
+		{"unknown input", "123456", FrameConfig{Input: "oct", Format: WIEGAND_FMT_W26}, "", "", "unknown input"},
+
+		{"fc strip", "10111101110110010011011101", FrameConfig{Facility: WIEGAND_FC_STRIP}, "B26E", "26", ""},
+		{"fc required", "10111101110110010011011101", FrameConfig{Facility: "123"}, "B26E", "26", ""},
+		{"fc mismatch", "10111101110110010011011101", FrameConfig{Format: WIEGAND_FMT_W26, Facility: "124"}, "", "", "facility code mismatch"},
+		{"uid dec", "10111101110110010011011101", FrameConfig{UIDFmt: WIEGAND_UID_DEC}, "8106606", "26", ""},
+		{"empty", " \r", FrameConfig{}, "", "", "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packet, err := ParseLine(tt.line, tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if uid := packet.Data["uid"]; uid != tt.uid {
+				t.Errorf("uid = %v, want %s", uid, tt.uid)
+			}
+			if format := packet.Data["format"]; format != tt.format {
+				t.Errorf("format = %v, want %s", format, tt.format)
+			}
+		})
+	}
+}
+
+func TestEncodeCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		params   map[string]string
+		want     string
+	}{
+		{"crlf appended", "OPEN {time}", map[string]string{"time": "3000"}, "OPEN 3000\r\n"},
+		{"own terminator", `OPEN\r`, nil, "OPEN\r"},
+		{"hex escape for colon", `RELAY\x3A1\x3A{time}`, map[string]string{"time": "500"}, "RELAY:1:500\r\n"},
+		{"binary byte", `\x02OPEN\x03\n`, nil, "\x02OPEN\x03\n"},
+		{"invalid escape kept", `A\xZZ\q`, nil, `A\xZZ\q` + "\r\n"},
+		{"params not unescaped", "MSG {text}", map[string]string{"text": `a\nb`}, `MSG a\nb` + "\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(EncodeCommand(tt.template, tt.params)); got != tt.want {
+				t.Errorf("EncodeCommand(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package session
+
+import (
+	"fmt"
+	"nd-go/internal/protocols/sphinx"
+	"nd-go/pkg/types"
+	"strings"
+	"time"
+)
+
+// Escort access roles
+const (
+	ESCORT_ROLE_VISITOR = "visitor"
+	ESCORT_ROLE_ESCORT  = "escort"
+
+	ESCORT_WAIT_MSG    = "Ожидание сопровождающего"
+	ESCORT_TIMEOUT_MSG = "Нет сопровождающего"
+)
+
+// escortHalf is a card presented at escort access point waiting for its pair
+type escortHalf struct {
+	session *types.Session
+	role    string
+	time    time.Time
+}
+
+// sphinxPool is connection pool reporting access type of pending SPHINX delegation
+type sphinxPool interface {
+	SphinxAccessType(key string) string
+}
+
+// markEscort marks session started at SPHINX access point requiring escort
+func (sm *SessionManager) markEscort(session *types.Session) {
+	pool, ok := sm.pool.(sphinxPool)
+	if !ok {
+		return
+	}
+	if pool.SphinxAccessType(session.Key) == sphinx.SPHINX_APRT_ESCORT {
+		session.Data["access_type"] = sphinx.SPHINX_APRT_ESCORT
+	}
+}
+
+// isEscortSession checks if session needs escort pairing
+func isEscortSession(session *types.Session) bool {
+	accessType, _ := session.Data["access_type"].(string)
+	return accessType == sphinx.SPHINX_APRT_ESCORT
+}
+
+// processEscort pairs visitor and escort cards granted by 1C at the same terminal.
+// Escort card passes alone and lets visitor follow within escort timeout.
+// Visitor card without escort is denied by controller and waits for escort card:
+// door is opened by escort card and visitor session completes as passed once
+// escort session is granted. Cards waiting for pair are queued per terminal,
+// so several visitors are paired with escorts in order of arrival.
+func (sm *SessionManager) processEscort(session *types.Session) error {
+	role := sm.escortRole(session.UID)
+	session.Data["escort_role"] = role
+	timeout := sm.escortTimeout(session.Key)
+	now := time.Now()
+
+	sm.mutex.Lock()
+	var partner *escortHalf
+	halves := liveEscortHalves(sm.escorts[session.Key], now, timeout)
+	for i, half := range halves {
+		if half.role != role {
+			partner = half
+			halves = append(halves[:i], halves[i+1:]...)
+			break
+		}
+	}
+	if partner == nil {
+		halves = append(halves, &escortHalf{session: session, role: role, time: now})
+	}
+	if len(halves) > 0 {
+		sm.escorts[session.Key] = halves
+	} else {
+		delete(sm.escorts, session.Key)
+	}
+	sm.mutex.Unlock()
+
+	if partner == nil {
+		if role == ESCORT_ROLE_ESCORT {
+			sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
+			return nil
+		}
+		fmt.Printf("Session %s: visitor %s waits for escort on %s\n", session.ID, session.UID, session.Key)
+		session.Data["result"] = 0
+		session.Data["deny_reason"] = types.DENY_REASON_ESCORT
+		sm.showDenyMessage(session, ESCORT_WAIT_MSG)
+		sm.setStage(session, types.SESSION_STAGE_ESCORT_WAIT)
+		return sm.Wait(session, 0x04, types.SESSION_STAGE_PASSED, timeout.Seconds(), nil) // SESSION_PROC_ESCORT
+	}
+
+	if role == ESCORT_ROLE_VISITOR {
+		// Escort card was presented first: open door by visitor card
+		session.Data["escort_uid"] = partner.session.UID
+		fmt.Printf("Escort pair on %s: visitor %s, escort %s\n", session.Key, session.UID, partner.session.UID)
+	} else {
+		// Visitor is waiting: door is opened by escort card, visitor passes
+		// when escort session is granted (see escortPassed)
+		visitor := partner.session
+		visitor.Data["escort_session"] = session.ID
+		session.Data["escort_visitor_uid"] = visitor.UID
+		fmt.Printf("Escort pair on %s: visitor %s, escort %s\n", session.Key, visitor.UID, session.UID)
+	}
+	sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
+	return nil
+}
+
+// liveEscortHalves drops cards which can't be paired anymore: pair timeout
+// expired or visitor session completed
+func liveEscortHalves(halves []*escortHalf, now time.Time, timeout time.Duration) []*escortHalf {
+	live := halves[:0]
+	for _, half := range halves {
+		if now.Sub(half.time) > timeout || (half.role == ESCORT_ROLE_VISITOR && half.session.Completed) {
+			continue
+		}
+		live = append(live, half)
+	}
+	return live
+}
+
+// escortPassed checks escort session paired with waiting visitor: done is true
+// once escort session has decision, granted - if door was opened for escort.
+// Granted visitor gets result and escort UID for escort report to 1C.
+func (sm *SessionManager) escortPassed(visitor *types.Session) (done bool, granted bool) {
+	escortID, _ := visitor.Data["escort_session"].(string)
+	if escortID == "" {
+		return false, false
+	}
+	sm.mutex.Lock()
+	escort := sm.sessions[escortID]
+	sm.mutex.Unlock()
+	if escort == nil {
+		return true, false // escort session removed without decision
+	}
+
+	switch escort.Stage {
+	case types.SESSION_STAGE_PASSED, types.SESSION_STAGE_DONE:
+	default:
+		if !escort.Completed {
+			return false, false
+		}
+	}
+	if result, _ := escort.Data["result"].(int); result <= 0 {
+		return true, false
+	}
+
+	visitor.Data["escort_uid"] = escort.UID
+	visitor.Data["result"] = 1
+	visitor.Data["message"] = escort.Data["message"]
+	return true, true
+}
+
+// escortRole returns role of card at escort access point
+func (sm *SessionManager) escortRole(uid string) string {
+	uid = strings.ToUpper(strings.TrimSpace(uid))
+	for _, card := range sm.config.SphinxEscortCards {
+		if strings.ToUpper(strings.TrimSpace(card)) == uid {
+			return ESCORT_ROLE_ESCORT
+		}
+	}
+	return ESCORT_ROLE_VISITOR
+}
+
+// escortTimeout returns time to present pair card: term string "escort_timeout=<s>"
+// or sphinx.escort_timeout
+func (sm *SessionManager) escortTimeout(key string) time.Duration {
+	timeout := sm.config.SphinxEscortTimeout
+	if pool, ok := sm.pool.(ConnectionPoolInterface); ok {
+		if conn := pool.GetConnection(key); conn != nil && conn.Settings != nil {
+			if v, ok := conn.Settings.Extra["escort_timeout"].(int); ok && v > 0 {
+				timeout = float64(v)
+			}
+		}
+	}
+	if timeout <= 0 {
+		timeout = 10
+	}
+	return time.Duration(timeout * float64(time.Second))
+}
+
+// cleanupEscorts drops escort halves of removed sessions (sm.mutex must be held)
+func (sm *SessionManager) cleanupEscorts() {
+	for key, halves := range sm.escorts {
+		live := halves[:0]
+		for _, half := range halves {
+			if _, exists := sm.sessions[half.session.ID]; exists {
+				live = append(live, half)
+			}
+		}
+		if len(live) > 0 {
+			sm.escorts[key] = live
+		} else {
+			delete(sm.escorts, key)
+		}
+	}
+}
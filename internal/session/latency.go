@@ -0,0 +1,128 @@
+package session
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"nd-go/pkg/types"
+)
+
+// LATENCY_WINDOW is number of last completed sessions of terminal used for percentiles
+const LATENCY_WINDOW = 500
+
+// latencyRing keeps last LATENCY_WINDOW values, ms
+type latencyRing struct {
+	values []float64
+	next   int
+}
+
+func (r *latencyRing) add(v float64) {
+	if len(r.values) < LATENCY_WINDOW {
+		r.values = append(r.values, v)
+		return
+	}
+	r.values[r.next] = v
+	r.next = (r.next + 1) % LATENCY_WINDOW
+}
+
+// percentiles returns p50 and p95 (nearest rank)
+func (r *latencyRing) percentiles() map[string]interface{} {
+	sorted := append([]float64(nil), r.values...)
+	sort.Float64s(sorted)
+	return map[string]interface{}{
+		"p50": percentile(sorted, 50),
+		"p95": percentile(sorted, 95),
+	}
+}
+
+// percentile returns nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// terminalLatency holds latencies of completed sessions of terminal
+type terminalLatency struct {
+	count    int
+	decision latencyRing             // tag read to allow/deny sent to terminal
+	total    latencyRing             // tag read to session completion
+	stages   map[string]*latencyRing // stage name -> time in stage
+}
+
+// latencyStats collects per-terminal session latencies
+type latencyStats struct {
+	terminals map[string]*terminalLatency
+	mutex     sync.Mutex
+}
+
+// add records timeline of completed session
+func (ls *latencyStats) add(session *types.Session) {
+	if len(session.Stages) == 0 {
+		return
+	}
+
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+	if ls.terminals == nil {
+		ls.terminals = make(map[string]*terminalLatency)
+	}
+	tl, ok := ls.terminals[session.Key]
+	if !ok {
+		tl = &terminalLatency{stages: make(map[string]*latencyRing)}
+		ls.terminals[session.Key] = tl
+	}
+
+	tl.count++
+	last := session.Stages[len(session.Stages)-1]
+	tl.total.add(msSince(session, last))
+	if !session.Decision.IsZero() {
+		tl.decision.add(float64(session.Decision.Sub(session.ReqTime).Microseconds()) / 1000)
+	}
+	for _, st := range session.Stages {
+		ring, ok := tl.stages[st.Stage]
+		if !ok {
+			ring = &latencyRing{}
+			tl.stages[st.Stage] = ring
+		}
+		ring.add(st.Duration)
+	}
+}
+
+// msSince returns time from session start to end of stage, ms
+func msSince(session *types.Session, st types.SessionStageTime) float64 {
+	return float64(st.Start.Sub(session.ReqTime).Microseconds())/1000 + st.Duration
+}
+
+// stats returns p50/p95 of terminals: sessions count, decision and total time, stages
+func (ls *latencyStats) stats() map[string]interface{} {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	result := make(map[string]interface{}, len(ls.terminals))
+	for key, tl := range ls.terminals {
+		stages := make(map[string]interface{}, len(tl.stages))
+		for name, ring := range tl.stages {
+			stages[name] = ring.percentiles()
+		}
+		result[key] = map[string]interface{}{
+			"sessions":    tl.count,
+			"decision_ms": tl.decision.percentiles(),
+			"total_ms":    tl.total.percentiles(),
+			"stages_ms":   stages,
+		}
+	}
+	return result
+}
+
+// GetLatencyStats returns p50/p95 of session latencies by terminal key over
+// last LATENCY_WINDOW completed sessions
+func (sm *SessionManager) GetLatencyStats() map[string]interface{} {
+	return sm.latency.stats()
+}
@@ -2,6 +2,9 @@ package session
 
 import (
 	"fmt"
+	"nd-go/internal/face"
+	"nd-go/internal/metrics"
+	"nd-go/internal/tracing"
 	"nd-go/pkg/types"
 	"nd-go/pkg/utils"
 	"sync"
@@ -15,27 +18,26 @@ type CSVLoggerInterface interface {
 
 // SessionManager manages user sessions
 type SessionManager struct {
-	sessions     map[string]*types.Session
-	idGen        int
-	mutex        sync.RWMutex
-	config       *types.Config
-	httpClient   HTTPClientInterface
-	heliosClient HeliosClientInterface
-	pool         interface{}        // ConnectionPool interface
-	csvLogger    CSVLoggerInterface // CSV logger
-}
-
-// HeliosClientInterface defines Helios client methods
-type HeliosClientInterface interface {
-	StartVerification(sessionID string, camPID string, personID string) (string, error)
-	CloseRequest(requestID string)
+	sessions   map[string]*types.Session
+	idGen      int
+	mutex      sync.RWMutex
+	config     *types.Config
+	httpClient HTTPClientInterface
+	faces      *face.Registry           // face recognition providers
+	pool       interface{}              // ConnectionPool interface
+	csvLogger  CSVLoggerInterface       // CSV logger
+	escorts    map[string][]*escortHalf // connection key -> cards waiting for escort pair
+	latency    latencyStats             // per-terminal stage latencies
+	traces     map[string]*sessionTrace // session ID -> spans of session trace
+	traceMutex sync.Mutex
 }
 
 // ConnectionPoolInterface defines connection pool methods
 type ConnectionPoolInterface interface {
 	SendJSPRelayOpen(key string, uid string, caption string, timeMs int, cid string) error
-	SendJSPRelayClose(key string) error
 	SendJSPMessage(key string, text string, timeMs int) error
+	DenyAccess(key string, sessionID string, text string) error
+	OpenRelay(key string, sessionID string, uid string, caption string, timeMs int, cid string) error
 	Send(key string, data []byte) error
 	GetConnection(key string) *types.Connection
 	LockTerminal(key string, sessionID string, text string) error
@@ -46,7 +48,10 @@ type ConnectionPoolInterface interface {
 type HTTPClientInterface interface {
 	CheckAccess(uid string, terminalID string, tagType string, lockers []types.LockerInfo) (*types.KPOResult, string, error)
 	CheckSolarAccess(uid string, terminalID string, solarTime int, regQuery int) (*types.KPOResult, string, error)
+	CheckPIN(uid string, terminalID string, pin string) (*types.KPOResult, string, error)
 	SendAccessReport(uid string, terminalID string, result bool, message string) error
+	SendEscortReport(uid string, escortUID string, terminalID string, result bool) error
+	SendMFAReport(uid string, terminalID string, result bool, mfa string) error
 	GetUserCID(uid string) (string, error)
 }
 
@@ -54,6 +59,8 @@ type HTTPClientInterface interface {
 func NewSessionManager(config *types.Config) *SessionManager {
 	return &SessionManager{
 		sessions: make(map[string]*types.Session),
+		escorts:  make(map[string][]*escortHalf),
+		traces:   make(map[string]*sessionTrace),
 		idGen:    0,
 		config:   config,
 	}
@@ -99,6 +106,38 @@ func (sm *SessionManager) ProcessSessionStage(sessionID string) error {
 		// Wait completed, continue processing
 	}
 
+	return sm.processStage(session)
+}
+
+// setStage moves session to stage and records finished stage in timeline.
+// All stage changes go through it to keep timeline and trace complete.
+func (sm *SessionManager) setStage(session *types.Session, stage types.SessionStage) {
+	if session.Stage == stage {
+		return
+	}
+	prev := session.Stage
+	session.Stage = stage
+	sm.stageChanged(session, prev)
+}
+
+// stageChanged adds finished stage to session timeline
+func (sm *SessionManager) stageChanged(session *types.Session, stage types.SessionStage) {
+	now := time.Now()
+	if !session.StageTime.IsZero() {
+		elapsed := now.Sub(session.StageTime)
+		session.Stages = append(session.Stages, types.SessionStageTime{
+			Stage:    stage.String(),
+			Start:    session.StageTime,
+			Duration: float64(elapsed.Microseconds()) / 1000,
+		})
+		metrics.SessionStageDuration.Observe(elapsed.Seconds(), stage.String())
+	}
+	session.StageTime = now
+	sm.traceStage(session)
+}
+
+// processStage runs handler of current session stage
+func (sm *SessionManager) processStage(session *types.Session) error {
 	switch session.Stage {
 	case types.SESSION_STAGE_KPO_RESULT:
 		return sm.processKpoResult(session)
@@ -120,6 +159,8 @@ func (sm *SessionManager) ProcessSessionStage(sessionID string) error {
 		return sm.processLastAnswer(session)
 	case types.SESSION_STAGE_DONE:
 		return sm.processDone(session)
+	case types.SESSION_STAGE_MFA:
+		return sm.processMFA(session)
 	}
 
 	return nil
@@ -148,8 +189,12 @@ func (sm *SessionManager) processKpoResult(session *types.Session) error {
 	if result != types.KPO_RES_YES {
 		// Access denied
 		session.Data["result"] = 0
+		session.Data["deny_reason"] = types.DENY_REASON_1C
+		if result != types.KPO_RES_NO || kpoData["link_error"] == true {
+			session.Data["deny_reason"] = types.DENY_REASON_1C_ERROR
+		}
 		session.Data["message"] = message
-		session.Stage = types.SESSION_STAGE_LAST_ANSWER
+		sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 		return nil
 	}
 
@@ -157,11 +202,28 @@ func (sm *SessionManager) processKpoResult(session *types.Session) error {
 	session.Data["result"] = 1
 	session.Data["message"] = message
 
-	// Check if we need camera verification
-	if sm.config.CamServiceActive {
-		session.Stage = types.SESSION_STAGE_OPEN_FIRST
+	// Terminal requires PIN and/or face after card
+	if factors := sm.mfaFactors(session.Key); len(factors) > 0 {
+		return sm.startMFA(session, factors)
+	}
+
+	return sm.processGranted(session)
+}
+
+// processGranted continues session granted by 1C (and passed additional factors)
+func (sm *SessionManager) processGranted(session *types.Session) error {
+	// SPHINX escort access point: visitor needs escort card
+	if isEscortSession(session) {
+		return sm.processEscort(session)
+	}
+
+	// Check if we need camera verification (face already checked as MFA factor
+	// or by cardless face entry)
+	mfa, _ := session.Data["mfa"].(map[string]string)
+	if _, faceChecked := mfa[MFA_FACTOR_FACE]; sm.config.CamServiceActive && !faceChecked && !isFaceEntry(session) {
+		sm.setStage(session, types.SESSION_STAGE_OPEN_FIRST)
 	} else {
-		session.Stage = types.SESSION_STAGE_LAST_ANSWER
+		sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 	}
 
 	return nil
@@ -176,7 +238,7 @@ func (sm *SessionManager) processOpenFirst(session *types.Session) error {
 
 	// TODO: Send relay open command to terminal
 	// For now, just move to waiting for pass
-	session.Stage = types.SESSION_STAGE_FIRST_PASSED
+	sm.setStage(session, types.SESSION_STAGE_FIRST_PASSED)
 
 	return nil
 }
@@ -190,16 +252,17 @@ func (sm *SessionManager) processFirstPassed(session *types.Session) error {
 
 	if !passed {
 		session.Data["result"] = 0
+		session.Data["deny_reason"] = types.DENY_REASON_NOT_PASSED
 		session.Data["message"] = "Проход не зарегистрирован"
-		session.Stage = types.SESSION_STAGE_LAST_ANSWER
+		sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 		return nil
 	}
 
 	// Check if camera verification is needed
 	if sm.config.CamServiceActive && session.CID != "" {
-		session.Stage = types.SESSION_STAGE_CAM_RESULT
+		sm.setStage(session, types.SESSION_STAGE_CAM_RESULT)
 	} else {
-		session.Stage = types.SESSION_STAGE_OPEN_SECOND
+		sm.setStage(session, types.SESSION_STAGE_OPEN_SECOND)
 	}
 
 	return nil
@@ -220,27 +283,31 @@ func (sm *SessionManager) processCamResult(session *types.Session) error {
 	switch result {
 	case types.CAM_RES_YES:
 		session.Data["result"] = 1
-		session.Stage = types.SESSION_STAGE_OPEN_SECOND
+		sm.setStage(session, types.SESSION_STAGE_OPEN_SECOND)
 	case types.CAM_RES_NO:
 		session.Data["result"] = 0
+		session.Data["deny_reason"] = types.DENY_REASON_CAM_NO
 		session.Data["message"] = sm.config.CamServiceResultMsgNo
-		session.Stage = types.SESSION_STAGE_LAST_ANSWER
+		sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 	case types.CAM_RES_NF:
 		session.Data["result"] = 0
+		session.Data["deny_reason"] = types.DENY_REASON_CAM_NF
 		session.Data["message"] = sm.config.CamServiceResultMsgNf
-		session.Stage = types.SESSION_STAGE_LAST_ANSWER
+		sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 	case types.CAM_RES_FAIL:
 		session.Data["result"] = 0
+		session.Data["deny_reason"] = types.DENY_REASON_CAM_FAIL
 		session.Data["message"] = sm.config.CamServiceResultMsgFail
-		session.Stage = types.SESSION_STAGE_LAST_ANSWER
+		sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 	default:
 		if sm.config.CamAlwaysPass {
 			session.Data["result"] = 1
-			session.Stage = types.SESSION_STAGE_OPEN_SECOND
+			sm.setStage(session, types.SESSION_STAGE_OPEN_SECOND)
 		} else {
 			session.Data["result"] = 0
+			session.Data["deny_reason"] = types.DENY_REASON_CAM_FAIL
 			session.Data["message"] = "Ошибка распознавания"
-			session.Stage = types.SESSION_STAGE_LAST_ANSWER
+			sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 		}
 	}
 
@@ -250,7 +317,7 @@ func (sm *SessionManager) processCamResult(session *types.Session) error {
 // processOpenSecond processes opening second gate
 func (sm *SessionManager) processOpenSecond(session *types.Session) error {
 	// TODO: Send relay open command to second terminal
-	session.Stage = types.SESSION_STAGE_SECOND_PASSED
+	sm.setStage(session, types.SESSION_STAGE_SECOND_PASSED)
 	return nil
 }
 
@@ -262,7 +329,7 @@ func (sm *SessionManager) processSecondPassed(session *types.Session) error {
 	}
 
 	session.Data["passed"] = passed
-	session.Stage = types.SESSION_STAGE_PASSED
+	sm.setStage(session, types.SESSION_STAGE_PASSED)
 
 	return nil
 }
@@ -270,18 +337,26 @@ func (sm *SessionManager) processSecondPassed(session *types.Session) error {
 // processPassed processes successful access completion
 func (sm *SessionManager) processPassed(session *types.Session) error {
 	// Send access report to 1C
-	if sm.httpClient != nil {
+	if httpClient := sm.httpFor(session); httpClient != nil {
 		terminalID := sm.extractTerminalID(session.Key)
 		result := session.Data["result"].(int) > 0
 
-		if err := sm.httpClient.SendAccessReport(session.UID, terminalID, result, ""); err != nil {
+		var err error
+		if escortUID, ok := session.Data["escort_uid"].(string); ok && escortUID != "" {
+			err = httpClient.SendEscortReport(session.UID, escortUID, terminalID, result)
+		} else if mfa := session.MFASummary(); mfa != "" {
+			err = httpClient.SendMFAReport(session.UID, terminalID, result, mfa)
+		} else {
+			err = httpClient.SendAccessReport(session.UID, terminalID, result, "")
+		}
+		if err != nil {
 			fmt.Printf("Failed to send access report for session %s: %v\n", session.ID, err)
 			// Continue anyway
 		}
 	}
 
 	session.ReportSent = true
-	session.Stage = types.SESSION_STAGE_DONE
+	sm.setStage(session, types.SESSION_STAGE_DONE)
 	return nil
 }
 
@@ -293,18 +368,27 @@ func (sm *SessionManager) processLastAnswer(session *types.Session) error {
 	if result > 0 {
 		// Send allow message to terminal
 		sm.sendAllowMessage(session, message)
-		session.Stage = types.SESSION_STAGE_PASSED
+		sm.setStage(session, types.SESSION_STAGE_PASSED)
 	} else {
 		// Send deny message to terminal
 		sm.sendDenyMessage(session, message)
-		session.Stage = types.SESSION_STAGE_DONE
+		sm.setStage(session, types.SESSION_STAGE_DONE)
 	}
 
 	return nil
 }
 
+// markDecision records time of access decision (decision latency), the first
+// decision of session counts
+func markDecision(session *types.Session) {
+	if session.Decision.IsZero() {
+		session.Decision = time.Now()
+	}
+}
+
 // sendAllowMessage sends allow message to terminal
 func (sm *SessionManager) sendAllowMessage(session *types.Session, message string) {
+	markDecision(session)
 	if sm.pool == nil {
 		return
 	}
@@ -320,17 +404,14 @@ func (sm *SessionManager) sendAllowMessage(session *types.Session, message strin
 		passTime = pt
 	}
 
-	// Get connection settings to determine terminal type
-	// For now, try JSP first, then fallback to other protocols
 	cid := session.CID
 	if cid == "" {
 		cid = session.UID
 	}
 
-	// Try JSP protocol
-	if err := pool.SendJSPRelayOpen(session.Key, session.UID, message, passTime, cid); err != nil {
-		// If JSP fails, try other protocols or just log
-		fmt.Printf("Failed to send JSP relay open: %v\n", err)
+	// Open relay using terminal protocol driver
+	if err := pool.OpenRelay(session.Key, session.ID, session.UID, message, passTime, cid); err != nil {
+		fmt.Printf("Failed to send relay open: %v\n", err)
 	}
 }
 
@@ -360,8 +441,9 @@ func (sm *SessionManager) checkTagReadDeny(session *types.Session) bool {
 	if !auth {
 		session.Data["error_message"] = "Метка не прочитана"
 		session.Data["result"] = 0
+		session.Data["deny_reason"] = types.DENY_REASON_TAG_AUTH
 		session.Data["message"] = "Метка не прочитана"
-		session.Stage = types.SESSION_STAGE_LAST_ANSWER
+		sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 		return true
 	}
 
@@ -385,8 +467,9 @@ func (sm *SessionManager) checkTagReadDeny(session *types.Session) bool {
 			}
 			session.Data["error_message"] = msg
 			session.Data["result"] = 0
+			session.Data["deny_reason"] = types.DENY_REASON_LOCKERS
 			session.Data["message"] = msg
-			session.Stage = types.SESSION_STAGE_LAST_ANSWER
+			sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 			return true
 		}
 	}
@@ -397,8 +480,9 @@ func (sm *SessionManager) checkTagReadDeny(session *types.Session) bool {
 			msg := "Это карта\nдля\nкартоприемника"
 			session.Data["error_message"] = msg
 			session.Data["result"] = 0
+			session.Data["deny_reason"] = types.DENY_REASON_TEMP_CARD
 			session.Data["message"] = msg
-			session.Stage = types.SESSION_STAGE_LAST_ANSWER
+			sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 			return true
 		}
 	}
@@ -408,6 +492,13 @@ func (sm *SessionManager) checkTagReadDeny(session *types.Session) bool {
 
 // sendDenyMessage sends deny message to terminal
 func (sm *SessionManager) sendDenyMessage(session *types.Session, message string) {
+	markDecision(session)
+	sm.showDenyMessage(session, message)
+}
+
+// showDenyMessage sends deny message to terminal without recording decision
+// (visitor waiting for escort)
+func (sm *SessionManager) showDenyMessage(session *types.Session, message string) {
 	if sm.pool == nil {
 		return
 	}
@@ -417,11 +508,10 @@ func (sm *SessionManager) sendDenyMessage(session *types.Session, message string
 		return
 	}
 
-	// Close relay
-	pool.SendJSPRelayClose(session.Key)
-
-	// Send deny message
-	pool.SendJSPMessage(session.Key, message, 1500)
+	// Send deny message (JSP driver closes relay first)
+	if err := pool.DenyAccess(session.Key, session.ID, message); err != nil {
+		fmt.Printf("Failed to send deny message: %v\n", err)
+	}
 }
 
 // processDone processes session completion
@@ -430,6 +520,9 @@ func (sm *SessionManager) processDone(session *types.Session) error {
 		// TODO: Send final report
 		session.ReportSent = true
 	}
+	sm.countResult(session)
+	sm.latency.add(session)
+	sm.endTrace(session, "")
 
 	// Log session to CSV
 	if sm.csvLogger != nil {
@@ -445,19 +538,57 @@ func (sm *SessionManager) processDone(session *types.Session) error {
 
 	session.Processed = true
 	session.Completed = true
+	sm.unlockTerminal(session)
 
 	return nil
 }
 
+// countResult updates access metrics of completed session
+func (sm *SessionManager) countResult(session *types.Session) {
+	result := "deny"
+	if r, _ := session.Data["result"].(int); r > 0 {
+		result = "allow"
+		metrics.AccessAllowed.Inc(session.Key)
+	} else {
+		reason, _ := session.Data["deny_reason"].(string)
+		if reason == "" {
+			reason = types.DENY_REASON_OTHER
+		}
+		metrics.AccessDenied.Inc(session.Key, reason)
+	}
+	metrics.SessionDuration.Observe(time.Since(session.ReqTime).Seconds(), result)
+}
+
+// unlockTerminal releases reader lock still held by session (no decision was sent)
+func (sm *SessionManager) unlockTerminal(session *types.Session) {
+	if pool, ok := sm.pool.(ConnectionPoolInterface); ok {
+		// Error only means lock was already released by grant/deny
+		pool.UnlockTerminal(session.Key, session.ID)
+	}
+}
+
 // StartSession starts new access session from tag/card read
 func (sm *SessionManager) StartSession(uid string, key string, apkey string, lockers []types.LockerInfo) (*types.Session, error) {
+	return sm.StartTagSession(uid, key, apkey, "", lockers, nil)
+}
+
+// StartTagSession starts new access session with tag type sent to 1C
+// ("rfid" if empty, "faceid" for card of person identified by face).
+// Trace is root span of session started by caller (nil - new trace).
+func (sm *SessionManager) StartTagSession(uid string, key string, apkey string, tagType string, lockers []types.LockerInfo, trace *tracing.Span) (*types.Session, error) {
 	session, err := sm.CreateSession(uid, key, apkey)
 	if err != nil {
+		trace.SetError(err.Error())
+		trace.End()
 		return nil, err
 	}
+	sm.attachTrace(session, trace)
 
 	// Initialize session data
 	session.Data = make(map[string]interface{})
+	if tagType != "" {
+		session.Data["tag_type"] = tagType
+	}
 
 	// Store RFID data including lockers
 	rfidData := map[string]interface{}{
@@ -471,6 +602,7 @@ func (sm *SessionManager) StartSession(uid string, key string, apkey string, loc
 		rfidData["lockers_data_f"] = lockersDataF
 	}
 	session.Data["rfid"] = rfidData
+	sm.markEscort(session)
 
 	// Check deny conditions before starting KPO
 	if sm.checkTagReadDeny(session) {
@@ -507,11 +639,12 @@ func (sm *SessionManager) sendKpoRequest(session *types.Session) (*types.Session
 	}
 
 	session.ReqTime = time.Now()
-	session.Stage = types.SESSION_STAGE_KPO_RESULT
+	sm.setStage(session, types.SESSION_STAGE_KPO_RESULT)
+	httpClient := sm.httpFor(session)
 
 	// Send real HTTP request to 1C
 	go func() {
-		if sm.httpClient != nil {
+		if httpClient != nil {
 			// Extract terminal ID from connection key
 			terminalID := sm.extractTerminalID(session.Key)
 
@@ -573,9 +706,9 @@ func (sm *SessionManager) sendKpoRequest(session *types.Session) (*types.Session
 				if rq, ok := solarData["reg_query"].(bool); ok && rq {
 					regQuery = 1
 				}
-				result, message, err = sm.httpClient.CheckSolarAccess(session.UID, terminalID, solarTime, regQuery)
+				result, message, err = httpClient.CheckSolarAccess(session.UID, terminalID, solarTime, regQuery)
 			} else {
-				result, message, err = sm.httpClient.CheckAccess(session.UID, terminalID, tagType, lockers)
+				result, message, err = httpClient.CheckAccess(session.UID, terminalID, tagType, lockers)
 			}
 
 			if err != nil {
@@ -586,7 +719,7 @@ func (sm *SessionManager) sendKpoRequest(session *types.Session) (*types.Session
 					sm.setKpoResult(session.ID, types.KPO_RES_YES, sm.config.ServiceFixedMsg)
 					fmt.Printf("Using autofix for session %s due to HTTP error\n", session.ID)
 				} else {
-					sm.setKpoResult(session.ID, types.KPO_RES_NO, sm.config.ServiceLinkErrMsg)
+					sm.setKpoError(session.ID)
 				}
 				return
 			}
@@ -594,7 +727,7 @@ func (sm *SessionManager) sendKpoRequest(session *types.Session) (*types.Session
 			sm.setKpoResult(session.ID, *result, message)
 
 			// Try to get user CID
-			if cid, err := sm.httpClient.GetUserCID(session.UID); err == nil {
+			if cid, err := httpClient.GetUserCID(session.UID); err == nil {
 				session.CID = cid
 			}
 		} else {
@@ -638,6 +771,22 @@ func (sm *SessionManager) setKpoResult(sessionID string, result types.KPOResult,
 	return nil
 }
 
+// setKpoError denies session because 1C request failed or timed out
+func (sm *SessionManager) setKpoError(sessionID string) error {
+	if err := sm.setKpoResult(sessionID, types.KPO_RES_NO, sm.config.ServiceLinkErrMsg); err != nil {
+		return err
+	}
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	if session, exists := sm.sessions[sessionID]; exists {
+		if kpoData, ok := session.Data["kpo"].(map[string]interface{}); ok {
+			kpoData["link_error"] = true
+		}
+	}
+	return nil
+}
+
 // HandlePassEvent handles person passed event
 func (sm *SessionManager) HandlePassEvent(sessionID string, gateNumber int) error {
 	sm.mutex.Lock()
@@ -686,6 +835,7 @@ func (sm *SessionManager) CreateSession(uid string, key string, apkey string) (*
 	defer sm.mutex.Unlock()
 
 	sessionID := sm.generateSessionID()
+	now := time.Now()
 
 	session := &types.Session{
 		ID:        sessionID,
@@ -695,7 +845,8 @@ func (sm *SessionManager) CreateSession(uid string, key string, apkey string) (*
 		UIDRaw:    uid,
 		Data:      make(map[string]interface{}),
 		Stage:     types.SESSION_STAGE_INIT,
-		ReqTime:   time.Now(),
+		ReqTime:   now,
+		StageTime: now,
 		Processed: false,
 		Completed: false,
 		Alive:     true,
@@ -746,7 +897,7 @@ func (sm *SessionManager) UpdateSession(sessionID string, updates map[string]int
 		switch key {
 		case "stage":
 			if stage, ok := value.(types.SessionStage); ok {
-				session.Stage = stage
+				sm.setStage(session, stage)
 			}
 		case "data":
 			if data, ok := value.(map[string]interface{}); ok {
@@ -781,6 +932,9 @@ func (sm *SessionManager) DeleteSession(sessionID string) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
+	if session, ok := sm.sessions[sessionID]; ok {
+		sm.endTrace(session, "deleted")
+	}
 	delete(sm.sessions, sessionID)
 }
 
@@ -816,8 +970,8 @@ func (sm *SessionManager) ProcessSession(sessionID string) error {
 		if kpoData, ok := session.Data["kpo"].(map[string]interface{}); ok {
 			if result, ok := kpoData["result"].(types.KPOResult); ok {
 				// Try to get CID for camera check
-				if session.CID == "" && sm.httpClient != nil {
-					if cid, err := sm.httpClient.GetUserCID(session.UID); err == nil && cid != "" {
+				if httpClient := sm.httpFor(session); session.CID == "" && httpClient != nil {
+					if cid, err := httpClient.GetUserCID(session.UID); err == nil && cid != "" {
 						session.CID = cid
 					}
 				}
@@ -829,19 +983,19 @@ func (sm *SessionManager) ProcessSession(sessionID string) error {
 
 					if hasGate {
 						// Has gate - proceed to open first door
-						session.Stage = types.SESSION_STAGE_OPEN_FIRST
+						sm.setStage(session, types.SESSION_STAGE_OPEN_FIRST)
 						return sm.processAccess(session)
 					} else if sm.config.CamServiceActive && session.CID != "" && sm.shouldCheckCamera(session) {
 						// No gate but has camera - check camera
 						return sm.processCameraCheck(session)
 					} else {
 						// No gate and no camera - go to kpo_direct
-						session.Stage = types.SESSION_STAGE_KPO_DIRECT
+						sm.setStage(session, types.SESSION_STAGE_KPO_DIRECT)
 						return nil
 					}
 				} else {
 					// Access denied - go to kpo_direct
-					session.Stage = types.SESSION_STAGE_KPO_DIRECT
+					sm.setStage(session, types.SESSION_STAGE_KPO_DIRECT)
 					return nil
 				}
 			}
@@ -859,7 +1013,7 @@ func (sm *SessionManager) ProcessSession(sessionID string) error {
 				return sm.processAccess(session)
 			} else {
 				// Camera failed
-				session.Stage = types.SESSION_STAGE_LAST_ANSWER
+				sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 				return sm.sendDenyResponse(session)
 			}
 		}
@@ -884,7 +1038,7 @@ func (sm *SessionManager) ProcessSession(sessionID string) error {
 
 	case types.SESSION_STAGE_SECOND_PASSED:
 		// Second passage completed
-		session.Stage = types.SESSION_STAGE_PASSED
+		sm.setStage(session, types.SESSION_STAGE_PASSED)
 
 	case types.SESSION_STAGE_PASSED:
 		// Access completed successfully
@@ -904,7 +1058,7 @@ func (sm *SessionManager) processKPO(session *types.Session) error {
 	// For now, simulate KPO check
 	session.Data["kpo_result"] = types.KPO_RES_YES
 	session.Data["kpo_message"] = "Access granted"
-	session.Stage = types.SESSION_STAGE_KPO_RESULT
+	sm.setStage(session, types.SESSION_STAGE_KPO_RESULT)
 
 	return nil
 }
@@ -913,16 +1067,16 @@ func (sm *SessionManager) processKPO(session *types.Session) error {
 func (sm *SessionManager) processCameraCheck(session *types.Session) error {
 	if !sm.config.CamServiceActive {
 		session.Data["cam_result"] = types.CAM_RES_YES
-		session.Stage = types.SESSION_STAGE_OPEN_FIRST
+		sm.setStage(session, types.SESSION_STAGE_OPEN_FIRST)
 		return nil
 	}
 
-	// Get CID (person ID) for Helios
+	// Get CID (person ID) for face verification
 	personID := session.CID
 	if personID == "" {
 		// Try to get from HTTP client
-		if sm.httpClient != nil {
-			if cid, err := sm.httpClient.GetUserCID(session.UID); err == nil && cid != "" {
+		if httpClient := sm.httpFor(session); httpClient != nil {
+			if cid, err := httpClient.GetUserCID(session.UID); err == nil && cid != "" {
 				personID = cid
 				session.CID = cid
 			}
@@ -932,7 +1086,7 @@ func (sm *SessionManager) processCameraCheck(session *types.Session) error {
 	if personID == "" {
 		// No CID available, skip camera check
 		session.Data["cam_result"] = types.CAM_RES_YES
-		session.Stage = types.SESSION_STAGE_OPEN_FIRST
+		sm.setStage(session, types.SESSION_STAGE_OPEN_FIRST)
 		return nil
 	}
 
@@ -944,58 +1098,95 @@ func (sm *SessionManager) processCameraCheck(session *types.Session) error {
 	camData["result"] = types.CAM_RES_UNDEF
 	camData["start_time"] = time.Now()
 
-	// Get camera PID from connection or use default
-	camPID := "default"
-	if sm.pool != nil {
-		if pool, ok := sm.pool.(ConnectionPoolInterface); ok {
-			if conn := pool.GetConnection(session.Key); conn != nil && conn.Settings != nil {
-				// Try to get cam_pid from settings
-				if extra := conn.Settings.Extra; extra != nil {
-					if pid, ok := extra["cam_pid"].(string); ok && pid != "" {
-						camPID = pid
-					}
-				}
-			}
-		}
-	}
-
-	// Start Helios verification
-	if sm.heliosClient != nil {
-		requestID, err := sm.heliosClient.StartVerification(session.ID, camPID, personID)
+	// Start face verification
+	if provider := sm.faceProvider(session.Key); provider != nil {
+		requestID, err := provider.Verify(session.ID, sm.camPID(session), personID)
 		if err != nil {
 			// On error, use autofix if enabled
 			if sm.config.ServiceAutofixExpired {
 				camData["result"] = types.CAM_RES_YES
-				session.Stage = types.SESSION_STAGE_OPEN_FIRST
+				sm.setStage(session, types.SESSION_STAGE_OPEN_FIRST)
 			} else {
 				camData["result"] = types.CAM_RES_FAIL
 				session.Data["result"] = 0
+				session.Data["deny_reason"] = types.DENY_REASON_CAM_FAIL
 				session.Data["message"] = sm.config.CamServiceResultMsgFail
-				session.Stage = types.SESSION_STAGE_LAST_ANSWER
+				sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 			}
 			return err
 		}
 
 		// Store request ID in session
 		camData["rkey"] = requestID
-		session.Stage = types.SESSION_STAGE_CAM_RESULT
+		camData["provider"] = provider.Name()
+		sm.setStage(session, types.SESSION_STAGE_CAM_RESULT)
 	} else {
-		// No Helios client, skip camera check
+		// No face provider, skip camera check
 		camData["result"] = types.CAM_RES_YES
-		session.Stage = types.SESSION_STAGE_OPEN_FIRST
+		sm.setStage(session, types.SESSION_STAGE_OPEN_FIRST)
 	}
 
 	return nil
 }
 
-// SetHeliosClient sets Helios client for session manager
-func (sm *SessionManager) SetHeliosClient(client HeliosClientInterface) {
-	sm.heliosClient = client
+// camPID returns camera PID of session terminal ("cam_pid" setting) or "default"
+func (sm *SessionManager) camPID(session *types.Session) string {
+	camPID := "default"
+	if sm.pool != nil {
+		if pool, ok := sm.pool.(ConnectionPoolInterface); ok {
+			if conn := pool.GetConnection(session.Key); conn != nil && conn.Settings != nil {
+				// Try to get cam_pid from settings
+				if extra := conn.Settings.Extra; extra != nil {
+					if pid, ok := extra["cam_pid"].(string); ok && pid != "" {
+						camPID = pid
+					}
+				}
+			}
+		}
+	}
+	return camPID
+}
+
+// SetFaceProviders sets face recognition providers for session manager
+func (sm *SessionManager) SetFaceProviders(faces *face.Registry) {
+	sm.faces = faces
+}
+
+// closeFaceRequest cancels face verification request of session
+func (sm *SessionManager) closeFaceRequest(camData map[string]interface{}) {
+	rkey, _ := camData["rkey"].(string)
+	name, _ := camData["provider"].(string)
+	if rkey == "" || name == "" || sm.faces == nil {
+		return
+	}
+	if provider := sm.faces.Get(name); provider != nil {
+		provider.CloseRequest(rkey)
+	}
+}
+
+// faceProvider returns face provider of terminal: term string "face_provider=<name>"
+// or camera.provider
+func (sm *SessionManager) faceProvider(key string) face.Provider {
+	if sm.faces == nil {
+		return nil
+	}
+	name := ""
+	if pool, ok := sm.pool.(ConnectionPoolInterface); ok {
+		if conn := pool.GetConnection(key); conn != nil && conn.Settings != nil {
+			name, _ = conn.Settings.Extra["face_provider"].(string)
+		}
+	}
+	provider := sm.faces.Get(name)
+	if provider == nil && name != "" {
+		fmt.Printf("Unknown face provider %q on %s, using default\n", name, key)
+		provider = sm.faces.Get("")
+	}
+	return provider
 }
 
 // processAccess processes access granting
 func (sm *SessionManager) processAccess(session *types.Session) error {
-	session.Stage = types.SESSION_STAGE_OPEN_FIRST
+	sm.setStage(session, types.SESSION_STAGE_OPEN_FIRST)
 	return sm.sendAccessResponse(session, true, "Access granted")
 }
 
@@ -1005,7 +1196,7 @@ func (sm *SessionManager) waitForFirstPass(session *types.Session) error {
 	if passed, ok := session.Data["passed"].(map[string]interface{}); ok {
 		if passedVal, ok := passed["passed"].(bool); ok && passedVal {
 			session.Data["passed_first"] = true
-			session.Stage = types.SESSION_STAGE_FIRST_PASSED
+			sm.setStage(session, types.SESSION_STAGE_FIRST_PASSED)
 
 			// Clear passed data
 			delete(session.Data, "passed")
@@ -1024,7 +1215,7 @@ func (sm *SessionManager) waitForFirstPass(session *types.Session) error {
 
 // processSecondAccess processes second access
 func (sm *SessionManager) processSecondAccess(session *types.Session) error {
-	session.Stage = types.SESSION_STAGE_OPEN_SECOND
+	sm.setStage(session, types.SESSION_STAGE_OPEN_SECOND)
 	return sm.sendAccessResponse(session, true, "Proceed to second door")
 }
 
@@ -1034,7 +1225,7 @@ func (sm *SessionManager) waitForSecondPass(session *types.Session) error {
 	if passed, ok := session.Data["passed"].(map[string]interface{}); ok {
 		if passedVal, ok := passed["passed"].(bool); ok && passedVal {
 			session.Data["passed_second"] = true
-			session.Stage = types.SESSION_STAGE_SECOND_PASSED
+			sm.setStage(session, types.SESSION_STAGE_SECOND_PASSED)
 
 			// Clear passed data
 			delete(session.Data, "passed")
@@ -1075,7 +1266,13 @@ func (sm *SessionManager) sendDenyResponse(session *types.Session) error {
 
 // shouldCheckCamera checks if camera verification is needed
 func (sm *SessionManager) shouldCheckCamera(session *types.Session) bool {
-	return sm.config.CamServiceActive && session.CID != ""
+	return sm.config.CamServiceActive && session.CID != "" && !isFaceEntry(session)
+}
+
+// isFaceEntry checks if session is cardless entry of person identified by face
+func isFaceEntry(session *types.Session) bool {
+	tagType, _ := session.Data["tag_type"].(string)
+	return tagType == "faceid"
 }
 
 // generateSessionID generates unique session ID
@@ -1099,8 +1296,11 @@ func (sm *SessionManager) CleanupExpiredSessions() {
 	}
 
 	for _, id := range expired {
+		sm.unlockTerminal(sm.sessions[id])
+		sm.endTrace(sm.sessions[id], "expired")
 		delete(sm.sessions, id)
 	}
+	sm.cleanupEscorts()
 
 	if len(expired) > 0 {
 		fmt.Printf("Cleaned up %d expired sessions\n", len(expired))
@@ -1190,7 +1390,7 @@ func (sm *SessionManager) checkWait(session *types.Session) bool {
 			if sm.config.ServiceAutofixExpired {
 				sm.setKpoResult(session.ID, types.KPO_RES_YES, sm.config.ServiceFixedMsg)
 			} else {
-				sm.setKpoResult(session.ID, types.KPO_RES_NO, sm.config.ServiceLinkErrMsg)
+				sm.setKpoError(session.ID)
 			}
 			sm.waitDone(session)
 			return true
@@ -1212,6 +1412,7 @@ func (sm *SessionManager) checkWait(session *types.Session) bool {
 			if camData, ok := session.Data["cam"].(map[string]interface{}); ok {
 				camData["result"] = types.CAM_RES_NO
 				camData["answer_data"] = map[string]interface{}{"error": "idle_timeout"}
+				sm.closeFaceRequest(camData)
 			}
 			sm.waitDone(session)
 			return true
@@ -1244,6 +1445,50 @@ func (sm *SessionManager) checkWait(session *types.Session) bool {
 		}
 		return false
 
+	case 0x05: // SESSION_PROC_PIN
+		// Check if PIN check result is available
+		if pinData, ok := session.Data["pin"].(map[string]interface{}); ok {
+			if result, ok := pinData["result"].(types.KPOResult); ok && result != types.KPO_RES_UNDEF {
+				sm.waitDone(session)
+				return true
+			}
+			// Check timeout (PIN entered in time is still being checked)
+			if entered, _ := pinData["entered"].(bool); !entered && now.After(session.Wait.ExpireTime) {
+				pinData["tmo"] = true
+				sm.waitDone(session)
+				return true
+			}
+		}
+		// PIN check in 1C itself is limited by service request timeout
+		if now.After(session.Wait.ExpireTime.Add(time.Duration(sm.config.ServiceRequestExpireTime * float64(time.Second)))) {
+			sm.setPINResult(session.ID, types.KPO_RES_NO, sm.config.ServiceLinkErrMsg)
+			sm.waitDone(session)
+			return true
+		}
+		return false
+
+	case 0x04: // SESSION_PROC_ESCORT
+		// Check if escort card was paired with visitor and escort was granted
+		if done, granted := sm.escortPassed(session); done {
+			markDecision(session)
+			if !granted {
+				session.Data["message"] = ESCORT_TIMEOUT_MSG
+				session.Wait.DstStage = types.SESSION_STAGE_DONE
+			}
+			sm.waitDone(session)
+			return true
+		}
+		// Check timeout (paired visitor waits for decision of escort session)
+		if _, paired := session.Data["escort_session"]; !paired && now.After(session.Wait.ExpireTime) {
+			// Timeout - no escort, visitor access denied
+			markDecision(session)
+			session.Data["message"] = ESCORT_TIMEOUT_MSG
+			session.Wait.DstStage = types.SESSION_STAGE_DONE
+			sm.waitDone(session)
+			return true
+		}
+		return false
+
 	default:
 		// Unknown proc type - clear wait
 		sm.waitDone(session)
@@ -1261,7 +1506,7 @@ func (sm *SessionManager) waitDone(session *types.Session) {
 	session.Wait = nil
 
 	if dstStage != 0 {
-		session.Stage = dstStage
+		sm.setStage(session, dstStage)
 	}
 }
 
@@ -1294,19 +1539,21 @@ func (sm *SessionManager) processKpoDirect(session *types.Session) error {
 				// Access granted - show allow message
 				session.Data["result"] = 1
 				session.Data["message"] = message
-				session.Stage = types.SESSION_STAGE_LAST_ANSWER
+				sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 				sm.sendAllowMessage(session, message)
 			} else if result == types.KPO_RES_NO {
 				// Access denied - show deny message
 				session.Data["result"] = 0
+				session.Data["deny_reason"] = types.DENY_REASON_1C
 				session.Data["message"] = message
-				session.Stage = types.SESSION_STAGE_LAST_ANSWER
+				sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 				sm.sendDenyMessage(session, message)
 			} else {
 				// Error - show error message
 				session.Data["result"] = 0
+				session.Data["deny_reason"] = types.DENY_REASON_1C_ERROR
 				session.Data["message"] = message
-				session.Stage = types.SESSION_STAGE_LAST_ANSWER
+				sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
 				sm.sendDenyMessage(session, message)
 			}
 		} else {
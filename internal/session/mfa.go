@@ -0,0 +1,303 @@
+package session
+
+import (
+	"fmt"
+	"nd-go/pkg/types"
+	"strings"
+	"time"
+)
+
+// Additional access factors (term string "mfa=card+pin", "card+face", "card+pin+face")
+const (
+	MFA_FACTOR_PIN  = "pin"
+	MFA_FACTOR_FACE = "face"
+
+	// Factor results
+	MFA_RES_WAIT    = "WAIT"
+	MFA_RES_OK      = "OK"
+	MFA_RES_FAIL    = "FAIL"
+	MFA_RES_TIMEOUT = "TIMEOUT"
+	MFA_RES_NO_PIN  = "NO_PIN" // Terminal has no keypad delivering PIN
+
+	MFA_PIN_PROMPT_MSG  = "Введите PIN"
+	MFA_FACE_PROMPT_MSG = "Посмотрите в камеру"
+	MFA_PIN_FAIL_MSG    = "Неверный PIN"
+	MFA_PIN_TIMEOUT_MSG = "PIN не введен"
+	MFA_NO_PIN_MSG      = "Ввод PIN недоступен"
+)
+
+// mfaPINTerminals are terminal types whose drivers deliver keypad PIN
+// (reader type PIN)
+var mfaPINTerminals = map[types.TerminalType]bool{
+	types.TTYPE_GAT:  true,
+	types.TTYPE_OSDP: true,
+}
+
+// mfaFactors returns factors required by terminal after card, in order of policy
+func (sm *SessionManager) mfaFactors(key string) []string {
+	policy := ""
+	if pool, ok := sm.pool.(ConnectionPoolInterface); ok {
+		if conn := pool.GetConnection(key); conn != nil && conn.Settings != nil {
+			policy, _ = conn.Settings.Extra["mfa"].(string)
+		}
+	}
+	if policy == "" {
+		return nil
+	}
+
+	var factors []string
+	for _, factor := range strings.Split(strings.ToLower(policy), "+") {
+		switch factor = strings.TrimSpace(factor); factor {
+		case "card":
+		case MFA_FACTOR_PIN, MFA_FACTOR_FACE:
+			factors = append(factors, factor)
+		default:
+			fmt.Printf("Unknown MFA factor %q on %s ignored\n", factor, key)
+		}
+	}
+	return factors
+}
+
+// mfaTimeout returns time to present factor: term string "mfa_pin_timeout=<s>" /
+// "mfa_face_timeout=<s>" or timeouts.mfa_pin_timeout / timeouts.mfa_face_timeout
+func (sm *SessionManager) mfaTimeout(key string, factor string) float64 {
+	timeout := sm.config.MFAPINTimeout
+	if factor == MFA_FACTOR_FACE {
+		timeout = sm.config.MFAFaceTimeout
+	}
+	if pool, ok := sm.pool.(ConnectionPoolInterface); ok {
+		if conn := pool.GetConnection(key); conn != nil && conn.Settings != nil {
+			if v, ok := conn.Settings.Extra["mfa_"+factor+"_timeout"].(int); ok && v > 0 {
+				timeout = float64(v)
+			}
+		}
+	}
+	if timeout <= 0 {
+		timeout = sm.config.ServiceRequestExpireTime
+	}
+	return timeout
+}
+
+// startMFA starts checking additional factors of session granted by 1C
+func (sm *SessionManager) startMFA(session *types.Session, factors []string) error {
+	results := make(map[string]string, len(factors))
+	for _, factor := range factors {
+		results[factor] = ""
+	}
+	session.Data["mfa_factors"] = factors
+	session.Data["mfa"] = results
+	sm.setStage(session, types.SESSION_STAGE_MFA)
+	return sm.processMFA(session)
+}
+
+// processMFA checks factors one by one, each with its own timeout
+func (sm *SessionManager) processMFA(session *types.Session) error {
+	factors, _ := session.Data["mfa_factors"].([]string)
+	results, _ := session.Data["mfa"].(map[string]string)
+	if results == nil {
+		return fmt.Errorf("no MFA data in session")
+	}
+
+	for _, factor := range factors {
+		if results[factor] == "" {
+			return sm.startFactor(session, factor)
+		}
+		if results[factor] == MFA_RES_WAIT {
+			results[factor] = sm.factorResult(session, factor)
+		}
+		if results[factor] != MFA_RES_OK {
+			fmt.Printf("Session %s: MFA factor %s %s\n", session.ID, factor, results[factor])
+			session.Data["result"] = 0
+			session.Data["deny_reason"] = types.DENY_REASON_MFA
+			session.Data["message"] = sm.factorDenyMessage(session, factor, results[factor])
+			sm.setStage(session, types.SESSION_STAGE_LAST_ANSWER)
+			return nil
+		}
+	}
+
+	// All factors passed
+	return sm.processGranted(session)
+}
+
+// startFactor prompts person for factor and waits for it
+func (sm *SessionManager) startFactor(session *types.Session, factor string) error {
+	results := session.Data["mfa"].(map[string]string)
+	results[factor] = MFA_RES_WAIT
+	timeout := sm.mfaTimeout(session.Key, factor)
+
+	switch factor {
+	case MFA_FACTOR_PIN:
+		// Waiting for PIN the terminal can't send would only end in timeout
+		if termType := sm.terminalType(session.Key); !mfaPINTerminals[termType] {
+			fmt.Printf("Session %s: mfa=...pin on %s terminal %s which can't deliver PIN, access denied\n",
+				session.ID, termType, session.Key)
+			results[factor] = MFA_RES_NO_PIN
+			return sm.processMFA(session)
+		}
+		session.Data["pin"] = map[string]interface{}{
+			"result":     types.KPO_RES_UNDEF,
+			"start_time": time.Now(),
+		}
+		sm.promptFactor(session, MFA_PIN_PROMPT_MSG)
+		return sm.Wait(session, 0x05, types.SESSION_STAGE_MFA, timeout, nil) // SESSION_PROC_PIN
+
+	case MFA_FACTOR_FACE:
+		camData := map[string]interface{}{
+			"result":     types.CAM_RES_UNDEF,
+			"start_time": time.Now(),
+		}
+		session.Data["cam"] = camData
+
+		personID := session.CID
+		if httpClient := sm.httpFor(session); personID == "" && httpClient != nil {
+			if cid, err := httpClient.GetUserCID(session.UID); err == nil && cid != "" {
+				personID = cid
+				session.CID = cid
+			}
+		}
+		if personID == "" {
+			camData["result"] = types.CAM_RES_NF
+			return sm.processMFA(session)
+		}
+		provider := sm.faceProvider(session.Key)
+		if provider == nil {
+			camData["result"] = types.CAM_RES_FAIL
+			return sm.processMFA(session)
+		}
+		requestID, err := provider.Verify(session.ID, sm.camPID(session), personID)
+		if err != nil {
+			fmt.Printf("Session %s: face check failed to start: %v\n", session.ID, err)
+			camData["result"] = types.CAM_RES_FAIL
+			return sm.processMFA(session)
+		}
+		camData["rkey"] = requestID
+		camData["provider"] = provider.Name()
+		sm.promptFactor(session, MFA_FACE_PROMPT_MSG)
+		return sm.Wait(session, 0x02, types.SESSION_STAGE_MFA, timeout, nil) // SESSION_PROC_CAM
+	}
+	return nil
+}
+
+// factorResult returns result of factor once its wait is done
+func (sm *SessionManager) factorResult(session *types.Session, factor string) string {
+	switch factor {
+	case MFA_FACTOR_PIN:
+		pinData, _ := session.Data["pin"].(map[string]interface{})
+		if tmo, _ := pinData["tmo"].(bool); tmo {
+			return MFA_RES_TIMEOUT
+		}
+		if result, _ := pinData["result"].(types.KPOResult); result == types.KPO_RES_YES {
+			return MFA_RES_OK
+		}
+	case MFA_FACTOR_FACE:
+		camData, _ := session.Data["cam"].(map[string]interface{})
+		if answer, ok := camData["answer_data"].(map[string]interface{}); ok && answer["error"] == "idle_timeout" {
+			return MFA_RES_TIMEOUT
+		}
+		if result, _ := camData["result"].(types.CamResult); result == types.CAM_RES_YES {
+			return MFA_RES_OK
+		}
+	}
+	return MFA_RES_FAIL
+}
+
+// factorDenyMessage returns message shown when factor is not passed
+func (sm *SessionManager) factorDenyMessage(session *types.Session, factor string, result string) string {
+	if factor == MFA_FACTOR_PIN {
+		switch result {
+		case MFA_RES_TIMEOUT:
+			return MFA_PIN_TIMEOUT_MSG
+		case MFA_RES_NO_PIN:
+			return MFA_NO_PIN_MSG
+		}
+		pinData, _ := session.Data["pin"].(map[string]interface{})
+		if msg, _ := pinData["message"].(string); msg != "" {
+			return msg
+		}
+		return MFA_PIN_FAIL_MSG
+	}
+
+	camData, _ := session.Data["cam"].(map[string]interface{})
+	switch camData["result"] {
+	case types.CAM_RES_NF:
+		return sm.config.CamServiceResultMsgNf
+	case types.CAM_RES_NO:
+		return sm.config.CamServiceResultMsgNo
+	}
+	return sm.config.CamServiceResultMsgFail
+}
+
+// terminalType returns type of terminal connection, empty if unknown
+func (sm *SessionManager) terminalType(key string) types.TerminalType {
+	if pool, ok := sm.pool.(ConnectionPoolInterface); ok {
+		if conn := pool.GetConnection(key); conn != nil && conn.Settings != nil {
+			return conn.Settings.Type
+		}
+	}
+	return ""
+}
+
+// promptFactor shows factor prompt on terminal keeping reader locked by session
+func (sm *SessionManager) promptFactor(session *types.Session, text string) {
+	if pool, ok := sm.pool.(ConnectionPoolInterface); ok {
+		if err := pool.LockTerminal(session.Key, session.ID, text); err != nil {
+			fmt.Printf("Failed to show prompt on %s: %v\n", session.Key, err)
+		}
+	}
+}
+
+// HandlePIN handles PIN entered on terminal of session waiting for PIN
+func (sm *SessionManager) HandlePIN(key string, pin string) error {
+	sm.mutex.Lock()
+	var session *types.Session
+	for _, s := range sm.sessions {
+		if s.Key == key && !s.Completed && s.Wait != nil && s.Wait.ProcType == 0x05 { // SESSION_PROC_PIN
+			session = s
+			break
+		}
+	}
+	if session == nil {
+		sm.mutex.Unlock()
+		return fmt.Errorf("no session waiting for PIN on %s", key)
+	}
+	pinData, _ := session.Data["pin"].(map[string]interface{})
+	if entered, _ := pinData["entered"].(bool); entered || pinData == nil {
+		sm.mutex.Unlock()
+		return fmt.Errorf("PIN already entered for session %s", session.ID)
+	}
+	pinData["entered"] = true
+	sm.mutex.Unlock()
+
+	// Check PIN in 1C
+	httpClient := sm.httpFor(session)
+	go func() {
+		if httpClient == nil {
+			sm.setPINResult(session.ID, types.KPO_RES_NO, sm.config.ServiceLinkErrMsg)
+			return
+		}
+		result, message, err := httpClient.CheckPIN(session.UID, sm.extractTerminalID(session.Key), pin)
+		if err != nil {
+			fmt.Printf("PIN check failed for session %s: %v\n", session.ID, err)
+			sm.setPINResult(session.ID, types.KPO_RES_NO, sm.config.ServiceLinkErrMsg)
+			return
+		}
+		sm.setPINResult(session.ID, *result, message)
+	}()
+	return nil
+}
+
+// setPINResult sets PIN check result
+func (sm *SessionManager) setPINResult(sessionID string, result types.KPOResult, message string) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	session, exists := sm.sessions[sessionID]
+	if !exists {
+		return
+	}
+	if pinData, ok := session.Data["pin"].(map[string]interface{}); ok {
+		pinData["result"] = result
+		pinData["message"] = message
+		pinData["end_time"] = time.Now()
+	}
+}
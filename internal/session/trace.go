@@ -0,0 +1,162 @@
+package session
+
+import (
+	"nd-go/internal/tracing"
+	"nd-go/pkg/types"
+)
+
+// Span names of access session trace: root span covers session from
+// identifier read to completion, stage spans follow each other under root
+const (
+	SPAN_ACCESS_SESSION = "access_session"
+	SPAN_TAG_READ       = "tag_read"
+	SPAN_LOCAL_CHECKS   = "local_checks" // deny lists, MEMREG, lockers
+	SPAN_1C_IDENT       = "1c_ident"
+	SPAN_MFA            = "mfa"
+	SPAN_ESCORT_WAIT    = "escort_wait"
+	SPAN_CAMERA_VERIFY  = "camera_verify"
+	SPAN_RELAY_OPEN     = "relay_open"
+	SPAN_DENY           = "deny"
+	SPAN_PASS           = "pass"
+	SPAN_REPORT         = "report"
+)
+
+// tracedHTTPClient is HTTP client able to trace requests as child spans
+type tracedHTTPClient interface {
+	WithSpan(parent *tracing.Span) interface{}
+}
+
+// sessionTrace holds spans of access session
+type sessionTrace struct {
+	root      *tracing.Span
+	stage     *tracing.Span // span of current stage
+	stageName string
+}
+
+// StartTrace starts root span of access session of identifier read on terminal
+// key (kind: "card", "barcode", "face"). Parent is W3C traceparent of
+// preceding trace (face identification) or "". Returns nil if tracing is disabled.
+func StartTrace(key string, uid string, kind string, parent string) *tracing.Span {
+	root := tracing.StartRemote(SPAN_ACCESS_SESSION, parent)
+	root.SetAttr("skd.terminal", key)
+	if uid != "" {
+		root.SetID("skd.uid", uid)
+	}
+	if kind != "" {
+		root.SetAttr("skd.read_kind", kind)
+	}
+	return root
+}
+
+// attachTrace binds root span to session, session without span gets new trace
+func (sm *SessionManager) attachTrace(session *types.Session, root *tracing.Span) {
+	if root == nil {
+		root = StartTrace(session.Key, session.UID, "", "")
+		if root == nil {
+			return
+		}
+	}
+	root.SetAttr("skd.session_id", session.ID)
+
+	sm.traceMutex.Lock()
+	sm.traces[session.ID] = &sessionTrace{root: root}
+	sm.traceMutex.Unlock()
+}
+
+// traceStage starts span of current session stage, consecutive stages with
+// the same span name (gate stages) share span
+func (sm *SessionManager) traceStage(session *types.Session) {
+	sm.traceMutex.Lock()
+	defer sm.traceMutex.Unlock()
+
+	st, ok := sm.traces[session.ID]
+	if !ok {
+		return
+	}
+	name := stageSpanName(session)
+	if name == st.stageName {
+		return
+	}
+	st.stage.End()
+	st.stage, st.stageName = nil, name
+	if name != "" {
+		st.stage = st.root.Child(name)
+		st.stage.SetAttr("skd.stage", session.Stage.String())
+	}
+}
+
+// stageSpanName returns span name of session stage, "" for untraced stages
+func stageSpanName(session *types.Session) string {
+	switch session.Stage {
+	case types.SESSION_STAGE_KPO_RESULT, types.SESSION_STAGE_KPO_DIRECT:
+		return SPAN_1C_IDENT
+	case types.SESSION_STAGE_MFA:
+		return SPAN_MFA
+	case types.SESSION_STAGE_ESCORT_WAIT:
+		return SPAN_ESCORT_WAIT
+	case types.SESSION_STAGE_CAM_RESULT:
+		return SPAN_CAMERA_VERIFY
+	case types.SESSION_STAGE_OPEN_FIRST, types.SESSION_STAGE_FIRST_PASSED,
+		types.SESSION_STAGE_OPEN_SECOND, types.SESSION_STAGE_SECOND_PASSED:
+		return SPAN_PASS
+	case types.SESSION_STAGE_LAST_ANSWER:
+		if result, _ := session.Data["result"].(int); result > 0 {
+			return SPAN_RELAY_OPEN
+		}
+		return SPAN_DENY
+	case types.SESSION_STAGE_PASSED:
+		return SPAN_REPORT
+	}
+	return ""
+}
+
+// endTrace ends spans of session with result attributes, errMsg marks
+// session which was not completed (e.g. "expired")
+func (sm *SessionManager) endTrace(session *types.Session, errMsg string) {
+	sm.traceMutex.Lock()
+	st, ok := sm.traces[session.ID]
+	delete(sm.traces, session.ID)
+	sm.traceMutex.Unlock()
+	if !ok {
+		return
+	}
+
+	st.stage.End()
+	result := "deny"
+	if r, _ := session.Data["result"].(int); r > 0 {
+		result = "allow"
+	}
+	st.root.SetAttr("skd.result", result)
+	if reason, _ := session.Data["deny_reason"].(string); reason != "" && result == "deny" {
+		st.root.SetAttr("skd.deny_reason", reason)
+	}
+	if errMsg != "" {
+		st.root.SetError(errMsg)
+	}
+	st.root.End()
+}
+
+// httpFor returns HTTP client tracing requests as children of current stage
+// span of session (root span between stages)
+func (sm *SessionManager) httpFor(session *types.Session) HTTPClientInterface {
+	sm.traceMutex.Lock()
+	st, ok := sm.traces[session.ID]
+	var parent *tracing.Span
+	if ok {
+		parent = st.stage
+		if parent == nil {
+			parent = st.root
+		}
+	}
+	sm.traceMutex.Unlock()
+
+	if parent == nil {
+		return sm.httpClient
+	}
+	if tc, ok := sm.httpClient.(tracedHTTPClient); ok {
+		if hc, ok := tc.WithSpan(parent).(HTTPClientInterface); ok {
+			return hc
+		}
+	}
+	return sm.httpClient
+}
@@ -79,6 +79,9 @@ func (s *SQLiteStore) initSchema() error {
 			cam_cid TEXT,
 			final_result TEXT,
 			final_msg TEXT,
+			mfa TEXT,
+			stages TEXT,
+			duration_ms INTEGER,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		);
 		CREATE INDEX IF NOT EXISTS idx_sessions_created_at ON sessions(created_at);
@@ -86,6 +89,12 @@ func (s *SQLiteStore) initSchema() error {
 	if err != nil {
 		return fmt.Errorf("create sessions table: %w", err)
 	}
+	// MFA and stage timeline columns for databases created before they were added
+	for column, def := range map[string]string{"mfa": "TEXT", "stages": "TEXT", "duration_ms": "INTEGER"} {
+		if err := s.addColumn("sessions", column, def); err != nil {
+			return fmt.Errorf("migrate sessions table: %w", err)
+		}
+	}
 
 	_, err = s.db.Exec(`
 		CREATE TABLE IF NOT EXISTS gtime_events (
@@ -105,9 +114,56 @@ func (s *SQLiteStore) initSchema() error {
 		return fmt.Errorf("create gtime_events table: %w", err)
 	}
 
+	_, err = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS controller_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_time TEXT,
+			conn_key TEXT,
+			term_id TEXT,
+			type TEXT,
+			code TEXT,
+			access_point TEXT,
+			key_type TEXT,
+			uid TEXT,
+			direction TEXT,
+			raw TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_controller_events_created_at ON controller_events(created_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("create controller_events table: %w", err)
+	}
+
 	return nil
 }
 
+// addColumn adds column to table if it does not exist.
+func (s *SQLiteStore) addColumn(table, column, def string) error {
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, def))
+	return err
+}
+
 // LogSession writes session data (implements CSVLoggerInterface).
 func (s *SQLiteStore) LogSession(session *types.Session, conn *types.Connection) error {
 	data := s.prepareSessionData(session, conn)
@@ -119,10 +175,11 @@ func (s *SQLiteStore) LogSession(session *types.Session, conn *types.Connection)
 	}
 
 	_, err := s.db.Exec(`
-		INSERT INTO sessions (session_time, term_id, term_addr, term_role, uid, kpo_result, kpo_msg, cam_result, cam_cid, final_result, final_msg)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		INSERT INTO sessions (session_time, term_id, term_addr, term_role, uid, kpo_result, kpo_msg, cam_result, cam_cid, final_result, final_msg, mfa, stages, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		data["session_time"], data["term_id"], data["term_addr"], data["term_role"], data["uid"],
 		data["kpo_result"], data["kpo_msg"], data["cam_result"], data["cam_cid"], data["final_result"], data["final_msg"],
+		data["mfa"], data["stages"], data["duration_ms"],
 	)
 	return err
 }
@@ -182,6 +239,9 @@ func (s *SQLiteStore) prepareSessionData(session *types.Session, conn *types.Con
 		finalMsg = nl2comma(msg)
 	}
 	data["final_msg"] = finalMsg
+	data["mfa"] = session.MFASummary()
+	data["stages"] = session.StagesSummary()
+	data["duration_ms"] = fmt.Sprintf("%d", session.DurationMs())
 	return data
 }
 
@@ -233,6 +293,22 @@ func (s *SQLiteStore) RegisterGTimeEvent(data map[string]string) error {
 	return err
 }
 
+// RegisterControllerEvent logs an event decided by controller itself (SPHINX EVENT_CE).
+func (s *SQLiteStore) RegisterControllerEvent(data map[string]string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.db == nil {
+		return nil
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO controller_events (event_time, conn_key, term_id, type, code, access_point, key_type, uid, direction, raw)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		data["event_time"], data["conn_key"], data["term_id"], data["type"], data["code"],
+		data["access_point"], data["key_type"], data["uid"], data["direction"], data["raw"])
+	return err
+}
+
 // GetSessionsSince returns session rows since the given time (for email digest).
 func (s *SQLiteStore) GetSessionsSince(since time.Time) ([]map[string]string, error) {
 	s.mutex.Lock()
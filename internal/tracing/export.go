@@ -0,0 +1,230 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLP_TRACES_PATH is default path of OTLP/HTTP traces endpoint of collector
+const OTLP_TRACES_PATH = "/v1/traces"
+
+// SCOPE_NAME is instrumentation scope of exported spans
+const SCOPE_NAME = "nd-go/skd"
+
+// OTLP/JSON request (ExportTraceServiceRequest), IDs are hex strings and
+// 64-bit integers are decimal strings as required by OTLP/JSON
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// encodeOTLP encodes spans of service to OTLP/JSON
+func encodeOTLP(service string, spans []*Span) ([]byte, error) {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mutex.Lock()
+		span := otlpSpan{
+			TraceID:           fmt.Sprintf("%x", s.traceID),
+			SpanID:            fmt.Sprintf("%x", s.spanID),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Status:            otlpStatus{Code: s.status, Message: s.message},
+		}
+		if s.parentID != [8]byte{} {
+			span.ParentSpanID = fmt.Sprintf("%x", s.parentID)
+		}
+		for _, a := range s.attrs {
+			span.Attributes = append(span.Attributes, otlpKeyValue{a.key, anyValue(a.value)})
+		}
+		s.mutex.Unlock()
+		out = append(out, span)
+	}
+
+	req := otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			{"service.name", anyValue(service)},
+		}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: SCOPE_NAME}, Spans: out}},
+	}}}
+	return json.Marshal(req)
+}
+
+// anyValue converts attribute value to OTLP AnyValue, unknown types are
+// formatted as string
+func anyValue(v interface{}) otlpAnyValue {
+	switch val := v.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &val}
+	case bool:
+		return otlpAnyValue{BoolValue: &val}
+	case int:
+		s := strconv.Itoa(val)
+		return otlpAnyValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		return otlpAnyValue{IntValue: &s}
+	case float64:
+		return otlpAnyValue{DoubleValue: &val}
+	}
+	s := fmt.Sprintf("%v", v)
+	return otlpAnyValue{StringValue: &s}
+}
+
+// OTLPExporter posts spans to OTLP/HTTP collector (JSON encoding)
+type OTLPExporter struct {
+	url     string
+	headers []string
+	client  *http.Client
+}
+
+// NewOTLPExporter creates exporter to collector URL (path /v1/traces is added
+// if URL has no path), headers are "Key: Value" strings (e.g. authorization)
+func NewOTLPExporter(collectorURL string, headers []string) (*OTLPExporter, error) {
+	u, err := url.Parse(collectorURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid collector URL: %s", collectorURL)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = OTLP_TRACES_PATH
+	}
+	return &OTLPExporter{
+		url:     u.String(),
+		headers: headers,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Name returns exporter name for metrics
+func (e *OTLPExporter) Name() string {
+	return "otlp"
+}
+
+// Export posts payload to collector
+func (e *OTLPExporter) Export(payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, header := range e.headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) == 2 {
+			req.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		}
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Close does nothing, requests are not kept
+func (e *OTLPExporter) Close() error {
+	return nil
+}
+
+// FileExporter appends OTLP/JSON requests to file, one per line (format of
+// OpenTelemetry Collector file exporter, readable by otlpjsonfile receiver)
+type FileExporter struct {
+	file  *os.File
+	mutex sync.Mutex
+}
+
+// NewFileExporter opens file for appending, directory is created if missing
+func NewFileExporter(path string) (*FileExporter, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileExporter{file: file}, nil
+}
+
+// Name returns exporter name for metrics
+func (e *FileExporter) Name() string {
+	return "file"
+}
+
+// Export writes payload as line
+func (e *FileExporter) Export(payload []byte) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	_, err := e.file.Write(append(payload, '\n'))
+	return err
+}
+
+// Close closes file
+func (e *FileExporter) Close() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.file.Close()
+}
@@ -0,0 +1,337 @@
+// Package tracing records spans of access sessions and exports them in
+// OpenTelemetry format (OTLP/JSON) without SDK. Tracing is disabled until
+// Setup is called: Start returns nil span and all Span methods accept nil
+// receiver, so callers don't check whether tracing is enabled.
+package tracing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nd-go/internal/metrics"
+)
+
+// Span kinds (OTLP SpanKind)
+const (
+	KIND_INTERNAL = 1
+	KIND_CLIENT   = 3
+)
+
+// Span status codes (OTLP StatusCode)
+const (
+	STATUS_UNSET = 0
+	STATUS_OK    = 1
+	STATUS_ERROR = 2
+)
+
+// Batch export settings
+const (
+	QUEUE_SIZE     = 4096            // ended spans waiting for export, new spans are dropped when full
+	BATCH_SIZE     = 256             // spans per export request
+	FLUSH_INTERVAL = 5 * time.Second // max delay of span export
+)
+
+var (
+	spansDropped = metrics.NewCounter("skd_trace_spans_dropped_total",
+		"Spans dropped because export queue is full")
+	exportErrors = metrics.NewCounter("skd_trace_export_errors_total",
+		"Failed trace export requests by exporter", "exporter")
+)
+
+// Exporter sends OTLP/JSON payload (ExportTraceServiceRequest) of batch of spans
+type Exporter interface {
+	Name() string
+	Export(payload []byte) error
+	Close() error
+}
+
+// tracer batches ended spans and passes them to exporters
+type tracer struct {
+	service   string
+	exporters []Exporter
+	queue     chan *Span
+	stop      chan chan struct{}
+}
+
+var current atomic.Pointer[tracer]
+
+// idConfig sets how SetID records personal identifiers (card UIDs, person IDs)
+type idConfig struct {
+	raw bool   // record identifiers as is
+	key []byte // HMAC key of identifier hashes
+}
+
+var ids atomic.Pointer[idConfig]
+
+// SetupIDs sets recording of personal identifiers: raw records them as is,
+// otherwise they are recorded as HMAC-SHA256 hashes with key (random key when
+// empty: hashes match only within process run)
+func SetupIDs(raw bool, key string) {
+	ids.Store(newIDConfig(raw, key))
+}
+
+func newIDConfig(raw bool, key string) *idConfig {
+	cfg := &idConfig{raw: raw, key: []byte(key)}
+	if key == "" {
+		cfg.key = make([]byte, 32)
+		rand.Read(cfg.key)
+	}
+	return cfg
+}
+
+// Setup enables tracing: spans of service are exported by exporters
+func Setup(service string, exporters ...Exporter) {
+	t := &tracer{
+		service:   service,
+		exporters: exporters,
+		queue:     make(chan *Span, QUEUE_SIZE),
+		stop:      make(chan chan struct{}),
+	}
+	if old := current.Swap(t); old != nil {
+		old.shutdown()
+	}
+	go t.run()
+}
+
+// Shutdown exports queued spans, closes exporters and disables tracing
+func Shutdown() {
+	if t := current.Swap(nil); t != nil {
+		t.shutdown()
+	}
+}
+
+// Enabled reports whether new spans are recorded
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+func (t *tracer) shutdown() {
+	done := make(chan struct{})
+	t.stop <- done
+	<-done
+}
+
+// run exports spans by batches of BATCH_SIZE at least every FLUSH_INTERVAL
+func (t *tracer) run() {
+	ticker := time.NewTicker(FLUSH_INTERVAL)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, BATCH_SIZE)
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) >= BATCH_SIZE {
+				t.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			t.export(batch)
+			batch = batch[:0]
+		case done := <-t.stop:
+			for len(t.queue) > 0 {
+				batch = append(batch, <-t.queue)
+			}
+			t.export(batch)
+			for _, e := range t.exporters {
+				e.Close()
+			}
+			close(done)
+			return
+		}
+	}
+}
+
+// export sends batch to all exporters, errors are counted and printed
+func (t *tracer) export(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	payload, err := encodeOTLP(t.service, batch)
+	if err != nil {
+		fmt.Printf("Trace encode error: %v\n", err)
+		return
+	}
+	for _, e := range t.exporters {
+		if err := e.Export(payload); err != nil {
+			exportErrors.Inc(e.Name())
+			fmt.Printf("Trace export (%s) error: %v\n", e.Name(), err)
+		}
+	}
+}
+
+// Span is timed operation of trace
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    []attribute
+	status   int
+	message  string
+	ended    bool
+	tracer   *tracer
+	mutex    sync.Mutex
+}
+
+type attribute struct {
+	key   string
+	value interface{}
+}
+
+// Start starts root span of new trace, returns nil if tracing is disabled
+func Start(name string) *Span {
+	t := current.Load()
+	if t == nil {
+		return nil
+	}
+	s := &Span{name: name, kind: KIND_INTERNAL, start: time.Now(), tracer: t}
+	rand.Read(s.traceID[:])
+	rand.Read(s.spanID[:])
+	return s
+}
+
+// StartRemote starts span with parent from W3C traceparent header value
+// ("00-<trace id>-<span id>-<flags>"), new trace is started if it is invalid
+func StartRemote(name string, traceparent string) *Span {
+	s := Start(name)
+	if s == nil {
+		return nil
+	}
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return s
+	}
+	var traceID [16]byte
+	var parentID [8]byte
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return s
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil {
+		return s
+	}
+	s.traceID, s.parentID = traceID, parentID
+	return s
+}
+
+// Child starts child span of s
+func (s *Span) Child(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	c := &Span{traceID: s.traceID, parentID: s.spanID, name: name, kind: KIND_INTERNAL, start: time.Now(), tracer: s.tracer}
+	rand.Read(c.spanID[:])
+	return c
+}
+
+// Client starts child span of outgoing request
+func (s *Span) Client(name string) *Span {
+	c := s.Child(name)
+	if c != nil {
+		c.kind = KIND_CLIENT
+	}
+	return c
+}
+
+// SetRequest sets attributes of outgoing HTTP request: method, server address
+// and route (url.template). Full URL is not recorded: paths and queries of
+// requests carry card UIDs, person IDs and PINs (identifiers are recorded only
+// by SetID).
+func (s *Span) SetRequest(method string, host string, route string) {
+	s.SetAttr("http.request.method", method)
+	s.SetAttr("server.address", host)
+	s.SetAttr("url.template", route)
+}
+
+// SetID sets personal identifier attribute: "<key>_hash" with truncated
+// HMAC-SHA256 of id, or "<key>" with id itself when raw identifiers are enabled
+func (s *Span) SetID(key string, id string) {
+	if s == nil || id == "" {
+		return
+	}
+	cfg := ids.Load()
+	if cfg == nil {
+		ids.CompareAndSwap(nil, newIDConfig(false, ""))
+		cfg = ids.Load()
+	}
+	if cfg.raw {
+		s.SetAttr(key, id)
+		return
+	}
+	mac := hmac.New(sha256.New, cfg.key)
+	mac.Write([]byte(id))
+	s.SetAttr(key+"_hash", hex.EncodeToString(mac.Sum(nil)[:8]))
+}
+
+// SetAttr sets span attribute: string, bool, integer or float value
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.attrs {
+		if s.attrs[i].key == key {
+			s.attrs[i].value = value
+			return
+		}
+	}
+	s.attrs = append(s.attrs, attribute{key, value})
+}
+
+// SetError marks span as failed
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.status, s.message = STATUS_ERROR, message
+	s.mutex.Unlock()
+}
+
+// End finishes span and queues it for export, next calls are ignored
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mutex.Unlock()
+
+	select {
+	case s.tracer.queue <- s:
+	default:
+		spansDropped.Inc()
+	}
+}
+
+// TraceID returns hex trace ID, "" for nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// TraceParent returns W3C traceparent value of span, "" for nil span
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%x-%x-01", s.traceID, s.spanID)
+}
@@ -4,9 +4,13 @@
 package protopack
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
+	"strconv"
 )
 
 // Data type tags
@@ -178,6 +182,116 @@ func decodeHash(data []byte) (map[string]interface{}, error) {
 	return result, nil
 }
 
+// --- Stream framing ---
+
+// MAX_VALUE_LEN is the largest value length of 3-byte TLV header
+const MAX_VALUE_LEN = 0xFFFFFF
+
+// MAX_INT is the largest integer magnitude encodeInt/encodeDouble can hold (31 bits)
+const MAX_INT = 0x7FFFFFFF
+
+// ReadValue reads one TLV value from stream (the header carries value length,
+// so each top-level value is a frame). Frame with value longer than maxLen is
+// rejected before its value is read.
+func ReadValue(r io.Reader, maxLen int) (interface{}, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := (int(header[1]) << 16) | (int(header[2]) << 8) | int(header[3])
+	if length > maxLen {
+		return nil, fmt.Errorf("proto_pack frame too large: %d bytes, max %d", length, maxLen)
+	}
+	frame := make([]byte, 4+length)
+	copy(frame, header)
+	if _, err := io.ReadFull(r, frame[4:]); err != nil {
+		return nil, err
+	}
+	val, _, err := DecodeValue(frame, 0)
+	return val, err
+}
+
+// WriteValue writes one value as TLV frame
+func WriteValue(w io.Writer, value interface{}) error {
+	data := EncodeValue(value)
+	if len(data)-4 > MAX_VALUE_LEN {
+		return fmt.Errorf("proto_pack value too large: %d bytes", len(data)-4)
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// IsTag checks if byte is a known data type tag (first byte of binary frame)
+func IsTag(b byte) bool {
+	return b >= DT_BOOL && b <= DT_BIN
+}
+
+// Normalize converts Go value (structs, typed maps and slices, times) to values
+// EncodeValue supports: bool, int64, float64, string, nil, []interface{} and
+// map[string]interface{}. Numbers beyond 31 bits are sent as strings.
+func Normalize(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, bool, string:
+		return value
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return string(data)
+	}
+	return normalizeJSON(generic)
+}
+
+// normalizeJSON converts decoded JSON numbers to int64/float64
+func normalizeJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			if i >= -MAX_INT && i <= MAX_INT {
+				return i
+			}
+			return v.String()
+		}
+		if f, err := v.Float64(); err == nil && math.Abs(f) < MAX_INT {
+			return f
+		}
+		return v.String()
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeJSON(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = normalizeJSON(v[k])
+		}
+		return v
+	}
+	return value
+}
+
+// ToString returns decoded value as string (numbers formatted, nil empty)
+func ToString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
 // --- TLV helpers ---
 
 func encodeTLV(tag byte, value []byte) []byte {
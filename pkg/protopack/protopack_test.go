@@ -0,0 +1,133 @@
+package protopack
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadWriteValueRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"bool", true},
+		{"nil", nil},
+		{"string", "termlist add 10.0.0.5:9000"},
+		{"binary", "\x01\x02\x00"},
+		{"int", int64(-12345)},
+		{"double", 2.25},
+		{"array", []interface{}{int64(1), "two", false, nil}},
+		{"hash", map[string]interface{}{
+			"id":     int64(7),
+			"cmd":    "settings",
+			"params": map[string]interface{}{"key": "log_event_count", "value": int64(500)},
+			"list":   []interface{}{map[string]interface{}{"ip": "10.0.0.5", "port": int64(9000)}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteValue(&buf, tt.value); err != nil {
+				t.Fatalf("WriteValue: %v", err)
+			}
+			got, err := ReadValue(&buf, MAX_VALUE_LEN)
+			if err != nil {
+				t.Fatalf("ReadValue: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.value) {
+				t.Errorf("got %#v, want %#v", got, tt.value)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("%d bytes left unread", buf.Len())
+			}
+		})
+	}
+}
+
+func TestReadValueFrameLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		maxLen  int
+		wantErr string
+	}{
+		{"header claims 16 MiB", []byte{DT_STR, 0xFF, 0xFF, 0xFF, 'a'}, 1024, "too large"},
+		{"value over limit", EncodeValue(strings.Repeat("a", 1025)), 1024, "too large"},
+		{"value at limit", EncodeValue(strings.Repeat("a", 1024)), 1024, ""},
+		{"truncated value", []byte{DT_STR, 0x00, 0x00, 0x05, 'a'}, 1024, "EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadValue(bytes.NewReader(tt.data), tt.maxLen)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIntEncoding(t *testing.T) {
+	tests := []struct {
+		value int64
+		size  int
+	}{
+		{0, 1},
+		{0x7F, 1},
+		{-0x7F, 1},
+		{0x80, 2},
+		{-0x80, 2},
+		{0x7FFF, 2},
+		{0x8000, 3},
+		{0x7FFFFF, 3},
+		{0x800000, 4},
+		{MAX_INT, 4},
+		{-MAX_INT, 4},
+	}
+	for _, tt := range tests {
+		data := encodeInt(tt.value)
+		if len(data) != tt.size {
+			t.Errorf("encodeInt(%d): %d bytes, want %d", tt.value, len(data), tt.size)
+		}
+		if got := decodeInt(data, len(data)); got != tt.value {
+			t.Errorf("decodeInt(encodeInt(%d)) = %d", tt.value, got)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	type terminal struct {
+		ID   string `json:"id"`
+		Port int    `json:"port"`
+	}
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{"nil", nil, nil},
+		{"string", "x", "x"},
+		{"int", 42, int64(42)},
+		{"max 31-bit", MAX_INT, int64(MAX_INT)},
+		{"min 31-bit", -MAX_INT, int64(-MAX_INT)},
+		{"over 31 bits", MAX_INT + 1, "2147483648"},
+		{"under 31 bits", -MAX_INT - 1, "-2147483648"},
+		{"uint64", uint64(1) << 40, "1099511627776"},
+		{"float", 1.5, 1.5},
+		{"large float", 3e9 + 0.5, "3000000000.5"},
+		{"struct", terminal{ID: "T1", Port: 9000}, map[string]interface{}{"id": "T1", "port": int64(9000)}},
+		{"typed map", map[string]int{"a": 1}, map[string]interface{}{"a": int64(1)}},
+		{"typed slice", []int64{1, 1 << 32}, []interface{}{int64(1), "4294967296"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Normalize(%#v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
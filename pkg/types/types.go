@@ -1,6 +1,8 @@
 package types
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -8,10 +10,21 @@ import (
 type TerminalType string
 
 const (
-	TTYPE_GAT    TerminalType = "gat"
-	TTYPE_POCKET TerminalType = "pocket"
-	TTYPE_SPHINX TerminalType = "sphinx"
-	TTYPE_JSP    TerminalType = "jsp"
+	TTYPE_GAT     TerminalType = "gat"
+	TTYPE_POCKET  TerminalType = "pocket"
+	TTYPE_SPHINX  TerminalType = "sphinx"
+	TTYPE_JSP     TerminalType = "jsp"
+	TTYPE_WIEGAND TerminalType = "wiegand"
+	TTYPE_OSDP    TerminalType = "osdp"
+	TTYPE_MODBUS  TerminalType = "modbus"
+)
+
+// Reader types reported with tag reads (GAT, POCKET, JSP)
+const (
+	READER_TYPE_UNKNOWN   uint8 = 0x00
+	READER_TYPE_CARD      uint8 = 0x01
+	READER_TYPE_BIOMETRIC uint8 = 0x02
+	READER_TYPE_PIN       uint8 = 0x03 // uid is PIN entered on keypad
 )
 
 // Session Processing Stages
@@ -29,6 +42,8 @@ const (
 	SESSION_STAGE_PASSED
 	SESSION_STAGE_LAST_ANSWER
 	SESSION_STAGE_DONE
+	SESSION_STAGE_ESCORT_WAIT // Visitor waits for escort card (SPHINX escort access)
+	SESSION_STAGE_MFA         // Additional factors (PIN, face) after card
 )
 
 // String returns string representation of SessionStage
@@ -56,6 +71,10 @@ func (s SessionStage) String() string {
 		return "LAST_ANSWER"
 	case SESSION_STAGE_DONE:
 		return "DONE"
+	case SESSION_STAGE_ESCORT_WAIT:
+		return "ESCORT_WAIT"
+	case SESSION_STAGE_MFA:
+		return "MFA"
 	default:
 		return "UNKNOWN"
 	}
@@ -102,6 +121,26 @@ const (
 	CAM_RES_NF    CamResult = 0x04
 )
 
+// Access deny reasons (session.Data["deny_reason"], metrics label)
+const (
+	DENY_REASON_1C         = "1c"         // denied by 1C
+	DENY_REASON_1C_ERROR   = "1c_error"   // 1C unavailable or request timed out
+	DENY_REASON_CAM_NO     = "cam_no"     // face does not match card holder
+	DENY_REASON_CAM_NF     = "cam_nf"     // face not found
+	DENY_REASON_CAM_FAIL   = "cam_fail"   // face recognition error
+	DENY_REASON_NOT_PASSED = "not_passed" // pass through first gate not registered
+	DENY_REASON_TAG_AUTH   = "tag_auth"   // tag not authenticated
+	DENY_REASON_LOCKERS    = "lockers"    // lockers must be returned
+	DENY_REASON_TEMP_CARD  = "temp_card"  // temporary card on terminal with deny_ct
+	DENY_REASON_MFA        = "mfa"        // PIN or face factor failed
+	DENY_REASON_ESCORT     = "escort"     // visitor escort card not presented
+	DENY_REASON_GMCLIST    = "gmclist"    // card in global card list
+	DENY_REASON_MCLIST     = "mclist"     // card in terminal card list
+	DENY_REASON_MEMREG     = "memreg"     // repeated pass blocked by MEMREG
+	DENY_REASON_NO_CARD    = "no_card"    // recognized person has no card
+	DENY_REASON_OTHER      = "other"
+)
+
 // Terminal Settings
 type TerminalSettings struct {
 	ID           string                       `json:"id"`
@@ -170,6 +209,9 @@ type Session struct {
 	Data       map[string]interface{} `json:"data"`
 	Stage      SessionStage           `json:"stage"`
 	ReqTime    time.Time              `json:"req_time"`
+	StageTime  time.Time              `json:"stage_time"`       // Current stage start
+	Decision   time.Time              `json:"decision_time"`    // Allow/deny sent to terminal, zero before
+	Stages     []SessionStageTime     `json:"stages,omitempty"` // Finished stages
 	Processed  bool                   `json:"processed"`
 	Completed  bool                   `json:"completed"`
 	ReportSent bool                   `json:"report_sent"`
@@ -177,6 +219,44 @@ type Session struct {
 	Wait       *SessionWait           `json:"wait,omitempty"` // Waiting state
 }
 
+// SessionStageTime is time spent by session in stage
+type SessionStageTime struct {
+	Stage    string    `json:"stage"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration_ms"`
+}
+
+// DurationMs returns time from session start to current stage start (to DONE
+// for completed session), ms
+func (s *Session) DurationMs() int64 {
+	return s.StageTime.Sub(s.ReqTime).Milliseconds()
+}
+
+// StagesSummary formats finished stages as "KPO_RESULT:312,LAST_ANSWER:4" (ms)
+func (s *Session) StagesSummary() string {
+	parts := make([]string, 0, len(s.Stages))
+	for _, st := range s.Stages {
+		parts = append(parts, fmt.Sprintf("%s:%.0f", st.Stage, st.Duration))
+	}
+	return strings.Join(parts, ",")
+}
+
+// MFASummary formats additional factor results as "pin:OK,face:TIMEOUT",
+// factors not checked to the end are UNDEF
+func (s *Session) MFASummary() string {
+	factors, _ := s.Data["mfa_factors"].([]string)
+	results, _ := s.Data["mfa"].(map[string]string)
+	parts := make([]string, 0, len(factors))
+	for _, factor := range factors {
+		result := results[factor]
+		if result == "" || result == "WAIT" {
+			result = "UNDEF"
+		}
+		parts = append(parts, factor+":"+result)
+	}
+	return strings.Join(parts, ",")
+}
+
 // HTTP Request
 type HTTPRequest struct {
 	Key       string                 `json:"key"`
@@ -196,12 +276,27 @@ type Config struct {
 	// Server settings
 	ServerAddr string `json:"server_addr"`
 	ServerPort int    `json:"server_port"`
+	ServerMode string `json:"server_mode"` // admin port protocol: auto, text, protopack
 
 	// Web interface settings
 	WebAddr    string `json:"web_addr"`
 	WebPort    int    `json:"web_port"`
 	WebEnabled bool   `json:"web_enabled"`
 
+	// Web interface authentication
+	WebAuthEnabled   bool   `json:"web_auth_enabled"`
+	WebAuthDBPath    string `json:"web_auth_db_path"`
+	WebSessionTTL    int    `json:"web_session_ttl"` // seconds
+	WebAdminUser     string `json:"web_admin_user"`
+	WebAdminPassword string `json:"web_admin_password"`
+
+	// Web interface TLS (HTTPS)
+	WebTLSEnabled      bool   `json:"web_tls_enabled"`
+	WebTLSCertFile     string `json:"web_tls_cert_file"`
+	WebTLSKeyFile      string `json:"web_tls_key_file"`
+	WebTLSSelfSigned   bool   `json:"web_tls_self_signed"`   // generate self-signed certificate if files are missing
+	WebTLSRedirectPort int    `json:"web_tls_redirect_port"` // HTTP -> HTTPS redirect port, 0 - disabled
+
 	// Service connections
 	HTTPServiceActive              bool     `json:"http_service_active"`
 	HTTPServiceIP                  string   `json:"http_service_ip"`
@@ -210,7 +305,9 @@ type Config struct {
 	HTTPServiceTermlistPath        string   `json:"http_service_termlist_path"`
 	HTTPServiceIdentPath           string   `json:"http_service_ident_path"`
 	HTTPServiceSolarPath           string   `json:"http_service_solar_path"`
+	HTTPServicePINPath             string   `json:"http_service_pin_path"`
 	HTTPServiceUIDPath             string   `json:"http_service_uid_path"`
+	HTTPServiceCIDPath             string   `json:"http_service_cid_path"`
 	HTTPServiceUrlFmtSuff          string   `json:"http_service_url_fmt_suff"`
 	HTTPServiceRequestExtraHeaders []string `json:"http_service_request_extra_headers"`
 
@@ -222,6 +319,7 @@ type Config struct {
 	CamServiceResultMsgNo         string            `json:"cam_service_result_msg_no"`
 	CamServiceResultMsgNf         string            `json:"cam_service_result_msg_nf"`
 	CamServiceResultMsgFail       string            `json:"cam_service_result_msg_fail"`
+	CamServiceProvider            string            `json:"cam_service_provider"`
 
 	// Database
 	DBServiceIP     string            `json:"db_service_ip"`
@@ -232,6 +330,9 @@ type Config struct {
 
 	// Timeouts
 	TerminalConnectTimeout   float64 `json:"terminal_connect_timeout"`
+	TerminalLockTimeout      float64 `json:"terminal_lock_timeout"` // reader lock auto-release (s)
+	MFAPINTimeout            float64 `json:"mfa_pin_timeout"`       // PIN entry after card (s)
+	MFAFaceTimeout           float64 `json:"mfa_face_timeout"`      // face check after card (s)
 	ServiceRequestExpireTime float64 `json:"service_request_expire_time"`
 	SessionExpireTime        float64 `json:"session_expire_time"`
 	ReconnectionWaitTimeStep float64 `json:"reconnection_wait_time_step"`
@@ -279,6 +380,21 @@ type Config struct {
 	// JSP settings
 	JSPListenerPort       interface{} `json:"jsp_listener_port"` // false or int
 	JSPDevAutoPingEnabled bool        `json:"jsp_dev_autoping_enabled"`
+	JSPListenerSecret     string      `json:"-"` // Shared secret of terminals identified by ID
+
+	// POCKET terminal configuration profiles (profile name, terminal ID or IP, or "default")
+	PocketConfigOnConnect bool                              `json:"pocket_config_on_connect"`
+	PocketProfiles        map[string]map[string]interface{} `json:"pocket_profiles"`
+
+	// POCKET MIFARE sector key rotation (keys file is encrypted with master key)
+	PocketKeysFile          string  `json:"pocket_keys_file"`
+	PocketKeysMasterKey     string  `json:"-"`
+	PocketKeySectors        []int   `json:"pocket_key_sectors"`
+	PocketKeyConfirmTimeout float64 `json:"pocket_key_confirm_timeout"` // seconds per terminal
+
+	// SPHINX escort access: visitor card needs escort card at the same terminal
+	SphinxEscortCards   []string `json:"sphinx_escort_cards"`
+	SphinxEscortTimeout float64  `json:"sphinx_escort_timeout"` // seconds
 
 	// CRT (Vizir) settings
 	CRTServiceActive             bool              `json:"crt_service_active"`
@@ -301,12 +417,15 @@ type Config struct {
 	CRTNoKpoPass                 bool              `json:"crt_no_kpo_pass"`
 	CRTSeenTimeout               float64           `json:"crt_seen_timeout"`
 	CRTCamLinks                  map[string]string `json:"crt_cam_links"` // camera_id -> terminal_id
+	CRTFaceMinScore              float64           `json:"crt_face_min_score"` // cardless entry match score (0..1)
 
 	// Phrase fixes: message correction before sending to terminal display
 	PhrasesFixes map[string]string `json:"phrases_fixes"` // original -> corrected
 
 	// Storage: SQLite path (if set, replaces CSV for sessions and gtime)
 	StorageSqlitePath string `json:"storage_sqlite_path"`
+	// Audit trail of administrative changes (SQLite path)
+	StorageAuditPath string `json:"storage_audit_path"`
 
 	// Email: digest sending
 	EmailEnabled    bool     `json:"email_enabled"`
@@ -319,6 +438,15 @@ type Config struct {
 	EmailSendTimes  []string `json:"email_send_times"` // "HH:MM"
 	EmailSubject    string   `json:"email_subject"`
 	EmailLastSent   time.Time `json:"-"` // last time we sent (to avoid duplicate in same slot)
+
+	// Tracing: OpenTelemetry export of access session spans
+	TracingEnabled     bool     `json:"tracing_enabled"`
+	TracingOTLPURL     string   `json:"tracing_otlp_url"`     // OTLP/HTTP collector, e.g. "http://otel:4318"
+	TracingOTLPHeaders []string `json:"tracing_otlp_headers"` // "Key: Value"
+	TracingFile        string   `json:"tracing_file"`         // OTLP/JSON lines file
+	TracingServiceName string   `json:"tracing_service_name"`
+	TracingIncludeIDs  bool     `json:"tracing_include_ids"` // raw card UIDs and person IDs instead of hashes
+	TracingIDKey       string   `json:"-"`                   // HMAC key of identifier hashes
 }
 
 // Protocol Packet
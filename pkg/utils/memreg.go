@@ -164,3 +164,19 @@ func (mrs *MemRegStorage) Has(storageKey, uidKey string) (bool, error) {
 	return val != nil, nil
 }
 
+
+// Snapshot returns copy of all storages: storage_key -> uid_key -> value
+func (mrs *MemRegStorage) Snapshot() map[string]map[string]interface{} {
+	mrs.mutex.RLock()
+	defer mrs.mutex.RUnlock()
+
+	result := make(map[string]map[string]interface{}, len(mrs.storage))
+	for storage, uids := range mrs.storage {
+		items := make(map[string]interface{}, len(uids))
+		for uid, value := range uids {
+			items[uid] = value
+		}
+		result[storage] = items
+	}
+	return result
+}
@@ -1,18 +1,41 @@
 package utils
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/md5"
+	"crypto/rand"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"nd-go/pkg/types"
 	"net"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// StripQuery returns URL path without query
+func StripQuery(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// URLErrorCause returns cause of net/url error without the URL (it may carry
+// card UIDs and PINs), other errors are returned as is
+func URLErrorCause(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
 // GetMtf returns microtime as float64
 func GetMtf() float64 {
 	now := time.Now()
@@ -132,6 +155,12 @@ func ParseTType(ttype string) types.TerminalType {
 		return types.TTYPE_SPHINX
 	case "jsp":
 		return types.TTYPE_JSP
+	case "wiegand":
+		return types.TTYPE_WIEGAND
+	case "osdp":
+		return types.TTYPE_OSDP
+	case "modbus":
+		return types.TTYPE_MODBUS
 	default:
 		return types.TTYPE_GAT
 	}
@@ -256,6 +285,12 @@ func ParseTerm(termStr string) (*types.TerminalSettings, error) {
 			port = 8902 // JSP default port
 		case types.TTYPE_POCKET:
 			port = 8080 // POCKET default port
+		case types.TTYPE_WIEGAND:
+			port = 4001 // Serial-to-Ethernet converter default port
+		case types.TTYPE_OSDP:
+			port = 4001 // RS-485-to-TCP bridge default port
+		case types.TTYPE_MODBUS:
+			port = 502 // Modbus TCP default port
 		default:
 			port = 8080
 		}
@@ -354,6 +389,54 @@ func CryptHexToStr(h string) ([]byte, error) {
 	return hex.DecodeString(h)
 }
 
+// CryptKey decodes AES-256 key from secret of 64 hex chars. Passphrases are
+// not accepted: a single hash of a passphrase is open to offline guessing
+func CryptKey(secret string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(secret))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("key must be 64 hex characters (32 bytes)")
+	}
+	return key, nil
+}
+
+// CryptEncrypt encrypts data with AES-GCM, returns hex of nonce + ciphertext
+func CryptEncrypt(key []byte, data []byte) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return CryptStrToHex(string(gcm.Seal(nonce, nonce, data, nil))), nil
+}
+
+// CryptDecrypt decrypts hex string produced by CryptEncrypt
+func CryptDecrypt(key []byte, h string) ([]byte, error) {
+	data, err := CryptHexToStr(h)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted data too short")
+	}
+	nonce := data[:gcm.NonceSize()]
+	return gcm.Open(nil, nonce, data[gcm.NonceSize():], nil)
+}
+
 // GetNL returns newline character
 func GetNL() string {
 	return "\n"
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+const testCryptKey = "000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F"
+
+func TestCryptKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		ok     bool
+	}{
+		{"hex key", testCryptKey, true},
+		{"lower case", strings.ToLower(testCryptKey), true},
+		{"passphrase", "correct horse battery staple", false},
+		{"64 chars not hex", strings.Repeat("x", 64), false},
+		{"short hex", testCryptKey[:62], false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		key, err := CryptKey(tt.secret)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: CryptKey error = %v, want ok=%v", tt.name, err, tt.ok)
+			continue
+		}
+		if tt.ok && !strings.EqualFold(hex.EncodeToString(key), testCryptKey) {
+			t.Errorf("%s: CryptKey = %X", tt.name, key)
+		}
+	}
+}
+
+func TestCryptRoundTrip(t *testing.T) {
+	key, err := CryptKey(testCryptKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{[]byte("A0A1A2A3A4A5"), {}, bytes.Repeat([]byte{0xFF}, 100)} {
+		enc, err := CryptEncrypt(key, data)
+		if err != nil {
+			t.Fatalf("CryptEncrypt: %v", err)
+		}
+		dec, err := CryptDecrypt(key, enc)
+		if err != nil {
+			t.Fatalf("CryptDecrypt: %v", err)
+		}
+		if !bytes.Equal(dec, data) {
+			t.Errorf("round trip = %X, want %X", dec, data)
+		}
+	}
+
+	// Random nonce: same data encrypts differently
+	a, _ := CryptEncrypt(key, []byte("A0A1A2A3A4A5"))
+	b, _ := CryptEncrypt(key, []byte("A0A1A2A3A4A5"))
+	if a == b {
+		t.Error("CryptEncrypt reused nonce")
+	}
+}
+
+func TestCryptDecryptTamper(t *testing.T) {
+	key, _ := CryptKey(testCryptKey)
+	enc, err := CryptEncrypt(key, []byte("A0A1A2A3A4A5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := hex.DecodeString(enc)
+
+	otherKey := bytes.Repeat([]byte{0x55}, 32)
+	if _, err := CryptDecrypt(otherKey, enc); err == nil {
+		t.Error("CryptDecrypt succeeded with wrong key")
+	}
+
+	for _, pos := range []int{0, 12, len(raw) - 1} {
+		tampered := append([]byte{}, raw...)
+		tampered[pos] ^= 0x01
+		if _, err := CryptDecrypt(key, hex.EncodeToString(tampered)); err == nil {
+			t.Errorf("CryptDecrypt accepted data modified at byte %d", pos)
+		}
+	}
+
+	if _, err := CryptDecrypt(key, hex.EncodeToString(raw[:len(raw)-1])); err == nil {
+		t.Error("CryptDecrypt accepted truncated data")
+	}
+	if _, err := CryptDecrypt(key, enc[:10]); err == nil {
+		t.Error("CryptDecrypt accepted data shorter than nonce")
+	}
+	if _, err := CryptDecrypt(key, "zz"+enc[2:]); err == nil {
+		t.Error("CryptDecrypt accepted invalid hex")
+	}
+}